@@ -0,0 +1,131 @@
+// configcrypt: 加密、解密配置值，以及轮换配置文件中加密值的密钥
+// 密钥: 通过 -key_file 指定密钥文件，没有指定时读取环境变量 CONFIG_SECRET_KEY
+// 加密、解密的值: 没有指定 -value 时从标准输入读取（去掉末尾的换行），避免明文出现在 shell 历史和 ps 中
+// 示例:
+// go run ./cmd/configcrypt -action genkey > secret.key
+// read -s password && printf '%s' "$password" | go run ./cmd/configcrypt -action encrypt -key_file secret.key
+// go run ./cmd/configcrypt -action decrypt -key_file secret.key -value "ENC(...)"
+// go run ./cmd/configcrypt -action rotate -key_file secret.key -new_key_file new_secret.key -config conf_local.yaml
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/smiecj/go_common/config/secret"
+	"github.com/smiecj/go_common/util/file"
+)
+
+const (
+	actionGenKey  = "genkey"
+	actionEncrypt = "encrypt"
+	actionDecrypt = "decrypt"
+	actionRotate  = "rotate"
+)
+
+var (
+	action     = flag.String("action", "", "genkey / encrypt / decrypt / rotate")
+	keyFile    = flag.String("key_file", "", "secret key file, read env "+secret.DefaultKeyEnv+" if empty")
+	value      = flag.String("value", "", "value to encrypt or decrypt, read from stdin if empty (keeps secret out of shell history and ps)")
+	configPath = flag.String("config", "", "config file to rotate key, all ENC(...) values will be encrypted by new key")
+	newKeyFile = flag.String("new_key_file", "", "new secret key file for rotate")
+)
+
+func main() {
+	flag.Parse()
+	if err := run(); nil != err {
+		fmt.Fprintf(os.Stderr, "[configcrypt] %s failed: %s\n", *action, err.Error())
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	switch *action {
+	case actionGenKey:
+		key, err := secret.GenerateKey()
+		if nil != err {
+			return err
+		}
+		fmt.Println(key)
+		return nil
+	case actionEncrypt, actionDecrypt:
+		key, err := loadKey()
+		if nil != err {
+			return err
+		}
+		input, err := loadValue()
+		if nil != err {
+			return err
+		}
+		ret := ""
+		if *action == actionEncrypt {
+			ret, err = secret.Encrypt(key, input)
+		} else {
+			ret, err = secret.Decrypt(input, key)
+		}
+		if nil != err {
+			return err
+		}
+		fmt.Println(ret)
+		return nil
+	case actionRotate:
+		return rotate()
+	}
+	flag.Usage()
+	return fmt.Errorf("unknown action: %s", *action)
+}
+
+// 读取当前密钥
+func loadKey() ([]byte, error) {
+	if *keyFile != "" {
+		return secret.ReadKeyFile(*keyFile)
+	}
+	return secret.ParseKey(os.Getenv(secret.DefaultKeyEnv))
+}
+
+// 读取需要加密、解密的值: 没有指定 -value 时从标准输入读取
+func loadValue() (string, error) {
+	if *value != "" {
+		return *value, nil
+	}
+	content, err := ioutil.ReadAll(os.Stdin)
+	if nil != err {
+		return "", err
+	}
+	input := strings.TrimRight(string(content), "\r\n")
+	if input == "" {
+		return "", fmt.Errorf("value is empty, set -value or write it to stdin")
+	}
+	return input, nil
+}
+
+// 使用新密钥重新加密配置文件中的加密值，其他内容（包括注释）保持不变
+func rotate() error {
+	if *configPath == "" || *newKeyFile == "" {
+		return fmt.Errorf("config and new_key_file must be set")
+	}
+	oldKey, err := loadKey()
+	if nil != err {
+		return err
+	}
+	newKey, err := secret.ReadKeyFile(*newKeyFile)
+	if nil != err {
+		return err
+	}
+	content, err := ioutil.ReadFile(*configPath)
+	if nil != err {
+		return err
+	}
+	rotated, count, err := secret.RotateContent(content, oldKey, newKey)
+	if nil != err {
+		return err
+	}
+	if err = file.WriteAtomic(*configPath, rotated); nil != err {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "[configcrypt] rotate %d encrypted values in %s\n", count, *configPath)
+	return nil
+}
@@ -0,0 +1,62 @@
+// dbgen: 根据 mysql 表结构生成 go 结构体和字段名常量
+// 示例: go run ./cmd/dbgen -config conf_local.yaml -db d_meta -table "t_%" -package meta -trim_prefix t_ -output meta/table.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	yamlconfig "github.com/smiecj/go_common/config/yaml"
+	"github.com/smiecj/go_common/db/codegen"
+	"github.com/smiecj/go_common/db/mysql"
+)
+
+var (
+	configPath  = flag.String("config", "conf_local.yaml", "config path, read mysql connection from space mysql")
+	dbName      = flag.String("db", "", "database name")
+	table       = flag.String("table", "%", "table name pattern, same as sql like")
+	packageName = flag.String("package", "model", "package name of generated code")
+	trimPrefix  = flag.String("trim_prefix", "", "table name prefix to trim when generate struct name")
+	output      = flag.String("output", "", "output file path, print to stdout if empty")
+)
+
+func main() {
+	flag.Parse()
+	if err := generate(); nil != err {
+		fmt.Fprintf(os.Stderr, "[dbgen] generate failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func generate() error {
+	configManager, err := yamlconfig.GetYamlConfigManager(*configPath)
+	if nil != err {
+		return err
+	}
+	connector, err := mysql.GetMySQLConnector(configManager)
+	if nil != err {
+		return err
+	}
+	defer connector.Close()
+
+	tableArr, err := codegen.LoadTableArr(connector, *dbName, *table)
+	if nil != err {
+		return err
+	}
+	code, err := codegen.Generate(tableArr, codegen.GenerateSetPackage(*packageName), codegen.GenerateSetTrimPrefix(*trimPrefix))
+	if nil != err {
+		return err
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(code)
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(*output), 0755); nil != err {
+		return err
+	}
+	return ioutil.WriteFile(*output, code, 0644)
+}
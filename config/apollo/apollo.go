@@ -2,9 +2,12 @@ package config
 
 import (
 	"fmt"
+	"sort"
+	"sync"
 
 	"github.com/smiecj/agollo/v4"
 	apolloconfig "github.com/smiecj/agollo/v4/env/config"
+	"github.com/smiecj/agollo/v4/storage"
 	"github.com/smiecj/go_common/config"
 	"github.com/smiecj/go_common/errorcode"
 	"github.com/smiecj/go_common/util/log"
@@ -27,6 +30,42 @@ type apolloConfig struct {
 type apolloConfigManager struct {
 	apolloConfig apolloConfig
 	client       agollo.Client
+
+	// 配置监听: 第一次监听时向 apollo 客户端注册变更回调
+	notifier     *config.Notifier
+	listenerOnce sync.Once
+}
+
+// apollo 配置变更回调，转换成 config.ChangeEvent
+type apolloChangeListener struct {
+	notifier *config.Notifier
+}
+
+func (listener *apolloChangeListener) OnChange(event *storage.ChangeEvent) {
+	keyArr := make([]string, 0, len(event.Changes))
+	for key := range event.Changes {
+		keyArr = append(keyArr, key)
+	}
+	sort.Strings(keyArr)
+
+	eventArr := make([]config.ChangeEvent, 0, len(keyArr))
+	for _, key := range keyArr {
+		change := event.Changes[key]
+		currentEvent := config.ChangeEvent{Space: event.Namespace, Key: key, OldValue: change.OldValue, NewValue: change.NewValue}
+		switch change.ChangeType {
+		case storage.ADDED:
+			currentEvent.Type = config.ChangeAdd
+		case storage.DELETED:
+			currentEvent.Type = config.ChangeDelete
+		default:
+			currentEvent.Type = config.ChangeModify
+		}
+		eventArr = append(eventArr, currentEvent)
+	}
+	listener.notifier.Notify(eventArr...)
+}
+
+func (listener *apolloChangeListener) OnNewestChange(event *storage.FullChangeEvent) {
 }
 
 // apollo config init
@@ -49,6 +88,7 @@ func (manager *apolloConfigManager) init(conf apolloConfig) (err error) {
 	}
 
 	manager.client = client
+	manager.notifier = config.NewNotifier()
 	return nil
 }
 
@@ -88,6 +128,18 @@ func (manager *apolloConfigManager) Update() error {
 	return nil
 }
 
+// 监听配置变更: space 对应 namespace
+func (manager *apolloConfigManager) Watch(spaceName, key string, listener config.ChangeListener) (func(), error) {
+	manager.listenerOnce.Do(func() {
+		manager.client.AddChangeListener(&apolloChangeListener{notifier: manager.notifier})
+	})
+	// namespace 未加载时，需要先获取一次，后续才会通知变更
+	if nil == manager.client.GetConfigAndInit(spaceName) {
+		return nil, errorcode.BuildError(errorcode.SpaceNotExist)
+	}
+	return manager.notifier.Add(spaceName, key, listener), nil
+}
+
 // get apollo config manager
 func GetApolloConfigManager(yamlConfigManager config.Manager) (config.Manager, error) {
 	conf := apolloConfig{}
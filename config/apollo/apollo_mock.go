@@ -31,3 +31,7 @@ func (manager *apolloConfigManagerMock) Unmarshal(spaceName string, obj interfac
 func (manager *apolloConfigManagerMock) Update() error {
 	return nil
 }
+
+func (manager *apolloConfigManagerMock) Watch(spaceName, key string, listener config.ChangeListener) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/smiecj/go_common/errorcode"
+	"github.com/smiecj/go_common/util/log"
+)
+
+// 需要校验的配置对象可以实现该接口，绑定时会在替换配置之前校验
+type Validator interface {
+	Validate() error
+}
+
+// 配置绑定选项
+type bindConf struct {
+	validateFunc func(interface{}) error
+	onChangeFunc func(oldValue, newValue interface{})
+	onErrorFunc  func(error)
+}
+
+type BindConfigFunc func(*bindConf)
+
+// 设置校验方法，校验失败时保留当前配置
+func BindSetValidator(validateFunc func(interface{}) error) BindConfigFunc {
+	return func(conf *bindConf) {
+		conf.validateFunc = validateFunc
+	}
+}
+
+// 设置配置变更回调，参数为变更前后的配置对象（指针）
+func BindSetOnChange(onChangeFunc func(oldValue, newValue interface{})) BindConfigFunc {
+	return func(conf *bindConf) {
+		conf.onChangeFunc = onChangeFunc
+	}
+}
+
+// 设置重新加载配置失败的回调，默认只打印日志
+func BindSetOnError(onErrorFunc func(error)) BindConfigFunc {
+	return func(conf *bindConf) {
+		conf.onErrorFunc = onErrorFunc
+	}
+}
+
+// 配置绑定: 保存配置空间解析得到的对象快照，配置变更时重新解析、校验，然后原子替换
+// 读取到的对象是只读快照，不要修改
+type Binding struct {
+	conf       bindConf
+	manager    Manager
+	spaceName  string
+	objectType reflect.Type
+	value      atomic.Value
+	reloadLock sync.Mutex
+	cancel     func()
+}
+
+// 绑定配置空间到对象，obj 为结构体指针，只用于确定对象类型
+// 配置中心支持 Watcher 时，配置变更后自动重新加载，否则只能通过 Reload 手动加载
+func Bind(manager Manager, spaceName string, obj interface{}, funcArr ...BindConfigFunc) (*Binding, error) {
+	objectType := reflect.TypeOf(obj)
+	if nil == objectType || objectType.Kind() != reflect.Ptr {
+		return nil, errorcode.BuildErrorWithMsg(errorcode.ConfigParamInvalid, "bind object must be pointer")
+	}
+	binding := &Binding{manager: manager, spaceName: spaceName, objectType: objectType.Elem()}
+	for _, f := range funcArr {
+		f(&binding.conf)
+	}
+
+	newValue, err := binding.load()
+	if nil != err {
+		return nil, err
+	}
+	binding.value.Store(newValue)
+
+	cancel, err := Watch(manager, spaceName, "", func(ChangeEvent) {
+		if err := binding.Reload(); nil != err {
+			log.Warn("[Binding] reload config failed, space: %s, err: %s", spaceName, err.Error())
+			if nil != binding.conf.onErrorFunc {
+				binding.conf.onErrorFunc(err)
+			}
+		}
+	})
+	if nil != err {
+		log.Warn("[Bind] watch config failed, space %s will not reload automatically: %s", spaceName, err.Error())
+	} else {
+		binding.cancel = cancel
+	}
+	return binding, nil
+}
+
+// 获取当前配置对象（指针），并发安全
+func (binding *Binding) Get() interface{} {
+	return binding.value.Load()
+}
+
+// 重新解析配置，校验通过并且有变化时替换当前配置，并回调 on change
+func (binding *Binding) Reload() error {
+	binding.reloadLock.Lock()
+	defer binding.reloadLock.Unlock()
+
+	newValue, err := binding.load()
+	if nil != err {
+		return err
+	}
+	oldValue := binding.value.Load()
+	if reflect.DeepEqual(oldValue, newValue) {
+		return nil
+	}
+	binding.value.Store(newValue)
+	if nil != binding.conf.onChangeFunc {
+		binding.conf.onChangeFunc(oldValue, newValue)
+	}
+	return nil
+}
+
+// 取消监听配置变更
+func (binding *Binding) Close() {
+	if nil != binding.cancel {
+		binding.cancel()
+	}
+}
+
+// 解析配置到新的对象，并校验
+func (binding *Binding) load() (interface{}, error) {
+	newValue := reflect.New(binding.objectType).Interface()
+	err := binding.manager.Unmarshal(binding.spaceName, newValue)
+	if nil != err {
+		return nil, err
+	}
+	if validator, ok := newValue.(Validator); ok {
+		if err = validator.Validate(); nil != err {
+			return nil, err
+		}
+	}
+	if nil != binding.conf.validateFunc {
+		if err = binding.conf.validateFunc(newValue); nil != err {
+			return nil, err
+		}
+	}
+	return newValue, nil
+}
@@ -0,0 +1,309 @@
+package composite
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/smiecj/go_common/config"
+	"github.com/smiecj/go_common/errorcode"
+)
+
+// 组合配置: 多个配置中心按优先级叠加，如 yaml 保存默认配置，nacos / apollo 保存覆盖配置
+// 后添加的配置层优先级更高，获取配置时从优先级最高的配置层开始查找
+
+// 配置层
+type layer struct {
+	name    string
+	manager config.Manager
+}
+
+type compositeConf struct {
+	layerArr      []layer
+	writableLayer string
+}
+
+type CompositeConfigFunc func(*compositeConf)
+
+// 添加配置层，后添加的优先级更高
+func CompositeAddLayer(name string, manager config.Manager) CompositeConfigFunc {
+	return func(conf *compositeConf) {
+		conf.layerArr = append(conf.layerArr, layer{name: name, manager: manager})
+	}
+}
+
+// 设置 Set 写入的配置层，默认为优先级最高的配置层
+func CompositeSetWritableLayer(name string) CompositeConfigFunc {
+	return func(conf *compositeConf) {
+		conf.writableLayer = name
+	}
+}
+
+// 组合配置管理
+type CompositeManager struct {
+	// 按优先级从高到低排列
+	layerArr      []layer
+	writableLayer layer
+}
+
+// 获取组合配置管理
+func NewCompositeConfigManager(funcArr ...CompositeConfigFunc) (*CompositeManager, error) {
+	conf := compositeConf{}
+	for _, f := range funcArr {
+		f(&conf)
+	}
+	if len(conf.layerArr) == 0 {
+		return nil, errorcode.BuildErrorWithMsg(errorcode.ConfigParamInvalid, "composite config must add layer")
+	}
+
+	manager := new(CompositeManager)
+	layerNameMap := make(map[string]bool)
+	for index := len(conf.layerArr) - 1; index >= 0; index-- {
+		currentLayer := conf.layerArr[index]
+		if nil == currentLayer.manager || layerNameMap[currentLayer.name] {
+			return nil, errorcode.BuildErrorWithMsg(errorcode.ConfigParamInvalid,
+				fmt.Sprintf("composite config layer %s is empty or duplicate", currentLayer.name))
+		}
+		layerNameMap[currentLayer.name] = true
+		manager.layerArr = append(manager.layerArr, currentLayer)
+	}
+
+	manager.writableLayer = manager.layerArr[0]
+	if conf.writableLayer != "" {
+		if !layerNameMap[conf.writableLayer] {
+			return nil, errorcode.BuildErrorWithMsg(errorcode.ConfigParamInvalid,
+				fmt.Sprintf("composite config writable layer %s not exists", conf.writableLayer))
+		}
+		for _, currentLayer := range manager.layerArr {
+			if currentLayer.name == conf.writableLayer {
+				manager.writableLayer = currentLayer
+			}
+		}
+	}
+	return manager, nil
+}
+
+// 获取配置
+func (manager *CompositeManager) Get(spaceName, key string) (interface{}, error) {
+	value, _, err := manager.GetWithSource(spaceName, key)
+	return value, err
+}
+
+// 获取配置，并返回提供配置的配置层名称
+func (manager *CompositeManager) GetWithSource(spaceName, key string) (interface{}, string, error) {
+	return manager.getFromLayer(manager.layerArr, spaceName, key)
+}
+
+// 按顺序从配置层中获取配置，返回第一个获取成功的配置
+func (manager *CompositeManager) getFromLayer(layerArr []layer, spaceName, key string) (interface{}, string, error) {
+	err := errorcode.BuildError(errorcode.ConfigNotExist)
+	for _, currentLayer := range layerArr {
+		var value interface{}
+		value, err = currentLayer.manager.Get(spaceName, key)
+		if nil == err {
+			return value, currentLayer.name, nil
+		}
+	}
+	return nil, "", err
+}
+
+// 获取全部配置空间名: 合并所有支持该方法的配置层
+func (manager *CompositeManager) GetAllSpaceName() ([]string, error) {
+	var (
+		retArr       []string
+		lastErr      error
+		isSupported  bool
+		spaceNameMap = make(map[string]bool)
+	)
+	for _, currentLayer := range manager.layerArr {
+		spaceNameArr, err := currentLayer.manager.GetAllSpaceName()
+		if isNotSupport(err) {
+			lastErr = err
+			continue
+		} else if nil != err {
+			return nil, err
+		}
+		isSupported = true
+		for _, spaceName := range spaceNameArr {
+			if !spaceNameMap[spaceName] {
+				spaceNameMap[spaceName] = true
+				retArr = append(retArr, spaceName)
+			}
+		}
+	}
+	if !isSupported {
+		return nil, lastErr
+	}
+	sort.Strings(retArr)
+	return retArr, nil
+}
+
+// 获取合并之后的配置空间
+func (manager *CompositeManager) GetSpace(spaceName string) (config.Space, error) {
+	if _, err := manager.GetSpaceSource(spaceName); nil != err {
+		return nil, err
+	}
+	return &compositeSpace{manager: manager, spaceName: spaceName}, nil
+}
+
+// 获取配置空间中每个配置的来源: key -> 配置层名称，只包含支持 GetSpace 的配置层
+func (manager *CompositeManager) GetSpaceSource(spaceName string) (map[string]string, error) {
+	var (
+		lastErr     error = errorcode.BuildError(errorcode.SpaceNotExist)
+		isSupported bool
+		sourceMap   = make(map[string]string)
+	)
+	for _, currentLayer := range manager.layerArr {
+		space, err := currentLayer.manager.GetSpace(spaceName)
+		if isNotSupport(err) || isSpaceNotExist(err) {
+			lastErr = err
+			continue
+		} else if nil != err {
+			return nil, err
+		}
+		keyArr, err := space.GetAllKey()
+		if nil != err {
+			return nil, err
+		}
+		isSupported = true
+		for _, key := range keyArr {
+			if _, ok := sourceMap[key]; !ok {
+				sourceMap[key] = currentLayer.name
+			}
+		}
+	}
+	if !isSupported {
+		return nil, lastErr
+	}
+	return sourceMap, nil
+}
+
+// 设置配置: 写入指定的配置层
+func (manager *CompositeManager) Set(spaceName, key string, value interface{}) error {
+	return manager.writableLayer.manager.Set(spaceName, key, value)
+}
+
+// 保存写入的配置层
+func (manager *CompositeManager) Save() error {
+	return config.Save(manager.writableLayer.manager)
+}
+
+// 解析配置: 按优先级从低到高依次解析，高优先级的配置覆盖低优先级的配置
+// 不支持解析、没有该配置空间的配置层会跳过
+func (manager *CompositeManager) Unmarshal(spaceName string, obj interface{}) error {
+	var (
+		lastErr     error
+		isSupported bool
+	)
+	for index := len(manager.layerArr) - 1; index >= 0; index-- {
+		err := manager.layerArr[index].manager.Unmarshal(spaceName, obj)
+		if isNotSupport(err) || isSpaceNotExist(err) {
+			lastErr = err
+			continue
+		} else if nil != err {
+			return err
+		}
+		isSupported = true
+	}
+	if !isSupported {
+		return lastErr
+	}
+	return nil
+}
+
+// 更新全部配置层，忽略不支持更新的配置层
+func (manager *CompositeManager) Update() error {
+	for _, currentLayer := range manager.layerArr {
+		err := currentLayer.manager.Update()
+		if nil != err && !isNotSupport(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// 监听全部支持 Watcher 的配置层，被高优先级配置层覆盖的配置变更不会通知
+// 高优先级配置层新增、删除配置时，变更前后的值为实际生效的值
+func (manager *CompositeManager) Watch(spaceName, key string, listener config.ChangeListener) (func(), error) {
+	var (
+		cancelArr []func()
+		lastErr   error
+	)
+	for index, currentLayer := range manager.layerArr {
+		higherLayerArr, lowerLayerArr := manager.layerArr[:index], manager.layerArr[index+1:]
+		cancel, err := config.Watch(currentLayer.manager, spaceName, key, func(event config.ChangeEvent) {
+			if _, _, err := manager.getFromLayer(higherLayerArr, event.Space, event.Key); nil == err {
+				return
+			}
+			lowerValue, _, err := manager.getFromLayer(lowerLayerArr, event.Space, event.Key)
+			if nil == err {
+				switch event.Type {
+				case config.ChangeAdd:
+					event.Type, event.OldValue = config.ChangeModify, lowerValue
+				case config.ChangeDelete:
+					event.Type, event.NewValue = config.ChangeModify, lowerValue
+				}
+				if event.Type == config.ChangeModify && reflect.DeepEqual(event.OldValue, event.NewValue) {
+					return
+				}
+			}
+			listener(event)
+		})
+		if nil != err {
+			lastErr = err
+			continue
+		}
+		cancelArr = append(cancelArr, cancel)
+	}
+	if len(cancelArr) == 0 {
+		return nil, lastErr
+	}
+	return func() {
+		for _, cancel := range cancelArr {
+			cancel()
+		}
+	}, nil
+}
+
+// 判断是否是配置层不支持的方法
+func isNotSupport(err error) bool {
+	return nil != err && (errors.Is(err, errorcode.BuildError(errorcode.ConfigMethodNotSupport)) ||
+		errors.Is(err, errorcode.BuildError(errorcode.NotImplement)))
+}
+
+// 判断是否是配置空间不存在
+func isSpaceNotExist(err error) bool {
+	return nil != err && errors.Is(err, errorcode.BuildError(errorcode.SpaceNotExist))
+}
+
+// 组合配置空间: 读取时合并所有配置层，写入时写到指定的配置层
+type compositeSpace struct {
+	manager   *CompositeManager
+	spaceName string
+}
+
+func (space *compositeSpace) Get(key string) (interface{}, error) {
+	return space.manager.Get(space.spaceName, key)
+}
+
+func (space *compositeSpace) GetAllKey() ([]string, error) {
+	sourceMap, err := space.manager.GetSpaceSource(space.spaceName)
+	if nil != err {
+		return nil, err
+	}
+	keyArr := make([]string, 0, len(sourceMap))
+	for key := range sourceMap {
+		keyArr = append(keyArr, key)
+	}
+	sort.Strings(keyArr)
+	return keyArr, nil
+}
+
+func (space *compositeSpace) Set(key string, value interface{}) error {
+	return space.manager.Set(space.spaceName, key, value)
+}
+
+func (space *compositeSpace) Unmarshal(obj interface{}) error {
+	return space.manager.Unmarshal(space.spaceName, obj)
+}
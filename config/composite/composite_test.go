@@ -0,0 +1,112 @@
+package composite
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/smiecj/go_common/config"
+	yamlconfig "github.com/smiecj/go_common/config/yaml"
+	"github.com/stretchr/testify/require"
+)
+
+type mysqlConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+}
+
+// 测试多个配置层叠加
+func TestCompositeConfig(t *testing.T) {
+	defaultFilePath := filepath.Join(t.TempDir(), "conf_default.yaml")
+	overrideFilePath := filepath.Join(t.TempDir(), "conf_override.yaml")
+	require.Empty(t, ioutil.WriteFile(defaultFilePath, []byte("mysql:\n  host: localhost\n  port: 3306\n  user: root\nhttp:\n  max_idle: 1000\n"), 0644))
+	require.Empty(t, ioutil.WriteFile(overrideFilePath, []byte("mysql:\n  host: 10.0.0.1\n  password: secret\nredis:\n  host: redis\n"), 0644))
+	defaultManager, err := yamlconfig.GetYamlConfigManager(defaultFilePath)
+	require.Empty(t, err)
+	overrideManager, err := yamlconfig.GetYamlConfigManager(overrideFilePath)
+	require.Empty(t, err)
+
+	_, err = NewCompositeConfigManager()
+	require.NotEmpty(t, err)
+	_, err = NewCompositeConfigManager(CompositeAddLayer("default", defaultManager), CompositeSetWritableLayer("not_exist"))
+	require.NotEmpty(t, err)
+	manager, err := NewCompositeConfigManager(CompositeAddLayer("default", defaultManager),
+		CompositeAddLayer("override", overrideManager), CompositeSetWritableLayer("default"))
+	require.Empty(t, err)
+
+	// 获取配置和来源
+	host, source, err := manager.GetWithSource("mysql", "host")
+	require.Empty(t, err)
+	require.Equal(t, "10.0.0.1", host)
+	require.Equal(t, "override", source)
+	port, source, err := manager.GetWithSource("mysql", "port")
+	require.Empty(t, err)
+	require.Equal(t, 3306, port)
+	require.Equal(t, "default", source)
+	_, err = manager.Get("mysql", "not_exist")
+	require.NotEmpty(t, err)
+
+	spaceNameArr, err := manager.GetAllSpaceName()
+	require.Empty(t, err)
+	require.Equal(t, []string{"http", "mysql", "redis"}, spaceNameArr)
+	sourceMap, err := manager.GetSpaceSource("mysql")
+	require.Empty(t, err)
+	require.Equal(t, map[string]string{"host": "override", "port": "default", "user": "default", "password": "override"}, sourceMap)
+
+	// 合并解析
+	conf := mysqlConfig{}
+	require.Empty(t, manager.Unmarshal("mysql", &conf))
+	require.Equal(t, mysqlConfig{Host: "10.0.0.1", Port: 3306, User: "root", Password: "secret"}, conf)
+	redisSpace, err := manager.GetSpace("redis")
+	require.Empty(t, err)
+	keyArr, err := redisSpace.GetAllKey()
+	require.Empty(t, err)
+	require.Equal(t, []string{"host"}, keyArr)
+	_, err = manager.GetSpace("not_exist")
+	require.NotEmpty(t, err)
+
+	// 写入指定的配置层
+	mysqlSpace, err := manager.GetSpace("mysql")
+	require.Empty(t, err)
+	require.Empty(t, mysqlSpace.Set("port", 3307))
+	port, err = defaultManager.Get("mysql", "port")
+	require.Empty(t, err)
+	require.Equal(t, 3307, port)
+}
+
+// 测试组合配置监听变更
+func TestCompositeConfigWatch(t *testing.T) {
+	defaultFilePath := filepath.Join(t.TempDir(), "conf_default.yaml")
+	overrideFilePath := filepath.Join(t.TempDir(), "conf_override.yaml")
+	require.Empty(t, ioutil.WriteFile(defaultFilePath, []byte("mysql:\n  host: localhost\n  port: 3306\n"), 0644))
+	require.Empty(t, ioutil.WriteFile(overrideFilePath, []byte("mysql:\n  host: 10.0.0.1\n"), 0644))
+	defaultManager, err := yamlconfig.GetYamlConfigManager(defaultFilePath)
+	require.Empty(t, err)
+	overrideManager, err := yamlconfig.GetYamlConfigManager(overrideFilePath)
+	require.Empty(t, err)
+	manager, err := NewCompositeConfigManager(CompositeAddLayer("default", defaultManager), CompositeAddLayer("override", overrideManager))
+	require.Empty(t, err)
+
+	eventArr := make([]config.ChangeEvent, 0)
+	cancel, err := config.Watch(manager, "mysql", "", func(event config.ChangeEvent) {
+		eventArr = append(eventArr, event)
+	})
+	require.Empty(t, err)
+	defer cancel()
+
+	// 被覆盖的配置变更不通知
+	require.Empty(t, ioutil.WriteFile(defaultFilePath, []byte("mysql:\n  host: 127.0.0.1\n  port: 3307\n"), 0644))
+	require.Empty(t, manager.Update())
+	require.Equal(t, []config.ChangeEvent{{Space: "mysql", Key: "port", Type: config.ChangeModify, OldValue: 3306, NewValue: 3307}}, eventArr)
+
+	// 高优先级配置层删除配置，生效的是低优先级配置层的值
+	eventArr = eventArr[:0]
+	require.Empty(t, ioutil.WriteFile(overrideFilePath, []byte("mysql:\n  port: 3308\n"), 0644))
+	require.Empty(t, overrideManager.Update())
+	require.Equal(t, []config.ChangeEvent{
+		{Space: "mysql", Key: "host", Type: config.ChangeModify, OldValue: "10.0.0.1", NewValue: "127.0.0.1"},
+		{Space: "mysql", Key: "port", Type: config.ChangeModify, OldValue: 3307, NewValue: 3308},
+	}, eventArr)
+}
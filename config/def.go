@@ -1,6 +1,10 @@
 // package config config center
 package config
 
+import (
+	"github.com/smiecj/go_common/errorcode"
+)
+
 // 配置中心定义
 /*
  * 概念解释
@@ -26,3 +30,17 @@ type Space interface {
 	Set(string, interface{}) error
 	Unmarshal(interface{}) error
 }
+
+// 支持将配置保存到存储（如本地文件）的配置中心
+type Persister interface {
+	Save() error
+}
+
+// 保存配置，配置中心未实现 Persister 时返回 ConfigMethodNotSupport
+func Save(manager Manager) error {
+	persister, ok := manager.(Persister)
+	if !ok {
+		return errorcode.BuildError(errorcode.ConfigMethodNotSupport)
+	}
+	return persister.Save()
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/smiecj/go_common/util/log"
+)
+
+// 导出全部配置，用于排查问题时打印
+// 敏感字段（密码、token 等）和邮箱会脱敏，每个配置空间一段，配置缩进两个空格，格式同 DumpSpace
+func Dump(manager Manager) (string, error) {
+	spaceNameArr, err := manager.GetAllSpaceName()
+	if nil != err {
+		return "", err
+	}
+	sort.Strings(spaceNameArr)
+
+	builder := new(strings.Builder)
+	for _, spaceName := range spaceNameArr {
+		space, err := manager.GetSpace(spaceName)
+		if nil != err {
+			return "", err
+		}
+		spaceContent, err := DumpSpace(space)
+		if nil != err {
+			return "", err
+		}
+		builder.WriteString(spaceName + ":\n")
+		for _, line := range strings.Split(strings.TrimSuffix(spaceContent, "\n"), "\n") {
+			if line != "" {
+				builder.WriteString("  " + line + "\n")
+			}
+		}
+	}
+	return builder.String(), nil
+}
+
+// 导出配置空间中的全部配置，敏感信息脱敏，每行格式: key: value
+func DumpSpace(space Space) (string, error) {
+	keyArr, err := space.GetAllKey()
+	if nil != err {
+		return "", err
+	}
+	sort.Strings(keyArr)
+
+	builder := new(strings.Builder)
+	for _, key := range keyArr {
+		value, err := space.Get(key)
+		if nil != err {
+			return "", err
+		}
+		builder.WriteString(fmt.Sprintf("%s: %v\n", key, log.MaskValue(key, value)))
+	}
+	return builder.String(), nil
+}
@@ -0,0 +1,60 @@
+package local
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/smiecj/go_common/errorcode"
+)
+
+// 解析 json 配置: 顶层的每个对象对应一个配置空间，整数解析成 int，同 yaml
+func parseJSON(content []byte) (map[string]map[string]interface{}, error) {
+	fullConfigMap, err := parseJSONMap(content)
+	if nil != err {
+		return nil, err
+	}
+
+	retMap := make(map[string]map[string]interface{}, len(fullConfigMap))
+	for spaceName, spaceValue := range fullConfigMap {
+		configMap, ok := spaceValue.(map[string]interface{})
+		if !ok {
+			return nil, errorcode.BuildErrorWithMsg(errorcode.ConfigParamInvalid, fmt.Sprintf("json config space %s is not object", spaceName))
+		}
+		retMap[spaceName] = configMap
+	}
+	return retMap, nil
+}
+
+// 解析 json 对象
+func parseJSONMap(content []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	configMap := make(map[string]interface{})
+	if err := decoder.Decode(&configMap); nil != err {
+		return nil, err
+	}
+	return convertJSONValue(configMap).(map[string]interface{}), nil
+}
+
+// json 数字转换成 int 或 float64
+func convertJSONValue(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case json.Number:
+		if intValue, err := strconv.Atoi(typedValue.String()); nil == err {
+			return intValue
+		}
+		floatValue, _ := typedValue.Float64()
+		return floatValue
+	case map[string]interface{}:
+		for key, currentValue := range typedValue {
+			typedValue[key] = convertJSONValue(currentValue)
+		}
+	case []interface{}:
+		for index, currentValue := range typedValue {
+			typedValue[index] = convertJSONValue(currentValue)
+		}
+	}
+	return value
+}
@@ -0,0 +1,291 @@
+package local
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smiecj/go_common/config"
+	yamlconfig "github.com/smiecj/go_common/config/yaml"
+	"github.com/smiecj/go_common/errorcode"
+	"github.com/smiecj/go_common/util/log"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 本地文件配置: json、toml、properties、env 格式，语义同 yaml 配置，顶层的 section 对应配置空间
+// properties: mysql.host=localhost -> space: mysql, key: host
+// env: MYSQL_HOST=localhost -> space: mysql, key: host
+
+// 配置文件格式
+type Format string
+
+const (
+	FormatYAML       Format = "yaml"
+	FormatJSON       Format = "json"
+	FormatTOML       Format = "toml"
+	FormatProperties Format = "properties"
+	FormatEnv        Format = "env"
+)
+
+const (
+	defaultWatchInterval = 5 * time.Second
+)
+
+var (
+	localConfigMapLock sync.RWMutex
+	localConfigMap     map[string]config.Manager
+
+	// 配置文件解析方法: 文件内容 -> space -> key -> value
+	parseFuncMap = map[Format]func([]byte) (map[string]map[string]interface{}, error){
+		FormatJSON:       parseJSON,
+		FormatTOML:       parseTOML,
+		FormatProperties: parseProperties,
+		FormatEnv:        parseEnv,
+	}
+
+	// 单个配置空间内容的解析方法: 内容 -> key -> value
+	spaceParseFuncMap = map[Format]func([]byte) (map[string]interface{}, error){
+		FormatYAML:       parseYAMLMap,
+		FormatJSON:       parseJSONMap,
+		FormatTOML:       parseTOMLMap,
+		FormatProperties: parsePropertiesMap,
+		FormatEnv:        parseEnvMap,
+	}
+)
+
+// 根据文件扩展名获取配置文件格式，.env 文件也可以没有文件名
+func GetFormat(filePath string) (Format, error) {
+	fileName := strings.ToLower(filepath.Base(filePath))
+	switch {
+	case strings.HasSuffix(fileName, ".yaml"), strings.HasSuffix(fileName, ".yml"):
+		return FormatYAML, nil
+	case strings.HasSuffix(fileName, ".json"):
+		return FormatJSON, nil
+	case strings.HasSuffix(fileName, ".toml"):
+		return FormatTOML, nil
+	case strings.HasSuffix(fileName, ".properties"):
+		return FormatProperties, nil
+	case strings.HasSuffix(fileName, ".env"):
+		return FormatEnv, nil
+	}
+	return "", errorcode.BuildErrorWithMsg(errorcode.ConfigParamInvalid, fmt.Sprintf("unknown config file format: %s", filePath))
+}
+
+// 解析单个配置空间的内容，顶层的 key 即配置空间中的 key，用于配置中心中一个配置对应一个配置空间的场景
+// properties 的 key 和 env 的变量名（转成小写）不再拆分配置空间，如 host=localhost -> key: host
+func ParseSpace(content []byte, format Format) (map[string]interface{}, error) {
+	parseFunc, ok := spaceParseFuncMap[format]
+	if !ok {
+		return nil, errorcode.BuildErrorWithMsg(errorcode.ConfigParamInvalid, fmt.Sprintf("unknown config format: %s", format))
+	}
+	return parseFunc(content)
+}
+
+// 解析 yaml 对象
+func parseYAMLMap(content []byte) (map[string]interface{}, error) {
+	configMap := make(map[string]interface{})
+	if err := yaml.Unmarshal(content, &configMap); nil != err {
+		return nil, err
+	}
+	return configMap, nil
+}
+
+// 本地配置选项
+type localConf struct {
+	watchInterval time.Duration
+}
+
+type LocalConfigFunc func(*localConf)
+
+// 设置监听配置变更时检查文件的间隔，默认 5s
+func LocalSetWatchInterval(interval time.Duration) LocalConfigFunc {
+	return func(conf *localConf) {
+		if interval > 0 {
+			conf.watchInterval = interval
+		}
+	}
+}
+
+// 本地文件配置管理
+type localManager struct {
+	filePath  string
+	parseFunc func([]byte) (map[string]map[string]interface{}, error)
+	spaceMap  map[string]*config.MapSpace
+	spaceLock sync.RWMutex
+
+	// 配置监听: 定期检查文件修改时间和大小，有变化时重新加载
+	reloader *config.FileReloader
+}
+
+// 初始化: 对每一层配置都初始化一个 space
+func (manager *localManager) init(watchInterval time.Duration) error {
+	manager.spaceMap = make(map[string]*config.MapSpace)
+	manager.reloader = config.NewFileReloader(manager.filePath, watchInterval, manager.reload)
+	_, err := manager.reloader.Load()
+	return err
+}
+
+// 加载配置文件内容，已获取的 space 会同步更新，返回配置变更事件
+func (manager *localManager) reload(content []byte) ([]config.ChangeEvent, error) {
+	fullConfigMap, err := manager.parseFunc(content)
+	if nil != err {
+		return nil, err
+	}
+
+	eventArr := make([]config.ChangeEvent, 0)
+	manager.spaceLock.Lock()
+	defer manager.spaceLock.Unlock()
+	for spaceName, space := range manager.spaceMap {
+		if _, ok := fullConfigMap[spaceName]; !ok {
+			delete(manager.spaceMap, spaceName)
+			eventArr = append(eventArr, config.DiffSpace(spaceName, space.Replace(nil), nil)...)
+		}
+	}
+	for spaceName, configMap := range fullConfigMap {
+		space, ok := manager.spaceMap[spaceName]
+		if !ok {
+			space = config.NewMapSpace(nil)
+			manager.spaceMap[spaceName] = space
+		}
+		eventArr = append(eventArr, config.DiffSpace(spaceName, space.Replace(configMap), configMap)...)
+	}
+	return eventArr, nil
+}
+
+// 获取配置
+func (manager *localManager) Get(spaceName, key string) (interface{}, error) {
+	space, err := manager.getSpace(spaceName)
+	if nil != err {
+		return "", err
+	}
+	return space.Get(key)
+}
+
+// 获取全部配置空间名
+func (manager *localManager) GetAllSpaceName() (retArr []string, err error) {
+	manager.spaceLock.RLock()
+	defer manager.spaceLock.RUnlock()
+	for key := range manager.spaceMap {
+		retArr = append(retArr, key)
+	}
+	return
+}
+
+// 获取配置空间
+func (manager *localManager) GetSpace(spaceName string) (config.Space, error) {
+	return manager.getSpace(spaceName)
+}
+
+// 设置配置，只修改内存中的配置
+func (manager *localManager) Set(spaceName, key string, value interface{}) error {
+	space, err := manager.getSpace(spaceName)
+	if nil != err {
+		return err
+	}
+	return space.Set(key, value)
+}
+
+// 解析配置
+func (manager *localManager) Unmarshal(spaceName string, obj interface{}) error {
+	space, err := manager.getSpace(spaceName)
+	if nil != err {
+		return err
+	}
+	return space.Unmarshal(obj)
+}
+
+func (manager *localManager) getSpace(spaceName string) (*config.MapSpace, error) {
+	manager.spaceLock.RLock()
+	defer manager.spaceLock.RUnlock()
+	space := manager.spaceMap[spaceName]
+	if nil == space {
+		return nil, errorcode.BuildError(errorcode.SpaceNotExist)
+	}
+	return space, nil
+}
+
+// 重新加载配置文件，并通知配置变更
+func (manager *localManager) Update() error {
+	return manager.reloader.Update()
+}
+
+// 监听配置变更: 第一次监听时开始定期检查配置文件，所有监听都取消后停止检查
+func (manager *localManager) Watch(spaceName, key string, listener config.ChangeListener) (func(), error) {
+	return manager.reloader.Watch(spaceName, key, listener)
+}
+
+// 获取本地文件配置，根据文件扩展名判断格式，yaml 格式使用 yaml 配置
+func GetLocalConfigManager(filePath string, funcArr ...LocalConfigFunc) (config.Manager, error) {
+	format, err := GetFormat(filePath)
+	if nil != err {
+		return nil, err
+	}
+	return GetConfigManagerByFormat(filePath, format, funcArr...)
+}
+
+// 获取 json 配置
+func GetJSONConfigManager(filePath string, funcArr ...LocalConfigFunc) (config.Manager, error) {
+	return GetConfigManagerByFormat(filePath, FormatJSON, funcArr...)
+}
+
+// 获取 toml 配置
+func GetTOMLConfigManager(filePath string, funcArr ...LocalConfigFunc) (config.Manager, error) {
+	return GetConfigManagerByFormat(filePath, FormatTOML, funcArr...)
+}
+
+// 获取 properties 配置
+func GetPropertiesConfigManager(filePath string, funcArr ...LocalConfigFunc) (config.Manager, error) {
+	return GetConfigManagerByFormat(filePath, FormatProperties, funcArr...)
+}
+
+// 获取 env 配置
+func GetEnvConfigManager(filePath string, funcArr ...LocalConfigFunc) (config.Manager, error) {
+	return GetConfigManagerByFormat(filePath, FormatEnv, funcArr...)
+}
+
+// 获取指定格式的本地文件配置单例，配置只在第一次获取时生效
+func GetConfigManagerByFormat(filePath string, format Format, funcArr ...LocalConfigFunc) (config.Manager, error) {
+	conf := localConf{watchInterval: defaultWatchInterval}
+	for _, f := range funcArr {
+		f(&conf)
+	}
+	if format == FormatYAML {
+		return yamlconfig.GetYamlConfigManager(filePath, yamlconfig.YamlSetWatchInterval(conf.watchInterval))
+	}
+	parseFunc, ok := parseFuncMap[format]
+	if !ok {
+		return nil, errorcode.BuildErrorWithMsg(errorcode.ConfigParamInvalid, fmt.Sprintf("unknown config file format: %s", format))
+	}
+
+	// 同一个文件可以按照不同格式解析，缓存 key 需要包含格式
+	cacheKey := string(format) + ":" + filePath
+	var manager config.Manager
+	localConfigMapLock.RLock()
+	manager = localConfigMap[cacheKey]
+	localConfigMapLock.RUnlock()
+	if nil != manager {
+		return manager, nil
+	}
+
+	localConfigMapLock.Lock()
+	defer localConfigMapLock.Unlock()
+	if nil == localConfigMap {
+		localConfigMap = make(map[string]config.Manager)
+	}
+	if manager = localConfigMap[cacheKey]; nil != manager {
+		return manager, nil
+	}
+
+	// 必须要初始化成功
+	localConfig := &localManager{filePath: filePath, parseFunc: parseFunc}
+	err := localConfig.init(conf.watchInterval)
+	if nil != err {
+		log.Error("[config.GetConfigManagerByFormat] init %s config failed: %s", format, err.Error())
+		return nil, err
+	}
+	localConfigMap[cacheKey] = localConfig
+	return localConfig, nil
+}
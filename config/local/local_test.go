@@ -0,0 +1,187 @@
+package local
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type mysqlConfig struct {
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"`
+	Password string   `yaml:"password"`
+	SSL      bool     `yaml:"ssl"`
+	DBArr    []string `yaml:"db_arr"`
+}
+
+// 写入测试配置文件，返回文件路径
+func writeConfigFile(t *testing.T, fileName, content string) string {
+	filePath := filepath.Join(t.TempDir(), fileName)
+	require.Empty(t, ioutil.WriteFile(filePath, []byte(content), 0644))
+	return filePath
+}
+
+// 测试根据扩展名获取配置，并解析成相同的对象
+func TestLocalConfig(t *testing.T) {
+	expectConf := mysqlConfig{Host: "localhost", Port: 3306, Password: "0123", SSL: true, DBArr: []string{"school", "temp"}}
+	filePathArr := []string{
+		writeConfigFile(t, "conf.json", `{"mysql": {"host": "localhost", "port": 3306, "password": "0123", "ssl": true, "db_arr": ["school", "temp"]}, "http": {"timeout": 1.5}}`),
+		writeConfigFile(t, "conf.toml", `# mysql
+[mysql]
+host = "localhost" # host
+port = 3_306
+password = '0123'
+ssl = true
+db_arr = [
+  "school",
+  "temp", # trailing comma
+]
+
+[http]
+timeout = 1.5
+`),
+		writeConfigFile(t, "conf.yml", "mysql:\n  host: localhost\n  port: 3306\n  password: \"0123\"\n  ssl: true\n  db_arr: [school, temp]\nhttp:\n  timeout: 1.5\n"),
+	}
+	for _, filePath := range filePathArr {
+		manager, err := GetLocalConfigManager(filePath)
+		require.Empty(t, err, filePath)
+		conf := mysqlConfig{}
+		require.Empty(t, manager.Unmarshal("mysql", &conf), filePath)
+		require.Equal(t, expectConf, conf, filePath)
+		port, err := manager.Get("mysql", "port")
+		require.Empty(t, err)
+		require.Equal(t, 3306, port, filePath)
+		timeout, err := manager.Get("http", "timeout")
+		require.Empty(t, err)
+		require.Equal(t, 1.5, timeout, filePath)
+
+		// 按路径缓存
+		cachedManager, err := GetLocalConfigManager(filePath)
+		require.Empty(t, err)
+		require.True(t, manager == cachedManager)
+	}
+
+	// properties 和 env 的值都是字符串，解析对象时转换类型
+	expectConf.DBArr = nil
+	filePathArr = []string{
+		writeConfigFile(t, "conf.properties", "# mysql\nmysql.host = localhost\nmysql.port: 3306\nmysql.password=0123\nmysql.ssl=\\\n  true\n"+
+			"http.max_idle=1000\nignored=true\n"),
+		writeConfigFile(t, ".env", "# mysql\nMYSQL_HOST=localhost\nexport MYSQL_PORT=3306\nMYSQL_PASSWORD='0123'\nMYSQL_SSL=true # ssl\n"+
+			"HTTP_MAX_IDLE=\"1000\"\nIGNORED=true\n"),
+	}
+	for _, filePath := range filePathArr {
+		manager, err := GetLocalConfigManager(filePath)
+		require.Empty(t, err, filePath)
+		conf := mysqlConfig{}
+		require.Empty(t, manager.Unmarshal("mysql", &conf), filePath)
+		require.Equal(t, expectConf, conf, filePath)
+		maxIdle, err := manager.Get("http", "max_idle")
+		require.Empty(t, err)
+		require.Equal(t, "1000", maxIdle, filePath)
+		spaceNameArr, err := manager.GetAllSpaceName()
+		require.Empty(t, err)
+		require.ElementsMatch(t, []string{"mysql", "http"}, spaceNameArr, filePath)
+	}
+
+	// 同一个文件按照不同格式解析，分别缓存
+	filePath := writeConfigFile(t, "conf", "MYSQL_HOST=localhost\n")
+	envManager, err := GetEnvConfigManager(filePath)
+	require.Empty(t, err)
+	host, err := envManager.Get("mysql", "host")
+	require.Empty(t, err)
+	require.Equal(t, "localhost", host)
+	propertiesManager, err := GetPropertiesConfigManager(filePath)
+	require.Empty(t, err)
+	require.True(t, envManager != propertiesManager)
+	_, err = propertiesManager.Get("mysql", "host")
+	require.NotEmpty(t, err)
+
+	_, err = GetLocalConfigManager("conf.xml")
+	require.NotEmpty(t, err)
+	_, err = GetTOMLConfigManager(writeConfigFile(t, "invalid.toml", "[mysql]\nhost = \"localhost\nport = 3306\n"))
+	require.NotEmpty(t, err)
+	_, err = GetJSONConfigManager(writeConfigFile(t, "invalid.json", `{"mysql": 1}`))
+	require.NotEmpty(t, err)
+}
+
+// 测试 toml 解析: 多行字符串、表数组，顶层不是表的配置返回错误
+func TestParseTOML(t *testing.T) {
+	configMap, err := parseTOML([]byte(`[mysql]
+"user.name" = "root\tA"
+options.timeout = 10
+pool = { max = 0x10, min = 0 }
+created = 1979-05-27 07:32:00
+ratio = -1e3
+
+[mysql.replica]
+hosts = [["a", 'b'], []]
+
+[[mysql.shard]]
+name = """shard
+one"""
+
+[[mysql.shard]]
+name = '''shard\two'''
+`))
+	require.Empty(t, err)
+	require.Equal(t, map[string]map[string]interface{}{
+		"mysql": {
+			"user.name": "root\tA",
+			"options":   map[string]interface{}{"timeout": 10},
+			"pool":      map[string]interface{}{"max": 16, "min": 0},
+			"created":   "1979-05-27 07:32:00",
+			"ratio":     -1000.0,
+			"replica":   map[string]interface{}{"hosts": []interface{}{[]interface{}{"a", "b"}, []interface{}{}}},
+			"shard":     []interface{}{map[string]interface{}{"name": "shard\none"}, map[string]interface{}{"name": "shard\\two"}},
+		},
+	}, configMap)
+
+	for _, content := range []string{"host = 1", "[mysql]\nport = 1\nport = 2", "[[mysql]]", "[mysql]\nport = 012", "[mysql]\nhost = \"a"} {
+		_, err = parseTOML([]byte(content))
+		require.NotEmpty(t, err, content)
+	}
+}
+
+// 测试 properties 转义: 普通转义字符、unicode 转义（包括代理对），不完整的 unicode 转义按照普通字符处理
+func TestParseProperties(t *testing.T) {
+	configMap, err := parsePropertiesMap([]byte(`app.name = \u4e2d\u6587
+app.emoji = \ud83d\ude00
+app\u002ekey = a\tb\:c
+app.invalid = \u12x4 \u12
+`))
+	require.Empty(t, err)
+	require.Equal(t, map[string]interface{}{
+		"app.name":    "中文",
+		"app.emoji":   "😀",
+		"app.key":     "a\tb:c",
+		"app.invalid": "u12x4 u12",
+	}, configMap)
+}
+
+// 测试解析单个配置空间的内容
+func TestParseSpace(t *testing.T) {
+	expectMap := map[string]interface{}{"host": "localhost", "port": 3306}
+	for format, content := range map[Format]string{
+		FormatYAML: "host: localhost\nport: 3306\n",
+		FormatJSON: `{"host": "localhost", "port": 3306}`,
+		FormatTOML: "host = \"localhost\"\nport = 3306\n",
+	} {
+		configMap, err := ParseSpace([]byte(content), format)
+		require.Empty(t, err)
+		require.Equal(t, expectMap, configMap, format)
+	}
+
+	configMap, err := ParseSpace([]byte("# mysql\ndb.host=localhost\nport=3306\n"), FormatProperties)
+	require.Empty(t, err)
+	require.Equal(t, map[string]interface{}{"db.host": "localhost", "port": "3306"}, configMap)
+	configMap, err = ParseSpace([]byte("export HOST=localhost\nDB_PORT='3306'\n"), FormatEnv)
+	require.Empty(t, err)
+	require.Equal(t, map[string]interface{}{"host": "localhost", "db_port": "3306"}, configMap)
+
+	_, err = ParseSpace([]byte("host: localhost"), Format("xml"))
+	require.NotEmpty(t, err)
+	_, err = ParseSpace([]byte("[host"), FormatJSON)
+	require.NotEmpty(t, err)
+}
@@ -0,0 +1,228 @@
+package local
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/smiecj/go_common/errorcode"
+)
+
+// 解析 properties 配置: 第一个 . 之前为配置空间，之后为 key，如 mysql.host=localhost
+// 不包含 . 的配置会忽略
+func parseProperties(content []byte) (map[string]map[string]interface{}, error) {
+	configMap, err := parsePropertiesMap(content)
+	if nil != err {
+		return nil, err
+	}
+	return splitSpace(configMap, "."), nil
+}
+
+// 解析 properties 内容，返回 key -> value
+// 支持 = 和 : 分隔符、# 和 ! 注释、行尾 \ 续行、\uXXXX 转义
+func parsePropertiesMap(content []byte) (map[string]interface{}, error) {
+	retMap := make(map[string]interface{})
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	logicalLine := ""
+	for scanner.Scan() {
+		line := strings.TrimLeft(scanner.Text(), " \t\f")
+		if logicalLine == "" && (line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!")) {
+			continue
+		}
+		// 奇数个 \ 结尾表示续行
+		if trimmedLine := strings.TrimRight(line, "\\"); (len(line)-len(trimmedLine))%2 == 1 {
+			logicalLine += line[:len(line)-1]
+			continue
+		}
+		logicalLine += line
+
+		if key, value := splitProperty(logicalLine); key != "" {
+			retMap[key] = value
+		}
+		logicalLine = ""
+	}
+	if err := scanner.Err(); nil != err {
+		return nil, err
+	}
+	if logicalLine != "" {
+		if key, value := splitProperty(logicalLine); key != "" {
+			retMap[key] = value
+		}
+	}
+	return retMap, nil
+}
+
+// 拆分 properties 的 key 和 value，并处理转义字符
+func splitProperty(line string) (string, string) {
+	keyBuilder := new(strings.Builder)
+	index := 0
+	for ; index < len(line); index++ {
+		current := line[index]
+		if current == '\\' && index+1 < len(line) {
+			var unescaped string
+			unescaped, index = unescapeProperty(line, index+1)
+			keyBuilder.WriteString(unescaped)
+			continue
+		}
+		if current == '=' || current == ':' || current == ' ' || current == '\t' {
+			break
+		}
+		keyBuilder.WriteByte(current)
+	}
+
+	// 分隔符前后可以有空格
+	value := strings.TrimLeft(line[index:], " \t\f")
+	if strings.HasPrefix(value, "=") || strings.HasPrefix(value, ":") {
+		value = strings.TrimLeft(value[1:], " \t\f")
+	}
+	valueBuilder := new(strings.Builder)
+	for index = 0; index < len(value); index++ {
+		if value[index] == '\\' && index+1 < len(value) {
+			var unescaped string
+			unescaped, index = unescapeProperty(value, index+1)
+			valueBuilder.WriteString(unescaped)
+			continue
+		}
+		valueBuilder.WriteByte(value[index])
+	}
+	return keyBuilder.String(), valueBuilder.String()
+}
+
+// 处理 \ 之后的转义字符，index 为 \ 的下一个字符，返回转义之后的内容和最后处理的字符位置
+// 支持 \uXXXX 形式的 unicode 转义（包括代理对），格式不正确时按照普通字符处理
+func unescapeProperty(text string, index int) (string, int) {
+	switch text[index] {
+	case 't':
+		return "\t", index
+	case 'n':
+		return "\n", index
+	case 'r':
+		return "\r", index
+	case 'f':
+		return "\f", index
+	case 'u':
+		currentRune, ok := parseUnicodeEscape(text, index+1)
+		if !ok {
+			break
+		}
+		index += 4
+		if utf16.IsSurrogate(currentRune) && strings.HasPrefix(text[index+1:], "\\u") {
+			if lowRune, ok := parseUnicodeEscape(text, index+3); ok {
+				if pairRune := utf16.DecodeRune(currentRune, lowRune); pairRune != unicode.ReplacementChar {
+					return string(pairRune), index + 6
+				}
+			}
+		}
+		return string(currentRune), index
+	}
+	return string(text[index]), index
+}
+
+// 解析 start 开始的 4 位十六进制数
+func parseUnicodeEscape(text string, start int) (rune, bool) {
+	if start+4 > len(text) {
+		return 0, false
+	}
+	value, err := strconv.ParseUint(text[start:start+4], 16, 32)
+	if nil != err {
+		return 0, false
+	}
+	return rune(value), true
+}
+
+// 解析 env 配置: 第一个 _ 之前为配置空间，之后为 key，如 MYSQL_HOST=localhost
+// 不包含 _ 的变量会忽略
+func parseEnv(content []byte) (map[string]map[string]interface{}, error) {
+	configMap, err := parseEnvMap(content)
+	if nil != err {
+		return nil, err
+	}
+	return splitSpace(configMap, "_"), nil
+}
+
+// 解析 env 内容，返回小写的变量名 -> value
+// 支持 export 前缀、单双引号和 # 注释
+func parseEnvMap(content []byte) (map[string]interface{}, error) {
+	retMap := make(map[string]interface{})
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		splitIndex := strings.Index(line, "=")
+		if splitIndex <= 0 {
+			return nil, errorcode.BuildErrorWithMsg(errorcode.ConfigParamInvalid, fmt.Sprintf("env config line %d is invalid: %s", lineNumber, line))
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:splitIndex]))
+		value, ok := parseEnvValue(strings.TrimSpace(line[splitIndex+1:]))
+		if !ok {
+			return nil, errorcode.BuildErrorWithMsg(errorcode.ConfigParamInvalid, fmt.Sprintf("env config line %d quote not closed: %s", lineNumber, line))
+		}
+		retMap[key] = value
+	}
+	if err := scanner.Err(); nil != err {
+		return nil, err
+	}
+	return retMap, nil
+}
+
+// 解析 env 的值: 双引号支持转义，单引号保持原样，没有引号时 # 之后为注释，引号没有闭合时返回 false
+func parseEnvValue(value string) (string, bool) {
+	if value == "" {
+		return "", true
+	}
+	switch value[0] {
+	case '"':
+		builder := new(strings.Builder)
+		for index := 1; index < len(value); index++ {
+			switch value[index] {
+			case '\\':
+				if index+1 < len(value) {
+					var unescaped string
+					unescaped, index = unescapeProperty(value, index+1)
+					builder.WriteString(unescaped)
+				}
+			case '"':
+				return builder.String(), true
+			default:
+				builder.WriteByte(value[index])
+			}
+		}
+		return "", false
+	case '\'':
+		endIndex := strings.Index(value[1:], "'")
+		if endIndex < 0 {
+			return "", false
+		}
+		return value[1 : endIndex+1], true
+	}
+	if commentIndex := strings.Index(value, " #"); commentIndex >= 0 {
+		value = value[:commentIndex]
+	}
+	return strings.TrimSpace(value), true
+}
+
+// 按照分隔符拆分配置空间和 key
+func splitSpace(configMap map[string]interface{}, separator string) map[string]map[string]interface{} {
+	retMap := make(map[string]map[string]interface{})
+	for name, value := range configMap {
+		splitIndex := strings.Index(name, separator)
+		if splitIndex <= 0 || splitIndex == len(name)-1 {
+			continue
+		}
+		spaceName, key := name[:splitIndex], name[splitIndex+1:]
+		if nil == retMap[spaceName] {
+			retMap[spaceName] = make(map[string]interface{})
+		}
+		retMap[spaceName][key] = value
+	}
+	return retMap
+}
@@ -0,0 +1,74 @@
+package local
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/smiecj/go_common/errorcode"
+)
+
+// toml 中不带时区的日期时间，解析之后转换成字符串
+var tomlTimeFormatMap = map[string]string{
+	"datetime-local": "2006-01-02 15:04:05.999999999",
+	"date-local":     "2006-01-02",
+	"time-local":     "15:04:05.999999999",
+}
+
+// 解析 toml 配置: 顶层的表对应配置空间，[mysql.options] 解析成 mysql 空间下的 options 对象
+// 整数转换成 int，日期时间转换成字符串，表数组 [[mysql.replica]] 解析成对象数组
+func parseTOML(content []byte) (map[string]map[string]interface{}, error) {
+	rootMap, err := parseTOMLMap(content)
+	if nil != err {
+		return nil, err
+	}
+
+	retMap := make(map[string]map[string]interface{}, len(rootMap))
+	for spaceName, spaceValue := range rootMap {
+		configMap, ok := spaceValue.(map[string]interface{})
+		if !ok {
+			return nil, errorcode.BuildErrorWithMsg(errorcode.ConfigParamInvalid, fmt.Sprintf("toml key %s is not in table", spaceName))
+		}
+		retMap[spaceName] = configMap
+	}
+	return retMap, nil
+}
+
+// 解析 toml 内容，返回顶层的表
+func parseTOMLMap(content []byte) (map[string]interface{}, error) {
+	rootMap := make(map[string]interface{})
+	if err := toml.Unmarshal(content, &rootMap); nil != err {
+		return nil, errorcode.BuildErrorWithMsg(errorcode.ConfigParamInvalid, fmt.Sprintf("parse toml config failed: %s", err.Error()))
+	}
+	return convertTOMLValue(rootMap).(map[string]interface{}), nil
+}
+
+// 转换 toml 解析结果中的值，和其他格式的配置保持一致
+func convertTOMLValue(value interface{}) interface{} {
+	switch currentValue := value.(type) {
+	case int64:
+		return int(currentValue)
+	case time.Time:
+		if timeFormat, ok := tomlTimeFormatMap[currentValue.Location().String()]; ok {
+			return currentValue.Format(timeFormat)
+		}
+		return currentValue.Format(time.RFC3339Nano)
+	case map[string]interface{}:
+		for key, subValue := range currentValue {
+			currentValue[key] = convertTOMLValue(subValue)
+		}
+		return currentValue
+	case []map[string]interface{}:
+		retArr := make([]interface{}, 0, len(currentValue))
+		for _, subValue := range currentValue {
+			retArr = append(retArr, convertTOMLValue(subValue))
+		}
+		return retArr
+	case []interface{}:
+		for index, subValue := range currentValue {
+			currentValue[index] = convertTOMLValue(subValue)
+		}
+		return currentValue
+	}
+	return value
+}
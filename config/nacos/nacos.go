@@ -1,17 +1,25 @@
 package config
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
 	"github.com/nacos-group/nacos-sdk-go/clients"
 	"github.com/nacos-group/nacos-sdk-go/clients/config_client"
 	"github.com/nacos-group/nacos-sdk-go/common/constant"
 	"github.com/nacos-group/nacos-sdk-go/vo"
 	config "github.com/smiecj/go_common/config"
+	"github.com/smiecj/go_common/config/local"
 	"github.com/smiecj/go_common/errorcode"
 	"github.com/smiecj/go_common/util/log"
 )
 
 const (
 	nacosConfigSpace = "nacos"
+	// 配置空间没有设置 group 时使用 nacos 的默认 group
+	defaultGroup = "DEFAULT_GROUP"
 )
 
 type emptyLogger struct {
@@ -27,21 +35,78 @@ func (logger *emptyLogger) Errorf(fmt string, args ...interface{}) {}
 func (logger *emptyLogger) Debugf(fmt string, args ...interface{}) {}
 
 type nacosConfig struct {
-	Host        string `yaml:"host"`
-	Port        int    `yaml:"port"`
+	Host        string `yaml:"host" validate:"required"`
+	Port        int    `yaml:"port" default:"8848" validate:"min=1,max=65535"`
 	User        string `yaml:"user"`
 	Password    string `yaml:"password"`
 	NamespaceId string `yaml:"namespace_id"`
+	// 本地缓存配置的目录，默认为当前目录下的 cache
+	CacheDir string `yaml:"cache_dir"`
+	// 配置空间 -> 对应的 dataId
+	SpaceMap map[string]nacosSpaceConfig `yaml:"space"`
+}
+
+// 配置空间对应的 nacos 配置
+type nacosSpaceConfig struct {
+	DataId string `yaml:"data_id"`
+	// 默认 DEFAULT_GROUP
+	Group string `yaml:"group"`
+	// 配置内容格式: yaml、json、toml、properties、env，默认根据 dataId 的扩展名判断，判断不了时为 yaml
+	Format string `yaml:"format"`
+}
+
+// 配置空间: 对应 nacos 的一个 dataId，配置内容解析之后保存在 MapSpace 中
+type nacosSpace struct {
+	group  string
+	dataId string
+	format local.Format
+	space  *config.MapSpace
 }
 
 // nacos config manager
+// nacos.space 中配置了 dataId 的配置空间，Get、GetSpace、Unmarshal 都基于 dataId 解析后的配置
+// 没有配置的空间保持原来的用法: space 对应 group，key 对应 dataId，获取到的是 dataId 的原始内容
 type nacosConfigManager struct {
 	nacosConfig nacosConfig
 	client      config_client.IConfigClient
+
+	// 配置空间在初始化时加载，spaceMap 之后只读
+	spaceMap       map[string]*nacosSpace
+	listenSpaceMap map[listenKey]string
+	// 保证配置内容替换和变更对比的原子性
+	spaceLock sync.Mutex
+
+	// 配置监听: 每个 group + dataId 只向 nacos 注册一次，并记录最新的配置内容
+	notifier    *config.Notifier
+	listenLock  sync.Mutex
+	listenValue map[listenKey]string
+}
+
+// 监听的配置: group + dataId
+type listenKey struct {
+	group  string
+	dataId string
 }
 
 // nacos config init
 func (manager *nacosConfigManager) init(conf nacosConfig) (err error) {
+	manager.nacosConfig = conf
+	manager.spaceMap = make(map[string]*nacosSpace, len(conf.SpaceMap))
+	manager.listenSpaceMap = make(map[listenKey]string, len(conf.SpaceMap))
+	for spaceName, spaceConf := range conf.SpaceMap {
+		space, err := newNacosSpace(spaceName, spaceConf)
+		if nil != err {
+			return err
+		}
+		currentKey := listenKey{group: space.group, dataId: space.dataId}
+		if existSpaceName, ok := manager.listenSpaceMap[currentKey]; ok {
+			return errorcode.BuildErrorWithMsg(errorcode.ConfigValidateFailed,
+				fmt.Sprintf("nacos space %s and %s use the same data id: %s", existSpaceName, spaceName, space.dataId))
+		}
+		manager.spaceMap[spaceName] = space
+		manager.listenSpaceMap[currentKey] = spaceName
+	}
+
 	sc := []constant.ServerConfig{
 		*constant.NewServerConfig(manager.nacosConfig.Host, uint64(manager.nacosConfig.Port), constant.WithContextPath("/nacos")),
 	}
@@ -55,6 +120,9 @@ func (manager *nacosConfigManager) init(conf nacosConfig) (err error) {
 		constant.WithPassword(manager.nacosConfig.Password),
 		constant.WithNamespaceId(manager.nacosConfig.NamespaceId),
 	)
+	if manager.nacosConfig.CacheDir != "" {
+		cc.CacheDir = manager.nacosConfig.CacheDir
+	}
 
 	client, getClientErr := clients.NewConfigClient(
 		vo.NacosClientParam{
@@ -68,11 +136,97 @@ func (manager *nacosConfigManager) init(conf nacosConfig) (err error) {
 	}
 
 	manager.client = client
+	manager.notifier = config.NewNotifier()
+	manager.listenValue = make(map[listenKey]string)
+
+	for _, spaceName := range manager.getAllSpaceName() {
+		if _, err = manager.reloadSpace(spaceName); nil != err {
+			log.Warn("[NacosConfigManager] load space %s failed: %s", spaceName, err.Error())
+			return err
+		}
+	}
 	return nil
 }
 
+// 检查配置空间的配置，设置默认的 group 和格式
+func newNacosSpace(spaceName string, spaceConf nacosSpaceConfig) (*nacosSpace, error) {
+	if spaceConf.DataId == "" {
+		return nil, errorcode.BuildErrorWithMsg(errorcode.ConfigValidateFailed, fmt.Sprintf("nacos space %s data_id is required", spaceName))
+	}
+	space := &nacosSpace{group: spaceConf.Group, dataId: spaceConf.DataId, format: local.Format(spaceConf.Format), space: config.NewMapSpace(nil)}
+	if space.group == "" {
+		space.group = defaultGroup
+	}
+	if space.format == "" {
+		format, err := local.GetFormat(spaceConf.DataId)
+		if nil != err {
+			format = local.FormatYAML
+		}
+		space.format = format
+	}
+	switch space.format {
+	case local.FormatYAML, local.FormatJSON, local.FormatTOML, local.FormatProperties, local.FormatEnv:
+		return space, nil
+	}
+	return nil, errorcode.BuildErrorWithMsg(errorcode.ConfigValidateFailed, fmt.Sprintf("nacos space %s format %s is invalid", spaceName, space.format))
+}
+
+// 从 nacos 重新获取配置空间对应的 dataId，返回配置变更事件
+// dataId 已经在监听时同步更新最新的配置内容，避免之后的变更回调重复处理
+func (manager *nacosConfigManager) reloadSpace(spaceName string) ([]config.ChangeEvent, error) {
+	space := manager.spaceMap[spaceName]
+	content, err := manager.client.GetConfig(vo.ConfigParam{DataId: space.dataId, Group: space.group})
+	if nil != err {
+		return nil, err
+	}
+	eventArr, err := manager.applyContent(spaceName, content)
+	if nil != err {
+		return nil, err
+	}
+	currentKey := listenKey{group: space.group, dataId: space.dataId}
+	manager.listenLock.Lock()
+	if _, ok := manager.listenValue[currentKey]; ok {
+		manager.listenValue[currentKey] = content
+	}
+	manager.listenLock.Unlock()
+	return eventArr, nil
+}
+
+// 解析 dataId 的内容并替换配置空间的配置，dataId 不存在（内容为空）时配置空间为空
+func (manager *nacosConfigManager) applyContent(spaceName, content string) ([]config.ChangeEvent, error) {
+	space := manager.spaceMap[spaceName]
+	configMap := make(map[string]interface{})
+	if strings.TrimSpace(content) != "" {
+		var err error
+		configMap, err = local.ParseSpace([]byte(content), space.format)
+		if nil != err {
+			return nil, errorcode.BuildErrorWithMsg(errorcode.ConfigParamInvalid,
+				fmt.Sprintf("parse nacos space %s (group: %s, data id: %s) failed: %s", spaceName, space.group, space.dataId, err.Error()))
+		}
+	}
+
+	manager.spaceLock.Lock()
+	defer manager.spaceLock.Unlock()
+	oldConfigMap := space.space.Replace(configMap)
+	return config.DiffSpace(spaceName, oldConfigMap, configMap), nil
+}
+
+// 全部配置空间名，按字母排序
+func (manager *nacosConfigManager) getAllSpaceName() []string {
+	spaceNameArr := make([]string, 0, len(manager.spaceMap))
+	for spaceName := range manager.spaceMap {
+		spaceNameArr = append(spaceNameArr, spaceName)
+	}
+	sort.Strings(spaceNameArr)
+	return spaceNameArr
+}
+
 // nacos config manager get config
+// 配置空间没有对应的 dataId 时，space 为 group，key 为 dataId，返回 dataId 的原始内容
 func (manager *nacosConfigManager) Get(spaceName, key string) (ret interface{}, err error) {
+	if space, ok := manager.spaceMap[spaceName]; ok {
+		return space.space.Get(key)
+	}
 	val, err := manager.client.GetConfig(vo.ConfigParam{
 		DataId: key,
 		Group:  spaceName,
@@ -81,18 +235,26 @@ func (manager *nacosConfigManager) Get(spaceName, key string) (ret interface{},
 	return
 }
 
-// not supported
+// 获取配置了 dataId 的全部配置空间名
 func (manager *nacosConfigManager) GetAllSpaceName() (retArr []string, err error) {
-	return nil, errorcode.BuildError(errorcode.ConfigMethodNotSupport)
+	return manager.getAllSpaceName(), nil
 }
 
-// not supported
+// 获取配置了 dataId 的配置空间
 func (manager *nacosConfigManager) GetSpace(spaceName string) (space config.Space, err error) {
-	return nil, errorcode.BuildError(errorcode.ConfigMethodNotSupport)
+	if currentSpace, ok := manager.spaceMap[spaceName]; ok {
+		return currentSpace.space, nil
+	}
+	return nil, errorcode.BuildError(errorcode.SpaceNotExist)
 }
 
-// yaml config set config
+// 发布配置: space 为 group，key 为 dataId，value 为配置内容
+// 配置了 dataId 的配置空间不支持设置单个配置，需要直接发布整个 dataId 的内容
 func (manager *nacosConfigManager) Set(spaceName, key string, value interface{}) (err error) {
+	if space, ok := manager.spaceMap[spaceName]; ok {
+		return errorcode.BuildErrorWithMsg(errorcode.ConfigMethodNotSupport,
+			fmt.Sprintf("nacos space %s is mapped to data id %s, please publish whole content", spaceName, space.dataId))
+	}
 	valStr, ok := value.(string)
 	if !ok {
 		return errorcode.BuildErrorWithMsg(errorcode.ServiceError, "config set value not string")
@@ -105,22 +267,165 @@ func (manager *nacosConfigManager) Set(spaceName, key string, value interface{})
 	return publishConfErr
 }
 
-// nacos 接口不支持获取所有 group 、所有 key，因此无法支持 Unmarshal 方法
+// 解析配置空间对应的 dataId 内容
+// nacos 接口不支持获取所有 group 、所有 key，没有配置 dataId 的配置空间无法解析
 // https://nacos.io/en-us/docs/v2/guide/user/open-api.html
 func (manager *nacosConfigManager) Unmarshal(spaceName string, obj interface{}) error {
-	return errorcode.BuildError(errorcode.ConfigMethodNotSupport)
+	space, ok := manager.spaceMap[spaceName]
+	if !ok {
+		return errorcode.BuildError(errorcode.SpaceNotExist)
+	}
+	return space.space.Unmarshal(obj)
 }
 
-// not supported
+// 重新获取全部配置空间和监听中的 dataId，并通知配置变更
 func (manager *nacosConfigManager) Update() error {
-	// manager.client.
-	return errorcode.BuildError(errorcode.ConfigMethodNotSupport)
+	var retErr error
+	eventArr := make([]config.ChangeEvent, 0)
+	for _, spaceName := range manager.getAllSpaceName() {
+		currentEventArr, err := manager.reloadSpace(spaceName)
+		if nil != err {
+			log.Warn("[NacosConfigManager.Update] reload space %s failed: %s", spaceName, err.Error())
+			if nil == retErr {
+				retErr = err
+			}
+			continue
+		}
+		eventArr = append(eventArr, currentEventArr...)
+	}
+	manager.notifier.Notify(eventArr...)
+
+	// 没有对应配置空间的 dataId: 和变更回调一致，内容变化时通知
+	manager.listenLock.Lock()
+	keyArr := make([]listenKey, 0, len(manager.listenValue))
+	for currentKey := range manager.listenValue {
+		if _, ok := manager.listenSpaceMap[currentKey]; !ok {
+			keyArr = append(keyArr, currentKey)
+		}
+	}
+	manager.listenLock.Unlock()
+	for _, currentKey := range keyArr {
+		value, err := manager.client.GetConfig(vo.ConfigParam{DataId: currentKey.dataId, Group: currentKey.group})
+		if nil != err {
+			log.Warn("[NacosConfigManager.Update] get config (group: %s, data id: %s) failed: %s", currentKey.group, currentKey.dataId, err.Error())
+			if nil == retErr {
+				retErr = err
+			}
+			continue
+		}
+		manager.onChange(manager.nacosConfig.NamespaceId, currentKey.group, currentKey.dataId, value)
+	}
+	return retErr
+}
+
+// 监听配置变更
+// 配置了 dataId 的配置空间: 监听 dataId，按照 key 通知变更，key 为空时监听整个配置空间
+// 其他: space 对应 group，key 对应 dataId，key 不能为空
+func (manager *nacosConfigManager) Watch(spaceName, key string, listener config.ChangeListener) (func(), error) {
+	currentKey := listenKey{group: spaceName, dataId: key}
+	// 没有监听器时取消监听，配置空间需要统计整个空间的监听器
+	countKey := key
+	if space, ok := manager.spaceMap[spaceName]; ok {
+		currentKey = listenKey{group: space.group, dataId: space.dataId}
+		countKey = ""
+	} else if key == "" {
+		return nil, errorcode.BuildErrorWithMsg(errorcode.ConfigMethodNotSupport, "nacos watch must set key (data id)")
+	}
+
+	var eventArr []config.ChangeEvent
+	manager.listenLock.Lock()
+	if _, ok := manager.listenValue[currentKey]; !ok {
+		value, err := manager.client.GetConfig(vo.ConfigParam{DataId: currentKey.dataId, Group: currentKey.group})
+		if nil != err {
+			manager.listenLock.Unlock()
+			log.Warn("[NacosConfigManager.Watch] get config failed: %s", err.Error())
+			return nil, err
+		}
+		// 配置空间: 使用开始监听时的内容更新配置，获取配置空间之后到开始监听之间的变更也会通知，解析失败时保留当前配置
+		if spaceName, ok := manager.listenSpaceMap[currentKey]; ok {
+			if eventArr, err = manager.applyContent(spaceName, value); nil != err {
+				log.Warn("[NacosConfigManager.Watch] %s", err.Error())
+			}
+		}
+		err = manager.client.ListenConfig(vo.ConfigParam{
+			DataId:   currentKey.dataId,
+			Group:    currentKey.group,
+			OnChange: manager.onChange,
+		})
+		if nil != err {
+			manager.listenLock.Unlock()
+			log.Warn("[NacosConfigManager.Watch] listen config failed: %s", err.Error())
+			return nil, err
+		}
+		manager.listenValue[currentKey] = value
+	}
+
+	cancel := manager.notifier.Add(spaceName, key, listener)
+	manager.listenLock.Unlock()
+	// 不持有锁回调，回调中可以添加、取消监听
+	manager.notifier.Notify(eventArr...)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			manager.unlisten(currentKey, spaceName, countKey)
+		})
+	}, nil
+}
+
+// nacos 配置变更回调
+func (manager *nacosConfigManager) onChange(namespace, group, dataId, data string) {
+	currentKey := listenKey{group: group, dataId: dataId}
+	manager.listenLock.Lock()
+	oldValue, ok := manager.listenValue[currentKey]
+	if !ok || oldValue == data {
+		manager.listenLock.Unlock()
+		return
+	}
+	manager.listenValue[currentKey] = data
+	manager.listenLock.Unlock()
+
+	// 配置空间: 解析之后按照 key 通知变更，解析失败时保留当前配置
+	if spaceName, ok := manager.listenSpaceMap[currentKey]; ok {
+		eventArr, err := manager.applyContent(spaceName, data)
+		if nil != err {
+			log.Warn("[NacosConfigManager.onChange] %s", err.Error())
+			return
+		}
+		manager.notifier.Notify(eventArr...)
+		return
+	}
+
+	event := config.ChangeEvent{Space: group, Key: dataId, Type: config.ChangeModify, OldValue: oldValue, NewValue: data}
+	if oldValue == "" {
+		event.Type = config.ChangeAdd
+	} else if data == "" {
+		event.Type = config.ChangeDelete
+	}
+	manager.notifier.Notify(event)
+}
+
+// 配置没有监听器时，取消向 nacos 注册的监听
+func (manager *nacosConfigManager) unlisten(currentKey listenKey, spaceName, key string) {
+	manager.listenLock.Lock()
+	defer manager.listenLock.Unlock()
+	if manager.notifier.Count(spaceName, key) > 0 {
+		return
+	}
+	if _, ok := manager.listenValue[currentKey]; !ok {
+		return
+	}
+	delete(manager.listenValue, currentKey)
+	err := manager.client.CancelListenConfig(vo.ConfigParam{DataId: currentKey.dataId, Group: currentKey.group})
+	if nil != err {
+		log.Warn("[NacosConfigManager.unlisten] cancel listen config failed: %s", err.Error())
+	}
 }
 
 // get nacos config manager (server address config rely on local file)
 func GetNacosConfigManager(yamlConfigManager config.Manager) (config.Manager, error) {
 	conf := nacosConfig{}
-	getConfigErr := yamlConfigManager.Unmarshal(nacosConfigSpace, &conf)
+	getConfigErr := config.UnmarshalAndValidate(yamlConfigManager, nacosConfigSpace, &conf)
 	if nil != getConfigErr {
 		log.Warn("[GetNacosConfigManager] parse config err: %s", getConfigErr.Error())
 		return nil, getConfigErr
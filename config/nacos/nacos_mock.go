@@ -12,14 +12,12 @@ func (manager *nacosConfigManagerMock) Get(spaceName, key string) (ret interface
 	return "test_value", nil
 }
 
-// not supported
 func (manager *nacosConfigManagerMock) GetAllSpaceName() (retArr []string, err error) {
-	return nil, errorcode.BuildError(errorcode.ConfigMethodNotSupport)
+	return []string{}, nil
 }
 
-// not supported
 func (manager *nacosConfigManagerMock) GetSpace(spaceName string) (space config.Space, err error) {
-	return nil, errorcode.BuildError(errorcode.ConfigMethodNotSupport)
+	return nil, errorcode.BuildError(errorcode.SpaceNotExist)
 }
 
 // yaml config set config
@@ -28,9 +26,13 @@ func (manager *nacosConfigManagerMock) Set(spaceName, key string, value interfac
 }
 
 func (manager *nacosConfigManagerMock) Unmarshal(spaceName string, obj interface{}) error {
-	return errorcode.BuildError(errorcode.ConfigMethodNotSupport)
+	return errorcode.BuildError(errorcode.SpaceNotExist)
 }
 
 func (manager *nacosConfigManagerMock) Update() error {
 	return errorcode.BuildError(errorcode.ConfigMethodNotSupport)
 }
+
+func (manager *nacosConfigManagerMock) Watch(spaceName, key string, listener config.ChangeListener) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	// 监听配置的参数和返回值中，配置之间、字段之间的分隔符
+	nacosLineSeparator = "\x01"
+	nacosWordSeparator = "\x02"
+	// 监听配置没有变更时，最长等待时间
+	fakeNacosLongPollTimeout = 500 * time.Millisecond
+)
+
+// 本地模拟的 nacos 配置服务，只实现 nacos 客户端用到的配置 open api: 获取、发布、删除、监听配置
+// https://nacos.io/en-us/docs/open-api.html
+type fakeNacosServer struct {
+	server     *httptest.Server
+	lock       sync.Mutex
+	contentMap map[string]string
+	// 配置变更时关闭并重新创建，唤醒等待中的监听请求
+	changeChan chan struct{}
+}
+
+func newFakeNacosServer(t *testing.T) *fakeNacosServer {
+	server := &fakeNacosServer{contentMap: make(map[string]string), changeChan: make(chan struct{})}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/nacos/v1/cs/configs", server.handleConfig)
+	mux.HandleFunc("/nacos/v1/cs/configs/listener", server.handleListen)
+	mux.HandleFunc("/nacos/v1/auth/login", func(writer http.ResponseWriter, request *http.Request) {
+		fmt.Fprint(writer, `{"accessToken":"token","tokenTtl":18000,"globalAdmin":true}`)
+	})
+	server.server = httptest.NewServer(mux)
+	t.Cleanup(server.server.Close)
+	return server
+}
+
+// 服务地址
+func (server *fakeNacosServer) address() (string, int) {
+	addr := server.server.Listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+func getContentKey(group, dataId string) string {
+	return group + nacosWordSeparator + dataId
+}
+
+func getContentMD5(content string) string {
+	if content == "" {
+		return ""
+	}
+	sum := md5.Sum([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// 设置配置，内容为空表示删除
+func (server *fakeNacosServer) setConfig(group, dataId, content string) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	if content == "" {
+		delete(server.contentMap, getContentKey(group, dataId))
+	} else {
+		server.contentMap[getContentKey(group, dataId)] = content
+	}
+	close(server.changeChan)
+	server.changeChan = make(chan struct{})
+}
+
+func (server *fakeNacosServer) getConfig(group, dataId string) (string, bool) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	content, ok := server.contentMap[getContentKey(group, dataId)]
+	return content, ok
+}
+
+// 获取、发布、删除配置
+func (server *fakeNacosServer) handleConfig(writer http.ResponseWriter, request *http.Request) {
+	if err := request.ParseForm(); nil != err {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dataId, group := request.Form.Get("dataId"), request.Form.Get("group")
+	switch request.Method {
+	case http.MethodGet:
+		content, ok := server.getConfig(group, dataId)
+		if !ok {
+			http.Error(writer, "config data not exist", http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(writer, content)
+	case http.MethodPost:
+		server.setConfig(group, dataId, request.Form.Get("content"))
+		fmt.Fprint(writer, "true")
+	case http.MethodDelete:
+		server.setConfig(group, dataId, "")
+		fmt.Fprint(writer, "true")
+	default:
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// 长轮询监听配置: 参数为 dataId^2group^2md5[^2tenant]^1，返回 md5 不一致的配置
+func (server *fakeNacosServer) handleListen(writer http.ResponseWriter, request *http.Request) {
+	if err := request.ParseForm(); nil != err {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	listenArr := make([][]string, 0)
+	for _, line := range strings.Split(request.Form.Get("Listening-Configs"), nacosLineSeparator) {
+		if fieldArr := strings.Split(line, nacosWordSeparator); len(fieldArr) >= 3 {
+			listenArr = append(listenArr, fieldArr)
+		}
+	}
+
+	timeout := time.After(fakeNacosLongPollTimeout)
+	for {
+		server.lock.Lock()
+		changeChan := server.changeChan
+		server.lock.Unlock()
+
+		changed := new(strings.Builder)
+		for _, fieldArr := range listenArr {
+			content, _ := server.getConfig(fieldArr[1], fieldArr[0])
+			if getContentMD5(content) != fieldArr[2] {
+				changed.WriteString(strings.Join(append(fieldArr[:2:2], fieldArr[3:]...), nacosWordSeparator) + nacosLineSeparator)
+			}
+		}
+		if changed.Len() > 0 {
+			fmt.Fprint(writer, url.QueryEscape(changed.String()))
+			return
+		}
+
+		select {
+		case <-changeChan:
+		case <-timeout:
+			return
+		case <-request.Context().Done():
+			return
+		}
+	}
+}
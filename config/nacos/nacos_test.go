@@ -2,8 +2,13 @@ package config
 
 import (
 	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/smiecj/go_common/config"
 	yamlconfig "github.com/smiecj/go_common/config/yaml"
 	"github.com/smiecj/go_common/util/file"
 	"github.com/stretchr/testify/require"
@@ -23,3 +28,106 @@ func TestNacosConfig(t *testing.T) {
 	require.Nil(t, err)
 	require.Equal(t, "test_value", testValue)
 }
+
+type mysqlConfig struct {
+	Host string `yaml:"host" validate:"required"`
+	Port int    `yaml:"port" validate:"min=1,max=65535"`
+	User string `yaml:"user" validate:"required"`
+}
+
+// 使用本地模拟的 nacos 服务，测试配置空间对应 dataId
+func TestNacosConfigSpace(t *testing.T) {
+	server := newFakeNacosServer(t)
+	server.setConfig(defaultGroup, "mysql.yaml", "host: localhost\nport: 3306\nuser: root\n")
+	server.setConfig("cache", "redis", "host=redis\nport=6379\n")
+	server.setConfig("test_group", "test_key", "test_value")
+
+	host, port := server.address()
+	filePath := filepath.Join(t.TempDir(), "conf_nacos.yaml")
+	require.Empty(t, ioutil.WriteFile(filePath, []byte(fmt.Sprintf(`nacos:
+  host: %s
+  port: %d
+  cache_dir: %s
+  space:
+    mysql:
+      data_id: mysql.yaml
+    redis:
+      data_id: redis
+      group: cache
+      format: properties
+`, host, port, t.TempDir())), 0644))
+	yamlConfigManager, err := yamlconfig.GetYamlConfigManager(filePath)
+	require.Empty(t, err)
+	nacosConfigManager, err := GetNacosConfigManager(yamlConfigManager)
+	require.Empty(t, err)
+
+	spaceNameArr, err := nacosConfigManager.GetAllSpaceName()
+	require.Empty(t, err)
+	require.Equal(t, []string{"mysql", "redis"}, spaceNameArr)
+	mysqlPort, err := nacosConfigManager.Get("mysql", "port")
+	require.Empty(t, err)
+	require.Equal(t, 3306, mysqlPort)
+	redisSpace, err := nacosConfigManager.GetSpace("redis")
+	require.Empty(t, err)
+	keyArr, err := redisSpace.GetAllKey()
+	require.Empty(t, err)
+	require.Equal(t, []string{"host", "port"}, keyArr)
+	_, err = nacosConfigManager.GetSpace("not_exist")
+	require.NotEmpty(t, err)
+
+	// 可以用于组件的配置解析和校验
+	mysqlConf := mysqlConfig{}
+	require.Empty(t, config.UnmarshalAndValidate(nacosConfigManager, "mysql", &mysqlConf))
+	require.Equal(t, mysqlConfig{Host: "localhost", Port: 3306, User: "root"}, mysqlConf)
+	redisConf := mysqlConfig{}
+	require.Empty(t, redisSpace.Unmarshal(&redisConf))
+	require.Equal(t, mysqlConfig{Host: "redis", Port: 6379}, redisConf)
+
+	// 没有配置 dataId 的配置空间，保持 group + dataId 的用法
+	testValue, err := nacosConfigManager.Get("test_group", "test_key")
+	require.Empty(t, err)
+	require.Equal(t, "test_value", testValue)
+
+	// 监听配置空间变更
+	eventChan := make(chan config.ChangeEvent, 10)
+	cancel, err := config.Watch(nacosConfigManager, "mysql", "", func(event config.ChangeEvent) {
+		eventChan <- event
+	})
+	require.Empty(t, err)
+	defer cancel()
+	server.setConfig(defaultGroup, "mysql.yaml", "host: 127.0.0.1\nport: 3306\n")
+	eventArr := make([]config.ChangeEvent, 0)
+	for len(eventArr) < 2 {
+		select {
+		case event := <-eventChan:
+			eventArr = append(eventArr, event)
+		case <-time.After(10 * time.Second):
+			t.Fatal("wait nacos config change timeout")
+		}
+	}
+	require.Equal(t, []config.ChangeEvent{
+		{Space: "mysql", Key: "host", Type: config.ChangeModify, OldValue: "localhost", NewValue: "127.0.0.1"},
+		{Space: "mysql", Key: "user", Type: config.ChangeDelete, OldValue: "root"},
+	}, eventArr)
+
+	// Update 主动重新获取
+	server.setConfig("cache", "redis", "host=redis\nport=6380\n")
+	require.Empty(t, nacosConfigManager.Update())
+	redisPort, err := redisSpace.Get("port")
+	require.Empty(t, err)
+	require.Equal(t, "6380", redisPort)
+
+	// 开始监听时重新获取配置空间，获取配置空间之后的变更同步通知
+	server.setConfig("cache", "redis", "host=redis\nport=6381\n")
+	redisEventChan := make(chan config.ChangeEvent, 10)
+	redisCancel, err := config.Watch(nacosConfigManager, "redis", "port", func(event config.ChangeEvent) {
+		redisEventChan <- event
+	})
+	require.Empty(t, err)
+	defer redisCancel()
+	require.Equal(t, 1, len(redisEventChan))
+	require.Equal(t, config.ChangeEvent{Space: "redis", Key: "port", Type: config.ChangeModify, OldValue: "6380", NewValue: "6381"}, <-redisEventChan)
+	redisPort, err = redisSpace.Get("port")
+	require.Empty(t, err)
+	require.Equal(t, "6381", redisPort)
+}
@@ -0,0 +1,366 @@
+package overlay
+
+import (
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/smiecj/go_common/config"
+	"github.com/smiecj/go_common/errorcode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 覆盖配置: 在任意 config.Manager 之上，用环境变量和命令行参数覆盖配置
+// 优先级: 命令行参数 > 环境变量 > 原配置
+// 环境变量: [PREFIX_]SPACE_KEY，space 和 key 转成大写，非字母数字的字符转成 _，如 MYSQL_PASSWORD
+// 没有设置前缀时，环境变量只覆盖原配置中已存在的配置，避免 HTTP_PROXY、HOME 等无关的环境变量被当成配置
+// 命令行参数: --space.key=value 或 --space.key value，如 --monitor.port=9090
+
+var (
+	envNameRegexp = regexp.MustCompile(`[^A-Z0-9]`)
+)
+
+type overlayConf struct {
+	envPrefix string
+	envArr    []string
+	argArr    []string
+}
+
+type OverlayConfigFunc func(*overlayConf)
+
+// 设置环境变量前缀，如 APP -> APP_MYSQL_PASSWORD
+func OverlaySetEnvPrefix(prefix string) OverlayConfigFunc {
+	return func(conf *overlayConf) {
+		conf.envPrefix = prefix
+	}
+}
+
+// 设置环境变量，格式同 os.Environ，默认 os.Environ()，传 nil 表示不使用环境变量
+func OverlaySetEnv(envArr []string) OverlayConfigFunc {
+	return func(conf *overlayConf) {
+		conf.envArr = envArr
+	}
+}
+
+// 设置命令行参数，默认 os.Args[1:]，传 nil 表示不使用命令行参数
+func OverlaySetArgs(argArr []string) OverlayConfigFunc {
+	return func(conf *overlayConf) {
+		conf.argArr = argArr
+	}
+}
+
+// 覆盖配置管理
+type overlayManager struct {
+	manager config.Manager
+	// 环境变量: 去掉前缀之后的变量名 -> 值
+	envMap map[string]string
+	// 是否设置了环境变量前缀，没有前缀时环境变量只覆盖原配置中已存在的配置
+	hasEnvPrefix bool
+	// 命令行参数: space -> key -> 值
+	flagMap map[string]map[string]string
+}
+
+// 获取覆盖配置管理，环境变量和命令行参数在创建时读取
+func NewOverlayConfigManager(manager config.Manager, funcArr ...OverlayConfigFunc) config.Manager {
+	conf := overlayConf{envArr: os.Environ()}
+	if len(os.Args) > 1 {
+		conf.argArr = os.Args[1:]
+	}
+	for _, f := range funcArr {
+		f(&conf)
+	}
+
+	overlay := &overlayManager{manager: manager, envMap: make(map[string]string), flagMap: make(map[string]map[string]string)}
+	envPrefix := ""
+	if conf.envPrefix != "" {
+		envPrefix = toEnvName(conf.envPrefix) + "_"
+		overlay.hasEnvPrefix = true
+	}
+	for _, env := range conf.envArr {
+		splitIndex := strings.Index(env, "=")
+		if splitIndex <= 0 || !strings.HasPrefix(env[:splitIndex], envPrefix) {
+			continue
+		}
+		overlay.envMap[env[len(envPrefix):splitIndex]] = env[splitIndex+1:]
+	}
+	overlay.parseArgs(conf.argArr)
+	return overlay
+}
+
+// 解析命令行参数，只处理 --space.key=value 和 --space.key value 格式
+func (overlay *overlayManager) parseArgs(argArr []string) {
+	for index := 0; index < len(argArr); index++ {
+		arg := argArr[index]
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if arg == "--" {
+			return
+		}
+		name := strings.TrimLeft(arg, "-")
+		value, hasValue := "", false
+		if splitIndex := strings.Index(name, "="); splitIndex >= 0 {
+			name, value, hasValue = name[:splitIndex], name[splitIndex+1:], true
+		}
+		dotIndex := strings.Index(name, ".")
+		if dotIndex <= 0 || dotIndex == len(name)-1 {
+			continue
+		}
+		if !hasValue {
+			if index+1 >= len(argArr) || strings.HasPrefix(argArr[index+1], "-") {
+				continue
+			}
+			index++
+			value = argArr[index]
+		}
+		spaceName, key := name[:dotIndex], name[dotIndex+1:]
+		if nil == overlay.flagMap[spaceName] {
+			overlay.flagMap[spaceName] = make(map[string]string)
+		}
+		overlay.flagMap[spaceName][key] = value
+	}
+}
+
+// 获取单个配置的覆盖值，baseExist: 原配置中是否存在该配置
+func (overlay *overlayManager) lookup(spaceName, key string, baseExist bool) (string, bool) {
+	if value, ok := overlay.flagMap[spaceName][key]; ok {
+		return value, true
+	}
+	if !overlay.hasEnvPrefix && !baseExist {
+		return "", false
+	}
+	value, ok := overlay.envMap[toEnvName(spaceName)+"_"+toEnvName(key)]
+	return value, ok
+}
+
+// 获取配置空间的全部覆盖值，环境变量名优先匹配原配置中的 key，匹配不到时使用小写的变量名（没有前缀时忽略）
+func (overlay *overlayManager) overrideMap(spaceName string, keyArr []string) map[string]string {
+	keyMap := make(map[string]string, len(keyArr))
+	for _, key := range keyArr {
+		keyMap[toEnvName(key)] = key
+	}
+
+	retMap := make(map[string]string)
+	spacePrefix := toEnvName(spaceName) + "_"
+	for envName, value := range overlay.envMap {
+		if !strings.HasPrefix(envName, spacePrefix) || len(envName) == len(spacePrefix) {
+			continue
+		}
+		envKey := envName[len(spacePrefix):]
+		if key, ok := keyMap[envKey]; ok {
+			retMap[key] = value
+		} else if overlay.hasEnvPrefix {
+			retMap[strings.ToLower(envKey)] = value
+		}
+	}
+	for key, value := range overlay.flagMap[spaceName] {
+		retMap[key] = value
+	}
+	return retMap
+}
+
+// 获取配置，被覆盖的配置转换成原配置的类型
+func (overlay *overlayManager) Get(spaceName, key string) (interface{}, error) {
+	baseValue, err := overlay.manager.Get(spaceName, key)
+	value, ok := overlay.lookup(spaceName, key, nil == err)
+	if !ok {
+		return baseValue, err
+	}
+	if nil != err {
+		return value, nil
+	}
+	return convertValue(value, baseValue), nil
+}
+
+// 获取全部配置空间名，包括只在命令行参数中设置的配置空间
+func (overlay *overlayManager) GetAllSpaceName() ([]string, error) {
+	spaceNameArr, err := overlay.manager.GetAllSpaceName()
+	if nil != err {
+		return nil, err
+	}
+	spaceNameMap := make(map[string]bool, len(spaceNameArr))
+	for _, spaceName := range spaceNameArr {
+		spaceNameMap[spaceName] = true
+	}
+	for spaceName := range overlay.flagMap {
+		if !spaceNameMap[spaceName] {
+			spaceNameArr = append(spaceNameArr, spaceName)
+		}
+	}
+	return spaceNameArr, nil
+}
+
+// 获取配置空间，原配置中不存在的空间，有覆盖值时也能获取
+func (overlay *overlayManager) GetSpace(spaceName string) (config.Space, error) {
+	space, err := overlay.manager.GetSpace(spaceName)
+	if nil != err {
+		if len(overlay.overrideMap(spaceName, nil)) == 0 {
+			return nil, err
+		}
+		space = nil
+	}
+	return &overlaySpace{overlay: overlay, spaceName: spaceName, space: space}, nil
+}
+
+// 设置配置: 直接设置到原配置，有覆盖值时仍然以覆盖值为准
+func (overlay *overlayManager) Set(spaceName, key string, value interface{}) error {
+	return overlay.manager.Set(spaceName, key, value)
+}
+
+// 保存原配置，覆盖值不会保存
+func (overlay *overlayManager) Save() error {
+	return config.Save(overlay.manager)
+}
+
+// 解析配置: 先解析原配置，再解析覆盖值
+func (overlay *overlayManager) Unmarshal(spaceName string, obj interface{}) error {
+	var keyArr []string
+	space, err := overlay.manager.GetSpace(spaceName)
+	if nil == err {
+		keyArr, _ = space.GetAllKey()
+	}
+	overrideMap := overlay.overrideMap(spaceName, keyArr)
+	err = overlay.manager.Unmarshal(spaceName, obj)
+	if nil != err && len(overrideMap) == 0 {
+		return err
+	}
+	return unmarshalOverride(overrideMap, obj)
+}
+
+// 更新原配置
+func (overlay *overlayManager) Update() error {
+	return overlay.manager.Update()
+}
+
+// 监听原配置变更，被覆盖的配置变更不会通知
+func (overlay *overlayManager) Watch(spaceName, key string, listener config.ChangeListener) (func(), error) {
+	return config.Watch(overlay.manager, spaceName, key, func(event config.ChangeEvent) {
+		if _, ok := overlay.lookup(event.Space, event.Key, true); !ok {
+			listener(event)
+		}
+	})
+}
+
+// 覆盖配置空间
+type overlaySpace struct {
+	overlay   *overlayManager
+	spaceName string
+	// 原配置空间，可能为空
+	space config.Space
+}
+
+func (space *overlaySpace) Get(key string) (interface{}, error) {
+	var (
+		baseValue interface{}
+		err       = errorcode.BuildError(errorcode.ConfigNotExist)
+	)
+	if nil != space.space {
+		baseValue, err = space.space.Get(key)
+	}
+	value, ok := space.overlay.lookup(space.spaceName, key, nil == err)
+	if !ok {
+		return baseValue, err
+	}
+	if nil != err {
+		return value, nil
+	}
+	return convertValue(value, baseValue), nil
+}
+
+func (space *overlaySpace) GetAllKey() ([]string, error) {
+	var keyArr []string
+	if nil != space.space {
+		currentKeyArr, err := space.space.GetAllKey()
+		if nil != err {
+			return nil, err
+		}
+		keyArr = append(keyArr, currentKeyArr...)
+	}
+	keyMap := make(map[string]bool, len(keyArr))
+	for _, key := range keyArr {
+		keyMap[key] = true
+	}
+	overrideKeyArr := make([]string, 0)
+	for key := range space.overlay.overrideMap(space.spaceName, keyArr) {
+		if !keyMap[key] {
+			overrideKeyArr = append(overrideKeyArr, key)
+		}
+	}
+	sort.Strings(overrideKeyArr)
+	return append(keyArr, overrideKeyArr...), nil
+}
+
+func (space *overlaySpace) Set(key string, value interface{}) error {
+	if nil == space.space {
+		return errorcode.BuildError(errorcode.SpaceNotExist)
+	}
+	return space.space.Set(key, value)
+}
+
+func (space *overlaySpace) Unmarshal(obj interface{}) error {
+	var keyArr []string
+	if nil != space.space {
+		currentKeyArr, err := space.space.GetAllKey()
+		if nil != err {
+			return err
+		}
+		keyArr = currentKeyArr
+		if err = space.space.Unmarshal(obj); nil != err {
+			return err
+		}
+	}
+	return unmarshalOverride(space.overlay.overrideMap(space.spaceName, keyArr), obj)
+}
+
+// 解析覆盖值到对象: 覆盖值按照 yaml 标量解析，可以解析到数字、布尔类型的字段，字符串字段保持原样
+func unmarshalOverride(overrideMap map[string]string, obj interface{}) error {
+	if len(overrideMap) == 0 {
+		return nil
+	}
+	keyArr := make([]string, 0, len(overrideMap))
+	for key := range overrideMap {
+		keyArr = append(keyArr, key)
+	}
+	sort.Strings(keyArr)
+
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, key := range keyArr {
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key},
+			&yaml.Node{Kind: yaml.ScalarNode, Value: overrideMap[key]})
+	}
+	return node.Decode(obj)
+}
+
+// 覆盖值转换成原配置的类型，转换失败时返回字符串
+func convertValue(value string, baseValue interface{}) interface{} {
+	var (
+		ret interface{}
+		err error
+	)
+	switch baseValue.(type) {
+	case int:
+		ret, err = strconv.Atoi(value)
+	case int64:
+		ret, err = strconv.ParseInt(value, 10, 64)
+	case float64:
+		ret, err = strconv.ParseFloat(value, 64)
+	case bool:
+		ret, err = strconv.ParseBool(value)
+	case []interface{}, map[string]interface{}:
+		err = yaml.Unmarshal([]byte(value), &ret)
+	default:
+		return value
+	}
+	if nil != err {
+		return value
+	}
+	return ret
+}
+
+// 转换成环境变量名: 大写，非字母数字的字符转成 _
+func toEnvName(name string) string {
+	return envNameRegexp.ReplaceAllString(strings.ToUpper(name), "_")
+}
@@ -0,0 +1,107 @@
+package overlay
+
+import (
+	"testing"
+
+	yamlconfig "github.com/smiecj/go_common/config/yaml"
+	"github.com/smiecj/go_common/util/file"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	exampleConfigFile = "conf_example.yaml"
+)
+
+type mysqlConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Timeout  int    `yaml:"timeout"`
+}
+
+// 测试环境变量和命令行参数覆盖配置
+func TestOverlayConfig(t *testing.T) {
+	yamlConfigManager, err := yamlconfig.GetYamlConfigManager(file.FindFilePath(exampleConfigFile))
+	require.Empty(t, err)
+	manager := NewOverlayConfigManager(yamlConfigManager, OverlaySetEnvPrefix("app"),
+		OverlaySetEnv([]string{"APP_MYSQL_PASSWORD=0123", "APP_MYSQL_PORT=3307", "APP_MYSQL_TIMEOUT=10",
+			"APP_HTTP_MAX_IDLE=abc", "MYSQL_USER=ignored", "APP_REDIS_HOST=redis"}),
+		OverlaySetArgs([]string{"-v", "--mysql.port=3308", "--monitor.port", "9090", "--kafka.broker=localhost:9092", "--", "--mysql.user=ignored"}))
+
+	// 获取配置: 命令行参数 > 环境变量 > 原配置，覆盖值转换成原配置的类型
+	password, err := manager.Get("mysql", "password")
+	require.Empty(t, err)
+	require.Equal(t, "0123", password)
+	port, err := manager.Get("mysql", "port")
+	require.Empty(t, err)
+	require.Equal(t, 3308, port)
+	port, err = manager.Get("monitor", "port")
+	require.Empty(t, err)
+	require.Equal(t, 9090, port)
+	user, err := manager.Get("mysql", "user")
+	require.Empty(t, err)
+	require.Equal(t, "root", user)
+	maxIdle, err := manager.Get("http", "max_idle")
+	require.Empty(t, err)
+	require.Equal(t, "abc", maxIdle)
+	broker, err := manager.Get("kafka", "broker")
+	require.Empty(t, err)
+	require.Equal(t, "localhost:9092", broker)
+
+	// 解析配置
+	conf := mysqlConfig{}
+	require.Empty(t, manager.Unmarshal("mysql", &conf))
+	require.Equal(t, mysqlConfig{Host: "localhost", Port: 3308, User: "root", Password: "0123", Timeout: 10}, conf)
+	conf = mysqlConfig{}
+	mysqlSpace, err := manager.GetSpace("mysql")
+	require.Empty(t, err)
+	require.Empty(t, mysqlSpace.Unmarshal(&conf))
+	require.Equal(t, 3308, conf.Port)
+	keyArr, err := mysqlSpace.GetAllKey()
+	require.Empty(t, err)
+	require.Contains(t, keyArr, "timeout")
+
+	// 只存在于覆盖值中的配置空间
+	redisSpace, err := manager.GetSpace("redis")
+	require.Empty(t, err)
+	host, err := redisSpace.Get("host")
+	require.Empty(t, err)
+	require.Equal(t, "redis", host)
+	spaceNameArr, err := manager.GetAllSpaceName()
+	require.Empty(t, err)
+	require.Contains(t, spaceNameArr, "kafka")
+	_, err = manager.GetSpace("not_exist")
+	require.NotEmpty(t, err)
+}
+
+// 测试没有前缀时，环境变量只覆盖原配置中已存在的配置
+func TestOverlayConfigWithoutPrefix(t *testing.T) {
+	yamlConfigManager, err := yamlconfig.GetYamlConfigManager(file.FindFilePath(exampleConfigFile))
+	require.Empty(t, err)
+	manager := NewOverlayConfigManager(yamlConfigManager,
+		OverlaySetEnv([]string{"MYSQL_PASSWORD=0123", "MYSQL_CHARSET=utf8", "HTTP_PROXY=localhost:8080", "HOME=/root"}),
+		OverlaySetArgs(nil))
+
+	password, err := manager.Get("mysql", "password")
+	require.Empty(t, err)
+	require.Equal(t, "0123", password)
+	_, err = manager.Get("mysql", "charset")
+	require.NotEmpty(t, err)
+	_, err = manager.Get("http", "proxy")
+	require.NotEmpty(t, err)
+
+	mysqlSpace, err := manager.GetSpace("mysql")
+	require.Empty(t, err)
+	keyArr, err := mysqlSpace.GetAllKey()
+	require.Empty(t, err)
+	require.NotContains(t, keyArr, "charset")
+	_, err = mysqlSpace.Get("charset")
+	require.NotEmpty(t, err)
+	_, err = manager.GetSpace("home")
+	require.NotEmpty(t, err)
+
+	conf := mysqlConfig{}
+	require.Empty(t, manager.Unmarshal("mysql", &conf))
+	require.Equal(t, "0123", conf.Password)
+}
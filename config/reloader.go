@@ -0,0 +1,129 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/smiecj/go_common/util/log"
+)
+
+// 配置文件加载方法: 解析文件内容并替换配置，返回配置变更事件
+type FileLoadFunc func(content []byte) ([]ChangeEvent, error)
+
+// 本地配置文件重新加载器，供 yaml、json 等本地文件配置复用
+// 监听配置变更: 第一次监听时开始定期检查文件修改时间和大小，有变化时重新加载，所有监听都取消后停止检查
+type FileReloader struct {
+	filePath string
+	interval time.Duration
+	loadFunc FileLoadFunc
+	notifier *Notifier
+
+	// 加载、保存文件和检查文件状态时加锁
+	lock       sync.Mutex
+	isWatching bool
+	modTime    time.Time
+	fileSize   int64
+}
+
+// 创建配置文件重新加载器
+func NewFileReloader(filePath string, interval time.Duration, loadFunc FileLoadFunc) *FileReloader {
+	return &FileReloader{filePath: filePath, interval: interval, loadFunc: loadFunc, notifier: NewNotifier()}
+}
+
+// 读取并加载配置文件，加载成功后记录文件修改时间和大小，不通知配置变更
+func (reloader *FileReloader) Load() ([]ChangeEvent, error) {
+	reloader.lock.Lock()
+	defer reloader.lock.Unlock()
+	return reloader.load()
+}
+
+// 调用方需要先加锁
+func (reloader *FileReloader) load() ([]ChangeEvent, error) {
+	file, err := os.Open(reloader.filePath)
+	if nil != err {
+		return nil, err
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if nil != err {
+		return nil, err
+	}
+	content, err := ioutil.ReadAll(file)
+	if nil != err {
+		return nil, err
+	}
+	eventArr, err := reloader.loadFunc(content)
+	if nil != err {
+		return nil, err
+	}
+	reloader.modTime, reloader.fileSize = fileInfo.ModTime(), fileInfo.Size()
+	return eventArr, nil
+}
+
+// 重新加载配置文件，并通知配置变更
+func (reloader *FileReloader) Update() error {
+	eventArr, err := reloader.Load()
+	if nil != err {
+		return err
+	}
+	// 不持有锁回调，回调中可以调用 Update
+	reloader.notifier.Notify(eventArr...)
+	return nil
+}
+
+// 写入配置文件，写入之后记录文件状态，避免监听时重新加载
+func (reloader *FileReloader) Save(writeFunc func(filePath string) error) error {
+	reloader.lock.Lock()
+	defer reloader.lock.Unlock()
+
+	if err := writeFunc(reloader.filePath); nil != err {
+		return err
+	}
+	fileInfo, err := os.Stat(reloader.filePath)
+	if nil == err {
+		reloader.modTime, reloader.fileSize = fileInfo.ModTime(), fileInfo.Size()
+	}
+	return nil
+}
+
+// 监听配置变更，返回取消监听的方法
+func (reloader *FileReloader) Watch(spaceName, key string, listener ChangeListener) (func(), error) {
+	cancel := reloader.notifier.Add(spaceName, key, listener)
+
+	reloader.lock.Lock()
+	defer reloader.lock.Unlock()
+	if !reloader.isWatching {
+		reloader.isWatching = true
+		go reloader.watch()
+	}
+	return cancel, nil
+}
+
+// 定期检查配置文件是否有变化
+func (reloader *FileReloader) watch() {
+	ticker := time.NewTicker(reloader.interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		reloader.lock.Lock()
+		if reloader.notifier.Count("", "") == 0 {
+			reloader.isWatching = false
+			reloader.lock.Unlock()
+			return
+		}
+		var eventArr []ChangeEvent
+		fileInfo, err := os.Stat(reloader.filePath)
+		if nil != err {
+			log.Warn("[FileReloader.watch] stat config file %s failed: %s", reloader.filePath, err.Error())
+		} else if !fileInfo.ModTime().Equal(reloader.modTime) || fileInfo.Size() != reloader.fileSize {
+			eventArr, err = reloader.load()
+			if nil != err {
+				log.Warn("[FileReloader.watch] reload config file %s failed: %s", reloader.filePath, err.Error())
+			}
+		}
+		reloader.lock.Unlock()
+		reloader.notifier.Notify(eventArr...)
+	}
+}
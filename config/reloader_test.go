@@ -0,0 +1,57 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// 测试配置文件重新加载: 加载、保存之后不重复加载、监听文件变更
+func TestFileReloader(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "conf")
+	require.Empty(t, ioutil.WriteFile(filePath, []byte("localhost"), 0644))
+
+	space := NewMapSpace(nil)
+	reloader := NewFileReloader(filePath, 10*time.Millisecond, func(content []byte) ([]ChangeEvent, error) {
+		configMap := map[string]interface{}{"host": strings.TrimSpace(string(content))}
+		return DiffSpace("mysql", space.Replace(configMap), configMap), nil
+	})
+	eventArr, err := reloader.Load()
+	require.Empty(t, err)
+	require.Len(t, eventArr, 1)
+
+	eventChan := make(chan ChangeEvent, 10)
+	cancel, err := reloader.Watch("mysql", "host", func(event ChangeEvent) {
+		eventChan <- event
+	})
+	require.Empty(t, err)
+	defer cancel()
+
+	// 保存之后记录文件状态，不会重新加载
+	require.Empty(t, reloader.Save(func(filePath string) error {
+		return ioutil.WriteFile(filePath, []byte("127.0.0.1"), 0644)
+	}))
+	time.Sleep(50 * time.Millisecond)
+	require.Empty(t, eventChan)
+	host, err := space.Get("host")
+	require.Empty(t, err)
+	require.Equal(t, "localhost", host)
+
+	// 文件变更之后重新加载并通知
+	require.Empty(t, ioutil.WriteFile(filePath, []byte("127.0.0.2"), 0644))
+	select {
+	case event := <-eventChan:
+		require.Equal(t, ChangeEvent{Space: "mysql", Key: "host", Type: ChangeModify, OldValue: "localhost", NewValue: "127.0.0.2"}, event)
+	case <-time.After(3 * time.Second):
+		t.Fatal("wait config change timeout")
+	}
+
+	// Update 手动重新加载
+	require.Empty(t, ioutil.WriteFile(filePath, []byte("127.0.0.3"), 0644))
+	require.Empty(t, reloader.Update())
+	require.Equal(t, "127.0.0.3", (<-eventChan).NewValue)
+}
@@ -0,0 +1,281 @@
+package secret
+
+import (
+	"os"
+	"reflect"
+
+	"github.com/smiecj/go_common/config"
+	"github.com/smiecj/go_common/errorcode"
+	"github.com/smiecj/go_common/util/log"
+)
+
+// 加密配置管理: 在任意 config.Manager 之上，Get、GetSpace、Unmarshal 时自动解密 ENC(...) 格式的配置值
+// 密钥优先级: SecretSetKey > SecretSetKeyFile > 环境变量（默认 CONFIG_SECRET_KEY）
+
+type secretConf struct {
+	key       []byte
+	keyFile   string
+	keyEnv    string
+	oldKeyArr [][]byte
+}
+
+type SecretConfigFunc func(*secretConf)
+
+// 直接设置密钥
+func SecretSetKey(key []byte) SecretConfigFunc {
+	return func(conf *secretConf) {
+		conf.key = key
+	}
+}
+
+// 设置密钥文件，文件内容为 base64 编码的密钥
+func SecretSetKeyFile(filePath string) SecretConfigFunc {
+	return func(conf *secretConf) {
+		conf.keyFile = filePath
+	}
+}
+
+// 设置读取密钥的环境变量，环境变量的值为 base64 编码的密钥
+func SecretSetKeyEnv(name string) SecretConfigFunc {
+	return func(conf *secretConf) {
+		conf.keyEnv = name
+	}
+}
+
+// 添加旧密钥: 轮换密钥期间，旧密钥加密的配置仍然可以解密
+func SecretAddOldKey(key []byte) SecretConfigFunc {
+	return func(conf *secretConf) {
+		conf.oldKeyArr = append(conf.oldKeyArr, key)
+	}
+}
+
+// 加密配置管理
+type secretManager struct {
+	manager config.Manager
+	// 解密时依次尝试: 当前密钥、旧密钥
+	keyArr [][]byte
+}
+
+// 获取加密配置管理，密钥没有设置，或者密钥、旧密钥不合法时返回错误
+func NewSecretConfigManager(manager config.Manager, funcArr ...SecretConfigFunc) (config.Manager, error) {
+	conf := secretConf{keyEnv: DefaultKeyEnv}
+	for _, f := range funcArr {
+		f(&conf)
+	}
+
+	key, err := loadKey(conf)
+	if nil != err {
+		log.Error("[NewSecretConfigManager] load secret key failed: %s", err.Error())
+		return nil, err
+	}
+	return &secretManager{manager: manager, keyArr: append([][]byte{key}, conf.oldKeyArr...)}, nil
+}
+
+// 按优先级读取密钥，同时检查旧密钥是否合法
+func loadKey(conf secretConf) ([]byte, error) {
+	for _, oldKey := range conf.oldKeyArr {
+		if _, err := newAEAD(oldKey); nil != err {
+			return nil, err
+		}
+	}
+	if len(conf.key) != 0 {
+		if _, err := newAEAD(conf.key); nil != err {
+			return nil, err
+		}
+		return conf.key, nil
+	}
+	if conf.keyFile != "" {
+		return ReadKeyFile(conf.keyFile)
+	}
+	keyStr := os.Getenv(conf.keyEnv)
+	if keyStr == "" {
+		return nil, errorcode.BuildErrorWithMsg(errorcode.ConfigParamInvalid, "secret key is not set, env: "+conf.keyEnv)
+	}
+	return ParseKey(keyStr)
+}
+
+func (manager *secretManager) Get(spaceName, key string) (interface{}, error) {
+	value, err := manager.manager.Get(spaceName, key)
+	if nil != err {
+		return value, err
+	}
+	return manager.decryptValue(value)
+}
+
+func (manager *secretManager) GetAllSpaceName() ([]string, error) {
+	return manager.manager.GetAllSpaceName()
+}
+
+func (manager *secretManager) GetSpace(spaceName string) (config.Space, error) {
+	space, err := manager.manager.GetSpace(spaceName)
+	if nil != err {
+		return nil, err
+	}
+	return &secretSpace{manager: manager, space: space}, nil
+}
+
+// 设置配置: 原样设置，需要加密的配置可以先通过 Encrypt 加密
+func (manager *secretManager) Set(spaceName, key string, value interface{}) error {
+	return manager.manager.Set(spaceName, key, value)
+}
+
+// 保存原配置，加密的配置保持加密格式
+func (manager *secretManager) Save() error {
+	return config.Save(manager.manager)
+}
+
+func (manager *secretManager) Unmarshal(spaceName string, obj interface{}) error {
+	if err := manager.manager.Unmarshal(spaceName, obj); nil != err {
+		return err
+	}
+	return manager.decryptObject(reflect.ValueOf(obj))
+}
+
+func (manager *secretManager) Update() error {
+	return manager.manager.Update()
+}
+
+// 监听原配置变更，通知的配置值同样解密，解密失败时保留原值
+func (manager *secretManager) Watch(spaceName, key string, listener config.ChangeListener) (func(), error) {
+	return config.Watch(manager.manager, spaceName, key, func(event config.ChangeEvent) {
+		var err error
+		if event.OldValue, err = manager.decryptOrKeep(event.OldValue); nil != err {
+			log.Warn("[secretManager.Watch] decrypt old value failed, space: %s, key: %s, err: %s", event.Space, event.Key, err.Error())
+		}
+		if event.NewValue, err = manager.decryptOrKeep(event.NewValue); nil != err {
+			log.Warn("[secretManager.Watch] decrypt new value failed, space: %s, key: %s, err: %s", event.Space, event.Key, err.Error())
+		}
+		listener(event)
+	})
+}
+
+func (manager *secretManager) decryptOrKeep(value interface{}) (interface{}, error) {
+	decrypted, err := manager.decryptValue(value)
+	if nil != err {
+		return value, err
+	}
+	return decrypted, nil
+}
+
+// 解密配置值，map 和数组返回解密后的副本，不修改原配置
+func (manager *secretManager) decryptValue(value interface{}) (interface{}, error) {
+	switch typedValue := value.(type) {
+	case string:
+		return Decrypt(typedValue, manager.keyArr...)
+	case []interface{}:
+		retArr := make([]interface{}, len(typedValue))
+		for index, currentValue := range typedValue {
+			decrypted, err := manager.decryptValue(currentValue)
+			if nil != err {
+				return nil, err
+			}
+			retArr[index] = decrypted
+		}
+		return retArr, nil
+	case map[string]interface{}:
+		retMap := make(map[string]interface{}, len(typedValue))
+		for key, currentValue := range typedValue {
+			decrypted, err := manager.decryptValue(currentValue)
+			if nil != err {
+				return nil, err
+			}
+			retMap[key] = decrypted
+		}
+		return retMap, nil
+	case map[interface{}]interface{}:
+		retMap := make(map[interface{}]interface{}, len(typedValue))
+		for key, currentValue := range typedValue {
+			decrypted, err := manager.decryptValue(currentValue)
+			if nil != err {
+				return nil, err
+			}
+			retMap[key] = decrypted
+		}
+		return retMap, nil
+	}
+	return value, nil
+}
+
+// 解密已经解析的对象中所有 ENC(...) 格式的字符串
+func (manager *secretManager) decryptObject(value reflect.Value) error {
+	switch value.Kind() {
+	case reflect.Ptr:
+		if !value.IsNil() {
+			return manager.decryptObject(value.Elem())
+		}
+	case reflect.Interface:
+		if value.IsNil() || !value.CanSet() {
+			return nil
+		}
+		decrypted, err := manager.decryptValue(value.Elem().Interface())
+		if nil != err {
+			return err
+		}
+		value.Set(reflect.ValueOf(decrypted))
+	case reflect.String:
+		if !value.CanSet() || !IsEncrypted(value.String()) {
+			return nil
+		}
+		decrypted, err := Decrypt(value.String(), manager.keyArr...)
+		if nil != err {
+			return err
+		}
+		value.SetString(decrypted)
+	case reflect.Struct:
+		for index := 0; index < value.NumField(); index++ {
+			if value.Type().Field(index).PkgPath != "" {
+				continue
+			}
+			if err := manager.decryptObject(value.Field(index)); nil != err {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for index := 0; index < value.Len(); index++ {
+			if err := manager.decryptObject(value.Index(index)); nil != err {
+				return err
+			}
+		}
+	case reflect.Map:
+		// map 的值不能直接修改，复制之后解密再设置回去
+		iter := value.MapRange()
+		for iter.Next() {
+			currentValue := reflect.New(value.Type().Elem()).Elem()
+			currentValue.Set(iter.Value())
+			if err := manager.decryptObject(currentValue); nil != err {
+				return err
+			}
+			value.SetMapIndex(iter.Key(), currentValue)
+		}
+	}
+	return nil
+}
+
+// 加密配置空间
+type secretSpace struct {
+	manager *secretManager
+	space   config.Space
+}
+
+func (space *secretSpace) Get(key string) (interface{}, error) {
+	value, err := space.space.Get(key)
+	if nil != err {
+		return value, err
+	}
+	return space.manager.decryptValue(value)
+}
+
+func (space *secretSpace) GetAllKey() ([]string, error) {
+	return space.space.GetAllKey()
+}
+
+func (space *secretSpace) Set(key string, value interface{}) error {
+	return space.space.Set(key, value)
+}
+
+func (space *secretSpace) Unmarshal(obj interface{}) error {
+	if err := space.space.Unmarshal(obj); nil != err {
+		return err
+	}
+	return space.manager.decryptObject(reflect.ValueOf(obj))
+}
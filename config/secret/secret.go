@@ -0,0 +1,152 @@
+// package secret 配置加密: 配置值使用 AES-GCM 加密后以 ENC(...) 格式保存，读取配置时自动解密
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/smiecj/go_common/errorcode"
+)
+
+const (
+	// 默认读取密钥的环境变量
+	DefaultKeyEnv = "CONFIG_SECRET_KEY"
+
+	encryptedPrefix = "ENC("
+	encryptedSuffix = ")"
+	// 默认生成 AES-256 密钥
+	defaultKeyLength = 32
+)
+
+var (
+	encryptedRegexp = regexp.MustCompile(`ENC\(([A-Za-z0-9+/=]*)\)`)
+)
+
+// 生成随机密钥，返回 base64 编码
+func GenerateKey() (string, error) {
+	key := make([]byte, defaultKeyLength)
+	if _, err := io.ReadFull(rand.Reader, key); nil != err {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// 解析 base64 编码的密钥，长度必须是 16、24 或 32 字节
+func ParseKey(keyStr string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(keyStr))
+	if nil != err {
+		return nil, errorcode.BuildErrorWithMsg(errorcode.ConfigParamInvalid, "secret key is not base64: "+err.Error())
+	}
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	}
+	return nil, errorcode.BuildErrorWithMsg(errorcode.ConfigParamInvalid, "secret key length must be 16, 24 or 32 bytes")
+}
+
+// 从文件读取密钥，文件内容为 base64 编码的密钥
+func ReadKeyFile(filePath string) ([]byte, error) {
+	content, err := ioutil.ReadFile(filePath)
+	if nil != err {
+		return nil, err
+	}
+	return ParseKey(string(content))
+}
+
+// 判断配置值是否为加密格式: ENC(...)
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix) && strings.HasSuffix(value, encryptedSuffix)
+}
+
+// 加密配置值，返回 ENC(base64(nonce + 密文))
+func Encrypt(key []byte, value string) (string, error) {
+	aead, err := newAEAD(key)
+	if nil != err {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); nil != err {
+		return "", err
+	}
+	encrypted := aead.Seal(nonce, nonce, []byte(value), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(encrypted) + encryptedSuffix, nil
+}
+
+// 解密 ENC(...) 格式的配置值，依次尝试传入的密钥，不是加密格式时原样返回
+func Decrypt(value string, keyArr ...[]byte) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	encrypted, err := base64.StdEncoding.DecodeString(value[len(encryptedPrefix) : len(value)-len(encryptedSuffix)])
+	if nil != err {
+		return "", errorcode.BuildErrorWithMsg(errorcode.ConfigDecryptFailed, "encrypted value is not base64")
+	}
+
+	// 不合法的密钥跳过，继续尝试其他密钥，所有密钥都不合法时返回密钥错误
+	var (
+		keyErr      error
+		hasValidKey bool
+	)
+	for _, key := range keyArr {
+		aead, err := newAEAD(key)
+		if nil != err {
+			keyErr = err
+			continue
+		}
+		hasValidKey = true
+		nonceSize := aead.NonceSize()
+		if len(encrypted) < nonceSize {
+			break
+		}
+		plain, err := aead.Open(nil, encrypted[:nonceSize], encrypted[nonceSize:], nil)
+		if nil == err {
+			return string(plain), nil
+		}
+	}
+	if !hasValidKey && nil != keyErr {
+		return "", keyErr
+	}
+	return "", errorcode.BuildErrorWithMsg(errorcode.ConfigDecryptFailed, "decrypt failed, please check secret key")
+}
+
+// 使用新密钥重新加密内容中所有 ENC(...) 格式的配置值，其他内容保持不变，返回替换的个数
+func RotateContent(content []byte, oldKey, newKey []byte) ([]byte, int, error) {
+	var (
+		count     int
+		rotateErr error
+	)
+	ret := encryptedRegexp.ReplaceAllFunc(content, func(encrypted []byte) []byte {
+		if nil != rotateErr {
+			return encrypted
+		}
+		plain, err := Decrypt(string(encrypted), oldKey)
+		if nil == err {
+			var rotated string
+			rotated, err = Encrypt(newKey, plain)
+			if nil == err {
+				count++
+				return []byte(rotated)
+			}
+		}
+		rotateErr = err
+		return encrypted
+	})
+	if nil != rotateErr {
+		return nil, 0, rotateErr
+	}
+	return ret, count, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if nil != err {
+		return nil, errorcode.BuildErrorWithMsg(errorcode.ConfigParamInvalid, "secret key is invalid: "+err.Error())
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,150 @@
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/smiecj/go_common/config"
+	yamlconfig "github.com/smiecj/go_common/config/yaml"
+	"github.com/smiecj/go_common/errorcode"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testPassword = "mysql password"
+	testToken    = "smtp token"
+)
+
+type mysqlConfig struct {
+	Host     string            `yaml:"host"`
+	Password string            `yaml:"password"`
+	Extra    map[string]string `yaml:"extra"`
+}
+
+func getTestKey(t *testing.T) []byte {
+	keyStr, err := GenerateKey()
+	require.Empty(t, err)
+	key, err := ParseKey(keyStr)
+	require.Empty(t, err)
+	return key
+}
+
+// 测试加密、解密
+func TestEncrypt(t *testing.T) {
+	key := getTestKey(t)
+	encrypted, err := Encrypt(key, testPassword)
+	require.Empty(t, err)
+	require.True(t, IsEncrypted(encrypted))
+	require.NotContains(t, encrypted, testPassword)
+
+	plain, err := Decrypt(encrypted, key)
+	require.Empty(t, err)
+	require.Equal(t, testPassword, plain)
+	plain, err = Decrypt(testPassword, key)
+	require.Empty(t, err)
+	require.Equal(t, testPassword, plain)
+
+	// 密钥不匹配
+	_, err = Decrypt(encrypted, getTestKey(t))
+	require.True(t, errors.Is(err, errorcode.BuildError(errorcode.ConfigDecryptFailed)))
+	_, err = ParseKey("c2hvcnQ=")
+	require.NotEmpty(t, err)
+
+	// 不合法的密钥跳过，继续尝试其他密钥
+	plain, err = Decrypt(encrypted, []byte("short"), key)
+	require.Empty(t, err)
+	require.Equal(t, testPassword, plain)
+	_, err = Decrypt(encrypted, []byte("short"))
+	require.True(t, errors.Is(err, errorcode.BuildError(errorcode.ConfigParamInvalid)))
+}
+
+// 测试读取配置时自动解密
+func TestSecretConfig(t *testing.T) {
+	key := getTestKey(t)
+	oldKey := getTestKey(t)
+	encryptedPassword, err := Encrypt(key, testPassword)
+	require.Empty(t, err)
+	encryptedToken, err := Encrypt(oldKey, testToken)
+	require.Empty(t, err)
+
+	filePath := filepath.Join(t.TempDir(), "conf_secret.yaml")
+	require.Empty(t, ioutil.WriteFile(filePath, []byte(fmt.Sprintf(
+		"mysql:\n  host: localhost\n  password: %s\n  extra:\n    token: %s\nmail:\n  token: %s\n",
+		encryptedPassword, encryptedToken, encryptedToken)), 0644))
+	yamlManager, err := yamlconfig.GetYamlConfigManager(filePath)
+	require.Empty(t, err)
+
+	_, err = NewSecretConfigManager(yamlManager, SecretSetKeyEnv("NOT_EXIST_SECRET_KEY"))
+	require.NotEmpty(t, err)
+	keyFilePath := filepath.Join(t.TempDir(), "secret.key")
+	require.Empty(t, ioutil.WriteFile(keyFilePath, []byte("invalid key\n"), 0600))
+	_, err = NewSecretConfigManager(yamlManager, SecretSetKeyFile(keyFilePath))
+	require.NotEmpty(t, err)
+
+	_, err = NewSecretConfigManager(yamlManager, SecretSetKey(key), SecretAddOldKey([]byte("short")))
+	require.True(t, errors.Is(err, errorcode.BuildError(errorcode.ConfigParamInvalid)))
+
+	manager, err := NewSecretConfigManager(yamlManager, SecretSetKey(key), SecretAddOldKey(oldKey))
+	require.Empty(t, err)
+	password, err := manager.Get("mysql", "password")
+	require.Empty(t, err)
+	require.Equal(t, testPassword, password)
+	extra, err := manager.Get("mysql", "extra")
+	require.Empty(t, err)
+	require.Equal(t, map[string]interface{}{"token": testToken}, extra)
+
+	mysqlConf := mysqlConfig{}
+	require.Empty(t, manager.Unmarshal("mysql", &mysqlConf))
+	require.Equal(t, mysqlConfig{Host: "localhost", Password: testPassword, Extra: map[string]string{"token": testToken}}, mysqlConf)
+
+	mailSpace, err := manager.GetSpace("mail")
+	require.Empty(t, err)
+	token, err := mailSpace.Get("token")
+	require.Empty(t, err)
+	require.Equal(t, testToken, token)
+	mailConf := map[string]interface{}{}
+	require.Empty(t, mailSpace.Unmarshal(&mailConf))
+	require.Equal(t, map[string]interface{}{"token": testToken}, mailConf)
+
+	// 原配置不变
+	rawPassword, err := yamlManager.Get("mysql", "password")
+	require.Empty(t, err)
+	require.Equal(t, encryptedPassword, rawPassword)
+
+	// 缺少旧密钥时解密失败
+	manager, err = NewSecretConfigManager(yamlManager, SecretSetKey(key))
+	require.Empty(t, err)
+	_, err = manager.Get("mail", "token")
+	require.NotEmpty(t, err)
+	require.NotEmpty(t, manager.Unmarshal("mysql", &mysqlConfig{}))
+	_, err = config.Dump(manager)
+	require.NotEmpty(t, err)
+}
+
+// 测试轮换密钥，加密值以外的内容保持不变
+func TestRotateContent(t *testing.T) {
+	oldKey := getTestKey(t)
+	newKey := getTestKey(t)
+	encryptedPassword, err := Encrypt(oldKey, testPassword)
+	require.Empty(t, err)
+	content := "# mysql config\nmysql:\n  password: " + encryptedPassword + " # encrypted\n  user: root\n"
+
+	rotated, count, err := RotateContent([]byte(content), oldKey, newKey)
+	require.Empty(t, err)
+	require.Equal(t, 1, count)
+	require.NotContains(t, string(rotated), encryptedPassword)
+	require.True(t, strings.HasPrefix(string(rotated), "# mysql config\nmysql:\n  password: ENC("))
+	require.True(t, strings.HasSuffix(string(rotated), ") # encrypted\n  user: root\n"))
+
+	rotatedPassword := strings.Fields(strings.Split(string(rotated), "password: ")[1])[0]
+	plain, err := Decrypt(rotatedPassword, newKey)
+	require.Empty(t, err)
+	require.Equal(t, testPassword, plain)
+
+	_, _, err = RotateContent([]byte(content), newKey, oldKey)
+	require.NotEmpty(t, err)
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/smiecj/go_common/errorcode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 基于 map 的配置空间，供各配置中心复用
+type MapSpace struct {
+	configMap map[string]interface{}
+	mapLock   sync.RWMutex
+}
+
+// 创建基于 map 的配置空间
+func NewMapSpace(configMap map[string]interface{}) *MapSpace {
+	if nil == configMap {
+		configMap = make(map[string]interface{})
+	}
+	return &MapSpace{configMap: configMap}
+}
+
+// 获取配置
+func (space *MapSpace) Get(key string) (interface{}, error) {
+	space.mapLock.RLock()
+	defer space.mapLock.RUnlock()
+
+	ret, ok := space.configMap[key]
+	if !ok {
+		return "", errorcode.BuildError(errorcode.ConfigNotExist)
+	}
+	return ret, nil
+}
+
+// 获取全部 key，按字母排序
+func (space *MapSpace) GetAllKey() ([]string, error) {
+	space.mapLock.RLock()
+	defer space.mapLock.RUnlock()
+
+	retArr := make([]string, 0, len(space.configMap))
+	for key := range space.configMap {
+		retArr = append(retArr, key)
+	}
+	sort.Strings(retArr)
+	return retArr, nil
+}
+
+// 设置配置
+func (space *MapSpace) Set(key string, value interface{}) error {
+	space.mapLock.Lock()
+	defer space.mapLock.Unlock()
+
+	space.configMap[key] = value
+	return nil
+}
+
+// 解析配置，对象字段使用 yaml tag
+// 字符串按照 yaml 标量解析，可以解析到数字、布尔类型的字段，用于 properties 等没有类型的配置
+func (space *MapSpace) Unmarshal(obj interface{}) error {
+	space.mapLock.RLock()
+	node, err := toNode(space.configMap)
+	space.mapLock.RUnlock()
+	if nil != err {
+		return err
+	}
+	return node.Decode(obj)
+}
+
+// 替换全部配置，返回替换前的配置
+func (space *MapSpace) Replace(configMap map[string]interface{}) map[string]interface{} {
+	if nil == configMap {
+		configMap = make(map[string]interface{})
+	}
+	space.mapLock.Lock()
+	defer space.mapLock.Unlock()
+
+	oldConfigMap := space.configMap
+	space.configMap = configMap
+	return oldConfigMap
+}
+
+// 配置转换成 yaml 节点，字符串转换成不带类型的标量
+func toNode(value interface{}) (*yaml.Node, error) {
+	switch typedValue := value.(type) {
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: typedValue}, nil
+	case map[string]interface{}:
+		keyArr := make([]string, 0, len(typedValue))
+		for key := range typedValue {
+			keyArr = append(keyArr, key)
+		}
+		sort.Strings(keyArr)
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for _, key := range keyArr {
+			valueNode, err := toNode(typedValue[key])
+			if nil != err {
+				return nil, err
+			}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, valueNode)
+		}
+		return node, nil
+	case []interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, currentValue := range typedValue {
+			valueNode, err := toNode(currentValue)
+			if nil != err {
+				return nil, err
+			}
+			node.Content = append(node.Content, valueNode)
+		}
+		return node, nil
+	}
+	node := new(yaml.Node)
+	err := node.Encode(value)
+	return node, err
+}
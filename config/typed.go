@@ -0,0 +1,291 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/smiecj/go_common/errorcode"
+)
+
+// 带类型的配置空间: 支持点分隔的路径（如 retry.max、hosts.0）和类型转换
+// 配置不存在时返回默认值（如果有），否则返回 ConfigNotExist，类型转换失败返回 ConfigTypeInvalid
+type TypedSpace struct {
+	getFunc func(string) (interface{}, error)
+}
+
+// 基于配置空间创建
+func NewTypedSpace(space Space) *TypedSpace {
+	return &TypedSpace{getFunc: space.Get}
+}
+
+// 基于配置中心和配置空间名创建，适用于不支持 GetSpace 的配置中心（如 nacos、apollo）
+func GetTypedSpace(manager Manager, spaceName string) *TypedSpace {
+	return &TypedSpace{getFunc: func(key string) (interface{}, error) {
+		return manager.Get(spaceName, key)
+	}}
+}
+
+// 根据路径获取配置: 优先把完整路径作为 key，不存在时按照 . 拆分，逐层查找 map 和数组
+func (space *TypedSpace) Get(path string) (interface{}, error) {
+	value, err := space.getFunc(path)
+	if nil == err || !strings.Contains(path, ".") || !isNotExist(err) {
+		return value, err
+	}
+
+	pathArr := strings.Split(path, ".")
+	value, err = space.getFunc(pathArr[0])
+	if nil != err {
+		return nil, err
+	}
+	for _, current := range pathArr[1:] {
+		var ok bool
+		switch typedValue := value.(type) {
+		case map[string]interface{}:
+			value, ok = typedValue[current]
+		case map[interface{}]interface{}:
+			value, ok = typedValue[current]
+		case map[string]string:
+			value, ok = typedValue[current]
+		case []interface{}:
+			index, err := strconv.Atoi(current)
+			if ok = nil == err && index >= 0 && index < len(typedValue); ok {
+				value = typedValue[index]
+			}
+		case []string:
+			index, err := strconv.Atoi(current)
+			if ok = nil == err && index >= 0 && index < len(typedValue); ok {
+				value = typedValue[index]
+			}
+		}
+		if !ok {
+			return nil, errorcode.BuildErrorWithMsg(errorcode.ConfigNotExist, fmt.Sprintf("config %s not exists", path))
+		}
+	}
+	return value, nil
+}
+
+// 获取字符串，数字和布尔类型会转成字符串
+func (space *TypedSpace) GetString(path string, defaultValue ...string) (string, error) {
+	value, err := space.Get(path)
+	if nil != err {
+		if isNotExist(err) && len(defaultValue) > 0 {
+			return defaultValue[0], nil
+		}
+		return "", err
+	}
+	switch typedValue := value.(type) {
+	case string:
+		return typedValue, nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, bool:
+		return fmt.Sprint(typedValue), nil
+	}
+	return "", buildTypeError(path, value, "string")
+}
+
+// 获取整数，字符串会尝试转换，浮点数必须是整数
+func (space *TypedSpace) GetInt(path string, defaultValue ...int) (int, error) {
+	value, err := space.Get(path)
+	if nil != err {
+		if isNotExist(err) && len(defaultValue) > 0 {
+			return defaultValue[0], nil
+		}
+		return 0, err
+	}
+	switch typedValue := value.(type) {
+	case int:
+		return typedValue, nil
+	case int64:
+		return int(typedValue), nil
+	case string:
+		intValue, err := strconv.Atoi(strings.TrimSpace(typedValue))
+		if nil != err {
+			return 0, buildTypeError(path, value, "int")
+		}
+		return intValue, nil
+	}
+	floatValue, ok := toFloat(value)
+	if !ok || floatValue != math.Trunc(floatValue) || floatValue > math.MaxInt64 || floatValue < math.MinInt64 {
+		return 0, buildTypeError(path, value, "int")
+	}
+	return int(floatValue), nil
+}
+
+// 获取布尔值，字符串支持 true / false / 1 / 0 等
+func (space *TypedSpace) GetBool(path string, defaultValue ...bool) (bool, error) {
+	value, err := space.Get(path)
+	if nil != err {
+		if isNotExist(err) && len(defaultValue) > 0 {
+			return defaultValue[0], nil
+		}
+		return false, err
+	}
+	switch typedValue := value.(type) {
+	case bool:
+		return typedValue, nil
+	case string:
+		boolValue, err := strconv.ParseBool(strings.TrimSpace(typedValue))
+		if nil == err {
+			return boolValue, nil
+		}
+	}
+	return false, buildTypeError(path, value, "bool")
+}
+
+// 获取浮点数
+func (space *TypedSpace) GetFloat(path string, defaultValue ...float64) (float64, error) {
+	value, err := space.Get(path)
+	if nil != err {
+		if isNotExist(err) && len(defaultValue) > 0 {
+			return defaultValue[0], nil
+		}
+		return 0, err
+	}
+	if stringValue, ok := value.(string); ok {
+		floatValue, err := strconv.ParseFloat(strings.TrimSpace(stringValue), 64)
+		if nil != err {
+			return 0, buildTypeError(path, value, "float")
+		}
+		return floatValue, nil
+	}
+	floatValue, ok := toFloat(value)
+	if !ok {
+		return 0, buildTypeError(path, value, "float")
+	}
+	return floatValue, nil
+}
+
+// 获取时间间隔，字符串格式同 time.ParseDuration（如 10s、1m30s），整数表示秒
+func (space *TypedSpace) GetDuration(path string, defaultValue ...time.Duration) (time.Duration, error) {
+	value, err := space.Get(path)
+	if nil != err {
+		if isNotExist(err) && len(defaultValue) > 0 {
+			return defaultValue[0], nil
+		}
+		return 0, err
+	}
+	switch typedValue := value.(type) {
+	case time.Duration:
+		return typedValue, nil
+	case string:
+		duration, err := time.ParseDuration(strings.TrimSpace(typedValue))
+		if nil == err {
+			return duration, nil
+		}
+		if seconds, err := strconv.Atoi(strings.TrimSpace(typedValue)); nil == err {
+			return time.Duration(seconds) * time.Second, nil
+		}
+		return 0, buildTypeError(path, value, "duration")
+	}
+	seconds, err := space.GetInt(path)
+	if nil != err {
+		return 0, buildTypeError(path, value, "duration")
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// 获取字符串数组，字符串按照逗号拆分
+func (space *TypedSpace) GetStringArr(path string, defaultValue ...[]string) ([]string, error) {
+	value, err := space.Get(path)
+	if nil != err {
+		if isNotExist(err) && len(defaultValue) > 0 {
+			return defaultValue[0], nil
+		}
+		return nil, err
+	}
+	switch typedValue := value.(type) {
+	case []string:
+		return append([]string{}, typedValue...), nil
+	case []interface{}:
+		retArr := make([]string, 0, len(typedValue))
+		for index := range typedValue {
+			current, err := space.GetString(fmt.Sprintf("%s.%d", path, index))
+			if nil != err {
+				return nil, buildTypeError(path, value, "string array")
+			}
+			retArr = append(retArr, current)
+		}
+		return retArr, nil
+	case string:
+		retArr := make([]string, 0)
+		for _, current := range strings.Split(typedValue, ",") {
+			if current = strings.TrimSpace(current); current != "" {
+				retArr = append(retArr, current)
+			}
+		}
+		return retArr, nil
+	}
+	return nil, buildTypeError(path, value, "string array")
+}
+
+// 获取 map，key 转成字符串
+func (space *TypedSpace) GetMap(path string, defaultValue ...map[string]interface{}) (map[string]interface{}, error) {
+	value, err := space.Get(path)
+	if nil != err {
+		if isNotExist(err) && len(defaultValue) > 0 {
+			return defaultValue[0], nil
+		}
+		return nil, err
+	}
+	retMap := make(map[string]interface{})
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		for key, current := range typedValue {
+			retMap[key] = current
+		}
+	case map[interface{}]interface{}:
+		for key, current := range typedValue {
+			retMap[fmt.Sprint(key)] = current
+		}
+	case map[string]string:
+		for key, current := range typedValue {
+			retMap[key] = current
+		}
+	default:
+		return nil, buildTypeError(path, value, "map")
+	}
+	return retMap, nil
+}
+
+// 判断是否是配置、配置空间不存在
+func isNotExist(err error) bool {
+	return errors.Is(err, errorcode.BuildError(errorcode.ConfigNotExist)) || errors.Is(err, errorcode.BuildError(errorcode.SpaceNotExist))
+}
+
+// 数字转换成 float64
+func toFloat(value interface{}) (float64, bool) {
+	switch typedValue := value.(type) {
+	case int:
+		return float64(typedValue), true
+	case int8:
+		return float64(typedValue), true
+	case int16:
+		return float64(typedValue), true
+	case int32:
+		return float64(typedValue), true
+	case int64:
+		return float64(typedValue), true
+	case uint:
+		return float64(typedValue), true
+	case uint8:
+		return float64(typedValue), true
+	case uint16:
+		return float64(typedValue), true
+	case uint32:
+		return float64(typedValue), true
+	case uint64:
+		return float64(typedValue), true
+	case float32:
+		return float64(typedValue), true
+	case float64:
+		return typedValue, true
+	}
+	return 0, false
+}
+
+func buildTypeError(path string, value interface{}, typeName string) error {
+	return errorcode.BuildErrorWithMsg(errorcode.ConfigTypeInvalid, fmt.Sprintf("config %s: cannot convert %v (%T) to %s", path, value, value, typeName))
+}
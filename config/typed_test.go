@@ -0,0 +1,76 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// 测试路径查找和类型转换
+func TestTypedSpace(t *testing.T) {
+	space := NewTypedSpace(NewMapSpace(map[string]interface{}{
+		"port":    "3306",
+		"ssl":     true,
+		"ratio":   0.5,
+		"timeout": "1m30s",
+		"retry": map[string]interface{}{
+			"max":      3,
+			"interval": 2,
+		},
+		"hosts":      []interface{}{"a", "b", 1},
+		"user.name":  "root",
+		"tags":       "x, y,",
+		"labels":     map[interface{}]interface{}{"env": "dev"},
+		"max_memory": 1.5,
+	}))
+
+	port, err := space.GetInt("port")
+	require.Empty(t, err)
+	require.Equal(t, 3306, port)
+	retryMax, err := space.GetInt("retry.max")
+	require.Empty(t, err)
+	require.Equal(t, 3, retryMax)
+	host, err := space.GetString("hosts.1")
+	require.Empty(t, err)
+	require.Equal(t, "b", host)
+	userName, err := space.GetString("user.name")
+	require.Empty(t, err)
+	require.Equal(t, "root", userName)
+	ssl, err := space.GetBool("ssl")
+	require.Empty(t, err)
+	require.True(t, ssl)
+	ratio, err := space.GetFloat("ratio")
+	require.Empty(t, err)
+	require.Equal(t, 0.5, ratio)
+	timeout, err := space.GetDuration("timeout")
+	require.Empty(t, err)
+	require.Equal(t, 90*time.Second, timeout)
+	interval, err := space.GetDuration("retry.interval")
+	require.Empty(t, err)
+	require.Equal(t, 2*time.Second, interval)
+	hostArr, err := space.GetStringArr("hosts")
+	require.Empty(t, err)
+	require.Equal(t, []string{"a", "b", "1"}, hostArr)
+	tagArr, err := space.GetStringArr("tags")
+	require.Empty(t, err)
+	require.Equal(t, []string{"x", "y"}, tagArr)
+	labelMap, err := space.GetMap("labels")
+	require.Empty(t, err)
+	require.Equal(t, map[string]interface{}{"env": "dev"}, labelMap)
+
+	// 默认值和错误
+	retryMin, err := space.GetInt("retry.min", 1)
+	require.Empty(t, err)
+	require.Equal(t, 1, retryMin)
+	_, err = space.GetInt("retry.min")
+	require.NotEmpty(t, err)
+	_, err = space.GetInt("hosts.5", 1)
+	require.Empty(t, err)
+	_, err = space.GetInt("max_memory", 1)
+	require.NotEmpty(t, err)
+	_, err = space.GetBool("port")
+	require.NotEmpty(t, err)
+	_, err = space.GetMap("hosts")
+	require.NotEmpty(t, err)
+}
@@ -0,0 +1,313 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/smiecj/go_common/errorcode"
+	"github.com/smiecj/go_common/util/log"
+)
+
+// 默认值和校验规则使用的 tag
+// 示例: Port int `yaml:"port" default:"3306" validate:"min=1,max=65535"`
+// 校验规则用逗号分隔: required 不能为空, min/max 数字的范围（字符串、数组为长度）, enum 可选值（用 | 分隔）
+// 非 required 的字段为零值时不检查其他规则
+const (
+	defaultTagName  = "default"
+	validateTagName = "validate"
+	yamlTagName     = "yaml"
+
+	ruleRequired = "required"
+	ruleMin      = "min"
+	ruleMax      = "max"
+	ruleEnum     = "enum"
+
+	defaultArrSplitor = ","
+	enumSplitor       = "|"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// 解析配置空间到结构体，填充默认值并校验，obj 为结构体指针
+// 配置空间不存在时按空配置处理，所有不合法的字段合并成一个错误返回
+func UnmarshalAndValidate(manager Manager, spaceName string, obj interface{}) error {
+	if err := manager.Unmarshal(spaceName, obj); nil != err && !errors.Is(err, errorcode.BuildError(errorcode.SpaceNotExist)) {
+		return err
+	}
+	if err := ApplyDefault(obj); nil != err {
+		return err
+	}
+	return validate(obj, spaceName)
+}
+
+// 为零值字段填充 default tag 指定的默认值，obj 为结构体指针
+func ApplyDefault(obj interface{}) error {
+	value, err := getStructValue(obj)
+	if nil != err {
+		return err
+	}
+	return applyDefault(value, "")
+}
+
+// 根据 validate tag 校验结构体，obj 实现 Validator 时同时调用 Validate
+func Validate(obj interface{}) error {
+	return validate(obj, "")
+}
+
+func validate(obj interface{}, prefix string) error {
+	value, err := getStructValue(obj)
+	if nil != err {
+		return err
+	}
+	problemArr := validateStruct(value, prefix)
+	if validator, ok := obj.(Validator); ok {
+		if err := validator.Validate(); nil != err {
+			problemArr = append(problemArr, err.Error())
+		}
+	}
+	if len(problemArr) != 0 {
+		return errorcode.BuildErrorWithMsg(errorcode.ConfigValidateFailed, strings.Join(problemArr, "; "))
+	}
+	return nil
+}
+
+// 获取结构体指针指向的结构体
+func getStructValue(obj interface{}) (reflect.Value, error) {
+	value := reflect.ValueOf(obj)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return value, errorcode.BuildErrorWithMsg(errorcode.ConfigParamInvalid, "config object must be struct pointer")
+	}
+	return value.Elem(), nil
+}
+
+// 字段在配置中的名称: 优先使用 yaml tag，和 yaml 解析保持一致，默认为小写的字段名
+func getFieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get(yamlTagName), ",")[0]
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name
+}
+
+func joinFieldPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// 获取需要处理的字段: 导出的、没有被 yaml 忽略的字段
+func getFieldArr(value reflect.Value) []reflect.StructField {
+	fieldArr := make([]reflect.StructField, 0, value.NumField())
+	valueType := value.Type()
+	for index := 0; index < value.NumField(); index++ {
+		field := valueType.Field(index)
+		if field.PkgPath != "" || field.Tag.Get(yamlTagName) == "-" {
+			continue
+		}
+		fieldArr = append(fieldArr, field)
+	}
+	return fieldArr
+}
+
+// 获取嵌套的结构体，指针为空时返回 false
+func getNestedStruct(value reflect.Value) (reflect.Value, bool) {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return value, false
+		}
+		value = value.Elem()
+	}
+	return value, value.Kind() == reflect.Struct
+}
+
+func applyDefault(value reflect.Value, prefix string) error {
+	for _, field := range getFieldArr(value) {
+		fieldValue := value.FieldByIndex(field.Index)
+		fieldPath := joinFieldPath(prefix, getFieldName(field))
+		if nestedValue, ok := getNestedStruct(fieldValue); ok {
+			if err := applyDefault(nestedValue, fieldPath); nil != err {
+				return err
+			}
+			continue
+		}
+
+		defaultValue, ok := field.Tag.Lookup(defaultTagName)
+		if !ok || !fieldValue.IsZero() {
+			continue
+		}
+		if err := setDefaultValue(fieldValue, defaultValue); nil != err {
+			return errorcode.BuildErrorWithMsg(errorcode.ConfigParamInvalid,
+				fmt.Sprintf("field %s default value %s is invalid: %s", fieldPath, defaultValue, err.Error()))
+		}
+	}
+	return nil
+}
+
+// 将默认值字符串转换成字段类型并设置
+func setDefaultValue(fieldValue reflect.Value, defaultValue string) error {
+	if fieldValue.Type() == durationType {
+		duration, err := time.ParseDuration(defaultValue)
+		if nil != err {
+			return err
+		}
+		fieldValue.SetInt(int64(duration))
+		return nil
+	}
+
+	switch fieldValue.Kind() {
+	case reflect.String:
+		fieldValue.SetString(defaultValue)
+	case reflect.Bool:
+		boolValue, err := strconv.ParseBool(defaultValue)
+		if nil != err {
+			return err
+		}
+		fieldValue.SetBool(boolValue)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		intValue, err := strconv.ParseInt(defaultValue, 10, fieldValue.Type().Bits())
+		if nil != err {
+			return err
+		}
+		fieldValue.SetInt(intValue)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		uintValue, err := strconv.ParseUint(defaultValue, 10, fieldValue.Type().Bits())
+		if nil != err {
+			return err
+		}
+		fieldValue.SetUint(uintValue)
+	case reflect.Float32, reflect.Float64:
+		floatValue, err := strconv.ParseFloat(defaultValue, fieldValue.Type().Bits())
+		if nil != err {
+			return err
+		}
+		fieldValue.SetFloat(floatValue)
+	case reflect.Slice:
+		if fieldValue.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", fieldValue.Type())
+		}
+		strArr := strings.Split(defaultValue, defaultArrSplitor)
+		sliceValue := reflect.MakeSlice(fieldValue.Type(), 0, len(strArr))
+		for _, str := range strArr {
+			sliceValue = reflect.Append(sliceValue, reflect.ValueOf(strings.TrimSpace(str)).Convert(fieldValue.Type().Elem()))
+		}
+		fieldValue.Set(sliceValue)
+	default:
+		return fmt.Errorf("unsupported type %s", fieldValue.Type())
+	}
+	return nil
+}
+
+// 校验结构体的所有字段，返回不合法字段的说明
+func validateStruct(value reflect.Value, prefix string) []string {
+	problemArr := make([]string, 0)
+	for _, field := range getFieldArr(value) {
+		fieldValue := value.FieldByIndex(field.Index)
+		fieldPath := joinFieldPath(prefix, getFieldName(field))
+		ruleArr := make([]string, 0)
+		if rules := field.Tag.Get(validateTagName); rules != "" {
+			ruleArr = strings.Split(rules, ",")
+		}
+
+		// 嵌套的结构体即使为零值，也需要检查其中的必填字段
+		if nestedValue, ok := getNestedStruct(fieldValue); ok {
+			problemArr = append(problemArr, validateStruct(nestedValue, fieldPath)...)
+		}
+
+		if fieldValue.IsZero() {
+			for _, rule := range ruleArr {
+				if strings.TrimSpace(rule) == ruleRequired {
+					problemArr = append(problemArr, fmt.Sprintf("%s is required", fieldPath))
+					break
+				}
+			}
+			continue
+		}
+
+		for _, rule := range ruleArr {
+			if problem := checkRule(fieldValue, fieldPath, strings.TrimSpace(rule)); problem != "" {
+				problemArr = append(problemArr, problem)
+			}
+		}
+	}
+	return problemArr
+}
+
+// 检查单个规则，不满足时返回说明
+func checkRule(fieldValue reflect.Value, fieldPath, rule string) string {
+	ruleName, ruleParam := rule, ""
+	if index := strings.Index(rule, "="); index >= 0 {
+		ruleName, ruleParam = rule[:index], rule[index+1:]
+	}
+
+	switch ruleName {
+	case ruleRequired, "":
+		return ""
+	case ruleMin, ruleMax:
+		actual, limit, ok := getCompareValue(fieldValue, ruleParam)
+		if !ok {
+			return fmt.Sprintf("%s has invalid rule %s", fieldPath, rule)
+		}
+		if ruleName == ruleMin && actual < limit {
+			return fmt.Sprintf("%s must be >= %s%s", fieldPath, ruleParam, describeCompareValue(fieldValue, fieldPath))
+		}
+		if ruleName == ruleMax && actual > limit {
+			return fmt.Sprintf("%s must be <= %s%s", fieldPath, ruleParam, describeCompareValue(fieldValue, fieldPath))
+		}
+	case ruleEnum:
+		enumArr := strings.Split(ruleParam, enumSplitor)
+		actual := fmt.Sprintf("%v", fieldValue.Interface())
+		for _, enum := range enumArr {
+			if actual == enum {
+				return ""
+			}
+		}
+		if log.IsSensitiveField(fieldPath) {
+			return fmt.Sprintf("%s must be one of [%s]", fieldPath, strings.Join(enumArr, ", "))
+		}
+		return fmt.Sprintf("%s must be one of [%s], got %s", fieldPath, strings.Join(enumArr, ", "), actual)
+	default:
+		return fmt.Sprintf("%s has unknown rule %s", fieldPath, rule)
+	}
+	return ""
+}
+
+// 范围校验失败时的实际值说明: 敏感字段（如密码）不输出，字符串、数组只输出长度
+func describeCompareValue(fieldValue reflect.Value, fieldPath string) string {
+	if log.IsSensitiveField(fieldPath) {
+		return ""
+	}
+	switch fieldValue.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return fmt.Sprintf(", got length %d", fieldValue.Len())
+	}
+	return fmt.Sprintf(", got %v", fieldValue.Interface())
+}
+
+// 获取用于比较范围的值: 数字直接比较，字符串、数组比较长度，时间间隔的限制值按 time.ParseDuration 解析
+func getCompareValue(fieldValue reflect.Value, limitStr string) (actual, limit float64, ok bool) {
+	if fieldValue.Type() == durationType {
+		duration, err := time.ParseDuration(limitStr)
+		return float64(fieldValue.Int()), float64(duration), nil == err
+	}
+
+	limit, err := strconv.ParseFloat(limitStr, 64)
+	if nil != err {
+		return 0, 0, false
+	}
+	switch fieldValue.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(fieldValue.Int()), limit, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(fieldValue.Uint()), limit, true
+	case reflect.Float32, reflect.Float64:
+		return fieldValue.Float(), limit, true
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return float64(fieldValue.Len()), limit, true
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smiecj/go_common/errorcode"
+	"github.com/stretchr/testify/require"
+)
+
+type validateRetryConf struct {
+	Max      int           `yaml:"max" default:"3" validate:"min=1,max=10"`
+	Interval time.Duration `yaml:"interval" default:"1s" validate:"max=1m"`
+}
+
+type validateConf struct {
+	Host    string            `yaml:"host" validate:"required"`
+	Port    int               `yaml:"port" default:"3306" validate:"min=1,max=65535"`
+	Mode    string            `yaml:"mode" default:"rw" validate:"enum=r|rw"`
+	HostArr []string          `yaml:"host_arr" default:"a, b"`
+	Ratio   float64           `yaml:"ratio" validate:"max=1"`
+	Debug   bool              `yaml:"debug" default:"true"`
+	Retry   validateRetryConf `yaml:"retry"`
+	Backup  *struct {
+		Host string `yaml:"host" validate:"required"`
+	} `yaml:"backup"`
+}
+
+// 测试默认值填充和校验规则
+func TestValidate(t *testing.T) {
+	conf := validateConf{}
+	require.Empty(t, NewMapSpace(map[string]interface{}{
+		"host":  "localhost",
+		"ratio": 0.5,
+		"retry": map[string]interface{}{"max": 5},
+	}).Unmarshal(&conf))
+	require.Empty(t, ApplyDefault(&conf))
+	require.Empty(t, Validate(&conf))
+	require.Equal(t, 3306, conf.Port)
+	require.Equal(t, "rw", conf.Mode)
+	require.Equal(t, []string{"a", "b"}, conf.HostArr)
+	require.True(t, conf.Debug)
+	require.Equal(t, 5, conf.Retry.Max)
+	require.Equal(t, time.Second, conf.Retry.Interval)
+
+	// 所有不合法的字段合并成一个错误
+	conf = validateConf{Port: 70000, Mode: "w", Ratio: 2, Retry: validateRetryConf{Interval: time.Hour}}
+	conf.Backup = &struct {
+		Host string `yaml:"host" validate:"required"`
+	}{}
+	err := Validate(&conf)
+	require.True(t, errors.Is(err, errorcode.BuildError(errorcode.ConfigValidateFailed)))
+	require.Equal(t, "code: config_203, msg: host is required; port must be <= 65535, got 70000; mode must be one of [r, rw], got w; "+
+		"ratio must be <= 1, got 2; retry.interval must be <= 1m, got 1h0m0s; backup.host is required", err.Error())
+
+	// 敏感字段不输出取值，字符串只输出长度
+	err = Validate(&struct {
+		Password string `yaml:"password" validate:"min=8"`
+		Secret   string `yaml:"secret" validate:"enum=a|b"`
+		Name     string `yaml:"name" validate:"max=3"`
+	}{Password: "abc", Secret: "c", Name: "xiaoming"})
+	require.Equal(t, "code: config_203, msg: password must be >= 8; secret must be one of [a, b]; name must be <= 3, got length 8", err.Error())
+
+	// 默认值和类型不匹配
+	require.NotEmpty(t, ApplyDefault(&struct {
+		Port int `default:"port"`
+	}{}))
+	require.NotEmpty(t, Validate(conf))
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/smiecj/go_common/errorcode"
+)
+
+// 配置变更类型
+type ChangeType string
+
+const (
+	ChangeAdd    ChangeType = "add"
+	ChangeModify ChangeType = "modify"
+	ChangeDelete ChangeType = "delete"
+)
+
+// 配置变更事件
+type ChangeEvent struct {
+	Space    string
+	Key      string
+	Type     ChangeType
+	OldValue interface{}
+	NewValue interface{}
+}
+
+// 配置变更回调
+type ChangeListener func(ChangeEvent)
+
+// 支持监听配置变更的配置中心
+type Watcher interface {
+	// 监听配置变更，key 为空时监听整个配置空间，返回取消监听的方法
+	Watch(spaceName, key string, listener ChangeListener) (cancel func(), err error)
+}
+
+// 监听配置变更，配置中心未实现 Watcher 时返回 ConfigMethodNotSupport
+func Watch(manager Manager, spaceName, key string, listener ChangeListener) (func(), error) {
+	watcher, ok := manager.(Watcher)
+	if !ok {
+		return nil, errorcode.BuildError(errorcode.ConfigMethodNotSupport)
+	}
+	return watcher.Watch(spaceName, key, listener)
+}
+
+// 监听器: 记录 space、key 对应的回调方法
+type listenerEntry struct {
+	spaceName string
+	key       string
+	listener  ChangeListener
+}
+
+// 配置变更通知器，供各配置中心实现 Watcher 时复用
+type Notifier struct {
+	lock        sync.RWMutex
+	currentId   int
+	listenerMap map[int]listenerEntry
+}
+
+// 创建配置变更通知器
+func NewNotifier() *Notifier {
+	return &Notifier{listenerMap: make(map[int]listenerEntry)}
+}
+
+// 添加监听器，返回取消监听的方法（可重复调用）
+func (notifier *Notifier) Add(spaceName, key string, listener ChangeListener) func() {
+	notifier.lock.Lock()
+	defer notifier.lock.Unlock()
+	notifier.currentId++
+	id := notifier.currentId
+	notifier.listenerMap[id] = listenerEntry{spaceName: spaceName, key: key, listener: listener}
+	return func() {
+		notifier.lock.Lock()
+		defer notifier.lock.Unlock()
+		delete(notifier.listenerMap, id)
+	}
+}
+
+// 当前监听器个数，spaceName、key 为空时不作为过滤条件
+func (notifier *Notifier) Count(spaceName, key string) int {
+	notifier.lock.RLock()
+	defer notifier.lock.RUnlock()
+	count := 0
+	for _, entry := range notifier.listenerMap {
+		if (spaceName == "" || entry.spaceName == spaceName) && (key == "" || entry.key == key) {
+			count++
+		}
+	}
+	return count
+}
+
+// 通知配置变更，按监听器添加顺序回调
+func (notifier *Notifier) Notify(eventArr ...ChangeEvent) {
+	notifier.lock.RLock()
+	idArr := make([]int, 0, len(notifier.listenerMap))
+	for id := range notifier.listenerMap {
+		idArr = append(idArr, id)
+	}
+	sort.Ints(idArr)
+	entryArr := make([]listenerEntry, 0, len(idArr))
+	for _, id := range idArr {
+		entryArr = append(entryArr, notifier.listenerMap[id])
+	}
+	notifier.lock.RUnlock()
+
+	// 回调时不持有锁，回调中可以添加、取消监听
+	for _, event := range eventArr {
+		for _, entry := range entryArr {
+			if entry.spaceName == event.Space && (entry.key == "" || entry.key == event.Key) {
+				entry.listener(event)
+			}
+		}
+	}
+}
+
+// 对比配置空间变更前后的配置，返回变更事件（按 key 排序）
+func DiffSpace(spaceName string, oldMap, newMap map[string]interface{}) []ChangeEvent {
+	keyArr := make([]string, 0, len(oldMap)+len(newMap))
+	for key := range oldMap {
+		keyArr = append(keyArr, key)
+	}
+	for key := range newMap {
+		if _, ok := oldMap[key]; !ok {
+			keyArr = append(keyArr, key)
+		}
+	}
+	sort.Strings(keyArr)
+
+	eventArr := make([]ChangeEvent, 0)
+	for _, key := range keyArr {
+		oldValue, oldOk := oldMap[key]
+		newValue, newOk := newMap[key]
+		event := ChangeEvent{Space: spaceName, Key: key, OldValue: oldValue, NewValue: newValue}
+		switch {
+		case !oldOk:
+			event.Type = ChangeAdd
+		case !newOk:
+			event.Type = ChangeDelete
+		case !reflect.DeepEqual(oldValue, newValue):
+			event.Type = ChangeModify
+		default:
+			continue
+		}
+		eventArr = append(eventArr, event)
+	}
+	return eventArr
+}
@@ -1,17 +1,24 @@
 package yaml
 
 import (
-	"io/ioutil"
-	"os"
+	"bytes"
 	"sync"
+	"time"
 
 	config "github.com/smiecj/go_common/config"
 	"github.com/smiecj/go_common/errorcode"
+	"github.com/smiecj/go_common/util/file"
 	"github.com/smiecj/go_common/util/log"
 
 	"gopkg.in/yaml.v3"
 )
 
+const (
+	defaultWatchInterval = 5 * time.Second
+	// 保存配置文件时的缩进
+	saveIndent = 2
+)
+
 var (
 	yamlConfigMapLock sync.RWMutex
 	yamlConfigMap     map[string]config.Manager
@@ -20,11 +27,34 @@ var (
 // yaml config manager
 type yamlManager struct {
 	filePath string
-	spaceMap map[string]config.Space
+	spaceMap map[string]*yamlSpace
+	// 更新配置时 spaceMap 会变化，需要加锁
+	spaceLock sync.RWMutex
+	// 配置文件的 yaml 节点，设置配置时同步修改，保存时保留注释和 key 的顺序
+	document *yaml.Node
+	nodeLock sync.Mutex
+
+	// 配置监听: 定期检查文件修改时间和大小，有变化时重新加载
+	reloader      *config.FileReloader
+	watchInterval time.Duration
+}
+
+// yaml config manager 配置
+type YamlConfigFunc func(*yamlManager)
+
+// 设置监听配置变更时检查文件的间隔，默认 5s
+func YamlSetWatchInterval(interval time.Duration) YamlConfigFunc {
+	return func(manager *yamlManager) {
+		if interval > 0 {
+			manager.watchInterval = interval
+		}
+	}
 }
 
 // yaml config space
 type yamlSpace struct {
+	name      string
+	manager   *yamlManager
 	configMap map[string]interface{}
 	mapLock   sync.RWMutex
 }
@@ -51,11 +81,18 @@ func (space *yamlSpace) GetAllKey() (retArr []string, err error) {
 	return
 }
 
-// yaml space set config
+// yaml space set config: 同时修改配置文件的 yaml 节点，调用 Save 之后写入文件
 func (space *yamlSpace) Set(key string, value interface{}) error {
+	err := space.manager.setNode(space.name, key, value)
+	if nil != err {
+		return err
+	}
+
 	space.mapLock.Lock()
 	defer space.mapLock.Unlock()
-
+	if nil == space.configMap {
+		space.configMap = make(map[string]interface{})
+	}
 	space.configMap[key] = value
 	return nil
 }
@@ -69,32 +106,107 @@ func (space *yamlSpace) Unmarshal(obj interface{}) error {
 	return yaml.Unmarshal(configContent, obj)
 }
 
-// yaml config init
+// yaml space 替换全部配置，返回替换前的配置
+func (space *yamlSpace) replace(configMap map[string]interface{}) map[string]interface{} {
+	space.mapLock.Lock()
+	defer space.mapLock.Unlock()
+
+	oldConfigMap := space.configMap
+	space.configMap = configMap
+	return oldConfigMap
+}
+
+// yaml config init: 对每一层配置 都初始化一个 space
 func (manager *yamlManager) init() (err error) {
-	file, err := os.Open(manager.filePath)
+	manager.spaceMap = make(map[string]*yamlSpace)
+	manager.reloader = config.NewFileReloader(manager.filePath, manager.watchInterval, manager.reload)
+	_, err = manager.reloader.Load()
+	return
+}
+
+// 解析 yaml 配置文件内容，并替换 yaml 节点
+func (manager *yamlManager) parse(content []byte) (fullConfigMap map[string]map[string]interface{}, err error) {
+	document := new(yaml.Node)
+	err = yaml.Unmarshal(content, document)
 	if nil != err {
 		return
 	}
-	defer file.Close()
-
-	fileContentBytes, err := ioutil.ReadAll(file)
+	// 空文件
+	if document.Kind == 0 {
+		document = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	fullConfigMap = make(map[string]map[string]interface{})
+	err = document.Decode(&fullConfigMap)
 	if nil != err {
 		return
 	}
 
-	fullConfigMap := make(map[string]map[string]interface{})
-	err = yaml.Unmarshal(fileContentBytes, &fullConfigMap)
+	manager.nodeLock.Lock()
+	manager.document = document
+	manager.nodeLock.Unlock()
+	return
+}
+
+// 修改配置文件的 yaml 节点: key 已存在时替换值并保留注释，不存在时添加到配置空间末尾
+func (manager *yamlManager) setNode(spaceName, key string, value interface{}) error {
+	valueNode := new(yaml.Node)
+	err := valueNode.Encode(value)
+	if nil != err {
+		return err
+	}
+
+	manager.nodeLock.Lock()
+	defer manager.nodeLock.Unlock()
+	spaceNode := getOrAddNode(manager.document.Content[0], spaceName, &yaml.Node{Kind: yaml.MappingNode})
+	if spaceNode.Kind != yaml.MappingNode {
+		// 空的配置空间
+		*spaceNode = yaml.Node{Kind: yaml.MappingNode, HeadComment: spaceNode.HeadComment, LineComment: spaceNode.LineComment}
+	}
+	oldValueNode := getOrAddNode(spaceNode, key, valueNode)
+	if oldValueNode != valueNode {
+		valueNode.HeadComment, valueNode.LineComment, valueNode.FootComment =
+			oldValueNode.HeadComment, oldValueNode.LineComment, oldValueNode.FootComment
+		*oldValueNode = *valueNode
+	}
+	return nil
+}
+
+// 获取 mapping 节点中 key 对应的值节点，不存在时添加
+func getOrAddNode(mappingNode *yaml.Node, key string, defaultNode *yaml.Node) *yaml.Node {
+	for index := 0; index+1 < len(mappingNode.Content); index += 2 {
+		if mappingNode.Content[index].Value == key {
+			return mappingNode.Content[index+1]
+		}
+	}
+	mappingNode.Content = append(mappingNode.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, defaultNode)
+	return defaultNode
+}
+
+// 加载配置文件内容，已获取的 space 会同步更新，返回配置变更事件
+func (manager *yamlManager) reload(content []byte) ([]config.ChangeEvent, error) {
+	fullConfigMap, err := manager.parse(content)
 	if nil != err {
-		return
+		return nil, err
 	}
 
-	// 对每一层配置 都初始化一个 space
-	manager.spaceMap = make(map[string]config.Space)
+	eventArr := make([]config.ChangeEvent, 0)
+	manager.spaceLock.Lock()
+	for spaceName, space := range manager.spaceMap {
+		if _, ok := fullConfigMap[spaceName]; !ok {
+			delete(manager.spaceMap, spaceName)
+			eventArr = append(eventArr, config.DiffSpace(spaceName, space.replace(make(map[string]interface{})), nil)...)
+		}
+	}
 	for spaceName, configMap := range fullConfigMap {
-		currentSpace := yamlSpace{configMap: configMap}
-		manager.spaceMap[spaceName] = &currentSpace
+		space, ok := manager.spaceMap[spaceName]
+		if !ok {
+			space = &yamlSpace{name: spaceName, manager: manager}
+			manager.spaceMap[spaceName] = space
+		}
+		eventArr = append(eventArr, config.DiffSpace(spaceName, space.replace(configMap), configMap)...)
 	}
-	return
+	manager.spaceLock.Unlock()
+	return eventArr, nil
 }
 
 // yaml config manager get config
@@ -108,7 +220,8 @@ func (config *yamlManager) Get(spaceName, key string) (ret interface{}, err erro
 
 // yaml get all space name
 func (config *yamlManager) GetAllSpaceName() (retArr []string, err error) {
-	// space map 目前只有读操作，不需要加锁
+	config.spaceLock.RLock()
+	defer config.spaceLock.RUnlock()
 	for key := range config.spaceMap {
 		retArr = append(retArr, key)
 	}
@@ -124,7 +237,7 @@ func (config *yamlManager) GetSpace(spaceName string) (space config.Space, err e
 	return space, nil
 }
 
-// yaml config set config
+// yaml config set config: 只修改内存中的配置，调用 Save 之后写入文件
 func (config *yamlManager) Set(spaceName, key string, value interface{}) (err error) {
 	space, err := config.getSpace(spaceName)
 	if nil != err {
@@ -144,6 +257,8 @@ func (config *yamlManager) Unmarshal(spaceName string, obj interface{}) error {
 
 // common method: get space
 func (config *yamlManager) getSpace(spaceName string) (config.Space, error) {
+	config.spaceLock.RLock()
+	defer config.spaceLock.RUnlock()
 	space := config.spaceMap[spaceName]
 	if nil == space {
 		return nil, errorcode.BuildError(errorcode.SpaceNotExist)
@@ -151,13 +266,44 @@ func (config *yamlManager) getSpace(spaceName string) (config.Space, error) {
 	return space, nil
 }
 
-// update: yaml config not implement (current no need implement)
+// 保存配置到文件: 保留注释和 key 的顺序，先写临时文件再重命名，保证写入是原子的
+func (config *yamlManager) Save() error {
+	return config.reloader.Save(func(filePath string) error {
+		config.nodeLock.Lock()
+		buffer := new(bytes.Buffer)
+		encoder := yaml.NewEncoder(buffer)
+		encoder.SetIndent(saveIndent)
+		err := encoder.Encode(config.document)
+		config.nodeLock.Unlock()
+		if nil != err {
+			return err
+		}
+		if err = encoder.Close(); nil != err {
+			return err
+		}
+
+		err = file.WriteAtomic(filePath, buffer.Bytes())
+		if nil != err {
+			log.Error("[yamlManager.Save] save config file failed: %s", err.Error())
+			return errorcode.BuildErrorWithMsg(errorcode.FileWriteFailed, err.Error())
+		}
+		return nil
+	})
+}
+
+// update: 重新加载配置文件，并通知配置变更
 func (config *yamlManager) Update() error {
-	return errorcode.BuildError(errorcode.NotImplement)
+	return config.reloader.Update()
+}
+
+// 监听配置变更: 第一次监听时开始定期检查配置文件，所有监听都取消后停止检查
+func (config *yamlManager) Watch(spaceName, key string, listener config.ChangeListener) (func(), error) {
+	return config.reloader.Watch(spaceName, key, listener)
 }
 
 // 获取 yaml 配置中心单例
-func GetYamlConfigManager(filePath string) (config.Manager, error) {
+// 配置只在第一次获取时生效
+func GetYamlConfigManager(filePath string, funcArr ...YamlConfigFunc) (config.Manager, error) {
 	var manager config.Manager
 	yamlConfigMapLock.RLock()
 	if nil == yamlConfigMap {
@@ -176,6 +322,10 @@ func GetYamlConfigManager(filePath string) (config.Manager, error) {
 	// 必须要初始化成功
 	yamlConfig := new(yamlManager)
 	yamlConfig.filePath = filePath
+	yamlConfig.watchInterval = defaultWatchInterval
+	for _, f := range funcArr {
+		f(yamlConfig)
+	}
 	err := yamlConfig.init()
 	if nil != err {
 		log.Error("[config.GetYamlConfigManager] init yaml config failed: %s", err.Error())
@@ -1,8 +1,13 @@
 package yaml
 
 import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/smiecj/go_common/config"
 	"github.com/smiecj/go_common/util/file"
 	"github.com/stretchr/testify/require"
 )
@@ -58,3 +63,193 @@ func TestYamlConfig(t *testing.T) {
 	require.Equal(t, testValueMySQLHost, mysqlConfigObj.Host)
 	require.Equal(t, testValueMySQLPort, mysqlConfigObj.Port)
 }
+
+// 测试导出配置，敏感信息脱敏
+func TestYamlConfigDump(t *testing.T) {
+	manager, err := GetYamlConfigManager(file.FindFilePath(exampleConfigFile))
+	require.Empty(t, err)
+
+	content, err := config.Dump(manager)
+	require.Empty(t, err)
+	require.Contains(t, content, "mysql:\n  database: mysql\n  host: localhost\n  password: ******\n  port: 3306\n  user: root\n")
+	require.Contains(t, content, "  Secret: ******\n")
+	require.Contains(t, content, "  sender: s******@xxx.com\n")
+	require.NotContains(t, content, "smtp token")
+}
+
+// 测试监听配置变更
+func TestYamlConfigWatch(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "conf_watch.yaml")
+	require.Empty(t, ioutil.WriteFile(filePath, []byte("mysql:\n  host: localhost\n  port: 3306\n"), 0644))
+	manager, err := GetYamlConfigManager(filePath, YamlSetWatchInterval(10*time.Millisecond))
+	require.Empty(t, err)
+	mysqlSpace, err := manager.GetSpace(testSpaceMySQL)
+	require.Empty(t, err)
+
+	eventChan := make(chan config.ChangeEvent, 10)
+	cancel, err := config.Watch(manager, testSpaceMySQL, "", func(event config.ChangeEvent) {
+		eventChan <- event
+	})
+	require.Empty(t, err)
+	hostEventChan := make(chan config.ChangeEvent, 10)
+	_, err = config.Watch(manager, testSpaceMySQL, testKeyMySQLHost, func(event config.ChangeEvent) {
+		hostEventChan <- event
+	})
+	require.Empty(t, err)
+
+	// 修改文件，等待定期检查通知变更
+	require.Empty(t, ioutil.WriteFile(filePath, []byte("mysql:\n  host: 127.0.0.1\n  user: root\nredis:\n  port: 6379\n"), 0644))
+	eventArr := make([]config.ChangeEvent, 0)
+	for len(eventArr) < 3 {
+		select {
+		case event := <-eventChan:
+			eventArr = append(eventArr, event)
+		case <-time.After(3 * time.Second):
+			t.Fatal("wait config change timeout")
+		}
+	}
+	require.Equal(t, []config.ChangeEvent{
+		{Space: testSpaceMySQL, Key: testKeyMySQLHost, Type: config.ChangeModify, OldValue: testValueMySQLHost, NewValue: "127.0.0.1"},
+		{Space: testSpaceMySQL, Key: testKeyMySQLPort, Type: config.ChangeDelete, OldValue: testValueMySQLPort},
+		{Space: testSpaceMySQL, Key: "user", Type: config.ChangeAdd, NewValue: "root"},
+	}, eventArr)
+	require.Equal(t, "127.0.0.1", (<-hostEventChan).NewValue)
+	require.Empty(t, hostEventChan)
+
+	// 已获取的 space 同步更新
+	host, err := mysqlSpace.Get(testKeyMySQLHost)
+	require.Empty(t, err)
+	require.Equal(t, "127.0.0.1", host)
+	port, err := manager.Get("redis", testKeyMySQLPort)
+	require.Empty(t, err)
+	require.Equal(t, 6379, port)
+
+	// 取消监听之后不再通知，Update 手动重新加载
+	cancel()
+	require.Empty(t, ioutil.WriteFile(filePath, []byte("mysql:\n  host: localhost\n"), 0644))
+	require.Empty(t, manager.Update())
+	require.Empty(t, eventChan)
+	require.Equal(t, testValueMySQLHost, (<-hostEventChan).NewValue)
+}
+
+// 带校验的 mysql 配置
+type validMySQLConfig struct {
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
+}
+
+func (conf *validMySQLConfig) Validate() error {
+	if conf.Port <= 0 {
+		return errors.New("port is invalid")
+	}
+	return nil
+}
+
+// 测试配置绑定和热更新
+func TestYamlConfigBind(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "conf_bind.yaml")
+	require.Empty(t, ioutil.WriteFile(filePath, []byte("mysql:\n  host: localhost\n  port: 3306\n"), 0644))
+	manager, err := GetYamlConfigManager(filePath)
+	require.Empty(t, err)
+
+	changeChan := make(chan *validMySQLConfig, 10)
+	errChan := make(chan error, 10)
+	binding, err := config.Bind(manager, testSpaceMySQL, &validMySQLConfig{},
+		config.BindSetOnChange(func(oldValue, newValue interface{}) {
+			changeChan <- newValue.(*validMySQLConfig)
+		}), config.BindSetOnError(func(err error) {
+			errChan <- err
+		}))
+	require.Empty(t, err)
+	defer binding.Close()
+	oldConf := binding.Get().(*validMySQLConfig)
+	require.Equal(t, &validMySQLConfig{Host: testValueMySQLHost, Port: testValueMySQLPort}, oldConf)
+
+	// 配置变更后替换快照，之前获取的快照不变
+	require.Empty(t, ioutil.WriteFile(filePath, []byte("mysql:\n  host: 127.0.0.1\n  port: 3307\n"), 0644))
+	require.Empty(t, manager.Update())
+	newConf := <-changeChan
+	require.Equal(t, &validMySQLConfig{Host: "127.0.0.1", Port: 3307}, newConf)
+	require.Equal(t, newConf, binding.Get())
+	require.Equal(t, testValueMySQLHost, oldConf.Host)
+
+	// 校验失败时保留当前配置
+	require.Empty(t, ioutil.WriteFile(filePath, []byte("mysql:\n  host: 127.0.0.2\n  port: -1\n"), 0644))
+	require.Empty(t, manager.Update())
+	require.NotEmpty(t, <-errChan)
+	require.Equal(t, newConf, binding.Get())
+	require.Empty(t, changeChan)
+
+	_, err = config.Bind(manager, testSpaceMySQL, validMySQLConfig{})
+	require.NotEmpty(t, err)
+}
+
+// 测试保存配置，保留注释和 key 的顺序
+func TestYamlConfigSave(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "conf_save.yaml")
+	require.Empty(t, ioutil.WriteFile(filePath, []byte(`# mysql config
+mysql:
+  # mysql host
+  host: localhost
+  port: 3306 # mysql port
+redis:
+`), 0644))
+	manager, err := GetYamlConfigManager(filePath)
+	require.Empty(t, err)
+
+	require.Empty(t, manager.Set(testSpaceMySQL, testKeyMySQLPort, 3307))
+	require.Empty(t, manager.Set(testSpaceMySQL, "db_arr", []string{"school"}))
+	redisSpace, err := manager.GetSpace("redis")
+	require.Empty(t, err)
+	require.Empty(t, redisSpace.Set("host", "redis"))
+	require.NotEmpty(t, manager.Set("not_exist", "host", "localhost"))
+	require.Empty(t, config.Save(manager))
+
+	content, err := ioutil.ReadFile(filePath)
+	require.Empty(t, err)
+	require.Equal(t, `# mysql config
+mysql:
+  # mysql host
+  host: localhost
+  port: 3307 # mysql port
+  db_arr:
+    - school
+redis:
+  host: redis
+`, string(content))
+
+	// 重新读取文件
+	require.Empty(t, ioutil.WriteFile(filePath, []byte("mysql:\n  host: 127.0.0.1\n"), 0644))
+	require.Empty(t, manager.Update())
+	host, err := manager.Get(testSpaceMySQL, testKeyMySQLHost)
+	require.Empty(t, err)
+	require.Equal(t, "127.0.0.1", host)
+	_, err = manager.Get(testSpaceMySQL, testKeyMySQLPort)
+	require.NotEmpty(t, err)
+	_, err = manager.GetSpace("redis")
+	require.NotEmpty(t, err)
+}
+
+// 带默认值和校验规则的 mysql 配置
+type validateMySQLConfig struct {
+	Host     string `yaml:"host" validate:"required"`
+	Port     int    `yaml:"port" default:"3306" validate:"min=1,max=65535"`
+	User     string `yaml:"user" validate:"required"`
+	Database string `yaml:"database" default:"mysql"`
+}
+
+// 测试解析配置时填充默认值并校验
+func TestYamlConfigValidate(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "conf_validate.yaml")
+	require.Empty(t, ioutil.WriteFile(filePath, []byte("mysql:\n  host: localhost\n  user: root\n"), 0644))
+	manager, err := GetYamlConfigManager(filePath)
+	require.Empty(t, err)
+
+	mysqlConf := validateMySQLConfig{}
+	require.Empty(t, config.UnmarshalAndValidate(manager, testSpaceMySQL, &mysqlConf))
+	require.Equal(t, validateMySQLConfig{Host: testValueMySQLHost, Port: testValueMySQLPort, User: "root", Database: "mysql"}, mysqlConf)
+
+	// 配置空间不存在时按空配置校验
+	err = config.UnmarshalAndValidate(manager, "not_exist", &validateMySQLConfig{})
+	require.Equal(t, "code: config_203, msg: not_exist.host is required; not_exist.user is required", err.Error())
+}
@@ -0,0 +1,346 @@
+// package cache 查询结果缓存: 包装任意 RDBConnector，缓存 Search / Count / Distinct 的结果
+// 缓存 key 由查询的完整条件组成（表、字段、条件、join、排序、分页、结构体类型、是否查询软删除数据），Insert / Update / Delete / Exec 时清理相关表的缓存
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smiecj/go_common/db"
+)
+
+const (
+	// 默认缓存有效期
+	defaultTTL = time.Minute
+	// 默认最多缓存的查询结果数
+	defaultMaxSize = 1000
+
+	methodSearch   = "search"
+	methodCount    = "count"
+	methodDistinct = "distinct"
+)
+
+// 缓存配置
+type cacheOption struct {
+	ttl     time.Duration
+	maxSize int
+}
+
+type CacheConfigFunc func(*cacheOption)
+
+// 设置缓存有效期，默认 1 分钟
+func CacheSetTTL(ttl time.Duration) CacheConfigFunc {
+	return func(option *cacheOption) {
+		option.ttl = ttl
+	}
+}
+
+// 设置最多缓存的查询结果数，超过之后淘汰最久未使用的结果，默认 1000
+func CacheSetMaxSize(maxSize int) CacheConfigFunc {
+	return func(option *cacheOption) {
+		option.maxSize = maxSize
+	}
+}
+
+// 缓存统计
+type CacheStat struct {
+	Hit   int64
+	Miss  int64
+	Size  int
+	Evict int64
+}
+
+// 缓存的查询结果
+type cacheEntry struct {
+	key          string
+	spaceNameArr []string
+	ret          db.SearchRet
+	expireTime   time.Time
+}
+
+// 带查询缓存的连接器，未缓存的方法直接调用被包装的连接器
+type CachedConnector struct {
+	db.RDBConnector
+	option cacheOption
+
+	lock sync.Mutex
+	// 按最近使用排序，队首为最近使用
+	entryList *list.List
+	entryMap  map[string]*list.Element
+	// db.table -> 缓存 key
+	spaceKeyMap map[string]map[string]bool
+	// db.table -> 版本号，每次写入都会增加，用于丢弃写入前发起的查询结果
+	spaceVersionMap map[string]int64
+	// 全局版本号，Exec 等无法确定表的操作会增加
+	version int64
+	stat    CacheStat
+}
+
+// 创建带查询缓存的连接器
+func NewCachedConnector(connector db.RDBConnector, funcArr ...CacheConfigFunc) *CachedConnector {
+	option := cacheOption{ttl: defaultTTL, maxSize: defaultMaxSize}
+	for _, currentFunc := range funcArr {
+		currentFunc(&option)
+	}
+	cachedConnector := &CachedConnector{RDBConnector: connector, option: option}
+	cachedConnector.init()
+	return cachedConnector
+}
+
+func (connector *CachedConnector) init() {
+	connector.entryList = list.New()
+	connector.entryMap = make(map[string]*list.Element)
+	connector.spaceKeyMap = make(map[string]map[string]bool)
+	connector.spaceVersionMap = make(map[string]int64)
+}
+
+// 查询: 优先从缓存获取
+func (connector *CachedConnector) Search(funcArr ...db.RDBSearchConfigFunc) (db.SearchRet, error) {
+	return connector.cachedSearch(methodSearch, connector.RDBConnector.Search, funcArr...)
+}
+
+// 统计: 优先从缓存获取
+func (connector *CachedConnector) Count(funcArr ...db.RDBSearchConfigFunc) (db.SearchRet, error) {
+	return connector.cachedSearch(methodCount, connector.RDBConnector.Count, funcArr...)
+}
+
+// 去重查询: 优先从缓存获取
+func (connector *CachedConnector) Distinct(funcArr ...db.RDBSearchConfigFunc) (db.SearchRet, error) {
+	return connector.cachedSearch(methodDistinct, connector.RDBConnector.Distinct, funcArr...)
+}
+
+// 插入: 清理表缓存
+func (connector *CachedConnector) Insert(funcArr ...db.RDBInsertConfigFunc) (db.UpdateRet, error) {
+	action := db.MakeRDBInsertAction()
+	for _, currentFunc := range funcArr {
+		currentFunc(action)
+	}
+	defer connector.Invalidate(action.GetSpaceName())
+	return connector.RDBConnector.Insert(funcArr...)
+}
+
+// 更新: 清理表缓存
+func (connector *CachedConnector) Update(funcArr ...db.RDBUpdateConfigFunc) (db.UpdateRet, error) {
+	action := db.MakeRDBUpdateAction()
+	for _, currentFunc := range funcArr {
+		currentFunc(action)
+	}
+	defer connector.Invalidate(action.GetSpaceName())
+	return connector.RDBConnector.Update(funcArr...)
+}
+
+// 删除: 清理表缓存
+func (connector *CachedConnector) Delete(funcArr ...db.RDBDeleteConfigFunc) (db.UpdateRet, error) {
+	action := db.MakeRDBDeleteAction()
+	for _, currentFunc := range funcArr {
+		currentFunc(action)
+	}
+	defer connector.Invalidate(action.GetSpaceName())
+	return connector.RDBConnector.Delete(funcArr...)
+}
+
+// 备份: 清理目标表缓存
+func (connector *CachedConnector) Backup(funcArr ...db.RDBBackupConfigFunc) (db.UpdateRet, error) {
+	action := db.MakeRDBBackupAction()
+	for _, currentFunc := range funcArr {
+		currentFunc(action)
+	}
+	defer connector.Invalidate(action.GetTargetSpaceName())
+	return connector.RDBConnector.Backup(funcArr...)
+}
+
+// 执行语句: 设置了表时只清理该表缓存，否则无法确定影响的表，清理全部缓存
+func (connector *CachedConnector) Exec(funcArr ...db.RDBUpdateConfigFunc) (db.UpdateRet, error) {
+	action := db.MakeRDBUpdateAction()
+	for _, currentFunc := range funcArr {
+		currentFunc(action)
+	}
+	if spaceName := action.GetSpaceName(); isValidSpaceName(spaceName) {
+		defer connector.Invalidate(spaceName)
+	} else {
+		defer connector.Purge()
+	}
+	return connector.RDBConnector.Exec(funcArr...)
+}
+
+// 关闭: 清理全部缓存
+func (connector *CachedConnector) Close() error {
+	connector.Purge()
+	return connector.RDBConnector.Close()
+}
+
+// 清理指定表（格式: db.table）的缓存
+func (connector *CachedConnector) Invalidate(spaceName string) {
+	connector.lock.Lock()
+	defer connector.lock.Unlock()
+
+	connector.spaceVersionMap[spaceName]++
+	for key := range connector.spaceKeyMap[spaceName] {
+		if element, ok := connector.entryMap[key]; ok {
+			connector.removeElement(element)
+		}
+	}
+	delete(connector.spaceKeyMap, spaceName)
+}
+
+// 清理全部缓存
+func (connector *CachedConnector) Purge() {
+	connector.lock.Lock()
+	defer connector.lock.Unlock()
+
+	version := connector.version + 1
+	connector.init()
+	connector.version = version
+}
+
+// 获取缓存统计
+func (connector *CachedConnector) CacheStat() CacheStat {
+	connector.lock.Lock()
+	defer connector.lock.Unlock()
+
+	stat := connector.stat
+	stat.Size = connector.entryList.Len()
+	return stat
+}
+
+// 查询缓存，未命中时调用被包装的连接器查询并缓存结果（查询失败不缓存）
+func (connector *CachedConnector) cachedSearch(method string, searchFunc func(...db.RDBSearchConfigFunc) (db.SearchRet, error),
+	funcArr ...db.RDBSearchConfigFunc) (db.SearchRet, error) {
+	key, spaceNameArr := buildKey(method, funcArr...)
+
+	connector.lock.Lock()
+	if element, ok := connector.entryMap[key]; ok {
+		entry := element.Value.(*cacheEntry)
+		if time.Now().Before(entry.expireTime) {
+			connector.entryList.MoveToFront(element)
+			connector.stat.Hit++
+			connector.lock.Unlock()
+			return copySearchRet(entry.ret), nil
+		}
+		connector.removeElement(element)
+	}
+	connector.stat.Miss++
+	version, spaceVersionArr := connector.getVersion(spaceNameArr)
+	connector.lock.Unlock()
+
+	ret, err := searchFunc(funcArr...)
+	if nil != err {
+		return ret, err
+	}
+
+	connector.lock.Lock()
+	defer connector.lock.Unlock()
+	// 查询期间表有写入，结果可能已经过期，不缓存
+	currentVersion, currentSpaceVersionArr := connector.getVersion(spaceNameArr)
+	if currentVersion != version || !reflect.DeepEqual(currentSpaceVersionArr, spaceVersionArr) {
+		return ret, nil
+	}
+	connector.addEntry(&cacheEntry{key: key, spaceNameArr: spaceNameArr, ret: copySearchRet(ret),
+		expireTime: time.Now().Add(connector.option.ttl)})
+	return ret, nil
+}
+
+// 获取全局和表的版本号（调用方需要先加锁）
+func (connector *CachedConnector) getVersion(spaceNameArr []string) (int64, []int64) {
+	spaceVersionArr := make([]int64, 0, len(spaceNameArr))
+	for _, spaceName := range spaceNameArr {
+		spaceVersionArr = append(spaceVersionArr, connector.spaceVersionMap[spaceName])
+	}
+	return connector.version, spaceVersionArr
+}
+
+// 添加缓存，超过最大数量时淘汰最久未使用的结果（调用方需要先加锁）
+func (connector *CachedConnector) addEntry(entry *cacheEntry) {
+	if element, ok := connector.entryMap[entry.key]; ok {
+		connector.removeElement(element)
+	}
+	connector.entryMap[entry.key] = connector.entryList.PushFront(entry)
+	for _, spaceName := range entry.spaceNameArr {
+		if _, ok := connector.spaceKeyMap[spaceName]; !ok {
+			connector.spaceKeyMap[spaceName] = make(map[string]bool)
+		}
+		connector.spaceKeyMap[spaceName][entry.key] = true
+	}
+	for connector.option.maxSize > 0 && connector.entryList.Len() > connector.option.maxSize {
+		connector.removeElement(connector.entryList.Back())
+		connector.stat.Evict++
+	}
+}
+
+// 删除缓存（调用方需要先加锁）
+func (connector *CachedConnector) removeElement(element *list.Element) {
+	entry := element.Value.(*cacheEntry)
+	connector.entryList.Remove(element)
+	delete(connector.entryMap, entry.key)
+	for _, spaceName := range entry.spaceNameArr {
+		if keyMap, ok := connector.spaceKeyMap[spaceName]; ok {
+			delete(keyMap, entry.key)
+			if len(keyMap) == 0 {
+				delete(connector.spaceKeyMap, spaceName)
+			}
+		}
+	}
+}
+
+// 缓存 key: 查询方法 + 查询的完整条件（包括是否查询软删除数据），同时返回查询涉及的所有表（包括 join 和子查询的表）
+func buildKey(method string, funcArr ...db.RDBSearchConfigFunc) (string, []string) {
+	action := db.MakeRDBSearchAction()
+	for _, currentFunc := range funcArr {
+		currentFunc(action)
+	}
+	condition := action.GetCondition()
+	objectType := ""
+	if nil != action.GetObject() {
+		objectType = reflect.TypeOf(action.GetObject()).String()
+	}
+	if nil != action.GetObjectArrType() {
+		objectType = fmt.Sprintf("%s|%s", objectType, action.GetObjectArrType().String())
+	}
+	key := strings.Join([]string{
+		method,
+		action.GetSpaceName(),
+		action.GetAlias(),
+		strings.Join(action.GetKeyArr(), ","),
+		condition.WhereArr.ToSQL(),
+		condition.Join.ToSQL(),
+		strings.Join(condition.GetOrderFieldArr(), ","),
+		condition.Order.Sc,
+		fmt.Sprintf("%d,%d", condition.Page.No, condition.Page.Limit),
+		objectType,
+		strconv.FormatBool(action.IsIncludeDeleted()),
+		action.GetSQL(),
+	}, "\x00")
+	spaceNameArr := append([]string{action.GetSpaceName()}, condition.Join.GetSpaceNameArr()...)
+	return key, append(spaceNameArr, condition.WhereArr.GetSubQuerySpaceNameArr()...)
+}
+
+// 没有设置表时，表名为 "."
+func isValidSpaceName(spaceName string) bool {
+	return spaceName != "" && spaceName != "."
+}
+
+// 复制查询结果，防止调用方修改返回结果影响缓存
+func copySearchRet(ret db.SearchRet) db.SearchRet {
+	copyRet := db.SearchRet{Page: ret.Page, Len: ret.Len, Total: ret.Total}
+	for _, currentField := range ret.FieldArr {
+		copyField := db.BuildNewField()
+		copyField.AddMap(currentField.GetMap())
+		copyRet.AddField(copyField)
+	}
+	if nil != ret.ObjectArr {
+		objectArrValue := reflect.ValueOf(ret.ObjectArr)
+		if objectArrValue.Kind() == reflect.Slice {
+			copyValue := reflect.MakeSlice(objectArrValue.Type(), objectArrValue.Len(), objectArrValue.Len())
+			reflect.Copy(copyValue, objectArrValue)
+			copyRet.ObjectArr = copyValue.Interface()
+		} else {
+			copyRet.ObjectArr = ret.ObjectArr
+		}
+	}
+	return copyRet
+}
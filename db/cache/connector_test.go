@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/smiecj/go_common/db"
+	"github.com/smiecj/go_common/db/dbtest"
+	"github.com/smiecj/go_common/db/local"
+	"github.com/smiecj/go_common/db/policy"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testDB    = "temp"
+	testTable = "test_cache"
+)
+
+// 测试缓存命中、写入清理缓存、Exec 清理全部缓存
+func TestCachedConnector(t *testing.T) {
+	mockConnector := dbtest.NewMockConnector()
+	connector := NewCachedConnector(mockConnector)
+
+	mockConnector.Expect(dbtest.MethodSearch).Space(testDB, testTable).Condition("name", "=", "xiaoming").
+		ReturnRows(map[string]string{"name": "xiaoming"}).Times(2)
+	mockConnector.Expect(dbtest.MethodCount).Space(testDB, testTable).
+		ReturnSearchRet(db.SearchRet{Total: 3}).Times(2)
+	mockConnector.Expect(dbtest.MethodInsert).Space(testDB, testTable).ReturnAffectedRows(1)
+	mockConnector.Expect(dbtest.MethodExec).ReturnAffectedRows(1)
+
+	// 相同条件只查询一次
+	for i := 0; i < 3; i++ {
+		searchRet, err := connector.Search(db.SearchSetSpace(testDB, testTable), db.SearchSetCondition("name", "=", "xiaoming"))
+		require.Empty(t, err)
+		require.Equal(t, 1, searchRet.Len)
+		require.Equal(t, "xiaoming", searchRet.FieldArr[0].GetMap()["name"])
+		// 修改返回结果不影响缓存
+		searchRet.FieldArr[0].AddKeyValue("name", "modified")
+	}
+	countRet, err := connector.Count(db.SearchSetSpace(testDB, testTable))
+	require.Empty(t, err)
+	require.Equal(t, 3, countRet.Total)
+	require.Equal(t, CacheStat{Hit: 2, Miss: 2, Size: 2}, connector.CacheStat())
+
+	// 插入数据之后，表缓存失效
+	field := db.BuildNewField()
+	field.AddKeyValue("name", "xiaohong")
+	_, err = connector.Insert(db.InsertSetSpace(testDB, testTable), db.InsertAddField(field))
+	require.Empty(t, err)
+	require.Equal(t, 0, connector.CacheStat().Size)
+	_, err = connector.Search(db.SearchSetSpace(testDB, testTable), db.SearchSetCondition("name", "=", "xiaoming"))
+	require.Empty(t, err)
+
+	// Exec 没有指定表，清理全部缓存
+	_, err = connector.Exec(db.UpdateSetSQL("truncate table temp.test_cache"))
+	require.Empty(t, err)
+	require.Equal(t, 0, connector.CacheStat().Size)
+	_, err = connector.Count(db.SearchSetSpace(testDB, testTable))
+	require.Empty(t, err)
+
+	require.Empty(t, mockConnector.ExpectationsWereMet())
+}
+
+// 测试有效期、容量限制和 join 表的缓存清理
+func TestCachedConnectorLimit(t *testing.T) {
+	mockConnector := dbtest.NewMockConnector()
+	mockConnector.Expect(dbtest.MethodSearch).ReturnRows(map[string]string{"id": "1"}).AnyTimes()
+	mockConnector.Expect(dbtest.MethodUpdate).AnyTimes()
+	connector := NewCachedConnector(mockConnector, CacheSetTTL(50*time.Millisecond), CacheSetMaxSize(2))
+
+	search := func(table string, funcArr ...db.RDBSearchConfigFunc) {
+		_, err := connector.Search(append([]db.RDBSearchConfigFunc{db.SearchSetSpace(testDB, table)}, funcArr...)...)
+		require.Empty(t, err)
+	}
+
+	// 超过容量，淘汰最久未使用的结果
+	search("a")
+	search("b")
+	search("a")
+	search("c")
+	require.Equal(t, CacheStat{Hit: 1, Miss: 3, Size: 2, Evict: 1}, connector.CacheStat())
+	search("a")
+	require.Equal(t, int64(2), connector.CacheStat().Hit)
+
+	// 过期之后重新查询
+	time.Sleep(100 * time.Millisecond)
+	search("a")
+	require.Equal(t, int64(2), connector.CacheStat().Hit)
+
+	// 更新 join 的表，查询缓存也会失效
+	connector.Purge()
+	require.Equal(t, 0, connector.CacheStat().Size)
+	search("a", db.SearchAddJoin(testDB, "a", "id", "b", "a_id"))
+	search("a", db.SearchAddJoin(testDB, "a", "id", "b", "a_id"))
+	require.Equal(t, CacheStat{Hit: 3, Miss: 5, Size: 1, Evict: 1}, connector.CacheStat())
+	field := db.BuildNewField()
+	field.AddKeyValue("a_id", "2")
+	_, err := connector.Update(db.UpdateSetSpace(testDB, "b"), db.UpdateAddField(field))
+	require.Empty(t, err)
+	require.Equal(t, 0, connector.CacheStat().Size)
+}
+
+// 测试包装软删除策略连接器: 是否查询已删除数据使用不同的缓存
+func TestCachedPolicyConnector(t *testing.T) {
+	memoryConnector, err := local.GetLocalMemoryConnector()
+	require.Empty(t, err)
+	connector := NewCachedConnector(policy.NewPolicyConnector(memoryConnector,
+		policy.PolicyAddRule(testDB, testTable, policy.RuleSetSoftDelete("deleted_at"))))
+	defer memoryConnector.Delete(db.DeleteSetSpace(testDB, testTable))
+
+	for _, name := range []string{"xiaoming", "xiaohong"} {
+		field := db.BuildNewField()
+		field.AddKeyValue("name", name)
+		_, err = connector.Insert(db.InsertSetSpace(testDB, testTable), db.InsertAddField(field))
+		require.Empty(t, err)
+	}
+	_, err = connector.Delete(db.DeleteSetSpace(testDB, testTable), db.DeleteSetCondition("name", "=", "xiaohong"))
+	require.Empty(t, err)
+
+	for i := 0; i < 2; i++ {
+		countRet, err := connector.Count(db.SearchSetSpace(testDB, testTable))
+		require.Empty(t, err)
+		require.Equal(t, 1, countRet.Total)
+		countRet, err = connector.Count(db.SearchSetSpace(testDB, testTable), db.SearchIncludeDeleted())
+		require.Empty(t, err)
+		require.Equal(t, 2, countRet.Total)
+	}
+	require.Equal(t, CacheStat{Hit: 2, Miss: 2, Size: 2}, connector.CacheStat())
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/smiecj/go_common/errorcode"
+	"github.com/smiecj/go_common/util/file"
+)
+
+// 断点信息存储，用于数据复制、变更监听等需要断点续传的场景
+type Checkpoint interface {
+	// 读取断点，没有断点时返回空字符串
+	Load() (string, error)
+	// 保存断点
+	Save(string) error
+}
+
+// 本地文件断点存储
+type fileCheckpoint struct {
+	filePath string
+	lock     sync.Mutex
+}
+
+// 读取断点: 文件不存在时返回空
+func (checkpoint *fileCheckpoint) Load() (string, error) {
+	checkpoint.lock.Lock()
+	defer checkpoint.lock.Unlock()
+
+	contentBytes, err := ioutil.ReadFile(checkpoint.filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	} else if nil != err {
+		return "", errorcode.BuildErrorWithMsg(errorcode.DBExecFailed, err.Error())
+	}
+	return string(contentBytes), nil
+}
+
+// 保存断点: 原子写入，防止写入中断导致断点损坏
+func (checkpoint *fileCheckpoint) Save(value string) error {
+	checkpoint.lock.Lock()
+	defer checkpoint.lock.Unlock()
+
+	err := file.WriteAtomic(checkpoint.filePath, []byte(value))
+	if nil != err {
+		return errorcode.BuildErrorWithMsg(errorcode.DBExecFailed, err.Error())
+	}
+	return nil
+}
+
+// 获取本地文件断点存储，文件所在目录不存在时会自动创建
+func NewFileCheckpoint(filePath string) (Checkpoint, error) {
+	err := os.MkdirAll(filepath.Dir(filePath), 0755)
+	if nil != err {
+		return nil, errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid, err.Error())
+	}
+	return &fileCheckpoint{filePath: filePath}, nil
+}
+
+// 数据库断点存储: 断点保存在指定表中，一个名称对应一行，建表语句参考 checkpoint_init.sql
+type connectorCheckpoint struct {
+	connector RDBConnector
+	dbName    string
+	table     string
+	name      string
+}
+
+// 读取断点: 没有对应记录时返回空
+func (checkpoint *connectorCheckpoint) Load() (string, error) {
+	searchRet, err := checkpoint.connector.Search(SearchSetSpace(checkpoint.dbName, checkpoint.table),
+		SearchSetCondition("name", "=", checkpoint.name), SearchSetKeyArr([]string{"value"}))
+	if nil != err {
+		return "", err
+	}
+	if len(searchRet.FieldArr) == 0 {
+		return "", nil
+	}
+	return searchRet.FieldArr[0].GetMap()["value"], nil
+}
+
+// 保存断点: 优先更新已有记录，没有记录时插入
+func (checkpoint *connectorCheckpoint) Save(value string) error {
+	currentField := BuildNewField()
+	currentField.AddKeyValue("value", value)
+	currentField.AddKeyValue("update_time", time.Now().Format(objectTimeFormat))
+	updateRet, err := checkpoint.connector.Update(UpdateSetSpace(checkpoint.dbName, checkpoint.table),
+		UpdateSetCondition("name", "=", checkpoint.name), UpdateAddField(currentField))
+	if nil != err {
+		return err
+	}
+	if updateRet.AffectedRows != 0 {
+		return nil
+	}
+
+	// 更新行数为 0 也可能是取值没有变化，需要先确认记录是否存在
+	countRet, err := checkpoint.connector.Count(SearchSetSpace(checkpoint.dbName, checkpoint.table),
+		SearchSetCondition("name", "=", checkpoint.name))
+	if nil != err {
+		return err
+	}
+	if countRet.Total != 0 {
+		return nil
+	}
+	currentField.AddKeyValue("name", checkpoint.name)
+	_, err = checkpoint.connector.Insert(InsertSetSpace(checkpoint.dbName, checkpoint.table),
+		InsertAddField(currentField), InsertAppend())
+	return err
+}
+
+// 获取数据库断点存储
+func NewConnectorCheckpoint(connector RDBConnector, dbName, table, name string) (Checkpoint, error) {
+	if nil == connector || table == "" || name == "" {
+		return nil, errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid, "connector, table and name must be set")
+	}
+	return &connectorCheckpoint{connector: connector, dbName: dbName, table: table, name: name}, nil
+}
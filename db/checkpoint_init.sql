@@ -0,0 +1,12 @@
+USE `d_meta`;
+
+-- checkpoint
+DROP TABLE IF EXISTS `t_checkpoint`;
+CREATE TABLE IF NOT EXISTS `t_checkpoint` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `name` varchar(64) DEFAULT '' COMMENT '断点名称，一般是任务名',
+  `value` varchar(256) DEFAULT '' COMMENT '断点取值，如水位线',
+  `update_time` varchar(32) DEFAULT "" COMMENT '最近更新时间',
+  PRIMARY KEY (`id`),
+  UNIQUE(name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 AUTO_INCREMENT=1 COMMENT="数据复制、变更监听等任务的断点表";
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/smiecj/go_common/errorcode"
+)
+
+// 分批写入时单个批次的结果
+type ChunkRet struct {
+	// 批次序号，从 0 开始
+	Index int
+	// 批次数据在写入数据中的范围: [Start, End)
+	Start int
+	End   int
+	// 当前批次影响的行数，事务回滚后为 0
+	AffectedRows int
+	// 前面的批次失败后没有执行
+	Skipped bool
+	// 当前批次的错误信息
+	Err error
+}
+
+// 按照单批数据量将写入数据拆分成多个批次
+// 没有设置单批数据量时，所有数据作为一个批次
+func (action *rdbInsertAction) SplitChunk() []ChunkRet {
+	rowCount := action.RowCount()
+	batch := action.batch
+	if batch <= 0 || batch > rowCount {
+		batch = rowCount
+	}
+
+	chunkRetArr := make([]ChunkRet, 0)
+	for start := 0; start < rowCount; start += batch {
+		end := start + batch
+		if end > rowCount {
+			end = rowCount
+		}
+		chunkRetArr = append(chunkRetArr, ChunkRet{Index: len(chunkRetArr), Start: start, End: end})
+	}
+	return chunkRetArr
+}
+
+// 根据各批次的结果生成错误信息，列出所有失败的批次，全部成功时返回 nil
+func BuildChunkError(chunkRetArr []ChunkRet) error {
+	errBuf := new(bytes.Buffer)
+	for _, currentChunkRet := range chunkRetArr {
+		if nil == currentChunkRet.Err {
+			continue
+		}
+		if errBuf.Len() != 0 {
+			errBuf.WriteString("; ")
+		}
+		errBuf.WriteString(fmt.Sprintf("chunk %d [%d, %d): %s", currentChunkRet.Index,
+			currentChunkRet.Start, currentChunkRet.End, currentChunkRet.Err.Error()))
+	}
+	if errBuf.Len() == 0 {
+		return nil
+	}
+	return errorcode.BuildErrorWithMsg(errorcode.DBExecFailed, errBuf.String())
+}
+
+// 检查设置的变更数据量: 需要在 (0, maxModifyLimit] 范围内，没有设置时由各操作使用默认值
+func checkModifyLimit(limit int) error {
+	if limit <= 0 || limit > maxModifyLimit {
+		return errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid,
+			fmt.Sprintf("modify limit %d out of range (0, %d]", limit, maxModifyLimit))
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/smiecj/go_common/errorcode"
+	"github.com/stretchr/testify/require"
+)
+
+// 测试分批写入的批次拆分和错误汇总
+func TestInsertChunk(t *testing.T) {
+	fieldInsertFuncArr := make([]RDBInsertConfigFunc, 0)
+	for index := 0; index < 5; index++ {
+		field := BuildNewField()
+		field.AddKeyValue("name", fmt.Sprintf("stu-%d", index))
+		fieldInsertFuncArr = append(fieldInsertFuncArr, InsertAddField(field))
+	}
+
+	action := MakeRDBInsertAction()
+	for _, currentFunc := range append(fieldInsertFuncArr, InsertBatch(2)) {
+		currentFunc(action)
+	}
+	chunkRetArr := action.SplitChunk()
+	require.Equal(t, 3, len(chunkRetArr))
+	require.Equal(t, ChunkRet{Index: 2, Start: 4, End: 5}, chunkRetArr[2])
+	require.Nil(t, BuildChunkError(chunkRetArr))
+
+	chunkRetArr[1].Err = fmt.Errorf("duplicate key")
+	err := BuildChunkError(chunkRetArr)
+	require.True(t, errors.Is(err, errorcode.BuildError(errorcode.DBExecFailed)))
+	require.Contains(t, err.Error(), "chunk 1 [2, 4): duplicate key")
+
+	// 没有设置单批数据量: 只有一个批次
+	action = MakeRDBInsertAction()
+	for _, currentFunc := range fieldInsertFuncArr {
+		currentFunc(action)
+	}
+	require.Equal(t, []ChunkRet{{Index: 0, Start: 0, End: 5}}, action.SplitChunk())
+	require.Nil(t, action.CheckLimit())
+}
+
+// 测试变更数据量限制: 没有设置时使用默认值，设置的取值超出范围时返回错误
+func TestModifyLimit(t *testing.T) {
+	deleteAction := MakeRDBDeleteAction()
+	require.Nil(t, deleteAction.CheckLimit())
+	require.Equal(t, maxModifyLimit, deleteAction.GetCondition().Limit)
+	DeleteSetLimit(10)(deleteAction)
+	require.Nil(t, deleteAction.CheckLimit())
+	require.Equal(t, 10, deleteAction.GetCondition().Limit)
+
+	for _, limit := range []int{0, -1, maxModifyLimit + 1} {
+		deleteAction = MakeRDBDeleteAction()
+		DeleteSetLimit(limit)(deleteAction)
+		require.True(t, errors.Is(deleteAction.CheckLimit(), errorcode.BuildError(errorcode.DBParamInvalid)))
+
+		backupAction := MakeRDBBackupAction()
+		BackupSetLimit(limit)(backupAction)
+		require.True(t, errors.Is(backupAction.CheckLimit(), errorcode.BuildError(errorcode.DBParamInvalid)))
+
+		updateAction := MakeRDBUpdateAction()
+		UpdateSetLimit(limit)(updateAction)
+		require.True(t, errors.Is(updateAction.CheckLimit(), errorcode.BuildError(errorcode.DBParamInvalid)))
+	}
+
+	updateAction := MakeRDBUpdateAction()
+	require.Nil(t, updateAction.CheckLimit())
+	require.Equal(t, 0, updateAction.GetCondition().Limit)
+}
@@ -0,0 +1,332 @@
+// package codegen 根据 mysql information_schema 中的表结构，生成带 gorm / json tag 的结构体和字段名常量
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/smiecj/go_common/db"
+	"github.com/smiecj/go_common/errorcode"
+)
+
+const (
+	schemaDB     = "information_schema"
+	schemaColumn = "COLUMNS"
+	schemaTable  = "TABLES"
+
+	defaultPackageName = "model"
+	// 生成的结构体方法名，字段名不能和方法名相同，和 fileTemplate 保持一致
+	tableNameMethod = "TableName"
+)
+
+var (
+	// mysql 数据类型 和 go 类型的对应关系，未定义的类型都使用 string
+	dataTypeMap = map[string]string{
+		"tinyint":    "int8",
+		"smallint":   "int16",
+		"mediumint":  "int32",
+		"int":        "int",
+		"integer":    "int",
+		"bigint":     "int64",
+		"float":      "float32",
+		"double":     "float64",
+		"decimal":    "float64",
+		"bit":        "[]byte",
+		"binary":     "[]byte",
+		"varbinary":  "[]byte",
+		"tinyblob":   "[]byte",
+		"blob":       "[]byte",
+		"mediumblob": "[]byte",
+		"longblob":   "[]byte",
+	}
+	unsignedTypeMap = map[string]string{
+		"int8":  "uint8",
+		"int16": "uint16",
+		"int32": "uint32",
+		"int":   "uint",
+		"int64": "uint64",
+	}
+
+	fileTemplate = template.Must(template.New("codegen").Parse(`// Code generated by dbgen. DO NOT EDIT.
+
+package {{ .Package }}
+{{ range $table := .TableArr }}
+// {{ .Name }} 表名和字段名
+const (
+	{{ .StructName }}Table = "{{ .Name }}"
+{{- range .ColumnArr }}
+	{{ $table.StructName }}Column{{ .FieldName }} = "{{ .ColumnName }}"
+{{- end }}
+)
+
+// {{ .Name }} 全部字段，可用于 db.InsertAddKeyArr / db.SearchSetKeyArr
+var {{ .StructName }}ColumnArr = []string{ {{- range $index, $column := .ColumnArr }}{{ if $index }}, {{ end }}{{ $table.StructName }}Column{{ .FieldName }}{{ end -}} }
+
+// {{ .StructName }} {{ if .Comment }}{{ .Comment }}{{ else }}{{ .Name }} 表结构{{ end }}
+type {{ .StructName }} struct {
+{{- range .ColumnArr }}
+	{{ .FieldName }} {{ .GoType }} ` + "`" + `gorm:"column:{{ .ColumnName }}" json:"{{ .ColumnName }}"` + "`" + `{{ if .ColumnComment }} // {{ .ColumnComment }}{{ end }}
+{{- end }}
+}
+
+func ({{ .StructName }}) TableName() string {
+	return {{ .StructName }}Table
+}
+{{ end }}`))
+)
+
+// information_schema.COLUMNS 中的字段信息
+type Column struct {
+	TableName       string `gorm:"column:TABLE_NAME"`
+	ColumnName      string `gorm:"column:COLUMN_NAME"`
+	OrdinalPosition int    `gorm:"column:ORDINAL_POSITION"`
+	DataType        string `gorm:"column:DATA_TYPE"`
+	ColumnType      string `gorm:"column:COLUMN_TYPE"`
+	IsNullable      string `gorm:"column:IS_NULLABLE"`
+	ColumnComment   string `gorm:"column:COLUMN_COMMENT"`
+}
+
+type columnSlice []Column
+
+// information_schema.TABLES 中的表信息
+type tableInfo struct {
+	TableName    string `gorm:"column:TABLE_NAME"`
+	TableComment string `gorm:"column:TABLE_COMMENT"`
+}
+
+type tableInfoSlice []tableInfo
+
+// 表结构
+type Table struct {
+	Name      string
+	Comment   string
+	ColumnArr []Column
+}
+
+// 从 information_schema 中读取表结构，tablePattern 格式和 SQL like 一致，如 t_%
+func LoadTableArr(connector db.RDBConnector, dbName, tablePattern string) ([]Table, error) {
+	if dbName == "" {
+		return nil, errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid, "[codegen.LoadTableArr] db name is empty")
+	}
+	if tablePattern == "" {
+		tablePattern = "%"
+	}
+
+	tableRet, err := connector.Search(db.SearchSetSpace(schemaDB, schemaTable),
+		db.SearchSetKeyArr([]string{"TABLE_NAME", "TABLE_COMMENT"}),
+		db.SearchSetCondition("TABLE_SCHEMA", "=", dbName, "and", "TABLE_NAME", "like", tablePattern),
+		db.SearchSetObjectArrType(tableInfoSlice{}))
+	if nil != err {
+		return nil, err
+	}
+	tableInfoArr, ok := tableRet.ObjectArr.(tableInfoSlice)
+	if !ok {
+		return nil, errorcode.BuildErrorWithMsg(errorcode.DBDataBroken, "[codegen.LoadTableArr] convert table info failed")
+	}
+	if len(tableInfoArr) == 0 {
+		return nil, errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid,
+			fmt.Sprintf("[codegen.LoadTableArr] no table match %s.%s", dbName, tablePattern))
+	}
+
+	columnRet, err := connector.Search(db.SearchSetSpace(schemaDB, schemaColumn),
+		db.SearchSetKeyArr([]string{"TABLE_NAME", "COLUMN_NAME", "ORDINAL_POSITION", "DATA_TYPE",
+			"COLUMN_TYPE", "IS_NULLABLE", "COLUMN_COMMENT"}),
+		db.SearchSetCondition("TABLE_SCHEMA", "=", dbName, "and", "TABLE_NAME", "like", tablePattern),
+		db.SearchSetObjectArrType(columnSlice{}))
+	if nil != err {
+		return nil, err
+	}
+	columnArr, ok := columnRet.ObjectArr.(columnSlice)
+	if !ok {
+		return nil, errorcode.BuildErrorWithMsg(errorcode.DBDataBroken, "[codegen.LoadTableArr] convert column failed")
+	}
+
+	tableMap := make(map[string]*Table, len(tableInfoArr))
+	for _, currentTableInfo := range tableInfoArr {
+		tableMap[currentTableInfo.TableName] = &Table{Name: currentTableInfo.TableName, Comment: currentTableInfo.TableComment}
+	}
+	for _, currentColumn := range columnArr {
+		// 视图等只出现在 COLUMNS 中的表也需要生成
+		currentTable, ok := tableMap[currentColumn.TableName]
+		if !ok {
+			currentTable = &Table{Name: currentColumn.TableName}
+			tableMap[currentColumn.TableName] = currentTable
+		}
+		currentTable.ColumnArr = append(currentTable.ColumnArr, currentColumn)
+	}
+
+	// 按表名和字段顺序排序，保证每次生成结果一致
+	tableArr := make([]Table, 0, len(tableMap))
+	for _, currentTable := range tableMap {
+		if len(currentTable.ColumnArr) == 0 {
+			continue
+		}
+		sort.SliceStable(currentTable.ColumnArr, func(i, j int) bool {
+			return currentTable.ColumnArr[i].OrdinalPosition < currentTable.ColumnArr[j].OrdinalPosition
+		})
+		tableArr = append(tableArr, *currentTable)
+	}
+	sort.Slice(tableArr, func(i, j int) bool {
+		return tableArr[i].Name < tableArr[j].Name
+	})
+	return tableArr, nil
+}
+
+// 代码生成配置
+type generateOption struct {
+	packageName string
+	trimPrefix  string
+}
+
+type GenerateConfigFunc func(*generateOption)
+
+// 设置生成代码的包名，默认 model
+func GenerateSetPackage(packageName string) GenerateConfigFunc {
+	return func(option *generateOption) {
+		option.packageName = packageName
+	}
+}
+
+// 设置生成结构体名时需要去掉的表名前缀，如 t_
+func GenerateSetTrimPrefix(prefix string) GenerateConfigFunc {
+	return func(option *generateOption) {
+		option.trimPrefix = prefix
+	}
+}
+
+// 模板中的表结构
+type templateTable struct {
+	Name       string
+	Comment    string
+	StructName string
+	ColumnArr  []templateColumn
+}
+
+// 模板中的字段
+type templateColumn struct {
+	ColumnName    string
+	ColumnComment string
+	FieldName     string
+	GoType        string
+}
+
+// 生成 go 代码: 每张表生成表名、字段名常量，全部字段数组，以及带 gorm / json tag 的结构体
+func Generate(tableArr []Table, funcArr ...GenerateConfigFunc) ([]byte, error) {
+	option := generateOption{packageName: defaultPackageName}
+	for _, currentFunc := range funcArr {
+		currentFunc(&option)
+	}
+
+	templateTableArr := make([]templateTable, 0, len(tableArr))
+	// 生成代码中包级别的标识符（结构体名、表名常量、字段名常量、字段数组）和来源，不能重复
+	identMap := make(map[string]string)
+	addIdent := func(ident, source string) error {
+		if existSource, ok := identMap[ident]; ok {
+			return errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid,
+				fmt.Sprintf("[codegen.Generate] %s and %s generate same name %s", existSource, source, ident))
+		}
+		identMap[ident] = source
+		return nil
+	}
+	for _, currentTable := range tableArr {
+		structName := toCamelCase(strings.TrimPrefix(currentTable.Name, option.trimPrefix))
+		tableSource := fmt.Sprintf("table %s", currentTable.Name)
+		for _, ident := range []string{structName, structName + "Table", structName + "ColumnArr"} {
+			if err := addIdent(ident, tableSource); nil != err {
+				return nil, err
+			}
+		}
+
+		currentTemplateTable := templateTable{Name: currentTable.Name, Comment: singleLine(currentTable.Comment), StructName: structName}
+		// 结构体字段名不能重复，也不能和 TableName 方法重名
+		fieldMap := map[string]string{tableNameMethod: "method"}
+		for _, currentColumn := range currentTable.ColumnArr {
+			fieldName := toCamelCase(currentColumn.ColumnName)
+			columnSource := fmt.Sprintf("column %s.%s", currentTable.Name, currentColumn.ColumnName)
+			if existSource, ok := fieldMap[fieldName]; ok {
+				return nil, errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid,
+					fmt.Sprintf("[codegen.Generate] %s and %s generate same field %s.%s", existSource, columnSource, structName, fieldName))
+			}
+			fieldMap[fieldName] = columnSource
+			if err := addIdent(structName+"Column"+fieldName, columnSource); nil != err {
+				return nil, err
+			}
+
+			currentTemplateTable.ColumnArr = append(currentTemplateTable.ColumnArr, templateColumn{
+				ColumnName:    currentColumn.ColumnName,
+				ColumnComment: singleLine(currentColumn.ColumnComment),
+				FieldName:     fieldName,
+				GoType:        goType(currentColumn),
+			})
+		}
+		templateTableArr = append(templateTableArr, currentTemplateTable)
+	}
+
+	buffer := new(bytes.Buffer)
+	err := fileTemplate.Execute(buffer, map[string]interface{}{
+		"Package":  option.packageName,
+		"TableArr": templateTableArr,
+	})
+	if nil != err {
+		return nil, errorcode.BuildErrorWithMsg(errorcode.ServiceError, err.Error())
+	}
+	formatBytes, err := format.Source(buffer.Bytes())
+	if nil != err {
+		return nil, errorcode.BuildErrorWithMsg(errorcode.ServiceError, fmt.Sprintf("[codegen.Generate] format failed: %s", err.Error()))
+	}
+	return formatBytes, nil
+}
+
+// 字段对应的 go 类型: 无符号整数使用 uint，可以为 NULL 的字段使用指针
+func goType(column Column) string {
+	dataType := strings.ToLower(column.DataType)
+	goType, ok := dataTypeMap[dataType]
+	if !ok {
+		goType = "string"
+	}
+	// tinyint(1) 一般用于表示 bool
+	if dataType == "tinyint" && strings.HasPrefix(strings.ToLower(column.ColumnType), "tinyint(1)") {
+		goType = "bool"
+	}
+	if unsignedType, ok := unsignedTypeMap[goType]; ok && strings.Contains(strings.ToLower(column.ColumnType), "unsigned") {
+		goType = unsignedType
+	}
+	if strings.EqualFold(column.IsNullable, "YES") && goType != "[]byte" {
+		goType = "*" + goType
+	}
+	return goType
+}
+
+// 下划线命名 转换成 驼峰命名: class_id -> ClassId
+func toCamelCase(name string) string {
+	buffer := new(bytes.Buffer)
+	isUpper := true
+	for _, currentRune := range name {
+		if !unicode.IsLetter(currentRune) && !unicode.IsDigit(currentRune) {
+			isUpper = true
+			continue
+		}
+		if isUpper {
+			buffer.WriteRune(unicode.ToUpper(currentRune))
+			isUpper = false
+		} else {
+			buffer.WriteRune(currentRune)
+		}
+	}
+	camelName := buffer.String()
+	// 数字开头的名称不能作为标识符
+	if camelName == "" || unicode.IsDigit([]rune(camelName)[0]) {
+		camelName = "X" + camelName
+	}
+	return camelName
+}
+
+// 注释中的换行替换成空格
+func singleLine(comment string) string {
+	return strings.Join(strings.Fields(comment), " ")
+}
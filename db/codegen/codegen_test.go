@@ -0,0 +1,106 @@
+package codegen
+
+import (
+	"errors"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/smiecj/go_common/db/dbtest"
+	"github.com/smiecj/go_common/errorcode"
+	"github.com/stretchr/testify/require"
+)
+
+// 测试读取表结构和生成代码
+func TestGenerate(t *testing.T) {
+	connector := dbtest.NewMockConnector()
+	connector.Expect(dbtest.MethodSearch).Space(schemaDB, schemaTable).
+		Condition("TABLE_SCHEMA", "=", "d_meta", "and", "TABLE_NAME", "like", "t_%").
+		ReturnObjectArr(tableInfoSlice{{TableName: "t_lock", TableComment: "cluster lock"}, {TableName: "t_checkpoint"}})
+	connector.Expect(dbtest.MethodSearch).Space(schemaDB, schemaColumn).
+		Condition("TABLE_SCHEMA", "=", "d_meta", "and", "TABLE_NAME", "like", "t_%").
+		ReturnObjectArr(columnSlice{
+			{TableName: "t_lock", ColumnName: "version", OrdinalPosition: 2, DataType: "bigint", ColumnType: "bigint(20) unsigned", IsNullable: "NO"},
+			{TableName: "t_lock", ColumnName: "name", OrdinalPosition: 1, DataType: "varchar", ColumnType: "varchar(64)", IsNullable: "NO", ColumnComment: "lock\nname"},
+			{TableName: "t_lock", ColumnName: "update_time", OrdinalPosition: 3, DataType: "datetime", ColumnType: "datetime", IsNullable: "NO"},
+			{TableName: "t_lock", ColumnName: "env", OrdinalPosition: 4, DataType: "varchar", ColumnType: "varchar(32)", IsNullable: "YES"},
+			{TableName: "t_checkpoint", ColumnName: "value", OrdinalPosition: 2, DataType: "text", ColumnType: "text", IsNullable: "NO"},
+			{TableName: "t_checkpoint", ColumnName: "is_valid", OrdinalPosition: 1, DataType: "tinyint", ColumnType: "tinyint(1)", IsNullable: "NO"},
+		})
+
+	tableArr, err := LoadTableArr(connector, "d_meta", "t_%")
+	require.Empty(t, err)
+	require.Empty(t, connector.ExpectationsWereMet())
+	require.Equal(t, 2, len(tableArr))
+	require.Equal(t, "t_checkpoint", tableArr[0].Name)
+	require.Equal(t, "t_lock", tableArr[1].Name)
+	require.Equal(t, "name", tableArr[1].ColumnArr[0].ColumnName)
+
+	code, err := Generate(tableArr, GenerateSetPackage("meta"), GenerateSetTrimPrefix("t_"))
+	require.Empty(t, err)
+	codeStr := string(code)
+
+	// 生成的代码需要能通过语法检查
+	_, err = parser.ParseFile(token.NewFileSet(), "meta.go", code, parser.ParseComments)
+	require.Empty(t, err)
+
+	require.True(t, strings.HasPrefix(codeStr, "// Code generated by dbgen. DO NOT EDIT."))
+	// 忽略 gofmt 对齐产生的空格差异
+	fieldStr := strings.Join(strings.Fields(codeStr), " ")
+	for _, expectStr := range []string{
+		"package meta",
+		`LockTable = "t_lock"`,
+		`LockColumnUpdateTime = "update_time"`,
+		"var LockColumnArr = []string{LockColumnName, LockColumnVersion, LockColumnUpdateTime, LockColumnEnv}",
+		"// Lock cluster lock",
+		"Name string `gorm:\"column:name\" json:\"name\"` // lock name",
+		"Version uint64 `gorm:\"column:version\" json:\"version\"`",
+		"Env *string `gorm:\"column:env\" json:\"env\"`",
+		"IsValid bool `gorm:\"column:is_valid\" json:\"is_valid\"`",
+		"func (Checkpoint) TableName() string",
+	} {
+		require.Contains(t, fieldStr, expectStr)
+	}
+
+	// 没有匹配的表
+	connector = dbtest.NewMockConnector()
+	connector.Expect(dbtest.MethodSearch).Space(schemaDB, schemaTable).ReturnObjectArr(tableInfoSlice{})
+	_, err = LoadTableArr(connector, "d_meta", "t_not_exist")
+	require.NotEmpty(t, err)
+}
+
+// 测试生成的标识符冲突: 结构体名、字段名、方法名、表名和字段名常量重复时返回参数错误
+func TestGenerateNameConflict(t *testing.T) {
+	column := func(name string) Column {
+		return Column{ColumnName: name, DataType: "varchar"}
+	}
+	for _, tableArr := range [][]Table{
+		// 去掉前缀之后结构体名相同
+		{{Name: "t_user", ColumnArr: []Column{column("id")}}, {Name: "user", ColumnArr: []Column{column("id")}}},
+		// 字段名和 TableName 方法重名
+		{{Name: "user", ColumnArr: []Column{column("id"), column("table_name")}}},
+		// user 表的表名常量 UserTable 和 user_table 表的结构体名相同
+		{{Name: "user", ColumnArr: []Column{column("id")}}, {Name: "user_table", ColumnArr: []Column{column("id")}}},
+		// 不同字段转换成相同的字段名
+		{{Name: "user", ColumnArr: []Column{column("user_id"), column("userId")}}},
+		// 字段名常量 UserColumnArr 和全部字段数组重名
+		{{Name: "user", ColumnArr: []Column{column("arr")}}},
+	} {
+		_, err := Generate(tableArr, GenerateSetTrimPrefix("t_"))
+		require.True(t, errors.Is(err, errorcode.BuildError(errorcode.DBParamInvalid)), tableArr)
+	}
+
+	code, err := Generate([]Table{{Name: "user", ColumnArr: []Column{column("id"), column("table")}},
+		{Name: "user_info", ColumnArr: []Column{column("id")}}})
+	require.Empty(t, err)
+	_, err = parser.ParseFile(token.NewFileSet(), "model.go", code, parser.ParseComments)
+	require.Empty(t, err)
+}
+
+// 测试命名转换
+func TestToCamelCase(t *testing.T) {
+	require.Equal(t, "ClassId", toCamelCase("class_id"))
+	require.Equal(t, "UpdateTime", toCamelCase("update-time"))
+	require.Equal(t, "X2fa", toCamelCase("2fa"))
+}
@@ -4,6 +4,8 @@ package db
 import (
 	"bytes"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -17,6 +19,8 @@ const (
 	conditionTypeAssert conditionType = "assert"
 	conditionTypeOr     conditionType = "or"
 	conditionTypeAnd    conditionType = "and"
+	// 括号: 条件组合，Group 中的条件作为一个整体
+	conditionTypeGroup conditionType = "group"
 
 	conditionMethodLike           conditionMethod = "like"
 	conditionMethodEqual          conditionMethod = "equal"
@@ -27,14 +31,24 @@ const (
 	conditionMethodBigger         conditionMethod = ">"
 	conditionMethodSmallerOrEqual conditionMethod = "<="
 	conditionMethodBiggerOrEqual  conditionMethod = ">="
+	conditionMethodExists         conditionMethod = "exists"
+	conditionMethodNotExists      conditionMethod = "not exists"
+	conditionMethodIsNull         conditionMethod = "is null"
+	conditionMethodIsNotNull      conditionMethod = "is not null"
 
 	LeftJoin  JoinMethod = "LEFT JOIN"
 	RightJoin JoinMethod = "RIGHT JOIN"
+	InnerJoin JoinMethod = "INNER JOIN"
+	FullJoin  JoinMethod = "FULL JOIN"
+	CrossJoin JoinMethod = "CROSS JOIN"
+
+	// 分页的 in 子查询包装成的派生表别名
+	subQueryTableAlias = "sub_query"
 )
 
 var (
 	methodToKeywordMap = map[string]string{
-		string(conditionMethodLike):           "%",
+		string(conditionMethodLike):           "LIKE",
 		string(conditionMethodEqual):          "=",
 		string(conditionMethodNotEqual):       "!=",
 		string(conditionMethodIn):             "IN",
@@ -43,12 +57,17 @@ var (
 		string(conditionMethodBigger):         ">",
 		string(conditionMethodSmallerOrEqual): "<=",
 		string(conditionMethodBiggerOrEqual):  ">=",
+		string(conditionMethodExists):         "EXISTS",
+		string(conditionMethodNotExists):      "NOT EXISTS",
+		string(conditionMethodIsNull):         "IS NULL",
+		string(conditionMethodIsNotNull):      "IS NOT NULL",
 	}
 	keyWordToMethodMap = map[string]conditionMethod{
-		"%": conditionMethodLike,
-		"=": conditionMethodEqual,
-		"<": conditionMethodSmaller,
-		">": conditionMethodBigger,
+		"%":  conditionMethodLike,
+		"=":  conditionMethodEqual,
+		"!=": conditionMethodNotEqual,
+		"<":  conditionMethodSmaller,
+		">":  conditionMethodBigger,
 	}
 )
 
@@ -60,6 +79,10 @@ type whereCondition struct {
 	Key    string          `json:"key"`
 	Method conditionMethod `json:"method"`
 	Value  string          `json:"value"`
+	// 条件组合（Type 为 group）
+	Group whereArr `json:"group,omitempty"`
+	// 子查询，作为 in / not in / exists / not exists / 比较 的右侧
+	subQuery *rdbSearchAction
 }
 
 // 多个where 条件定义
@@ -67,38 +90,313 @@ type whereArr []whereCondition
 
 // where 组合条件 转换成 SQL 查询语句
 func (arr whereArr) ToSQL() string {
+	sql, _ := arr.render(false)
+	return sql
+}
+
+// where 组合条件 转换成带参数的 SQL 查询语句: 普通取值使用 ? 占位，和参数列表一起交给 driver 处理，子查询中的取值同样使用参数
+// in / not in 和包含 ` 或 UNIX_TIMESTAMP 的取值（一般是字段名或函数）仍然直接拼接到 SQL 中
+func (arr whereArr) ToSQLWithArgs() (string, []interface{}) {
+	return arr.render(true)
+}
+
+func (arr whereArr) render(isParam bool) (string, []interface{}) {
 	buffer := new(bytes.Buffer)
+	argArr := make([]interface{}, 0)
 	for _, currentCond := range arr {
 		switch currentCond.Type {
 		case conditionTypeAssert:
-			// 特殊字符转义
-			// https://www.cnblogs.com/end/archive/2011/04/01/2002516.html
-			if strings.Contains(currentCond.Value, "\\") || strings.Contains(currentCond.Value, "/") {
-				currentCond.Value = strings.ReplaceAll(currentCond.Value, "\\", "\\\\")
-				currentCond.Value = strings.ReplaceAll(currentCond.Value, "/", "\\/")
+			if nil != currentCond.subQuery {
+				subQuerySQL, subQueryArgArr := currentCond.subQuery.renderSubQuery(isParam)
+				if currentCond.Method == conditionMethodExists || currentCond.Method == conditionMethodNotExists {
+					buffer.WriteString(fmt.Sprintf("%s (%s)", methodToKeywordMap[string(currentCond.Method)], subQuerySQL))
+				} else if currentCond.isPagedInSubQuery() {
+					// mysql 不支持 in 子查询中使用 limit（error 1235），分页的子查询包装成派生表
+					buffer.WriteString(fmt.Sprintf("%s %s (SELECT * FROM (%s) AS %s)", currentCond.Key,
+						methodToKeywordMap[string(currentCond.Method)], subQuerySQL, subQueryTableAlias))
+				} else {
+					buffer.WriteString(fmt.Sprintf("%s %s (%s)", currentCond.Key, methodToKeywordMap[string(currentCond.Method)], subQuerySQL))
+				}
+				argArr = append(argArr, subQueryArgArr...)
+			} else if isParam && !currentCond.isRawValue() {
+				buffer.WriteString(fmt.Sprintf("%s %s ?", currentCond.Key, methodToKeywordMap[string(currentCond.Method)]))
+				argArr = append(argArr, currentCond.Value)
+			} else {
+				buffer.WriteString(currentCond.toSQL())
 			}
-			// fix-对 value 关键字中有`` -- 作为字段标识, in, not in 这种条件，value 前后不需要加上引号
-			condFormatStr := "%s %s '%s'"
-			if currentCond.Method == conditionMethodIn || currentCond.Method == conditionMethodNotIn ||
-				strings.Contains(currentCond.Value, "`") || strings.Contains(currentCond.Value, "UNIX_TIMESTAMP") {
-				condFormatStr = "%s %s %s"
-			} else if strings.Contains(currentCond.Value, "'") {
-				condFormatStr = "%s %s \"%s\""
-			} else if strings.Contains(currentCond.Value, " ") {
-				currentCond.Value = strings.ReplaceAll(currentCond.Value, "\"", "\\\"")
-				condFormatStr = "%s %s \"%s\""
-			}
-			buffer.WriteString(fmt.Sprintf(condFormatStr,
-				currentCond.Key, methodToKeywordMap[string(currentCond.Method)], currentCond.Value))
+		case conditionTypeGroup:
+			groupSQL, groupArgArr := currentCond.Group.render(isParam)
+			buffer.WriteString(fmt.Sprintf("(%s)", groupSQL))
+			argArr = append(argArr, groupArgArr...)
 		case conditionTypeAnd, conditionTypeOr:
 			buffer.WriteString(fmt.Sprintf(" %s ", currentCond.Type))
 		}
 	}
-	return buffer.String()
+	return buffer.String(), argArr
+}
+
+// 是否为设置了分页条件的 in / not in 子查询
+func (cond whereCondition) isPagedInSubQuery() bool {
+	return nil != cond.subQuery && cond.subQuery.condition.Page.Limit > 0 &&
+		(cond.Method == conditionMethodIn || cond.Method == conditionMethodNotIn)
+}
+
+// 取值是否直接拼接到 SQL 中: in, not in, is null 这种条件，以及 value 中包含反引号（字段标识）或函数
+func (cond whereCondition) isRawValue() bool {
+	return cond.Method == conditionMethodIn || cond.Method == conditionMethodNotIn || cond.isNullMethod() ||
+		strings.Contains(cond.Value, "`") || strings.Contains(cond.Value, "UNIX_TIMESTAMP")
+}
+
+// 是否是 is null / is not null 判断，不需要取值
+func (cond whereCondition) isNullMethod() bool {
+	return cond.Method == conditionMethodIsNull || cond.Method == conditionMethodIsNotNull
+}
+
+// 单个条件转换成 SQL，取值直接拼接
+func (cond whereCondition) toSQL() string {
+	if cond.isNullMethod() {
+		return fmt.Sprintf("%s %s", cond.Key, methodToKeywordMap[string(cond.Method)])
+	}
+	// 特殊字符转义
+	// https://www.cnblogs.com/end/archive/2011/04/01/2002516.html
+	if strings.Contains(cond.Value, "\\") || strings.Contains(cond.Value, "/") {
+		cond.Value = strings.ReplaceAll(cond.Value, "\\", "\\\\")
+		cond.Value = strings.ReplaceAll(cond.Value, "/", "\\/")
+	}
+	// fix-对 value 关键字中有`` -- 作为字段标识, in, not in 这种条件，value 前后不需要加上引号
+	condFormatStr := "%s %s '%s'"
+	if cond.isRawValue() {
+		condFormatStr = "%s %s %s"
+	} else if strings.Contains(cond.Value, "'") {
+		condFormatStr = "%s %s \"%s\""
+	} else if strings.Contains(cond.Value, " ") {
+		cond.Value = strings.ReplaceAll(cond.Value, "\"", "\\\"")
+		condFormatStr = "%s %s \"%s\""
+	}
+	return fmt.Sprintf(condFormatStr, cond.Key, methodToKeywordMap[string(cond.Method)], cond.Value)
+}
+
+// 是否包含子查询（包括条件组合中的子查询），不支持 SQL 的连接器无法执行子查询
+func (arr whereArr) HasSubQuery() bool {
+	for _, currentCond := range arr {
+		if nil != currentCond.subQuery || currentCond.Group.HasSubQuery() {
+			return true
+		}
+	}
+	return false
+}
+
+// 子查询涉及的所有表，格式: db.table
+func (arr whereArr) GetSubQuerySpaceNameArr() []string {
+	spaceNameArr := make([]string, 0)
+	for _, currentCond := range arr {
+		if nil != currentCond.subQuery {
+			spaceNameArr = append(spaceNameArr, currentCond.subQuery.GetSpaceName())
+			spaceNameArr = append(spaceNameArr, currentCond.subQuery.condition.Join.GetSpaceNameArr()...)
+			spaceNameArr = append(spaceNameArr, currentCond.subQuery.condition.WhereArr.GetSubQuerySpaceNameArr()...)
+		}
+		spaceNameArr = append(spaceNameArr, currentCond.Group.GetSubQuerySpaceNameArr()...)
+	}
+	return spaceNameArr
+}
+
+// 是否包含 or 条件（不包括条件组合中的 or）
+func (arr whereArr) hasOr() bool {
+	for _, currentCond := range arr {
+		if currentCond.Type == conditionTypeOr {
+			return true
+		}
+	}
+	return false
+}
+
+// 使用 and 连接两组条件，包含 or 的一组条件会放到括号中，保证优先级不变
+func (arr whereArr) and(toAddArr whereArr) whereArr {
+	if len(toAddArr) == 0 {
+		return arr
+	}
+	if len(arr) == 0 {
+		return toAddArr
+	}
+	retArr := make(whereArr, 0, len(arr)+len(toAddArr)+1)
+	retArr = append(retArr, arr.group()...)
+	retArr = append(retArr, whereCondition{Type: conditionTypeAnd})
+	return append(retArr, toAddArr.group()...)
+}
+
+// 包含 or 时转换成一个条件组合
+func (arr whereArr) group() whereArr {
+	if !arr.hasOr() {
+		return arr
+	}
+	return whereArr{{Type: conditionTypeGroup, Group: arr}}
+}
+
+// 判断一行数据是否满足条件，用于不支持 SQL 的连接器（如本地内存）
+// 优先级和 SQL 一致: and 高于 or，条件组合作为一个整体判断；子查询条件总是不满足，调用方需要先通过 HasSubQuery 判断
+func (arr whereArr) Match(row map[string]string) bool {
+	anyAssert, groupHasAssert, groupMatch := false, false, true
+	for _, currentCond := range arr {
+		switch currentCond.Type {
+		case conditionTypeAssert:
+			anyAssert, groupHasAssert = true, true
+			groupMatch = groupMatch && nil == currentCond.subQuery && currentCond.match(row)
+		case conditionTypeGroup:
+			anyAssert, groupHasAssert = true, true
+			groupMatch = groupMatch && currentCond.Group.Match(row)
+		case conditionTypeOr:
+			if groupHasAssert && groupMatch {
+				return true
+			}
+			groupHasAssert, groupMatch = false, true
+		}
+	}
+	if !anyAssert {
+		return true
+	}
+	return groupHasAssert && groupMatch
+}
+
+// 单个条件判断，字段不存在时和 SQL 中的 NULL 一样，任何比较都不成立
+func (cond whereCondition) match(row map[string]string) bool {
+	value, ok := GetRowValue(row, cond.Key)
+	if cond.isNullMethod() {
+		return ok == (cond.Method == conditionMethodIsNotNull)
+	}
+	if !ok {
+		return false
+	}
+
+	switch cond.Method {
+	case conditionMethodEqual:
+		return CompareValue(value, cond.Value) == 0
+	case conditionMethodNotEqual:
+		return CompareValue(value, cond.Value) != 0
+	case conditionMethodSmaller:
+		return CompareValue(value, cond.Value) < 0
+	case conditionMethodBigger:
+		return CompareValue(value, cond.Value) > 0
+	case conditionMethodSmallerOrEqual:
+		return CompareValue(value, cond.Value) <= 0
+	case conditionMethodBiggerOrEqual:
+		return CompareValue(value, cond.Value) >= 0
+	case conditionMethodLike:
+		pattern := regexp.QuoteMeta(cond.Value)
+		pattern = strings.ReplaceAll(strings.ReplaceAll(pattern, "%", ".*"), "_", ".")
+		isMatch, _ := regexp.MatchString("^"+pattern+"$", value)
+		return isMatch
+	case conditionMethodIn, conditionMethodNotIn:
+		isIn := false
+		for _, currentValue := range parseInValueArr(cond.Value) {
+			if CompareValue(value, currentValue) == 0 {
+				isIn = true
+				break
+			}
+		}
+		return isIn == (cond.Method == conditionMethodIn)
+	}
+	return false
+}
+
+// 获取条件中指定字段的取值列表，用于分库分表等根据条件定位数据的场景
+// 只有所有条件都通过 and 连接，且该字段使用 = 或 in 判断时才能确定取值，否则返回 false
+func (arr whereArr) GetKeyValueArr(key string) ([]string, bool) {
+	if arr.hasOr() {
+		return nil, false
+	}
+	for _, currentCond := range arr {
+		if currentCond.Type != conditionTypeAssert || nil != currentCond.subQuery || trimKeyTable(currentCond.Key) != trimKeyTable(key) {
+			continue
+		}
+		switch currentCond.Method {
+		case conditionMethodEqual:
+			return []string{currentCond.Value}, true
+		case conditionMethodIn:
+			return parseInValueArr(currentCond.Value), true
+		}
+	}
+	return nil, false
+}
+
+// 获取数据行中的字段值，字段名支持 field、table.field、`table`.field 格式
+// 数据行中没有带表名的字段时，使用去掉表名之后的字段名
+func GetRowValue(row map[string]string, key string) (string, bool) {
+	if value, ok := row[key]; ok {
+		return value, true
+	}
+	if value, ok := row[strings.ReplaceAll(key, "`", "")]; ok {
+		return value, true
+	}
+	value, ok := row[trimKeyTable(key)]
+	return value, ok
+}
+
+// 按照查询字段列表获取数据行中的字段，和 SQL 一致: 字段支持 table.field 和 AS 别名，返回结果中的字段名不带表名
+// 没有指定查询字段时，返回所有不带表名的字段
+func ProjectRow(row map[string]string, keyArr []string) map[string]string {
+	retMap := make(map[string]string)
+	if len(keyArr) == 0 {
+		for key, value := range row {
+			if !strings.Contains(key, ".") {
+				retMap[key] = value
+			}
+		}
+		return retMap
+	}
+	for _, key := range keyArr {
+		sourceKey, label := key, trimKeyTable(key)
+		if index := strings.Index(strings.ToUpper(key), " AS "); index >= 0 {
+			sourceKey, label = strings.TrimSpace(key[:index]), strings.TrimSpace(strings.ReplaceAll(key[index+4:], "`", ""))
+		}
+		if value, ok := GetRowValue(row, sourceKey); ok {
+			retMap[label] = value
+		}
+	}
+	return retMap
+}
+
+// 去掉字段名中的表名，兼容 table.field 或 `table`.field 格式的字段名
+func trimKeyTable(key string) string {
+	key = strings.ReplaceAll(key, "`", "")
+	if index := strings.LastIndex(key, "."); index >= 0 {
+		key = key[index+1:]
+	}
+	return key
+}
+
+// 解析 in 条件的取值列表，格式: (1, 2, 3) 或 ('a', 'b')
+func parseInValueArr(value string) []string {
+	value = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(value), "("), ")")
+	retArr := make([]string, 0)
+	for _, currentValue := range strings.Split(value, ",") {
+		currentValue = strings.TrimSpace(currentValue)
+		currentValue = strings.Trim(currentValue, "'\"")
+		retArr = append(retArr, currentValue)
+	}
+	return retArr
+}
+
+// 比较两个字段值: 都是数字时按数值比较，否则按字符串比较
+// 返回值和 strings.Compare 一致
+func CompareValue(a, b string) int {
+	aNum, aErr := strconv.ParseFloat(a, 64)
+	bNum, bErr := strconv.ParseFloat(b, 64)
+	if nil == aErr && nil == bErr {
+		switch {
+		case aNum < bNum:
+			return -1
+		case aNum > bNum:
+			return 1
+		default:
+			return 0
+		}
+	}
+	return strings.Compare(a, b)
 }
 
 // 通过传入的条件 生成 whereCondition
 // 格式: "name", "equal" / "=", "xiaoming", "and", "grade", "equal" / "=", "3"
+// is null / is not null 不需要取值: "deleted_at", "is null", "and", "grade", "=", "3"
 func buildWhereConditionArr(args ...string) whereArr {
 	retArr := make(whereArr, 0)
 	index := 0
@@ -109,6 +407,11 @@ func buildWhereConditionArr(args ...string) whereArr {
 		case string(conditionTypeAnd), string(conditionTypeOr):
 			currentCondition.Type = conditionType(currentArg)
 		default:
+			if index+1 < len(args) && (args[index+1] == string(conditionMethodIsNull) || args[index+1] == string(conditionMethodIsNotNull)) {
+				currentCondition.Key, currentCondition.Method = currentArg, conditionMethod(args[index+1])
+				currentCondition.Type = conditionTypeAssert
+				break
+			}
 			if index+2 >= len(args) || (methodToKeywordMap[args[index+1]] == "" && keyWordToMethodMap[args[index+1]] == "") {
 				break
 			}
@@ -131,7 +434,55 @@ func buildWhereConditionArr(args ...string) whereArr {
 type joinCondition struct {
 	joinMethod JoinMethod
 	space      space
-	condition  []string
+	// 表别名，同一张表 join 多次时需要设置
+	alias string
+	// 关联字段: 左侧字段 = 右侧字段
+	onArr [][2]string
+	// 其他关联条件，格式和 SearchSetCondition 一致，取值作为字符串比较
+	condition []string
+}
+
+// 查询中引用 join 表的名称: 有别名时使用别名，否则使用表名
+func (condition joinCondition) getName() string {
+	if condition.alias != "" {
+		return condition.alias
+	}
+	return condition.space.table
+}
+
+// join 的 ON 条件
+func (condition joinCondition) getOnSQL() string {
+	onArr := make([]string, 0, len(condition.onArr)+1)
+	for _, currentOn := range condition.onArr {
+		onArr = append(onArr, fmt.Sprintf("%s = %s", quoteColumn(currentOn[0]), quoteColumn(currentOn[1])))
+	}
+	if len(condition.condition) != 0 {
+		onArr = append(onArr, buildWhereConditionArr(condition.condition...).ToSQL())
+	}
+	return strings.Join(onArr, " and ")
+}
+
+// 判断合并之后的数据行是否满足 join 条件
+func (condition joinCondition) match(row map[string]string) bool {
+	for _, currentOn := range condition.onArr {
+		leftValue, isLeftExist := GetRowValue(row, currentOn[0])
+		rightValue, isRightExist := GetRowValue(row, currentOn[1])
+		if !isLeftExist || !isRightExist || CompareValue(leftValue, rightValue) != 0 {
+			return false
+		}
+	}
+	return buildWhereConditionArr(condition.condition...).Match(row)
+}
+
+// 字段加上表标识，防止当成字符串: table.field -> `table`.field
+func quoteColumn(column string) string {
+	if strings.Contains(column, "`") {
+		return column
+	}
+	if index := strings.LastIndex(column, "."); index >= 0 {
+		return fmt.Sprintf("`%s`.%s", column[:index], column[index+1:])
+	}
+	return column
 }
 
 type joinConditionSlice []joinCondition
@@ -142,17 +493,100 @@ func (conditionSlice joinConditionSlice) ToSQL() string {
 		if conditionBuf.Len() != 0 {
 			conditionBuf.WriteString(" ")
 		}
-		conditionBuf.WriteString(fmt.Sprintf("%s %s ON %s", currentCondition.joinMethod, currentCondition.space.GetSpaceName(),
-			buildWhereConditionArr(currentCondition.condition...).ToSQL()))
+		conditionBuf.WriteString(fmt.Sprintf("%s %s", currentCondition.joinMethod, currentCondition.space.GetSpaceName()))
+		if currentCondition.alias != "" {
+			conditionBuf.WriteString(fmt.Sprintf(" AS %s", currentCondition.alias))
+		}
+		if onSQL := currentCondition.getOnSQL(); onSQL != "" {
+			conditionBuf.WriteString(fmt.Sprintf(" ON %s", onSQL))
+		}
 	}
 	return conditionBuf.String()
 }
 
+// join 涉及的所有表，格式: db.table
+func (conditionSlice joinConditionSlice) GetSpaceNameArr() []string {
+	spaceNameArr := make([]string, 0, len(conditionSlice))
+	for _, currentCondition := range conditionSlice {
+		spaceNameArr = append(spaceNameArr, currentCondition.space.GetSpaceName())
+	}
+	return spaceNameArr
+}
+
+// 是否包含指定的 join 方式
+func (conditionSlice joinConditionSlice) Contains(method JoinMethod) bool {
+	for _, currentCondition := range conditionSlice {
+		if currentCondition.joinMethod == method {
+			return true
+		}
+	}
+	return false
+}
+
+// 在内存中执行 join，用于不支持 SQL 的连接器（如本地内存）
+// mainName: 主表的名称（别名或表名），getRowArr: 根据 db.table 获取表中的所有数据行
+// 返回的数据行中，每个字段都会以 名称.字段 的格式保存，同时保留不带表名的字段（主表优先）
+func (conditionSlice joinConditionSlice) JoinRows(mainName string, mainRowArr []map[string]string,
+	getRowArr func(spaceName string) []map[string]string) []map[string]string {
+	retArr := make([]map[string]string, 0, len(mainRowArr))
+	for _, row := range mainRowArr {
+		retArr = append(retArr, mergeRow(nil, mainName, row))
+	}
+
+	for _, currentCondition := range conditionSlice {
+		name := currentCondition.getName()
+		rightRowArr := getRowArr(currentCondition.space.GetSpaceName())
+		joinRetArr := make([]map[string]string, 0, len(retArr))
+		isRightMatchArr := make([]bool, len(rightRowArr))
+		for _, leftRow := range retArr {
+			isLeftMatch := false
+			for rightIndex, rightRow := range rightRowArr {
+				mergedRow := mergeRow(leftRow, name, rightRow)
+				if currentCondition.joinMethod != CrossJoin && !currentCondition.match(mergedRow) {
+					continue
+				}
+				isLeftMatch, isRightMatchArr[rightIndex] = true, true
+				joinRetArr = append(joinRetArr, mergedRow)
+			}
+			// 左侧没有关联数据时保留左侧数据，右侧字段为 NULL（不存在）
+			if !isLeftMatch && (currentCondition.joinMethod == LeftJoin || currentCondition.joinMethod == FullJoin) {
+				joinRetArr = append(joinRetArr, leftRow)
+			}
+		}
+		if currentCondition.joinMethod == RightJoin || currentCondition.joinMethod == FullJoin {
+			for rightIndex, rightRow := range rightRowArr {
+				if !isRightMatchArr[rightIndex] {
+					joinRetArr = append(joinRetArr, mergeRow(nil, name, rightRow))
+				}
+			}
+		}
+		retArr = joinRetArr
+	}
+	return retArr
+}
+
+// 将数据行合并到已有数据行中，返回新的数据行
+func mergeRow(baseRow map[string]string, name string, row map[string]string) map[string]string {
+	retRow := make(map[string]string, len(baseRow)+2*len(row))
+	for key, value := range baseRow {
+		retRow[key] = value
+	}
+	for key, value := range row {
+		retRow[fmt.Sprintf("%s.%s", name, key)] = value
+		if _, ok := retRow[key]; !ok {
+			retRow[key] = value
+		}
+	}
+	return retRow
+}
+
 type SearchCondition struct {
 	Join  joinConditionSlice
 	Order struct {
 		Field string `json:"field"`
-		Sc    string `json:"sc"`
+		// 排序字段相同时，依次按照这些字段排序，顺序和 Field 一致
+		ThenFieldArr []string `json:"then_field,omitempty"`
+		Sc           string   `json:"sc"`
 	} `json:"order"`
 	Page struct {
 		No    int `json:"no"`
@@ -161,9 +595,61 @@ type SearchCondition struct {
 	WhereArr whereArr `json:"where"`
 }
 
+// 所有排序字段
+func (condition SearchCondition) GetOrderFieldArr() []string {
+	if condition.Order.Field == "" {
+		return nil
+	}
+	return append([]string{condition.Order.Field}, condition.Order.ThenFieldArr...)
+}
+
+// 排序条件转换成 SQL（不包括 ORDER BY），没有排序字段时返回空字符串
+func (condition SearchCondition) GetOrderSQL() string {
+	orderArr := make([]string, 0)
+	for _, field := range condition.GetOrderFieldArr() {
+		orderArr = append(orderArr, strings.TrimRight(field+" "+condition.Order.Sc, " "))
+	}
+	return strings.Join(orderArr, ", ")
+}
+
+// 按照排序条件比较两行数据，用于不支持 SQL 的连接器排序、多个查询结果归并
+func (condition SearchCondition) CompareOrderValueArr(leftArr, rightArr []string) int {
+	for index := 0; index < len(leftArr) && index < len(rightArr); index++ {
+		if compareRet := CompareValue(leftArr[index], rightArr[index]); compareRet != 0 {
+			if strings.EqualFold(condition.Order.Sc, "desc") {
+				return -compareRet
+			}
+			return compareRet
+		}
+	}
+	return 0
+}
+
+// 获取数据行中所有排序字段的取值
+func (condition SearchCondition) GetRowOrderValueArr(row map[string]string) []string {
+	valueArr := make([]string, 0)
+	for _, field := range condition.GetOrderFieldArr() {
+		value, _ := GetRowValue(row, field)
+		valueArr = append(valueArr, value)
+	}
+	return valueArr
+}
+
 type updateCondition struct {
 	WhereArr whereArr `json:"where"`
 	Limit    int
+	// 设置的 limit 不合法时的错误信息
+	limitErr error
+}
+
+// 设置 limit，不合法的取值在执行前通过 CheckLimit 返回错误
+func (condition *updateCondition) setLimit(limit int) {
+	condition.Limit, condition.limitErr = limit, checkModifyLimit(limit)
+}
+
+// 检查设置的 limit 是否合法
+func (condition updateCondition) CheckLimit() error {
+	return condition.limitErr
 }
 
 // 获取更新条件中的 limit 部分
@@ -0,0 +1,145 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// 测试 join 条件转换成 SQL
+func TestJoinToSQL(t *testing.T) {
+	action := MakeRDBSearchAction()
+	SearchAddJoin("temp", "test_student", "class_id", "test_class", "id")(action)
+	SearchAddJoin("temp", "test_student", "class_id", "test_class", "id", string(RightJoin))(action)
+	SearchAddJoinTable("temp", "test_class", JoinSetMethod(InnerJoin), JoinSetAlias("c"),
+		JoinSetOn("s.class_id", "c.id"), JoinSetCondition("c.status", "=", "1"))(action)
+	SearchAddJoinTable("temp", "test_teacher", JoinSetMethod(CrossJoin))(action)
+	require.Equal(t, "LEFT JOIN temp.test_class ON `test_student`.class_id = `test_class`.id "+
+		"RIGHT JOIN temp.test_class ON `test_student`.class_id = `test_class`.id "+
+		"INNER JOIN temp.test_class AS c ON `s`.class_id = `c`.id and c.status = '1' "+
+		"CROSS JOIN temp.test_teacher", action.GetCondition().Join.ToSQL())
+	require.True(t, action.GetCondition().Join.Contains(CrossJoin))
+	require.False(t, action.GetCondition().Join.Contains(FullJoin))
+}
+
+// 测试数据行条件判断和字段选择
+func TestWhereMatch(t *testing.T) {
+	row := map[string]string{"name": "xiaoming", "class_id": "10", "s.name": "xiaoming"}
+	require.True(t, buildWhereConditionArr("class_id", ">", "9", "and", "name", "like", "xiao%").Match(row))
+	require.True(t, buildWhereConditionArr("class_id", "=", "1", "or", "`s`.name", "=", "xiaoming").Match(row))
+	require.False(t, buildWhereConditionArr("class_id", "in", "(1, 2)").Match(row))
+	require.False(t, buildWhereConditionArr("not_exist", "!=", "1").Match(row))
+	require.True(t, buildWhereConditionArr("deleted_at", "is null", "and", "class_id", "=", "10").Match(row))
+	require.False(t, buildWhereConditionArr("name", "is null").Match(row))
+	require.True(t, buildWhereConditionArr("s.name", "is not null").Match(row))
+
+	whereSQL, argArr := buildWhereConditionArr("deleted_at", "is null", "and", "name", "=", "a").ToSQLWithArgs()
+	require.Equal(t, "deleted_at IS NULL and name = ?", whereSQL)
+	require.Equal(t, []interface{}{"a"}, argArr)
+
+	valueArr, ok := buildWhereConditionArr("s.class_id", "in", "(1, '2')", "and", "name", "=", "a").GetKeyValueArr("class_id")
+	require.True(t, ok)
+	require.Equal(t, []string{"1", "2"}, valueArr)
+	_, ok = buildWhereConditionArr("class_id", "=", "1", "or", "name", "=", "a").GetKeyValueArr("class_id")
+	require.False(t, ok)
+
+	require.Equal(t, map[string]string{"name": "xiaoming", "id": "10"}, ProjectRow(row, []string{"s.name", "class_id AS id"}))
+	require.Equal(t, map[string]string{"name": "xiaoming", "class_id": "10"}, ProjectRow(row, nil))
+}
+
+// 测试 like 条件生成 LIKE 关键字，% 和 like 等价
+func TestLikeToSQL(t *testing.T) {
+	for _, method := range []string{"like", "%"} {
+		whereArr := buildWhereConditionArr("name", method, "xiao%", "and", "class_id", "=", "1")
+		require.Equal(t, "name LIKE 'xiao%' and class_id = '1'", whereArr.ToSQL())
+		whereSQL, argArr := whereArr.ToSQLWithArgs()
+		require.Equal(t, "name LIKE ? and class_id = ?", whereSQL)
+		require.Equal(t, []interface{}{"xiao%", "1"}, argArr)
+	}
+}
+
+// 测试子查询条件和带参数的 SQL
+func TestSubQueryToSQL(t *testing.T) {
+	action := MakeRDBSearchAction()
+	SearchSetCondition("status", "=", "1", "or", "status", "=", "2")(action)
+	SearchAddSubQueryCondition("id", "in", SearchSetSpace("temp", "test_order"), SearchSetKeyArr([]string{"user_id"}),
+		SearchSetCondition("amount", ">", "100"))(action)
+	SearchAddExistsCondition(SearchSetSpace("temp", "test_class"), SearchSetAlias("c"), SearchSetKeyArr([]string{"1"}),
+		SearchSetCondition("c.id", "=", "`u`.class_id", "and", "c.name", "=", "class one"))(action)
+	SearchAddSubQueryCondition("score", ">=", SearchSetSpace("temp", "test_score"), SearchSetKeyArr([]string{"avg(score)"}),
+		SearchSetOrderFieldAndAsc("score", "desc"), SearchSetLimit(1))(action)
+	whereArr := action.GetCondition().WhereArr
+
+	require.Equal(t, "(status = '1' or status = '2') and id IN (SELECT user_id FROM temp.test_order WHERE amount > '100') and "+
+		"EXISTS (SELECT 1 FROM temp.test_class AS c WHERE c.id = `u`.class_id and c.name = \"class one\") and "+
+		"score >= (SELECT avg(score) FROM temp.test_score ORDER BY score desc LIMIT 1)", whereArr.ToSQL())
+
+	sql, argArr := whereArr.ToSQLWithArgs()
+	require.Equal(t, "(status = ? or status = ?) and id IN (SELECT user_id FROM temp.test_order WHERE amount > ?) and "+
+		"EXISTS (SELECT 1 FROM temp.test_class AS c WHERE c.id = `u`.class_id and c.name = ?) and "+
+		"score >= (SELECT avg(score) FROM temp.test_score ORDER BY score desc LIMIT 1)", sql)
+	require.Equal(t, []interface{}{"1", "2", "100", "class one"}, argArr)
+
+	require.True(t, whereArr.HasSubQuery())
+	require.Equal(t, []string{"temp.test_order", "temp.test_class", "temp.test_score"}, whereArr.GetSubQuerySpaceNameArr())
+
+	// 分页的 in / not in 子查询包装成派生表
+	action = MakeRDBSearchAction()
+	SearchAddSubQueryCondition("id", "not in", SearchSetSpace("temp", "test_order"), SearchSetKeyArr([]string{"user_id"}),
+		SearchSetOrderFieldAndAsc("amount", "desc"), SearchSetPageCondition(10, 10))(action)
+	require.Equal(t, "id NOT IN (SELECT * FROM (SELECT user_id FROM temp.test_order ORDER BY amount desc LIMIT 10 OFFSET 10) AS sub_query)",
+		action.GetCondition().WhereArr.ToSQL())
+}
+
+// 测试追加条件: 包含 or 的条件作为整体判断
+func TestAddCondition(t *testing.T) {
+	action := MakeRDBSearchAction()
+	SearchSetCondition("class_id", "=", "1", "or", "class_id", "=", "2")(action)
+	SearchAddCondition("name", "=", "xiaoming")(action)
+	whereArr := action.GetCondition().WhereArr
+	require.Equal(t, "(class_id = '1' or class_id = '2') and name = 'xiaoming'", whereArr.ToSQL())
+	require.False(t, whereArr.HasSubQuery())
+	require.True(t, whereArr.Match(map[string]string{"class_id": "2", "name": "xiaoming"}))
+	require.False(t, whereArr.Match(map[string]string{"class_id": "1", "name": "xiaohong"}))
+
+	deleteAction := MakeRDBDeleteAction()
+	DeleteAddCondition("name", "=", "xiaoming")(deleteAction)
+	DeleteAddCondition("class_id", "<", "3")(deleteAction)
+	require.Equal(t, "name = 'xiaoming' and class_id < '3'", deleteAction.GetCondition().WhereArr.ToSQL())
+
+	updateAction := MakeRDBUpdateAction()
+	UpdateSetCondition("name", "=", "xiaoming")(updateAction)
+	UpdateAddCondition("class_id", "=", "1", "or", "class_id", "=", "2")(updateAction)
+	require.Equal(t, "name = 'xiaoming' and (class_id = '1' or class_id = '2')", updateAction.GetCondition().WhereArr.ToSQL())
+}
+
+// 测试多个排序字段
+func TestAddOrderField(t *testing.T) {
+	action := MakeRDBSearchAction()
+	SearchSetOrderFieldAndAsc("update_time", "desc")(action)
+	SearchAddOrderField("id")(action)
+	condition := action.GetCondition()
+	require.Equal(t, "update_time desc, id desc", condition.GetOrderSQL())
+	require.Equal(t, []string{"2022-01-01", "2"}, condition.GetRowOrderValueArr(map[string]string{"id": "2", "update_time": "2022-01-01"}))
+	require.True(t, condition.CompareOrderValueArr([]string{"2022-01-01", "10"}, []string{"2022-01-01", "9"}) < 0)
+
+	action = MakeRDBSearchAction()
+	SearchAddOrderField("id")(action)
+	require.Equal(t, "id", action.GetCondition().GetOrderSQL())
+}
+
+// 测试查询配置转换成完整的查询语句和统计语句
+func TestSearchToSQL(t *testing.T) {
+	action := MakeRDBSearchAction()
+	SearchSetSpace("temp", "test_student")(action)
+	SearchSetAlias("s")(action)
+	SearchSetKeyArr([]string{"s.id", "s.name"})(action)
+	SearchAddJoinTable("temp", "test_class", JoinSetAlias("c"), JoinSetOn("s.class_id", "c.id"))(action)
+	SearchSetCondition("s.name", "=", "xiaoming")(action)
+	SearchSetOrderField("s.id")(action)
+	SearchSetPageCondition(20, 10)(action)
+	require.Equal(t, "SELECT s.id, s.name FROM temp.test_student AS s LEFT JOIN temp.test_class AS c ON `s`.class_id = `c`.id "+
+		"WHERE s.name = 'xiaoming' ORDER BY s.id LIMIT 10 OFFSET 20", action.ToSQL())
+	require.Equal(t, "SELECT COUNT(*) FROM temp.test_student AS s LEFT JOIN temp.test_class AS c ON `s`.class_id = `c`.id "+
+		"WHERE s.name = 'xiaoming'", action.ToCountSQL())
+}
@@ -0,0 +1,401 @@
+// package conformance RDBConnector 实现的一致性测试
+// 任意连接器都可以在自己的测试中调用 Run，按照声明的能力检查各个动作的行为是否和约定一致
+// 不支持的动作（Capability 中为 false）需要返回 errorcode.NotImplement
+package conformance
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/smiecj/go_common/db"
+	"github.com/smiecj/go_common/errorcode"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	defaultDBName    = "temp"
+	defaultTableName = "test_conformance"
+
+	columnName    = "name"
+	columnClassId = "class_id"
+)
+
+// 连接器能力声明
+type Capability struct {
+	// 支持的动作，不支持时需要返回 errorcode.NotImplement
+	Insert   bool
+	Search   bool
+	Update   bool
+	Delete   bool
+	Count    bool
+	Distinct bool
+
+	// 支持插入和查询结构体，不支持时插入结构体需要返回 errorcode.NotImplement
+	Object bool
+	// Search / Count / Distinct 支持 where 条件，不支持时条件会被忽略
+	Condition bool
+	// Search 支持排序和分页，不支持时返回所有数据
+	OrderAndPage bool
+	// Delete 支持按条件删除，不支持时删除整个表
+	DeleteCondition bool
+	// Insert 没有设置 InsertAppend 时覆盖整个表（如本地文件），否则追加
+	InsertOverwrite bool
+}
+
+// 测试用的表结构: name varchar, class_id bigint，建表语句参考 db/mysql/mysql_test.sql
+type conformanceStudent struct {
+	Name    string `gorm:"column:name" json:"name"`
+	ClassId int    `gorm:"column:class_id" json:"class_id"`
+}
+
+// 测试配置
+type conformanceConf struct {
+	dbName string
+	table  string
+}
+
+type ConformanceConfigFunc func(*conformanceConf)
+
+// 设置测试使用的表，测试过程中会清空表数据
+func SetSpace(dbName, table string) ConformanceConfigFunc {
+	return func(conf *conformanceConf) {
+		conf.dbName, conf.table = dbName, table
+	}
+}
+
+// 测试套件
+type suite struct {
+	connector  db.RDBConnector
+	capability Capability
+	conf       conformanceConf
+}
+
+// 执行一致性测试
+func Run(t *testing.T, connector db.RDBConnector, capability Capability, funcArr ...ConformanceConfigFunc) {
+	s := &suite{connector: connector, capability: capability,
+		conf: conformanceConf{dbName: defaultDBName, table: defaultTableName}}
+	for _, currentFunc := range funcArr {
+		currentFunc(&s.conf)
+	}
+
+	t.Run("insert_search", s.testInsertSearch)
+	t.Run("insert_append", s.testInsertAppend)
+	t.Run("insert_limit", s.testInsertLimit)
+	t.Run("condition", s.testCondition)
+	t.Run("order_page", s.testOrderPage)
+	t.Run("count", s.testCount)
+	t.Run("distinct", s.testDistinct)
+	t.Run("update", s.testUpdate)
+	t.Run("delete", s.testDelete)
+	t.Run("object", s.testObject)
+}
+
+// 是否可以准备测试数据并查询
+func (s *suite) hasData() bool {
+	return s.capability.Insert && s.capability.Search && s.capability.Delete
+}
+
+// 清空表并写入测试数据: xiaoming(1), xiaohong(2), xiaolin(2)
+func (s *suite) prepare(t *testing.T) {
+	_, err := s.connector.Delete(db.DeleteSetSpace(s.conf.dbName, s.conf.table))
+	require.Empty(t, err)
+	insertRet, err := s.insert(true, []string{"xiaoming", "1"}, []string{"xiaohong", "2"}, []string{"xiaolin", "2"})
+	require.Empty(t, err)
+	require.Equal(t, 3, insertRet.AffectedRows)
+}
+
+// 插入 key-value 格式的数据，每一行: name, class_id
+func (s *suite) insert(isAppend bool, rowArr ...[]string) (db.UpdateRet, error) {
+	funcArr := []db.RDBInsertConfigFunc{db.InsertSetSpace(s.conf.dbName, s.conf.table)}
+	if isAppend {
+		funcArr = append(funcArr, db.InsertAppend())
+	}
+	for _, row := range rowArr {
+		currentField := db.BuildNewField()
+		currentField.AddKeyValue(columnName, row[0])
+		currentField.AddKeyValue(columnClassId, row[1])
+		funcArr = append(funcArr, db.InsertAddField(currentField))
+	}
+	return s.connector.Insert(funcArr...)
+}
+
+// 查询并返回 name 列表，orderly 为 false 时排序后返回
+func (s *suite) searchNameArr(t *testing.T, orderly bool, funcArr ...db.RDBSearchConfigFunc) []string {
+	searchRet, err := s.connector.Search(append([]db.RDBSearchConfigFunc{db.SearchSetSpace(s.conf.dbName, s.conf.table)}, funcArr...)...)
+	require.Empty(t, err)
+	require.Equal(t, len(searchRet.FieldArr), searchRet.Len)
+
+	nameArr := make([]string, 0, len(searchRet.FieldArr))
+	for _, currentField := range searchRet.FieldArr {
+		nameArr = append(nameArr, currentField.GetMap()[columnName])
+	}
+	if !orderly {
+		sort.Strings(nameArr)
+	}
+	return nameArr
+}
+
+// 判断错误码
+func requireErrorCode(t *testing.T, err error, code string) {
+	require.NotEmpty(t, err)
+	require.True(t, errors.Is(err, errorcode.BuildError(code)), "expect error code %s, actual: %s", code, err.Error())
+}
+
+// 插入并查询: 不支持的动作返回 NotImplement
+func (s *suite) testInsertSearch(t *testing.T) {
+	if !s.capability.Insert {
+		_, err := s.insert(true, []string{"xiaoming", "1"})
+		requireErrorCode(t, err, errorcode.NotImplement)
+	}
+	if !s.capability.Search {
+		_, err := s.connector.Search(db.SearchSetSpace(s.conf.dbName, s.conf.table))
+		requireErrorCode(t, err, errorcode.NotImplement)
+	}
+	if !s.hasData() {
+		t.Skip("insert, search and delete are required")
+	}
+
+	s.prepare(t)
+	require.Equal(t, []string{"xiaohong", "xiaolin", "xiaoming"}, s.searchNameArr(t, false))
+}
+
+// 追加写入和覆盖写入
+func (s *suite) testInsertAppend(t *testing.T) {
+	if !s.hasData() {
+		t.Skip("insert, search and delete are required")
+	}
+
+	s.prepare(t)
+	insertRet, err := s.insert(false, []string{"xiaozhang", "3"})
+	require.Empty(t, err)
+	require.Equal(t, 1, insertRet.AffectedRows)
+	if s.capability.InsertOverwrite {
+		require.Equal(t, []string{"xiaozhang"}, s.searchNameArr(t, false))
+	} else {
+		require.Equal(t, []string{"xiaohong", "xiaolin", "xiaoming", "xiaozhang"}, s.searchNameArr(t, false))
+	}
+
+	_, err = s.insert(true, []string{"xiaobai", "3"})
+	require.Empty(t, err)
+	require.Contains(t, s.searchNameArr(t, false), "xiaobai")
+	require.Contains(t, s.searchNameArr(t, false), "xiaozhang")
+}
+
+// 单次写入数据量超过限制: 返回 DBParamInvalid
+func (s *suite) testInsertLimit(t *testing.T) {
+	if !s.capability.Insert {
+		t.Skip("insert is required")
+	}
+
+	rowArr := make([][]string, 0)
+	for index := 0; index <= db.MaxModifyLimit; index++ {
+		rowArr = append(rowArr, []string{"xiaoming", "1"})
+	}
+	_, err := s.insert(true, rowArr...)
+	requireErrorCode(t, err, errorcode.DBParamInvalid)
+}
+
+// 条件查询: 比较、in、like，and 优先级高于 or；不支持条件时检查条件被忽略
+func (s *suite) testCondition(t *testing.T) {
+	if !s.hasData() {
+		t.Skip("insert, search and delete are required")
+	}
+
+	s.prepare(t)
+	// 不支持条件时条件会被忽略，返回所有数据
+	if !s.capability.Condition {
+		require.Equal(t, []string{"xiaohong", "xiaolin", "xiaoming"}, s.searchNameArr(t, false, db.SearchSetCondition(columnClassId, "=", "2")))
+		if s.capability.Count {
+			countRet, err := s.connector.Count(db.SearchSetSpace(s.conf.dbName, s.conf.table), db.SearchSetCondition(columnClassId, "=", "2"))
+			require.Empty(t, err)
+			require.Equal(t, 3, countRet.Total)
+		}
+		return
+	}
+
+	caseArr := []struct {
+		condition []string
+		nameArr   []string
+	}{
+		{[]string{columnClassId, "=", "2"}, []string{"xiaohong", "xiaolin"}},
+		{[]string{columnClassId, "!=", "2"}, []string{"xiaoming"}},
+		{[]string{columnClassId, ">", "1"}, []string{"xiaohong", "xiaolin"}},
+		{[]string{columnClassId, "<=", "1"}, []string{"xiaoming"}},
+		{[]string{columnClassId, "in", "(1, 3)"}, []string{"xiaoming"}},
+		{[]string{columnClassId, "not in", "(1, 3)"}, []string{"xiaohong", "xiaolin"}},
+		{[]string{columnName, "like", "xiao%ng"}, []string{"xiaohong", "xiaoming"}},
+		{[]string{columnClassId, "=", "2", "and", columnName, "=", "xiaohong"}, []string{"xiaohong"}},
+		{[]string{columnName, "=", "xiaoming", "or", columnClassId, "=", "2"}, []string{"xiaohong", "xiaolin", "xiaoming"}},
+		{[]string{columnName, "=", "xiaoming", "or", columnClassId, "=", "2", "and", columnName, "=", "xiaolin"},
+			[]string{"xiaolin", "xiaoming"}},
+		{[]string{columnName, "=", "xiaobai"}, []string{}},
+	}
+	for _, currentCase := range caseArr {
+		require.Equal(t, currentCase.nameArr, s.searchNameArr(t, false, db.SearchSetCondition(currentCase.condition...)),
+			"condition: %v", currentCase.condition)
+	}
+}
+
+// 排序和分页: Total 为满足条件的总数，Len 为当前页数据量
+func (s *suite) testOrderPage(t *testing.T) {
+	if !s.hasData() || !s.capability.OrderAndPage {
+		t.Skip("order and page is not supported")
+	}
+
+	s.prepare(t)
+	require.Equal(t, []string{"xiaohong", "xiaolin"},
+		s.searchNameArr(t, true, db.SearchSetOrderField(columnName), db.SearchSetPageCondition(0, 2)))
+	require.Equal(t, []string{"xiaoming"},
+		s.searchNameArr(t, true, db.SearchSetOrderField(columnName), db.SearchSetPageCondition(2, 2)))
+	require.Equal(t, []string{"xiaoming", "xiaolin", "xiaohong"},
+		s.searchNameArr(t, true, db.SearchSetOrderFieldAndAsc(columnName, "desc")))
+
+	searchRet, err := s.connector.Search(db.SearchSetSpace(s.conf.dbName, s.conf.table),
+		db.SearchSetOrderField(columnName), db.SearchSetPageCondition(0, 2))
+	require.Empty(t, err)
+	require.Equal(t, 2, searchRet.Len)
+	require.Equal(t, 3, searchRet.Total)
+}
+
+// 统计数据量
+func (s *suite) testCount(t *testing.T) {
+	if !s.capability.Count {
+		_, err := s.connector.Count(db.SearchSetSpace(s.conf.dbName, s.conf.table))
+		requireErrorCode(t, err, errorcode.NotImplement)
+		return
+	}
+	if !s.hasData() {
+		_, err := s.connector.Count(db.SearchSetSpace(s.conf.dbName, s.conf.table))
+		require.Empty(t, err)
+		return
+	}
+
+	s.prepare(t)
+	countRet, err := s.connector.Count(db.SearchSetSpace(s.conf.dbName, s.conf.table))
+	require.Empty(t, err)
+	require.Equal(t, 3, countRet.Total)
+	if s.capability.Condition {
+		countRet, err = s.connector.Count(db.SearchSetSpace(s.conf.dbName, s.conf.table),
+			db.SearchSetCondition(columnClassId, "=", "2"))
+		require.Empty(t, err)
+		require.Equal(t, 2, countRet.Total)
+	}
+}
+
+// 查询不同取值: 必须指定字段
+func (s *suite) testDistinct(t *testing.T) {
+	if !s.capability.Distinct {
+		_, err := s.connector.Distinct(db.SearchSetSpace(s.conf.dbName, s.conf.table), db.SearchSetKeyArr([]string{columnClassId}))
+		requireErrorCode(t, err, errorcode.NotImplement)
+		return
+	}
+
+	_, err := s.connector.Distinct(db.SearchSetSpace(s.conf.dbName, s.conf.table))
+	requireErrorCode(t, err, errorcode.DBParamInvalid)
+	if !s.hasData() {
+		return
+	}
+
+	s.prepare(t)
+	distinctRet, err := s.connector.Distinct(db.SearchSetSpace(s.conf.dbName, s.conf.table), db.SearchSetKeyArr([]string{columnClassId}))
+	require.Empty(t, err)
+	require.Equal(t, 2, distinctRet.Len)
+	valueArr := make([]string, 0)
+	for _, currentField := range distinctRet.FieldArr {
+		valueArr = append(valueArr, currentField.GetMap()[columnClassId])
+	}
+	sort.Strings(valueArr)
+	require.Equal(t, []string{"1", "2"}, valueArr)
+}
+
+// 按条件更新
+func (s *suite) testUpdate(t *testing.T) {
+	updateField := db.BuildNewField()
+	updateField.AddKeyValue(columnClassId, "3")
+	updateFunc := func() (db.UpdateRet, error) {
+		return s.connector.Update(db.UpdateSetSpace(s.conf.dbName, s.conf.table),
+			db.UpdateSetCondition(columnName, "=", "xiaoming"), db.UpdateAddField(updateField))
+	}
+	if !s.capability.Update {
+		_, err := updateFunc()
+		requireErrorCode(t, err, errorcode.NotImplement)
+		return
+	}
+	if !s.hasData() {
+		t.Skip("insert, search and delete are required")
+	}
+
+	s.prepare(t)
+	updateRet, err := updateFunc()
+	require.Empty(t, err)
+	require.Equal(t, 1, updateRet.AffectedRows)
+	if s.capability.Condition {
+		require.Equal(t, []string{"xiaoming"}, s.searchNameArr(t, false, db.SearchSetCondition(columnClassId, "=", "3")))
+	}
+}
+
+// 删除: 按条件删除 或 删除整个表
+func (s *suite) testDelete(t *testing.T) {
+	if !s.capability.Delete {
+		_, err := s.connector.Delete(db.DeleteSetSpace(s.conf.dbName, s.conf.table))
+		requireErrorCode(t, err, errorcode.NotImplement)
+		return
+	}
+	if !s.hasData() {
+		t.Skip("insert and search are required")
+	}
+
+	s.prepare(t)
+	if s.capability.DeleteCondition {
+		deleteRet, err := s.connector.Delete(db.DeleteSetSpace(s.conf.dbName, s.conf.table),
+			db.DeleteSetCondition(columnClassId, "=", "2"))
+		require.Empty(t, err)
+		require.Equal(t, 2, deleteRet.AffectedRows)
+		require.Equal(t, []string{"xiaoming"}, s.searchNameArr(t, false))
+	}
+
+	_, err := s.connector.Delete(db.DeleteSetSpace(s.conf.dbName, s.conf.table))
+	require.Empty(t, err)
+	require.Equal(t, []string{}, s.searchNameArr(t, false))
+
+	// 删除空表不返回错误
+	deleteRet, err := s.connector.Delete(db.DeleteSetSpace(s.conf.dbName, s.conf.table))
+	require.Empty(t, err)
+	require.Equal(t, 0, deleteRet.AffectedRows)
+}
+
+// 插入和查询结构体
+func (s *suite) testObject(t *testing.T) {
+	studentArr := []conformanceStudent{{Name: "xiaoming", ClassId: 1}, {Name: "xiaohong", ClassId: 2}}
+	insertFunc := func() (db.UpdateRet, error) {
+		return s.connector.Insert(db.InsertSetSpace(s.conf.dbName, s.conf.table), db.InsertAppend(),
+			db.InsertAddKeyArr([]string{columnName, columnClassId}), db.InsertAddObjectArr(studentArr))
+	}
+	if !s.capability.Insert {
+		t.Skip("insert is required")
+	}
+	if !s.capability.Object {
+		_, err := insertFunc()
+		requireErrorCode(t, err, errorcode.NotImplement)
+		return
+	}
+	if !s.hasData() {
+		t.Skip("insert, search and delete are required")
+	}
+
+	_, err := s.connector.Delete(db.DeleteSetSpace(s.conf.dbName, s.conf.table))
+	require.Empty(t, err)
+	insertRet, err := insertFunc()
+	require.Empty(t, err)
+	require.Equal(t, 2, insertRet.AffectedRows)
+
+	// 本地文件按行反序列化成结构体指针，这里统一使用指针数组
+	searchRet, err := s.connector.Search(db.SearchSetSpace(s.conf.dbName, s.conf.table),
+		db.SearchSetKeyArr([]string{columnName, columnClassId}),
+		db.SearchSetObject(conformanceStudent{}), db.SearchSetObjectArrType([]*conformanceStudent{}))
+	require.Empty(t, err)
+	require.Equal(t, 2, reflect.ValueOf(searchRet.ObjectArr).Len())
+	require.Equal(t, 2, searchRet.Len)
+}
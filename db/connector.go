@@ -4,11 +4,17 @@ import (
 	"bytes"
 	"fmt"
 	"reflect"
+	"strings"
+	"time"
+
+	"github.com/smiecj/go_common/errorcode"
 )
 
 const (
 	// 变更操作 最大 limit
 	maxModifyLimit = 100000
+	// 单次变更操作的最大数据量，供连接器外部（如一致性测试）使用
+	MaxModifyLimit = maxModifyLimit
 )
 
 // Relational data connector
@@ -29,15 +35,28 @@ type RDBConnector interface {
 // db connect status
 // refer: golang/src/database/sql/sql.go DBStats
 type DBStat struct {
+	// 最大连接数
+	MaxOpenConnections int
+
 	// pool status
 	OpenConnections int
 	InUse           int
 	Idle            int
+
+	// counter: 等待获取连接的次数和总时长
+	WaitCount    int64
+	WaitDuration time.Duration
+	// counter: 因为超过最大空闲连接数、最大空闲时间、最大存活时间而关闭的连接数
+	MaxIdleClosed     int64
+	MaxIdleTimeClosed int64
+	MaxLifetimeClosed int64
 }
 
 // 更新类型动作结果
 type UpdateRet struct {
 	AffectedRows int
+	// 分批写入时每一批的结果，不支持分批写入的连接器为空
+	ChunkRetArr []ChunkRet
 }
 
 // 查询类型动作结果
@@ -179,11 +198,42 @@ func (rdbField *rdbField) setObjectArrType(t reflect.Type) {
 	rdbField.objectArrType = t
 }
 
+// 填充字段取值: 已经设置的字段保持不变
+// key-value 格式的数据中没有该字段时添加；结构体中有对应字段且为零值时设置，非指针结构体会替换成设置之后的副本
+// 指定了字段列表时，同时把该字段加入字段列表
+func (rdbField *rdbField) fillColumn(key, value string) {
+	for _, currentField := range rdbField.fieldArr {
+		if _, ok := currentField.keyValueMap[key]; !ok {
+			currentField.keyValueMap[key] = value
+		}
+	}
+	isFilled := false
+	if nil != rdbField.object {
+		rdbField.object, isFilled = fillObjectColumnValue(rdbField.object, key, value)
+	}
+	for index, object := range rdbField.objectArr {
+		var isCurrentFilled bool
+		rdbField.objectArr[index], isCurrentFilled = fillObjectColumnValue(object, key, value)
+		isFilled = isFilled || isCurrentFilled
+	}
+	if isFilled && len(rdbField.keyArr) != 0 {
+		for _, currentKey := range rdbField.keyArr {
+			if currentKey == key {
+				return
+			}
+		}
+		rdbField.keyArr = append(rdbField.keyArr, key)
+	}
+}
+
 // DB connect config
 // 插入配置
 type rdbInsertAction struct {
 	rdbField
-	batch int
+	batch           int
+	isAppend        bool
+	continueOnError bool
+	transaction     bool
 }
 
 // 创建一个插入设置
@@ -196,6 +246,41 @@ func (action *rdbInsertAction) Batch() int {
 	return action.batch
 }
 
+// 是否追加写入
+func (action *rdbInsertAction) IsAppend() bool {
+	return action.isAppend
+}
+
+// 分批写入时，某一批失败后是否继续写入后面的批次
+func (action *rdbInsertAction) IsContinueOnError() bool {
+	return action.continueOnError
+}
+
+// 分批写入时，是否将所有批次放在同一个事务中
+func (action *rdbInsertAction) IsTransaction() bool {
+	return action.transaction
+}
+
+// 获取需要写入的数据条数
+func (action *rdbInsertAction) RowCount() int {
+	if nil != action.object {
+		return 1
+	}
+	if len(action.fieldArr) != 0 {
+		return len(action.fieldArr)
+	}
+	return len(action.objectArr)
+}
+
+// 检查写入数据量，单次写入不能超过 maxModifyLimit
+func (action *rdbInsertAction) CheckLimit() error {
+	if rowCount := action.RowCount(); rowCount > maxModifyLimit {
+		return errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid,
+			fmt.Sprintf("insert rows %d exceed max modify limit %d", rowCount, maxModifyLimit))
+	}
+	return nil
+}
+
 // 插入数据配置方法定义
 type RDBInsertConfigFunc func(*rdbInsertAction)
 
@@ -258,6 +343,14 @@ func InsertAddKeyArr(keyArr []string) func(*rdbInsertAction) {
 	}
 }
 
+// 填充插入字段: 每一行数据中没有设置该字段时使用指定取值，如 创建时间、创建人
+// 需要放在添加数据的方法之后
+func InsertFillColumn(key, value string) func(*rdbInsertAction) {
+	return func(action *rdbInsertAction) {
+		action.rdbField.fillColumn(key, value)
+	}
+}
+
 // 设置单批插入的数据大小，防止同一批数据量过大导致报 Error 1390 类似错误
 func InsertBatch(batch int) func(*rdbInsertAction) {
 	return func(action *rdbInsertAction) {
@@ -265,6 +358,29 @@ func InsertBatch(batch int) func(*rdbInsertAction) {
 	}
 }
 
+// 设置分批写入时遇到失败的批次继续写入后面的批次（默认在第一个失败的批次停止）
+func InsertContinueOnError() func(*rdbInsertAction) {
+	return func(action *rdbInsertAction) {
+		action.continueOnError = true
+	}
+}
+
+// 设置分批写入的所有批次在同一个事务中执行，任意批次失败都会回滚
+// 事务模式下总是在第一个失败的批次停止，会忽略 InsertContinueOnError
+func InsertTransaction() func(*rdbInsertAction) {
+	return func(action *rdbInsertAction) {
+		action.transaction = true
+	}
+}
+
+// 设置追加写入: 对于默认覆盖写入的连接器（如本地文件），保留已有的数据
+// 本身就是追加写入的连接器（如 mysql）会忽略该配置
+func InsertAppend() func(*rdbInsertAction) {
+	return func(action *rdbInsertAction) {
+		action.isAppend = true
+	}
+}
+
 // 更新配置
 type rdbUpdateAction struct {
 	rdbField
@@ -319,6 +435,13 @@ func UpdateSetCondition(args ...string) func(*rdbUpdateAction) {
 	}
 }
 
+// 添加更新条件，格式和 UpdateSetCondition 一致，和已有条件使用 and 连接（包含 or 的条件会加上括号）
+func UpdateAddCondition(args ...string) func(*rdbUpdateAction) {
+	return func(action *rdbUpdateAction) {
+		action.condition.WhereArr = action.condition.WhereArr.and(buildWhereConditionArr(args...))
+	}
+}
+
 // 添加表字段: 设置更新数据需要涉及的表字段列表
 func UpdateAddKeyArr(keyArr []string) func(*rdbUpdateAction) {
 	return func(action *rdbUpdateAction) {
@@ -326,6 +449,27 @@ func UpdateAddKeyArr(keyArr []string) func(*rdbUpdateAction) {
 	}
 }
 
+// 填充更新字段: 没有设置该字段时使用指定取值，如 更新时间、更新人
+// 需要放在添加数据的方法之后
+func UpdateFillColumn(key, value string) func(*rdbUpdateAction) {
+	return func(action *rdbUpdateAction) {
+		action.rdbField.fillColumn(key, value)
+	}
+}
+
+// 设置更新数据量，需要在 (0, maxModifyLimit] 范围内，否则执行时返回 DBParamInvalid
+// 没有设置时不限制
+func UpdateSetLimit(limit int) func(*rdbUpdateAction) {
+	return func(action *rdbUpdateAction) {
+		action.condition.setLimit(limit)
+	}
+}
+
+// 检查设置的更新数据量
+func (action *rdbUpdateAction) CheckLimit() error {
+	return action.condition.CheckLimit()
+}
+
 // 设置变更语句
 func UpdateSetSQL(sql string) func(*rdbUpdateAction) {
 	return func(action *rdbUpdateAction) {
@@ -344,6 +488,15 @@ func (action *rdbDeleteAction) GetCondition() updateCondition {
 	return action.condition
 }
 
+// 删除转换成更新（如 软删除）: 使用删除配置中的表空间和条件（包括 limit），更新的字段通过 funcArr 设置
+func (action *rdbDeleteAction) BuildUpdateFuncArr(funcArr ...RDBUpdateConfigFunc) []RDBUpdateConfigFunc {
+	space, condition := action.space, action.condition
+	return append([]RDBUpdateConfigFunc{func(updateAction *rdbUpdateAction) {
+		updateAction.space = space
+		updateAction.condition = condition
+	}}, funcArr...)
+}
+
 // 创建一个删除配置
 // DB 保护: 默认最多查询 1kw 条数据
 func MakeRDBDeleteAction() *rdbDeleteAction {
@@ -355,6 +508,13 @@ func MakeRDBDeleteAction() *rdbDeleteAction {
 // 删除数据配置方法定义
 type RDBDeleteConfigFunc func(*rdbDeleteAction)
 
+// 添加删除条件，格式和 DeleteSetCondition 一致，和已有条件使用 and 连接（包含 or 的条件会加上括号）
+func DeleteAddCondition(args ...string) func(*rdbDeleteAction) {
+	return func(action *rdbDeleteAction) {
+		action.condition.WhereArr = action.condition.WhereArr.and(buildWhereConditionArr(args...))
+	}
+}
+
 // 设置表空间
 func DeleteSetSpace(db, table string) func(*rdbDeleteAction) {
 	return func(action *rdbDeleteAction) {
@@ -369,13 +529,19 @@ func DeleteSetCondition(args ...string) func(*rdbDeleteAction) {
 	}
 }
 
-// 设置删除数据量
+// 设置删除数据量，需要在 (0, maxModifyLimit] 范围内，否则执行时返回 DBParamInvalid
+// 没有设置时默认为 maxModifyLimit
 func DeleteSetLimit(limit int) func(*rdbDeleteAction) {
 	return func(action *rdbDeleteAction) {
-		action.condition.Limit = limit
+		action.condition.setLimit(limit)
 	}
 }
 
+// 检查设置的删除数据量
+func (action *rdbDeleteAction) CheckLimit() error {
+	return action.condition.CheckLimit()
+}
+
 // 查询配置
 type rdbSearchAction struct {
 	space
@@ -384,6 +550,27 @@ type rdbSearchAction struct {
 	objectArrType reflect.Type // 用于生成最后的对象数组的类型
 	condition     SearchCondition
 	sql           string // 查询语句
+	alias         string // 主表别名
+	// 查询包括已经软删除的数据，只在软删除策略中使用
+	includeDeleted bool
+}
+
+// 获取主表别名
+func (action *rdbSearchAction) GetAlias() string {
+	return action.alias
+}
+
+// 获取查询中引用主表的名称: 有别名时使用别名，否则使用表名
+func (action *rdbSearchAction) GetName() string {
+	if action.alias != "" {
+		return action.alias
+	}
+	return action.table
+}
+
+// 是否查询已经软删除的数据
+func (action *rdbSearchAction) IsIncludeDeleted() bool {
+	return action.includeDeleted
 }
 
 // 获取需要查询的字段列表
@@ -478,6 +665,17 @@ func SearchSetOrderField(field string) func(*rdbSearchAction) {
 	}
 }
 
+// 添加排序字段: 前面的排序字段相同时，按照该字段排序，排序顺序和第一个排序字段一致
+func SearchAddOrderField(field string) RDBSearchConfigFunc {
+	return func(action *rdbSearchAction) {
+		if action.condition.Order.Field == "" {
+			action.condition.Order.Field = field
+			return
+		}
+		action.condition.Order.ThenFieldArr = append(action.condition.Order.ThenFieldArr, field)
+	}
+}
+
 // 设置排序条件（排序字段 + 排序顺序，asc or desc）
 func SearchSetOrderFieldAndAsc(field, asc string) func(*rdbSearchAction) {
 	return func(action *rdbSearchAction) {
@@ -485,7 +683,114 @@ func SearchSetOrderFieldAndAsc(field, asc string) func(*rdbSearchAction) {
 	}
 }
 
+// 添加查询条件，格式和 SearchSetCondition 一致，和已有条件使用 and 连接（包含 or 的条件会加上括号）
+func SearchAddCondition(args ...string) RDBSearchConfigFunc {
+	return func(action *rdbSearchAction) {
+		action.condition.WhereArr = action.condition.WhereArr.and(buildWhereConditionArr(args...))
+	}
+}
+
+// 添加子查询条件，和已有条件使用 and 连接
+// method: in / not in / = / != / < / > / <= / >=，子查询通过 SearchSetSpace 等查询配置方法设置
+// 示例: SearchAddSubQueryCondition("id", "in", SearchSetSpace("db", "order"), SearchSetKeyArr([]string{"user_id"}))
+func SearchAddSubQueryCondition(key, method string, subQueryFuncArr ...RDBSearchConfigFunc) RDBSearchConfigFunc {
+	return func(action *rdbSearchAction) {
+		conditionMethod := conditionMethod(method)
+		if keywordMethod, ok := keyWordToMethodMap[method]; ok {
+			conditionMethod = keywordMethod
+		}
+		action.condition.WhereArr = action.condition.WhereArr.and(whereArr{{Type: conditionTypeAssert, Key: key,
+			Method: conditionMethod, subQuery: buildSubQuery(subQueryFuncArr...)}})
+	}
+}
+
+// 添加 EXISTS 子查询条件，和已有条件使用 and 连接
+// 子查询中引用外层表的字段时需要带上 `，如: SearchSetCondition("o.user_id", "=", "`u`.id")
+func SearchAddExistsCondition(subQueryFuncArr ...RDBSearchConfigFunc) RDBSearchConfigFunc {
+	return SearchAddSubQueryCondition("", string(conditionMethodExists), subQueryFuncArr...)
+}
+
+// 添加 NOT EXISTS 子查询条件，和已有条件使用 and 连接
+func SearchAddNotExistsCondition(subQueryFuncArr ...RDBSearchConfigFunc) RDBSearchConfigFunc {
+	return SearchAddSubQueryCondition("", string(conditionMethodNotExists), subQueryFuncArr...)
+}
+
+// 创建子查询: 和普通查询不同，没有设置分页条件时不限制条数
+// mysql 不支持 in 子查询中使用 limit，设置了分页条件的 in / not in 子查询会包装成派生表: IN (SELECT * FROM (...) AS sub_query)
+func buildSubQuery(funcArr ...RDBSearchConfigFunc) *rdbSearchAction {
+	action := new(rdbSearchAction)
+	for _, currentFunc := range funcArr {
+		currentFunc(action)
+	}
+	return action
+}
+
+// 子查询转换成 SQL
+func (action *rdbSearchAction) renderSubQuery(isParam bool) (string, []interface{}) {
+	buffer := new(bytes.Buffer)
+	keyStr := "*"
+	if len(action.keyArr) != 0 {
+		keyStr = strings.Join(action.keyArr, ", ")
+	}
+	fromSQL, argArr := action.renderFrom(isParam)
+	buffer.WriteString(fmt.Sprintf("SELECT %s FROM %s", keyStr, fromSQL))
+	if orderSQL := action.condition.GetOrderSQL(); orderSQL != "" {
+		buffer.WriteString(" ORDER BY " + orderSQL)
+	}
+	if action.condition.Page.Limit > 0 {
+		buffer.WriteString(fmt.Sprintf(" LIMIT %d", action.condition.Page.Limit))
+		if action.condition.Page.No > 0 {
+			buffer.WriteString(fmt.Sprintf(" OFFSET %d", action.condition.Page.No*action.condition.Page.Limit))
+		}
+	}
+	return buffer.String(), argArr
+}
+
+// 查询的表、join 和 where 条件部分（FROM 之后）
+func (action *rdbSearchAction) renderFrom(isParam bool) (string, []interface{}) {
+	buffer := new(bytes.Buffer)
+	buffer.WriteString(action.GetSpaceName())
+	if action.alias != "" {
+		buffer.WriteString(fmt.Sprintf(" AS %s", action.alias))
+	}
+	if joinSQL := action.condition.Join.ToSQL(); joinSQL != "" {
+		buffer.WriteString(" " + joinSQL)
+	}
+	whereSQL, argArr := action.condition.WhereArr.render(isParam)
+	if whereSQL != "" {
+		buffer.WriteString(" WHERE " + whereSQL)
+	}
+	return buffer.String(), argArr
+}
+
+// 查询配置转换成查询语句，取值直接拼接到 SQL 中，用于不支持参数的 driver（如 impala）
+func (action *rdbSearchAction) ToSQL() string {
+	sql, _ := action.renderSubQuery(false)
+	return sql
+}
+
+// 查询配置转换成统计语句，不包括排序和分页条件
+func (action *rdbSearchAction) ToCountSQL() string {
+	fromSQL, _ := action.renderFrom(false)
+	return "SELECT COUNT(*) FROM " + fromSQL
+}
+
+// 查询包括已经软删除的数据，连接器没有配置软删除策略时不生效
+func SearchIncludeDeleted() RDBSearchConfigFunc {
+	return func(action *rdbSearchAction) {
+		action.includeDeleted = true
+	}
+}
+
+// 设置主表别名，查询字段和条件中可以通过 别名.字段 引用
+func SearchSetAlias(alias string) RDBSearchConfigFunc {
+	return func(action *rdbSearchAction) {
+		action.alias = alias
+	}
+}
+
 // 设置 join 条件, 默认 join 表 为 查询条件中的右侧表
+// 参数: db, 左表, 左表字段, 右表, 右表字段, [join 方式，默认 LEFT JOIN]
 func SearchAddJoin(fieldArr ...string) RDBSearchConfigFunc {
 	return func(rsa *rdbSearchAction) {
 		// at least contain: db, left table, left field, right table, right field
@@ -493,24 +798,57 @@ func SearchAddJoin(fieldArr ...string) RDBSearchConfigFunc {
 		if len(fieldArr) < 5 {
 			return
 		}
-		toAppendJoinCondition := joinCondition{}
 		db, leftTable, leftField, rightTable, rightField := fieldArr[0], fieldArr[1], fieldArr[2], fieldArr[3], fieldArr[4]
-		toAppendJoinCondition.joinMethod = LeftJoin
+		joinMethod := LeftJoin
 		if len(fieldArr) == 6 {
-			toAppendJoinCondition.joinMethod = JoinMethod(fieldArr[6])
+			joinMethod = JoinMethod(fieldArr[5])
 		}
-		toAppendJoinCondition.space = space{db: db, table: rightTable}
 		// condition 的组装，一般左右条件都是字段名，暂不考虑更复杂的情况（多条件，或是值判断）
+		SearchAddJoinTable(db, rightTable, JoinSetMethod(joinMethod),
+			JoinSetOn(fmt.Sprintf("%s.%s", leftTable, leftField), fmt.Sprintf("%s.%s", rightTable, rightField)))(rsa)
+	}
+}
 
-		// condition: 去掉多余空格，并按空格拆分成多个条件
-		toAppendJoinCondition.condition = []string{
-			// 加上 ` 表标识，防止当成字段
-			fmt.Sprintf("`%s`.%s", leftTable, leftField),
-			"=",
-			fmt.Sprintf("`%s`.%s", rightTable, rightField),
-		}
+// join 配置方法定义
+type JoinConfigFunc func(*joinCondition)
+
+// 设置 join 方式，默认 LEFT JOIN
+func JoinSetMethod(method JoinMethod) JoinConfigFunc {
+	return func(condition *joinCondition) {
+		condition.joinMethod = method
+	}
+}
+
+// 设置 join 表的别名，同一张表 join 多次时需要设置
+func JoinSetAlias(alias string) JoinConfigFunc {
+	return func(condition *joinCondition) {
+		condition.alias = alias
+	}
+}
 
-		rsa.condition.Join = append(rsa.condition.Join, toAppendJoinCondition)
+// 添加关联字段: 左侧字段 = 右侧字段，字段格式: 表名（或别名）.字段，多次调用时使用 and 连接
+func JoinSetOn(leftColumn, rightColumn string) JoinConfigFunc {
+	return func(condition *joinCondition) {
+		condition.onArr = append(condition.onArr, [2]string{leftColumn, rightColumn})
+	}
+}
+
+// 设置其他关联条件，格式和 SearchSetCondition 一致，如: "c.status", "=", "1"
+func JoinSetCondition(args ...string) JoinConfigFunc {
+	return func(condition *joinCondition) {
+		condition.condition = args
+	}
+}
+
+// 添加 join 表，示例:
+// SearchAddJoinTable("temp", "test_class", JoinSetMethod(InnerJoin), JoinSetAlias("c"), JoinSetOn("s.class_id", "c.id"))
+func SearchAddJoinTable(db, table string, funcArr ...JoinConfigFunc) RDBSearchConfigFunc {
+	return func(action *rdbSearchAction) {
+		toAppendJoinCondition := joinCondition{joinMethod: LeftJoin, space: space{db: db, table: table}}
+		for _, currentFunc := range funcArr {
+			currentFunc(&toAppendJoinCondition)
+		}
+		action.condition.Join = append(action.condition.Join, toAppendJoinCondition)
 	}
 }
 
@@ -535,11 +873,17 @@ func (action *rdbBackupAction) GetCondition() updateCondition {
 
 // 获取源表名称
 func (action *rdbBackupAction) GetSourceSpaceName() string {
+	if nil == action.sourceSpace {
+		return ""
+	}
 	return action.sourceSpace.GetSpaceName()
 }
 
 // 获取目标表名称
 func (action *rdbBackupAction) GetTargetSpaceName() string {
+	if nil == action.targetSpace {
+		return ""
+	}
 	return action.targetSpace.GetSpaceName()
 }
 
@@ -568,13 +912,19 @@ func BackupSetCondition(args ...string) func(*rdbBackupAction) {
 	}
 }
 
-// 设置备份数据量
+// 设置备份数据量，需要在 (0, maxModifyLimit] 范围内，否则执行时返回 DBParamInvalid
+// 没有设置时默认为 maxModifyLimit
 func BackupSetLimit(limit int) func(*rdbBackupAction) {
 	return func(action *rdbBackupAction) {
-		action.condition.Limit = limit
+		action.condition.setLimit(limit)
 	}
 }
 
+// 检查设置的备份数据量
+func (action *rdbBackupAction) CheckLimit() error {
+	return action.condition.CheckLimit()
+}
+
 // 设置备份目标表
 func BackupSetTargetSpace(db, table string) func(*rdbBackupAction) {
 	return func(action *rdbBackupAction) {
@@ -0,0 +1,376 @@
+// package dbtest 单元测试使用的 RDBConnector 实现
+// 测试可以预先声明期望的调用（方法、表、条件）及返回值，连接器会记录所有调用，最后统一校验
+// 示例:
+//
+//	connector := dbtest.NewMockConnector()
+//	connector.Expect(dbtest.MethodSearch).Space("d_meta", "t_lock").Condition("name", "=", "x").
+//		ReturnRows(map[string]string{"name": "x"})
+//	... 执行被测试的代码 ...
+//	require.Empty(t, connector.ExpectationsWereMet())
+package dbtest
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/smiecj/go_common/db"
+	"github.com/smiecj/go_common/errorcode"
+)
+
+// 调用方法
+type Method string
+
+const (
+	MethodInsert     Method = "Insert"
+	MethodUpdate     Method = "Update"
+	MethodDelete     Method = "Delete"
+	MethodBackup     Method = "Backup"
+	MethodSearch     Method = "Search"
+	MethodExec       Method = "Exec"
+	MethodExecSearch Method = "ExecSearch"
+	MethodCount      Method = "Count"
+	MethodDistinct   Method = "Distinct"
+)
+
+// 一次调用的记录，条件统一转换成 SQL 格式（db.WhereArr.ToSQL），方便和期望值对比
+type Call struct {
+	Method Method
+	// 库名.表名，备份时为源表
+	Space string
+	// 备份目标表
+	TargetSpace string
+	Condition   string
+	KeyArr      []string
+	// key-value 格式的数据
+	FieldArr []map[string]string
+	// 结构体格式的数据，单个结构体（InsertSetObject）也会放到数组中
+	ObjectArr []interface{}
+	SQL       string
+	// 查询排序和分页
+	OrderField string
+	OrderSc    string
+	PageNo     int
+	PageLimit  int
+}
+
+// 调用的简短描述，用于错误信息
+func (call Call) String() string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(fmt.Sprintf("%s %s", call.Method, call.Space))
+	if call.Condition != "" {
+		buf.WriteString(fmt.Sprintf(" where %s", call.Condition))
+	}
+	if call.SQL != "" {
+		buf.WriteString(fmt.Sprintf(" sql: %s", call.SQL))
+	}
+	return buf.String()
+}
+
+// 期望的调用
+type Expectation struct {
+	method       Method
+	space        string
+	condition    string
+	hasCondition bool
+	sql          string
+	matchFunc    func(Call) bool
+
+	updateRet db.UpdateRet
+	searchRet db.SearchRet
+	err       error
+
+	// 期望调用次数，-1 表示不限次数
+	times   int
+	matched int
+}
+
+// 期望调用的表
+func (expectation *Expectation) Space(dbName, table string) *Expectation {
+	expectation.space = fmt.Sprintf("%s.%s", dbName, table)
+	return expectation
+}
+
+// 期望调用的条件，格式和 db.SearchSetCondition 一致，比较的是转换成 SQL 之后的结果
+func (expectation *Expectation) Condition(args ...string) *Expectation {
+	expectation.condition, expectation.hasCondition = renderCondition(args...), true
+	return expectation
+}
+
+// 期望执行的 SQL（Exec / ExecSearch）
+func (expectation *Expectation) SQL(sql string) *Expectation {
+	expectation.sql = sql
+	return expectation
+}
+
+// 自定义匹配方法，用于校验写入的数据等
+func (expectation *Expectation) Match(matchFunc func(Call) bool) *Expectation {
+	expectation.matchFunc = matchFunc
+	return expectation
+}
+
+// 期望调用次数，默认 1 次
+func (expectation *Expectation) Times(times int) *Expectation {
+	expectation.times = times
+	return expectation
+}
+
+// 不限制调用次数（也可以不调用）
+func (expectation *Expectation) AnyTimes() *Expectation {
+	expectation.times = -1
+	return expectation
+}
+
+// 更新类操作的返回: 影响行数
+func (expectation *Expectation) ReturnAffectedRows(affectedRows int) *Expectation {
+	expectation.updateRet = db.UpdateRet{AffectedRows: affectedRows}
+	return expectation
+}
+
+// 查询类操作的返回: key-value 格式数据，Len 和 Total 为数据条数
+func (expectation *Expectation) ReturnRows(rowArr ...map[string]string) *Expectation {
+	ret := db.SearchRet{Len: len(rowArr), Total: len(rowArr)}
+	for _, row := range rowArr {
+		currentField := db.BuildNewField()
+		currentField.AddMap(row)
+		ret.AddField(currentField)
+	}
+	expectation.searchRet = ret
+	return expectation
+}
+
+// 查询类操作的返回: 结构体数组，Len 和 Total 为数组长度
+func (expectation *Expectation) ReturnObjectArr(objectArr interface{}) *Expectation {
+	length := reflect.ValueOf(objectArr).Len()
+	expectation.searchRet = db.SearchRet{ObjectArr: objectArr, Len: length, Total: length}
+	return expectation
+}
+
+// 查询类操作的返回: 完整的查询结果（如 Count 的 Total）
+func (expectation *Expectation) ReturnSearchRet(ret db.SearchRet) *Expectation {
+	expectation.searchRet = ret
+	return expectation
+}
+
+// 返回错误
+func (expectation *Expectation) ReturnError(err error) *Expectation {
+	expectation.err = err
+	return expectation
+}
+
+// 判断调用是否满足期望
+func (expectation *Expectation) isMatch(call Call) bool {
+	if expectation.method != call.Method {
+		return false
+	}
+	if expectation.times >= 0 && expectation.matched >= expectation.times {
+		return false
+	}
+	if expectation.space != "" && expectation.space != call.Space {
+		return false
+	}
+	if expectation.hasCondition && expectation.condition != call.Condition {
+		return false
+	}
+	if expectation.sql != "" && expectation.sql != call.SQL {
+		return false
+	}
+	if nil != expectation.matchFunc && !expectation.matchFunc(call) {
+		return false
+	}
+	return true
+}
+
+func (expectation *Expectation) String() string {
+	desc := fmt.Sprintf("%s %s", expectation.method, expectation.space)
+	if expectation.hasCondition {
+		desc = fmt.Sprintf("%s where %s", desc, expectation.condition)
+	}
+	if expectation.sql != "" {
+		desc = fmt.Sprintf("%s sql: %s", desc, expectation.sql)
+	}
+	return desc
+}
+
+// 期望 / 记录 连接器
+type MockConnector struct {
+	expectationArr []*Expectation
+	callArr        []Call
+	unexpectedArr  []Call
+	lock           sync.Mutex
+}
+
+// 创建连接器
+func NewMockConnector() *MockConnector {
+	return new(MockConnector)
+}
+
+// 声明一个期望的调用，按照声明顺序匹配
+func (connector *MockConnector) Expect(method Method) *Expectation {
+	connector.lock.Lock()
+	defer connector.lock.Unlock()
+
+	expectation := &Expectation{method: method, times: 1}
+	connector.expectationArr = append(connector.expectationArr, expectation)
+	return expectation
+}
+
+// 获取所有调用记录
+func (connector *MockConnector) Calls() []Call {
+	connector.lock.Lock()
+	defer connector.lock.Unlock()
+	return append([]Call{}, connector.callArr...)
+}
+
+// 校验: 所有期望的调用都已经发生，并且没有非预期的调用
+func (connector *MockConnector) ExpectationsWereMet() error {
+	connector.lock.Lock()
+	defer connector.lock.Unlock()
+
+	msgArr := make([]string, 0)
+	for _, expectation := range connector.expectationArr {
+		if expectation.times >= 0 && expectation.matched < expectation.times {
+			msgArr = append(msgArr, fmt.Sprintf("expected call not happen (%d/%d): %s",
+				expectation.matched, expectation.times, expectation.String()))
+		}
+	}
+	for _, call := range connector.unexpectedArr {
+		msgArr = append(msgArr, fmt.Sprintf("unexpected call: %s", call.String()))
+	}
+	if len(msgArr) == 0 {
+		return nil
+	}
+	return errorcode.BuildErrorWithMsg(errorcode.DBExecFailed, strings.Join(msgArr, "; "))
+}
+
+// 记录调用，并返回匹配的期望
+func (connector *MockConnector) record(call Call) (*Expectation, error) {
+	connector.lock.Lock()
+	defer connector.lock.Unlock()
+
+	connector.callArr = append(connector.callArr, call)
+	for _, expectation := range connector.expectationArr {
+		if expectation.isMatch(call) {
+			expectation.matched++
+			return expectation, expectation.err
+		}
+	}
+	connector.unexpectedArr = append(connector.unexpectedArr, call)
+	return nil, errorcode.BuildErrorWithMsg(errorcode.DBExecFailed, "unexpected call: "+call.String())
+}
+
+func (connector *MockConnector) recordUpdate(call Call) (db.UpdateRet, error) {
+	expectation, err := connector.record(call)
+	if nil == expectation {
+		return db.UpdateRet{}, err
+	}
+	return expectation.updateRet, err
+}
+
+func (connector *MockConnector) recordSearch(call Call) (db.SearchRet, error) {
+	expectation, err := connector.record(call)
+	if nil == expectation {
+		return db.SearchRet{}, err
+	}
+	return expectation.searchRet, err
+}
+
+func (connector *MockConnector) Insert(funcArr ...db.RDBInsertConfigFunc) (db.UpdateRet, error) {
+	action := db.MakeRDBInsertAction()
+	for _, currentFunc := range funcArr {
+		currentFunc(action)
+	}
+	call := Call{Method: MethodInsert, Space: action.GetSpaceName(), KeyArr: action.GetKeyArr(),
+		ObjectArr: action.GetObjectArr()}
+	for _, currentField := range action.GetFieldArr() {
+		call.FieldArr = append(call.FieldArr, currentField.GetMap())
+	}
+	if nil != action.GetObject() {
+		call.ObjectArr = append([]interface{}{action.GetObject()}, call.ObjectArr...)
+	}
+	return connector.recordUpdate(call)
+}
+
+func (connector *MockConnector) Update(funcArr ...db.RDBUpdateConfigFunc) (db.UpdateRet, error) {
+	action := db.MakeRDBUpdateAction()
+	for _, currentFunc := range funcArr {
+		currentFunc(action)
+	}
+	call := Call{Method: MethodUpdate, Space: action.GetSpaceName(), Condition: action.GetCondition().WhereArr.ToSQL(),
+		KeyArr: action.GetKeyArr(), ObjectArr: action.GetObjectArr()}
+	for _, currentField := range action.GetFieldArr() {
+		call.FieldArr = append(call.FieldArr, currentField.GetMap())
+	}
+	return connector.recordUpdate(call)
+}
+
+func (connector *MockConnector) Delete(funcArr ...db.RDBDeleteConfigFunc) (db.UpdateRet, error) {
+	action := db.MakeRDBDeleteAction()
+	for _, currentFunc := range funcArr {
+		currentFunc(action)
+	}
+	return connector.recordUpdate(Call{Method: MethodDelete, Space: action.GetSpaceName(),
+		Condition: action.GetCondition().WhereArr.ToSQL()})
+}
+
+func (connector *MockConnector) Backup(funcArr ...db.RDBBackupConfigFunc) (db.UpdateRet, error) {
+	action := db.MakeRDBBackupAction()
+	for _, currentFunc := range funcArr {
+		currentFunc(action)
+	}
+	return connector.recordUpdate(Call{Method: MethodBackup, Space: action.GetSourceSpaceName(),
+		TargetSpace: action.GetTargetSpaceName(), Condition: action.GetCondition().WhereArr.ToSQL()})
+}
+
+func (connector *MockConnector) Exec(funcArr ...db.RDBUpdateConfigFunc) (db.UpdateRet, error) {
+	action := db.MakeRDBUpdateAction()
+	for _, currentFunc := range funcArr {
+		currentFunc(action)
+	}
+	return connector.recordUpdate(Call{Method: MethodExec, Space: action.GetSpaceName(), SQL: action.GetSQL()})
+}
+
+func (connector *MockConnector) Search(funcArr ...db.RDBSearchConfigFunc) (db.SearchRet, error) {
+	return connector.recordSearch(buildSearchCall(MethodSearch, funcArr...))
+}
+
+func (connector *MockConnector) ExecSearch(funcArr ...db.RDBSearchConfigFunc) (db.SearchRet, error) {
+	return connector.recordSearch(buildSearchCall(MethodExecSearch, funcArr...))
+}
+
+func (connector *MockConnector) Count(funcArr ...db.RDBSearchConfigFunc) (db.SearchRet, error) {
+	return connector.recordSearch(buildSearchCall(MethodCount, funcArr...))
+}
+
+func (connector *MockConnector) Distinct(funcArr ...db.RDBSearchConfigFunc) (db.SearchRet, error) {
+	return connector.recordSearch(buildSearchCall(MethodDistinct, funcArr...))
+}
+
+func (connector *MockConnector) Close() error {
+	return nil
+}
+
+func (connector *MockConnector) Stat() (db.DBStat, error) {
+	return db.DBStat{}, nil
+}
+
+// 查询类调用记录
+func buildSearchCall(method Method, funcArr ...db.RDBSearchConfigFunc) Call {
+	action := db.MakeRDBSearchAction()
+	for _, currentFunc := range funcArr {
+		currentFunc(action)
+	}
+	condition := action.GetCondition()
+	return Call{Method: method, Space: action.GetSpaceName(), Condition: condition.WhereArr.ToSQL(),
+		KeyArr: action.GetKeyArr(), SQL: action.GetSQL(), OrderField: strings.Join(condition.GetOrderFieldArr(), ", "),
+		OrderSc: condition.Order.Sc, PageNo: condition.Page.No, PageLimit: condition.Page.Limit}
+}
+
+// 将条件参数转换成 SQL，和连接器中记录的条件格式一致
+func renderCondition(args ...string) string {
+	action := db.MakeRDBSearchAction()
+	db.SearchSetCondition(args...)(action)
+	return action.GetCondition().WhereArr.ToSQL()
+}
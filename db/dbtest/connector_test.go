@@ -0,0 +1,56 @@
+package dbtest
+
+import (
+	"testing"
+
+	"github.com/smiecj/go_common/db"
+	"github.com/stretchr/testify/require"
+)
+
+// 测试期望匹配、返回值和调用记录
+func TestMockConnector(t *testing.T) {
+	connector := NewMockConnector()
+	connector.Expect(MethodSearch).Space("d_meta", "t_lock").Condition("name", "=", "test_lock").
+		ReturnRows(map[string]string{"name": "test_lock", "version": "1"})
+	connector.Expect(MethodInsert).Space("d_meta", "t_lock").Match(func(call Call) bool {
+		return len(call.FieldArr) == 1 && call.FieldArr[0]["name"] == "new_lock"
+	}).ReturnAffectedRows(1)
+
+	searchRet, err := connector.Search(db.SearchSetSpace("d_meta", "t_lock"), db.SearchSetCondition("name", "=", "test_lock"))
+	require.Empty(t, err)
+	require.Equal(t, 1, searchRet.Len)
+	require.Equal(t, "1", searchRet.FieldArr[0].GetMap()["version"])
+
+	// 条件不匹配: 返回错误
+	_, err = connector.Search(db.SearchSetSpace("d_meta", "t_lock"), db.SearchSetCondition("name", "=", "other_lock"))
+	require.NotEmpty(t, err)
+
+	newField := db.BuildNewField()
+	newField.AddKeyValue("name", "new_lock")
+	insertRet, err := connector.Insert(db.InsertSetSpace("d_meta", "t_lock"), db.InsertAddField(newField))
+	require.Empty(t, err)
+	require.Equal(t, 1, insertRet.AffectedRows)
+
+	callArr := connector.Calls()
+	require.Equal(t, 3, len(callArr))
+	require.Equal(t, "name = 'test_lock'", callArr[0].Condition)
+
+	err = connector.ExpectationsWereMet()
+	require.NotEmpty(t, err)
+	require.Contains(t, err.Error(), "other_lock")
+}
+
+// 测试期望调用没有发生时校验失败
+func TestMockConnectorNotMet(t *testing.T) {
+	connector := NewMockConnector()
+	connector.Expect(MethodDelete).Space("d_meta", "t_lock").Times(2)
+	connector.Expect(MethodCount).AnyTimes()
+
+	_, err := connector.Delete(db.DeleteSetSpace("d_meta", "t_lock"))
+	require.Empty(t, err)
+	require.NotEmpty(t, connector.ExpectationsWereMet())
+
+	_, err = connector.Delete(db.DeleteSetSpace("d_meta", "t_lock"))
+	require.Empty(t, err)
+	require.Empty(t, connector.ExpectationsWereMet())
+}
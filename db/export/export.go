@@ -0,0 +1,278 @@
+// package export 查询结果导出: csv、json lines、xlsx
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+
+	"github.com/smiecj/go_common/db"
+	"github.com/smiecj/go_common/errorcode"
+	"github.com/smiecj/go_common/util/log"
+)
+
+// 导出格式
+type Format string
+
+const (
+	FormatCSV   Format = "csv"
+	FormatJSONL Format = "jsonl"
+	FormatXLSX  Format = "xlsx"
+
+	defaultSheetName = "Sheet1"
+)
+
+// 文件后缀
+func (format Format) Ext() string {
+	return "." + string(format)
+}
+
+// 文件类型，用于邮件附件和 http 上传
+func (format Format) ContentType() string {
+	switch format {
+	case FormatCSV:
+		return "text/csv"
+	case FormatJSONL:
+		return "application/x-ndjson"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "application/octet-stream"
+}
+
+// 导出配置
+type exportConfig struct {
+	keyArr     []string
+	noHeader   bool
+	sheetName  string
+	csvComma   rune
+	writeBOM   bool
+	rawNumbers bool
+}
+
+type ExportConfigFunc func(*exportConfig)
+
+// 设置导出的列和顺序，一般和查询时的 SearchSetKeyArr 保持一致
+// 不设置时: 结构体按照字段定义顺序，field 按照列名排序
+func ExportSetKeyArr(keyArr []string) ExportConfigFunc {
+	return func(config *exportConfig) {
+		config.keyArr = keyArr
+	}
+}
+
+// 不输出表头（csv、xlsx）
+func ExportDisableHeader() ExportConfigFunc {
+	return func(config *exportConfig) {
+		config.noHeader = true
+	}
+}
+
+// 设置 xlsx sheet 名称
+func ExportSetSheetName(sheetName string) ExportConfigFunc {
+	return func(config *exportConfig) {
+		config.sheetName = sheetName
+	}
+}
+
+// 设置 csv 分隔符
+func ExportSetCSVComma(comma rune) ExportConfigFunc {
+	return func(config *exportConfig) {
+		config.csvComma = comma
+	}
+}
+
+// csv 文件头写入 UTF-8 BOM，避免 excel 直接打开中文乱码
+func ExportSetCSVBOM() ExportConfigFunc {
+	return func(config *exportConfig) {
+		config.writeBOM = true
+	}
+}
+
+// xlsx 中的数字保持字符串格式（如 手机号、编号）
+func ExportSetXLSXStringNumbers() ExportConfigFunc {
+	return func(config *exportConfig) {
+		config.rawNumbers = true
+	}
+}
+
+// 导出的表格数据
+type table struct {
+	keyArr []string
+	rowArr [][]string
+}
+
+// 导出查询结果到 writer
+func Write(writer io.Writer, format Format, searchRet db.SearchRet, configFuncArr ...ExportConfigFunc) error {
+	config := &exportConfig{sheetName: defaultSheetName, csvComma: ','}
+	for _, configFunc := range configFuncArr {
+		configFunc(config)
+	}
+
+	exportTable, err := buildTable(searchRet, config.keyArr)
+	if nil != err {
+		return err
+	}
+
+	switch format {
+	case FormatCSV:
+		err = writeCSV(writer, exportTable, config)
+	case FormatJSONL:
+		err = writeJSONL(writer, exportTable)
+	case FormatXLSX:
+		err = writeXLSX(writer, exportTable, config)
+	default:
+		return errorcode.BuildErrorWithMsg(errorcode.NotImplement, fmt.Sprintf("export format %s not supported", format))
+	}
+	if nil != err {
+		log.Error("[export.Write] export %s failed: %s", format, err.Error())
+		return errorcode.BuildErrorWithMsg(errorcode.FileWriteFailed, err.Error())
+	}
+	return nil
+}
+
+// 导出查询结果到文件
+func WriteFile(filePath string, format Format, searchRet db.SearchRet, configFuncArr ...ExportConfigFunc) error {
+	file, err := os.Create(filePath)
+	if nil != err {
+		log.Error("[export.WriteFile] create file %s failed: %s", filePath, err.Error())
+		return errorcode.BuildErrorWithMsg(errorcode.FileWriteFailed, err.Error())
+	}
+	err = Write(file, format, searchRet, configFuncArr...)
+	closeErr := file.Close()
+	if nil != err {
+		return err
+	}
+	if nil != closeErr {
+		return errorcode.BuildErrorWithMsg(errorcode.FileWriteFailed, closeErr.Error())
+	}
+	return nil
+}
+
+// 导出查询结果到内存，可直接作为邮件附件或 http 上传内容
+func ToBytes(format Format, searchRet db.SearchRet, configFuncArr ...ExportConfigFunc) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := Write(buf, format, searchRet, configFuncArr...)
+	if nil != err {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// 将查询结果转换成表格: 优先使用结构体列表，否则使用 field 列表
+func buildTable(searchRet db.SearchRet, keyArr []string) (table, error) {
+	if nil != searchRet.ObjectArr {
+		return buildObjectTable(searchRet.ObjectArr, keyArr)
+	}
+
+	if len(keyArr) == 0 {
+		keyMap := make(map[string]struct{})
+		for _, currentField := range searchRet.FieldArr {
+			for key := range currentField.GetMap() {
+				keyMap[key] = struct{}{}
+			}
+		}
+		for key := range keyMap {
+			keyArr = append(keyArr, key)
+		}
+		sort.Strings(keyArr)
+	}
+
+	ret := table{keyArr: keyArr, rowArr: make([][]string, 0, len(searchRet.FieldArr))}
+	for _, currentField := range searchRet.FieldArr {
+		row := make([]string, len(keyArr))
+		for index, key := range keyArr {
+			row[index], _ = db.GetRowValue(currentField.GetMap(), key)
+		}
+		ret.rowArr = append(ret.rowArr, row)
+	}
+	return ret, nil
+}
+
+// 结构体列表转换成表格
+func buildObjectTable(objectArr interface{}, keyArr []string) (table, error) {
+	objectArrValue := reflect.ValueOf(objectArr)
+	for objectArrValue.Kind() == reflect.Ptr {
+		objectArrValue = objectArrValue.Elem()
+	}
+	if objectArrValue.Kind() != reflect.Slice {
+		return table{}, errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid,
+			fmt.Sprintf("object array type %s is not slice", objectArrValue.Type()))
+	}
+
+	if len(keyArr) == 0 {
+		var err error
+		keyArr, err = db.GetObjectColumnArr(objectArrValue.Interface())
+		if nil != err {
+			return table{}, err
+		}
+	}
+
+	ret := table{keyArr: keyArr, rowArr: make([][]string, 0, objectArrValue.Len())}
+	for index := 0; index < objectArrValue.Len(); index++ {
+		object := objectArrValue.Index(index).Interface()
+		row := make([]string, len(keyArr))
+		for keyIndex, key := range keyArr {
+			value, err := db.GetObjectColumnValue(object, key)
+			if nil != err {
+				return table{}, err
+			}
+			row[keyIndex] = value
+		}
+		ret.rowArr = append(ret.rowArr, row)
+	}
+	return ret, nil
+}
+
+// 写入 csv
+func writeCSV(writer io.Writer, exportTable table, config *exportConfig) error {
+	if config.writeBOM {
+		if _, err := writer.Write([]byte("\xEF\xBB\xBF")); nil != err {
+			return err
+		}
+	}
+	csvWriter := csv.NewWriter(writer)
+	csvWriter.Comma = config.csvComma
+	if !config.noHeader {
+		if err := csvWriter.Write(exportTable.keyArr); nil != err {
+			return err
+		}
+	}
+	if err := csvWriter.WriteAll(exportTable.rowArr); nil != err {
+		return err
+	}
+	return csvWriter.Error()
+}
+
+// 写入 json lines: 每行一个 json 对象，key 按照列顺序输出
+func writeJSONL(writer io.Writer, exportTable table) error {
+	keyBytesArr := make([][]byte, 0, len(exportTable.keyArr))
+	for _, key := range exportTable.keyArr {
+		keyBytes, _ := json.Marshal(key)
+		keyBytesArr = append(keyBytesArr, keyBytes)
+	}
+
+	buf := new(bytes.Buffer)
+	for _, row := range exportTable.rowArr {
+		buf.Reset()
+		buf.WriteByte('{')
+		for index, value := range row {
+			if index > 0 {
+				buf.WriteByte(',')
+			}
+			valueBytes, _ := json.Marshal(value)
+			buf.Write(keyBytesArr[index])
+			buf.WriteByte(':')
+			buf.Write(valueBytes)
+		}
+		buf.WriteString("}\n")
+		if _, err := writer.Write(buf.Bytes()); nil != err {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smiecj/go_common/db"
+	"github.com/smiecj/go_common/errorcode"
+	"github.com/stretchr/testify/require"
+)
+
+type testStudent struct {
+	Id         int    `gorm:"column:id"`
+	Name       string `json:"name"`
+	Phone      string
+	UpdateTime time.Time
+}
+
+// 构建 field 格式的查询结果
+func buildFieldSearchRet() db.SearchRet {
+	searchRet := db.SearchRet{}
+	for _, keyValueMap := range []map[string]string{
+		{"id": "1", "name": "xiaoming", "remark": "a,\"b\""},
+		{"id": "2", "name": "小红"},
+	} {
+		field := db.BuildNewField()
+		field.AddMap(keyValueMap)
+		searchRet.AddField(field)
+	}
+	searchRet.Len = len(searchRet.FieldArr)
+	return searchRet
+}
+
+// 测试导出 csv
+func TestExportCSV(t *testing.T) {
+	content, err := ToBytes(FormatCSV, buildFieldSearchRet(), ExportSetKeyArr([]string{"name", "id", "remark"}))
+	require.Empty(t, err)
+	require.Equal(t, "name,id,remark\nxiaoming,1,\"a,\"\"b\"\"\"\n小红,2,\n", string(content))
+
+	// 不指定列时按照列名排序
+	content, err = ToBytes(FormatCSV, buildFieldSearchRet(), ExportDisableHeader(), ExportSetCSVComma('\t'))
+	require.Empty(t, err)
+	require.Equal(t, "1\txiaoming\t\"a,\"\"b\"\"\"\n2\t小红\t\n", string(content))
+
+	// 结构体按照字段定义顺序
+	updateTime, _ := time.Parse("2006-01-02 15:04:05", "2022-01-01 10:00:00")
+	searchRet := db.SearchRet{ObjectArr: &[]testStudent{{Id: 1, Name: "xiaoming", Phone: "010", UpdateTime: updateTime}}}
+	content, err = ToBytes(FormatCSV, searchRet, ExportSetCSVBOM())
+	require.Empty(t, err)
+	require.Equal(t, "\xEF\xBB\xBFid,name,phone,update_time\n1,xiaoming,010,2022-01-01 10:00:00\n", string(content))
+
+	_, err = ToBytes(FormatCSV, searchRet, ExportSetKeyArr([]string{"not_exist"}))
+	require.ErrorIs(t, err, errorcode.BuildError(errorcode.DBParamInvalid))
+	_, err = ToBytes(Format("pdf"), searchRet)
+	require.ErrorIs(t, err, errorcode.BuildError(errorcode.NotImplement))
+}
+
+// 测试导出 json lines
+func TestExportJSONL(t *testing.T) {
+	content, err := ToBytes(FormatJSONL, buildFieldSearchRet(), ExportSetKeyArr([]string{"name", "id"}))
+	require.Empty(t, err)
+	require.Equal(t, "{\"name\":\"xiaoming\",\"id\":\"1\"}\n{\"name\":\"小红\",\"id\":\"2\"}\n", string(content))
+
+	content, err = ToBytes(FormatJSONL, db.SearchRet{ObjectArr: []*testStudent{{Id: 2, Name: "xiaohong"}}},
+		ExportSetKeyArr([]string{"id", "name"}))
+	require.Empty(t, err)
+	require.Equal(t, "{\"id\":\"2\",\"name\":\"xiaohong\"}\n", string(content))
+}
+
+// 测试导出 xlsx 文件
+func TestExportXLSX(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "student"+FormatXLSX.Ext())
+	searchRet := db.SearchRet{ObjectArr: []testStudent{{Id: 1, Name: "<xiaoming>", Phone: "010"}, {Id: 2, Name: "小红"}}}
+	err := WriteFile(filePath, FormatXLSX, searchRet, ExportSetKeyArr([]string{"id", "name", "phone"}),
+		ExportSetSheetName("student:list"))
+	require.Empty(t, err)
+
+	content, err := ioutil.ReadFile(filePath)
+	require.Empty(t, err)
+	zipReader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	require.Empty(t, err)
+	fileMap := make(map[string]string)
+	for _, file := range zipReader.File {
+		reader, err := file.Open()
+		require.Empty(t, err)
+		fileContent, _ := ioutil.ReadAll(reader)
+		reader.Close()
+		fileMap[file.Name] = string(fileContent)
+	}
+	require.Len(t, fileMap, 5)
+	require.Contains(t, fileMap["xl/workbook.xml"], `name="student_list"`)
+
+	sheet := fileMap["xl/worksheets/sheet1.xml"]
+	require.Equal(t, 3, strings.Count(sheet, "<row "))
+	require.Contains(t, sheet, `<c r="C1" t="inlineStr"><is><t xml:space="preserve">phone</t></is></c>`)
+	require.Contains(t, sheet, `<c r="A2"><v>1</v></c>`)
+	require.Contains(t, sheet, `<c r="B2" t="inlineStr"><is><t xml:space="preserve">&lt;xiaoming&gt;</t></is></c>`)
+	// 带前导 0 的数字保持字符串
+	require.Contains(t, sheet, `<c r="C2" t="inlineStr"><is><t xml:space="preserve">010</t></is></c>`)
+	require.Contains(t, sheet, `<t xml:space="preserve">小红</t>`)
+
+	require.Equal(t, "A", getColumnName(0))
+	require.Equal(t, "Z", getColumnName(25))
+	require.Equal(t, "AA", getColumnName(26))
+	require.Equal(t, "BA", getColumnName(52))
+}
@@ -0,0 +1,143 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// xlsx 文件中的固定内容
+// 只生成一个 sheet，单元格使用 inlineStr，不依赖 sharedStrings
+const (
+	xlsxContentTypes = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`
+	xlsxRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`
+	xlsxWorkbookRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`
+	xlsxWorkbookFormat = xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	xlsxSheetHeader = xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	xlsxSheetFooter = `</sheetData></worksheet>`
+
+	// sheet 名称最大长度
+	xlsxSheetNameMaxLen = 31
+)
+
+// 写入 xlsx
+func writeXLSX(writer io.Writer, exportTable table, config *exportConfig) error {
+	zipWriter := zip.NewWriter(writer)
+	fileArr := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRels},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/workbook.xml", strings.Replace(xlsxWorkbookFormat, "%s", escapeXML(getSheetName(config.sheetName)), 1)},
+	}
+	for _, file := range fileArr {
+		fileWriter, err := zipWriter.Create(file.name)
+		if nil != err {
+			return err
+		}
+		if _, err = io.WriteString(fileWriter, file.content); nil != err {
+			return err
+		}
+	}
+
+	sheetWriter, err := zipWriter.Create("xl/worksheets/sheet1.xml")
+	if nil != err {
+		return err
+	}
+	if err = writeSheet(sheetWriter, exportTable, config); nil != err {
+		return err
+	}
+	return zipWriter.Close()
+}
+
+// 写入 sheet 数据
+func writeSheet(writer io.Writer, exportTable table, config *exportConfig) error {
+	var builder strings.Builder
+	builder.WriteString(xlsxSheetHeader)
+	rowIndex := 0
+	writeRow := func(row []string, isHeader bool) error {
+		rowIndex++
+		builder.WriteString(`<row r="` + strconv.Itoa(rowIndex) + `">`)
+		for columnIndex, value := range row {
+			cellRef := getColumnName(columnIndex) + strconv.Itoa(rowIndex)
+			if !isHeader && !config.rawNumbers && isNumber(value) {
+				builder.WriteString(`<c r="` + cellRef + `"><v>` + value + `</v></c>`)
+			} else {
+				builder.WriteString(`<c r="` + cellRef + `" t="inlineStr"><is><t xml:space="preserve">` +
+					escapeXML(value) + `</t></is></c>`)
+			}
+		}
+		builder.WriteString(`</row>`)
+		// 按行刷新，避免大结果集占用过多内存
+		_, err := io.WriteString(writer, builder.String())
+		builder.Reset()
+		return err
+	}
+
+	if !config.noHeader {
+		if err := writeRow(exportTable.keyArr, true); nil != err {
+			return err
+		}
+	}
+	for _, row := range exportTable.rowArr {
+		if err := writeRow(row, false); nil != err {
+			return err
+		}
+	}
+	builder.WriteString(xlsxSheetFooter)
+	_, err := io.WriteString(writer, builder.String())
+	return err
+}
+
+// 列序号转换成 excel 列名: 0 -> A, 26 -> AA
+func getColumnName(index int) string {
+	name := ""
+	for index++; index > 0; index = (index - 1) / 26 {
+		name = string(rune('A'+(index-1)%26)) + name
+	}
+	return name
+}
+
+// 是否按数字写入: 需要和转换回字符串的格式一致，避免丢失前导 0 等信息
+func isNumber(value string) bool {
+	number, err := strconv.ParseFloat(value, 64)
+	if nil != err || math.IsNaN(number) || math.IsInf(number, 0) {
+		return false
+	}
+	return strconv.FormatFloat(number, 'f', -1, 64) == value
+}
+
+// sheet 名称不能包含特殊字符，且长度有限制
+func getSheetName(sheetName string) string {
+	sheetName = strings.NewReplacer(":", "_", "\\", "_", "/", "_", "?", "_", "*", "_", "[", "_", "]", "_").Replace(sheetName)
+	if runeArr := []rune(sheetName); len(runeArr) > xlsxSheetNameMaxLen {
+		sheetName = string(runeArr[:xlsxSheetNameMaxLen])
+	}
+	if sheetName == "" {
+		return defaultSheetName
+	}
+	return sheetName
+}
+
+// xml 转义
+func escapeXML(value string) string {
+	var builder strings.Builder
+	xml.EscapeText(&builder, []byte(value))
+	return builder.String()
+}
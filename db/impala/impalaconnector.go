@@ -1,12 +1,14 @@
-// package impala impala 数据库连接器，可进行基本的count查询
+// package impala impala 数据库连接器，支持计数和 key-value 格式的查询
 // 后续实现 对 struct 进行赋值（需要了解 struct tag 的机制）
 package impala
 
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"strconv"
 	"sync"
+	"time"
 
 	"database/sql/driver"
 
@@ -19,6 +21,8 @@ import (
 
 const (
 	impalaConfigDefaultSpace = "impala"
+	// 时间类型字段转换成字符串的格式，和 mysql 的 datetime 一致
+	timeFormat = "2006-01-02 15:04:05"
 )
 
 var (
@@ -29,8 +33,8 @@ var (
 // impala 连接配置
 // 后续 可支持传入用户 进行校验操作
 type ImpalaConnectOption struct {
-	Host string
-	Port int
+	Host string `yaml:"host" validate:"required"`
+	Port int    `yaml:"port" default:"21050" validate:"min=1,max=65535"`
 }
 
 // impala 连接器
@@ -59,9 +63,96 @@ func (connector *impalaConnector) Backup(funcArr ...RDBBackupConfigFunc) (ret Up
 	return ret, errorcode.BuildErrorWithMsg(errorcode.NotImplement, "[impalaConnector.Backup] not implement")
 }
 
-// 查询: 暂不实现
+// 查询: 支持条件、join、排序、分页和指定返回字段，仅支持 key-value 格式
+// impala driver 不支持参数，条件取值直接拼接到 SQL 中
+// impala 的 OFFSET 必须和 ORDER BY 一起使用，查询第一页之后的数据需要设置排序字段
 func (connector *impalaConnector) Search(funcArr ...RDBSearchConfigFunc) (ret SearchRet, err error) {
-	return ret, errorcode.BuildErrorWithMsg(errorcode.NotImplement, "[impalaConnector.Search] not implement")
+	action := MakeRDBSearchAction()
+	for _, currentFunc := range funcArr {
+		currentFunc(action)
+	}
+	if nil != action.GetObjectArrType() {
+		return ret, errorcode.BuildErrorWithMsg(errorcode.NotImplement, "[impalaConnector.Search] object not support")
+	}
+	condition := action.GetCondition()
+	if condition.Page.No > 0 && condition.Order.Field == "" {
+		return ret, errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid, "[impalaConnector.Search] impala paging with offset must set order field")
+	}
+
+	db := sql.OpenDB(connector.innerConnector)
+	defer db.Close()
+
+	ctx := context.Background()
+	ret.Total, err = connector.count(ctx, db, action.ToCountSQL())
+	if nil != err {
+		connector.log.Warn("[Search] Count failed: %s", err.Error())
+		return ret, errorcode.BuildErrorWithMsg(errorcode.DBExecFailed, err.Error())
+	}
+
+	rows, err := db.QueryContext(ctx, action.ToSQL())
+	if nil != err {
+		connector.log.Warn("[Search] Search failed: %s", err.Error())
+		return ret, errorcode.BuildErrorWithMsg(errorcode.DBExecFailed, err.Error())
+	}
+	defer rows.Close()
+	if err = addFieldByRows(rows, &ret); nil != err {
+		connector.log.Warn("[Search] Scan rows failed: %s", err.Error())
+		return ret, errorcode.BuildErrorWithMsg(errorcode.DBExecFailed, err.Error())
+	}
+	ret.Len = len(ret.FieldArr)
+	connector.log.Info("[Search] Search success: %s, search rows: %d", action.GetSpaceName(), ret.Len)
+	return
+}
+
+// impala: 执行统计语句
+func (connector *impalaConnector) count(ctx context.Context, db *sql.DB, countSQL string) (int, error) {
+	rows, err := db.QueryContext(ctx, countSQL)
+	if nil != err {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var dataCount int
+	if rows.Next() {
+		if err = rows.Scan(&dataCount); nil != err {
+			return 0, err
+		}
+	}
+	return dataCount, rows.Err()
+}
+
+// impala: 查询结果转换成 key-value field，NULL 转换成空字符串，时间按照 timeFormat 格式化
+func addFieldByRows(rows *sql.Rows, ret *SearchRet) error {
+	columnArr, err := rows.Columns()
+	if nil != err {
+		return err
+	}
+	valueArr := make([]interface{}, len(columnArr))
+	valuePtrArr := make([]interface{}, len(columnArr))
+	for index := range valueArr {
+		valuePtrArr[index] = &valueArr[index]
+	}
+	for rows.Next() {
+		if err = rows.Scan(valuePtrArr...); nil != err {
+			return err
+		}
+		currentField := BuildNewField()
+		for index, column := range columnArr {
+			var valueStr string
+			switch value := valueArr[index].(type) {
+			case nil:
+			case []byte:
+				valueStr = string(value)
+			case time.Time:
+				valueStr = value.Format(timeFormat)
+			default:
+				valueStr = fmt.Sprintf("%v", value)
+			}
+			currentField.AddKeyValue(column, valueStr)
+		}
+		ret.AddField(currentField)
+	}
+	return rows.Err()
 }
 
 func (connector *impalaConnector) Exec(funcArr ...RDBUpdateConfigFunc) (ret UpdateRet, err error) {
@@ -72,7 +163,7 @@ func (connector *impalaConnector) ExecSearch(funcArr ...RDBSearchConfigFunc) (re
 	return ret, errorcode.BuildErrorWithMsg(errorcode.NotImplement, "[impalaConnector.ExecSearch] not implement")
 }
 
-// 计数
+// 计数: 支持条件和 join
 func (connector *impalaConnector) Count(funcArr ...RDBSearchConfigFunc) (ret SearchRet, err error) {
 	action := MakeRDBSearchAction()
 	for _, currentFunc := range funcArr {
@@ -82,19 +173,11 @@ func (connector *impalaConnector) Count(funcArr ...RDBSearchConfigFunc) (ret Sea
 	db := sql.OpenDB(connector.innerConnector)
 	defer db.Close()
 
-	ctx := context.Background()
-	rows, err := db.QueryContext(ctx, "SELECT COUNT(*) FROM "+action.GetSpaceName())
+	ret.Total, err = connector.count(context.Background(), db, action.ToCountSQL())
 	if err != nil {
 		connector.log.Warn("[Count] Count failed: %s", err.Error())
 		return ret, errorcode.BuildErrorWithMsg(errorcode.DBExecFailed, err.Error())
 	}
-	defer rows.Close()
-
-	var dataCount int
-	if rows.Next() {
-		rows.Scan(&dataCount)
-	}
-	ret.Total = dataCount
 	connector.log.Info("[Count] Count success: %s, total: %d", action.GetSpaceName(), ret.Total)
 	return
 }
@@ -112,12 +195,15 @@ func (connector *impalaConnector) Close() error {
 
 // impala: stat
 func (connector *impalaConnector) Stat() (ret DBStat, err error) {
-	return ret, errorcode.BuildErrorWithMsg(errorcode.DBStatFailed, err.Error())
+	return ret, errorcode.BuildErrorWithMsg(errorcode.NotImplement, "[impalaConnector.Stat] not implement")
 }
 
 func GetImpalaConnector(configManager config.Manager) (RDBConnector, error) {
 	option := ImpalaConnectOption{}
-	configManager.Unmarshal(impalaConfigDefaultSpace, &option)
+	if err := config.UnmarshalAndValidate(configManager, impalaConfigDefaultSpace, &option); nil != err {
+		log.Error("[GetImpalaConnector] impala config is invalid: %s", err.Error())
+		return nil, err
+	}
 	return getImpalaConnector(option)
 }
 
@@ -160,7 +246,7 @@ func getImpalaConnector(option ImpalaConnectOption) (RDBConnector, error) {
 	_, err := db.QueryContext(ctx, "SHOW DATABASES")
 	if nil != err {
 		log.Error("[GetImpalaConnector] Get Impala connector failed: %s", err.Error())
-		return nil, errorcode.BuildErrorWithMsg(errorcode.DBConnectFailed, err.Error())
+		return nil, errorcode.BuildErrorWithMsg(errorcode.DBConnectFailed, log.MaskString(err.Error()))
 	}
 
 	ret := new(impalaConnector)
@@ -1,12 +1,24 @@
 package impala
 
 import (
+	"context"
+	"database/sql/driver"
+	"errors"
 	"flag"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
 
 	yamlconfig "github.com/smiecj/go_common/config/yaml"
 	"github.com/smiecj/go_common/db"
+	"github.com/smiecj/go_common/db/conformance"
+	"github.com/smiecj/go_common/db/local"
+	"github.com/smiecj/go_common/db/transfer"
+	"github.com/smiecj/go_common/errorcode"
 	"github.com/smiecj/go_common/util/file"
+	"github.com/smiecj/go_common/util/log"
 	"github.com/stretchr/testify/require"
 )
 
@@ -28,3 +40,159 @@ func TestImpalaConnector(t *testing.T) {
 	_, err = connector.Count(db.SearchSetSpace(testDBName, testTableName))
 	require.Empty(t, err)
 }
+
+// impala 连接器一致性测试: 只支持 Search 和 Count，其他动作返回 NotImplement
+func TestImpalaConformance(t *testing.T) {
+	configManager, err := yamlconfig.GetYamlConfigManager(file.FindFilePath(*configPath))
+	require.Empty(t, err)
+	connector, err := GetImpalaConnector(configManager)
+	require.Empty(t, err)
+
+	conformance.Run(t, connector, conformance.Capability{Search: true, Count: true, Condition: true, OrderAndPage: true}, conformance.SetSpace(testDBName, testTableName))
+}
+
+var (
+	limitRegexp  = regexp.MustCompile(`LIMIT (\d+)`)
+	offsetRegexp = regexp.MustCompile(`OFFSET (\d+)`)
+)
+
+// 模拟 impala 的 driver: 记录执行的 SQL，按照 LIMIT / OFFSET 返回内存中的数据
+// 和 impala 一致，OFFSET 必须和 ORDER BY 一起使用，不解析 where 条件
+type fakeImpalaConnector struct {
+	columnArr []string
+	rowArr    [][]driver.Value
+	sqlArr    []string
+}
+
+func (connector *fakeImpalaConnector) Connect(context.Context) (driver.Conn, error) {
+	return &fakeImpalaConn{connector: connector}, nil
+}
+
+func (connector *fakeImpalaConnector) Driver() driver.Driver {
+	return nil
+}
+
+type fakeImpalaConn struct {
+	connector *fakeImpalaConnector
+}
+
+func (conn *fakeImpalaConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not support")
+}
+
+func (conn *fakeImpalaConn) Close() error {
+	return nil
+}
+
+func (conn *fakeImpalaConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transaction not support")
+}
+
+func (conn *fakeImpalaConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	connector := conn.connector
+	connector.sqlArr = append(connector.sqlArr, query)
+	if strings.HasPrefix(query, "SELECT COUNT(*)") {
+		return &fakeImpalaRows{columnArr: []string{"count(*)"}, rowArr: [][]driver.Value{{int64(len(connector.rowArr))}}}, nil
+	}
+
+	start, end := 0, len(connector.rowArr)
+	if matchArr := offsetRegexp.FindStringSubmatch(query); nil != matchArr {
+		if !strings.Contains(query, "ORDER BY") {
+			return nil, errors.New("OFFSET requires an ORDER BY clause")
+		}
+		start, _ = strconv.Atoi(matchArr[1])
+	}
+	if matchArr := limitRegexp.FindStringSubmatch(query); nil != matchArr {
+		limit, _ := strconv.Atoi(matchArr[1])
+		if start+limit < end {
+			end = start + limit
+		}
+	}
+	if start > end {
+		start = end
+	}
+	return &fakeImpalaRows{columnArr: connector.columnArr, rowArr: connector.rowArr[start:end]}, nil
+}
+
+type fakeImpalaRows struct {
+	columnArr []string
+	rowArr    [][]driver.Value
+	index     int
+}
+
+func (rows *fakeImpalaRows) Columns() []string {
+	return rows.columnArr
+}
+
+func (rows *fakeImpalaRows) Close() error {
+	return nil
+}
+
+func (rows *fakeImpalaRows) Next(dest []driver.Value) error {
+	if rows.index >= len(rows.rowArr) {
+		return io.EOF
+	}
+	copy(dest, rows.rowArr[rows.index])
+	rows.index++
+	return nil
+}
+
+// 创建使用模拟 driver 的 impala 连接器，数据按照 id 排序
+func newFakeImpalaConnector(rowCount int) (*impalaConnector, *fakeImpalaConnector) {
+	fakeConnector := &fakeImpalaConnector{columnArr: []string{"id", "name", "score"}}
+	for id := 1; id <= rowCount; id++ {
+		var score driver.Value = float64(id) / 2
+		if id%2 == 0 {
+			score = nil
+		}
+		fakeConnector.rowArr = append(fakeConnector.rowArr, []driver.Value{int64(id), []byte("name_" + strconv.Itoa(id)), score})
+	}
+	return &impalaConnector{innerConnector: fakeConnector, log: log.PrefixLogger("impalaConnector")}, fakeConnector
+}
+
+// 测试查询: 条件、排序、分页拼接成 SQL，NULL 转换成空字符串
+func TestImpalaSearch(t *testing.T) {
+	connector, fakeConnector := newFakeImpalaConnector(5)
+
+	ret, err := connector.Search(db.SearchSetSpace(testDBName, testTableName), db.SearchSetKeyArr([]string{"id", "name", "score"}),
+		db.SearchSetCondition("name", "like", "name_%"), db.SearchSetOrderField("id"), db.SearchSetPageCondition(2, 2))
+	require.Empty(t, err)
+	require.Equal(t, []string{
+		"SELECT COUNT(*) FROM db_name.table_name WHERE name LIKE 'name_%'",
+		"SELECT id, name, score FROM db_name.table_name WHERE name LIKE 'name_%' ORDER BY id LIMIT 2 OFFSET 2",
+	}, fakeConnector.sqlArr)
+	require.Equal(t, 5, ret.Total)
+	require.Equal(t, 2, ret.Len)
+	require.Equal(t, map[string]string{"id": "3", "name": "name_3", "score": "1.5"}, ret.FieldArr[0].GetMap())
+	require.Equal(t, map[string]string{"id": "4", "name": "name_4", "score": ""}, ret.FieldArr[1].GetMap())
+
+	countRet, err := connector.Count(db.SearchSetSpace(testDBName, testTableName), db.SearchSetCondition("id", ">", "1"))
+	require.Empty(t, err)
+	require.Equal(t, 5, countRet.Total)
+	require.Equal(t, "SELECT COUNT(*) FROM db_name.table_name WHERE id > '1'", fakeConnector.sqlArr[len(fakeConnector.sqlArr)-1])
+
+	// 分页查询没有设置排序字段
+	_, err = connector.Search(db.SearchSetSpace(testDBName, testTableName), db.SearchSetPageCondition(2, 2))
+	require.True(t, errors.Is(err, errorcode.BuildError(errorcode.DBParamInvalid)))
+	_, err = connector.Search(db.SearchSetSpace(testDBName, testTableName), db.SearchSetObjectArrType([]*struct{}{}))
+	require.True(t, errors.Is(err, errorcode.BuildError(errorcode.NotImplement)))
+}
+
+// 测试从 impala 分页复制数据到其他连接器
+func TestImpalaCopy(t *testing.T) {
+	connector, _ := newFakeImpalaConnector(5)
+	target, err := local.GetLocalMemoryConnector()
+	require.Empty(t, err)
+	defer target.Close()
+
+	progress, err := transfer.Copy(connector, target, transfer.CopySetSourceSpace(testDBName, testTableName),
+		transfer.CopySetTargetSpace("target_db", "target_table"), transfer.CopySetOrderField("id"),
+		transfer.CopySetColumnMap(map[string]string{"name": "user_name"}), transfer.CopySetBatch(2))
+	require.Empty(t, err)
+	require.Equal(t, transfer.CopyProgress{Offset: 5, Copied: 5, Total: 5}, progress)
+
+	ret, err := target.Search(db.SearchSetSpace("target_db", "target_table"), db.SearchSetOrderField("id"))
+	require.Empty(t, err)
+	require.Equal(t, 5, ret.Len)
+	require.Equal(t, map[string]string{"id": "5", "user_name": "name_5", "score": "2.5"}, ret.FieldArr[4].GetMap())
+}
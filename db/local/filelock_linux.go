@@ -0,0 +1,39 @@
+//go:build linux
+// +build linux
+
+package local
+
+import (
+	"os"
+	"syscall"
+)
+
+// 文件锁: linux 下通过 flock 实现进程间的建议锁
+// exclusive: 排他锁（写），否则为共享锁（读）
+func lockFile(lockFilePath string, exclusive bool) (unlock func(), err error) {
+	file, err := os.OpenFile(lockFilePath, os.O_CREATE|os.O_RDWR, 0644)
+	if nil != err {
+		return nil, err
+	}
+
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	for {
+		err = syscall.Flock(int(file.Fd()), how)
+		// 被信号中断时重试
+		if err != syscall.EINTR {
+			break
+		}
+	}
+	if nil != err {
+		file.Close()
+		return nil, err
+	}
+
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
@@ -0,0 +1,31 @@
+//go:build !linux
+// +build !linux
+
+package local
+
+import (
+	"sync"
+)
+
+var (
+	fileLockMap     = make(map[string]*sync.RWMutex)
+	fileLockMapLock sync.Mutex
+)
+
+// 文件锁: 非 linux 系统暂不支持进程间的文件锁，只保证同一进程内的读写互斥
+func lockFile(lockFilePath string, exclusive bool) (unlock func(), err error) {
+	fileLockMapLock.Lock()
+	lock := fileLockMap[lockFilePath]
+	if nil == lock {
+		lock = new(sync.RWMutex)
+		fileLockMap[lockFilePath] = lock
+	}
+	fileLockMapLock.Unlock()
+
+	if exclusive {
+		lock.Lock()
+		return lock.Unlock, nil
+	}
+	lock.RLock()
+	return lock.RUnlock, nil
+}
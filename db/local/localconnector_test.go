@@ -1,9 +1,17 @@
 package local
 
 import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 
 	. "github.com/smiecj/go_common/db"
+	"github.com/smiecj/go_common/db/conformance"
+	"github.com/smiecj/go_common/errorcode"
+	"github.com/smiecj/go_common/util/file"
 	"github.com/smiecj/go_common/util/log"
 	"github.com/stretchr/testify/require"
 )
@@ -36,6 +44,141 @@ func TestLocalMemoryConnector(t *testing.T) {
 	log.Info("[TestLocalConnector] search rows: %d", SearchRet.Len)
 }
 
+// 测试内存连接器按行存储: 插入追加数据行，更新、删除只处理满足条件的数据行，不支持结构体
+// 之前的版本每个 space 只保存一个 key-value map: 插入会覆盖相同的 key，更新和删除忽略条件，结构体会被忽略
+func TestLocalMemoryConnectorRows(t *testing.T) {
+	localConnector, err := GetLocalMemoryConnector()
+	require.Empty(t, err)
+	table := "test_memory_rows"
+	defer localConnector.Delete(DeleteSetSpace(testDBName, table))
+
+	for _, name := range []string{"xiaoming", "xiaohong"} {
+		field := BuildNewField()
+		field.AddKeyValue("name", name)
+		field.AddKeyValue("class_id", "1")
+		_, err = localConnector.Insert(InsertSetSpace(testDBName, table), InsertAddField(field))
+		require.Empty(t, err)
+	}
+	searchRet, err := localConnector.Search(SearchSetSpace(testDBName, table))
+	require.Empty(t, err)
+	require.Equal(t, 2, searchRet.Len)
+
+	updateField := BuildNewField()
+	updateField.AddKeyValue("class_id", "2")
+	updateRet, err := localConnector.Update(UpdateSetSpace(testDBName, table), UpdateAddField(updateField),
+		UpdateSetCondition("name", "=", "xiaohong"))
+	require.Empty(t, err)
+	require.Equal(t, 1, updateRet.AffectedRows)
+	countRet, err := localConnector.Count(SearchSetSpace(testDBName, table), SearchSetCondition("class_id", "=", "1"))
+	require.Empty(t, err)
+	require.Equal(t, 1, countRet.Total)
+
+	updateRet, err = localConnector.Delete(DeleteSetSpace(testDBName, table), DeleteSetCondition("class_id", "=", "2"))
+	require.Empty(t, err)
+	require.Equal(t, 1, updateRet.AffectedRows)
+	searchRet, err = localConnector.Search(SearchSetSpace(testDBName, table))
+	require.Empty(t, err)
+	require.Equal(t, 1, searchRet.Len)
+	require.Equal(t, "xiaoming", searchRet.FieldArr[0].GetMap()["name"])
+
+	_, err = localConnector.Insert(InsertSetSpace(testDBName, table), InsertAddObject(testObj))
+	require.True(t, errors.Is(err, errorcode.BuildError(errorcode.NotImplement)))
+}
+
+// 测试内存连接器 join: inner / left / right / full / cross，别名和同一张表 join 多次
+func TestLocalMemoryConnectorJoin(t *testing.T) {
+	localConnector, err := GetLocalMemoryConnector()
+	require.Empty(t, err)
+	insert := func(table string, keyValueMapArr ...map[string]string) {
+		for _, keyValueMap := range keyValueMapArr {
+			currentField := BuildNewField()
+			currentField.AddMap(keyValueMap)
+			_, err := localConnector.Insert(InsertSetSpace(testDBName, table), InsertAddField(currentField))
+			require.Empty(t, err)
+		}
+	}
+	insert("join_student",
+		map[string]string{"name": "xiaoming", "class_id": "1", "friend": "xiaohong"},
+		map[string]string{"name": "xiaohong", "class_id": "2", "friend": "xiaoming"},
+		map[string]string{"name": "xiaobai", "class_id": "3"})
+	insert("join_class",
+		map[string]string{"id": "1", "name": "class one"},
+		map[string]string{"id": "2", "name": "class two"},
+		map[string]string{"id": "4", "name": "class four"})
+	defer func() {
+		localConnector.Delete(DeleteSetSpace(testDBName, "join_student"))
+		localConnector.Delete(DeleteSetSpace(testDBName, "join_class"))
+	}()
+
+	countJoin := func(method JoinMethod) int {
+		countRet, err := localConnector.Count(SearchSetSpace(testDBName, "join_student"), SearchSetAlias("s"),
+			SearchAddJoinTable(testDBName, "join_class", JoinSetMethod(method), JoinSetAlias("c"), JoinSetOn("s.class_id", "c.id")))
+		require.Empty(t, err)
+		return countRet.Total
+	}
+	require.Equal(t, 2, countJoin(InnerJoin))
+	require.Equal(t, 3, countJoin(LeftJoin))
+	require.Equal(t, 3, countJoin(RightJoin))
+	require.Equal(t, 4, countJoin(FullJoin))
+	require.Equal(t, 9, countJoin(CrossJoin))
+
+	// 别名字段和条件
+	searchRet, err := localConnector.Search(SearchSetSpace(testDBName, "join_student"), SearchSetAlias("s"),
+		SearchAddJoinTable(testDBName, "join_class", JoinSetMethod(InnerJoin), JoinSetAlias("c"), JoinSetOn("s.class_id", "c.id")),
+		SearchSetKeyArr([]string{"s.name", "c.name AS class_name"}),
+		SearchSetCondition("c.name", "=", "class two"))
+	require.Empty(t, err)
+	require.Equal(t, 1, searchRet.Len)
+	require.Equal(t, map[string]string{"name": "xiaohong", "class_name": "class two"}, searchRet.FieldArr[0].GetMap())
+
+	// 同一张表 join 多次: 查询同学的班级
+	searchRet, err = localConnector.Search(SearchSetSpace(testDBName, "join_student"), SearchSetAlias("s"),
+		SearchAddJoinTable(testDBName, "join_student", JoinSetMethod(InnerJoin), JoinSetAlias("f"), JoinSetOn("s.friend", "f.name")),
+		SearchAddJoinTable(testDBName, "join_class", JoinSetAlias("fc"), JoinSetOn("f.class_id", "fc.id")),
+		SearchSetKeyArr([]string{"s.name", "fc.name AS friend_class"}), SearchSetOrderField("s.name"))
+	require.Empty(t, err)
+	require.Equal(t, 2, searchRet.Len)
+	require.Equal(t, map[string]string{"name": "xiaohong", "friend_class": "class one"}, searchRet.FieldArr[0].GetMap())
+	require.Equal(t, map[string]string{"name": "xiaoming", "friend_class": "class two"}, searchRet.FieldArr[1].GetMap())
+
+	// 兼容原有的 join 方式，没有指定字段时返回不带表名的字段（主表优先）
+	searchRet, err = localConnector.Search(SearchSetSpace(testDBName, "join_student"),
+		SearchAddJoin(testDBName, "join_student", "class_id", "join_class", "id", string(InnerJoin)),
+		SearchSetCondition("join_student.name", "=", "xiaoming"))
+	require.Empty(t, err)
+	require.Equal(t, 1, searchRet.Len)
+	require.Equal(t, "xiaoming", searchRet.FieldArr[0].GetMap()["name"])
+	require.Equal(t, "1", searchRet.FieldArr[0].GetMap()["id"])
+
+	distinctRet, err := localConnector.Distinct(SearchSetSpace(testDBName, "join_student"),
+		SearchAddJoin(testDBName, "join_student", "class_id", "join_class", "id"), SearchSetKeyArr([]string{"join_class.name"}))
+	require.Empty(t, err)
+	require.Equal(t, 3, distinctRet.Len)
+}
+
+// 内存连接器一致性测试: 仅支持 key-value 格式，不支持结构体
+func TestLocalMemoryConnectorConformance(t *testing.T) {
+	localConnector, err := GetLocalMemoryConnector()
+	require.Empty(t, err)
+	conformance.Run(t, localConnector, conformance.Capability{
+		Insert: true, Search: true, Update: true, Delete: true, Count: true, Distinct: true,
+		Condition: true, OrderAndPage: true, DeleteCondition: true,
+	})
+}
+
+// 文件连接器一致性测试: 不支持条件、排序分页、更新和 distinct，默认覆盖写入，删除会删除整个文件
+func TestLocalFileConnectorConformance(t *testing.T) {
+	folderPath, err := ioutil.TempDir("", "local_file_conformance")
+	require.Empty(t, err)
+	defer os.RemoveAll(folderPath)
+
+	localConnector, err := GetLocalFileConnector(folderPath)
+	require.Empty(t, err)
+	conformance.Run(t, localConnector, conformance.Capability{
+		Insert: true, Search: true, Delete: true, Count: true, Object: true, InsertOverwrite: true,
+	})
+}
+
 func TestLocalFileConnector(t *testing.T) {
 	localConnector, err := GetLocalFileConnector("/tmp")
 	require.Empty(t, err)
@@ -68,3 +211,85 @@ func TestLocalFileConnector(t *testing.T) {
 		log.Info("[TestLocalFileConnector] search ret: index: %d, object: %s", index, currentStruct)
 	}
 }
+
+// 测试文件连接器的崩溃恢复: 残留临时文件清理 & 写入中断文件识别 & 兼容没有结束标识的文件
+func TestLocalFileConnectorRecover(t *testing.T) {
+	folderPath, err := ioutil.TempDir("", "local_file_connector")
+	require.Nil(t, err)
+	defer os.RemoveAll(folderPath)
+
+	// 表名中包含 "."，以及格式不正确的临时文件
+	fileName := testDBName + "." + testTableName
+	tempFilePath := filepath.Join(folderPath, fileName+file.TempFileMark+"123456")
+	dotTempFilePath := filepath.Join(folderPath, testDBName+".table.v2"+file.TempFileMark+"654321")
+	unknownTempFilePath := filepath.Join(folderPath, "foo"+file.TempFileMark+"bak")
+	for _, currentPath := range []string{tempFilePath, dotTempFilePath, unknownTempFilePath, filepath.Join(folderPath, "foo.writing")} {
+		require.Nil(t, ioutil.WriteFile(currentPath, []byte(fileFormatKeyValue+lineSeparator), 0644))
+	}
+	dotFileName, ok := file.ParseTempFileName(dotTempFilePath)
+	require.True(t, ok)
+	require.Equal(t, filepath.Join(folderPath, testDBName+".table.v2"), dotFileName)
+	_, ok = file.ParseTempFileName(unknownTempFilePath)
+	require.False(t, ok)
+
+	localConnector, err := GetLocalFileConnector(folderPath)
+	require.Nil(t, err)
+	for _, currentPath := range []string{tempFilePath, dotTempFilePath} {
+		_, err = os.Stat(currentPath)
+		require.True(t, errors.Is(err, os.ErrNotExist))
+	}
+	_, err = os.Stat(unknownTempFilePath)
+	require.Nil(t, err)
+
+	// 之前版本写入的文件没有结束标识，可以正常读取
+	err = ioutil.WriteFile(filepath.Join(folderPath, fileName),
+		[]byte(fileFormatKeyValue+lineSeparator+"user"+lineSeparator+"smiecj"+lineSeparator), 0644)
+	require.Nil(t, err)
+	searchRet, err := localConnector.Search(SearchSetSpace(testDBName, testTableName))
+	require.Nil(t, err)
+	require.Equal(t, 1, searchRet.Len)
+	require.Equal(t, "smiecj", searchRet.FieldArr[0].GetMap()["user"])
+
+	// 没有结束标识，并且残留了临时文件: 写入中断
+	require.Nil(t, ioutil.WriteFile(tempFilePath, []byte(fileFormatKeyValue+lineSeparator), 0644))
+	_, err = localConnector.Search(SearchSetSpace(testDBName, testTableName))
+	require.True(t, errors.Is(err, errorcode.BuildError(errorcode.DBDataBroken)))
+	_, err = localConnector.Count(SearchSetSpace(testDBName, testTableName))
+	require.True(t, errors.Is(err, errorcode.BuildError(errorcode.DBDataBroken)))
+}
+
+// 测试文件连接器并发读写: 读取到的文件始终是完整的
+func TestLocalFileConnectorConcurrent(t *testing.T) {
+	folderPath, err := ioutil.TempDir("", "local_file_connector")
+	require.Nil(t, err)
+	defer os.RemoveAll(folderPath)
+
+	localConnector, err := GetLocalFileConnector(folderPath)
+	require.Nil(t, err)
+	field := BuildNewField()
+	field.AddMap(testKeyValueMap)
+	_, err = localConnector.Insert(InsertSetSpace(testDBName, testTableName), InsertAddField(field))
+	require.Nil(t, err)
+
+	var wg sync.WaitGroup
+	errChan := make(chan error, 20)
+	for index := 0; index < 10; index++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, insertErr := localConnector.Insert(InsertSetSpace(testDBName, testTableName),
+				InsertAddField(field), InsertAddField(field))
+			errChan <- insertErr
+		}()
+		go func() {
+			defer wg.Done()
+			_, searchErr := localConnector.Search(SearchSetSpace(testDBName, testTableName))
+			errChan <- searchErr
+		}()
+	}
+	wg.Wait()
+	close(errChan)
+	for currentErr := range errChan {
+		require.Nil(t, currentErr)
+	}
+}
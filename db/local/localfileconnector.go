@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"reflect"
 	"strings"
@@ -12,6 +13,7 @@ import (
 
 	. "github.com/smiecj/go_common/db"
 	"github.com/smiecj/go_common/errorcode"
+	"github.com/smiecj/go_common/util/file"
 	"github.com/smiecj/go_common/util/json"
 	"github.com/smiecj/go_common/util/log"
 )
@@ -19,8 +21,12 @@ import (
 const (
 	fileFormatObject   = "# object"
 	fileFormatKeyValue = "# key-value"
-	keyValueSplitor    = " --- "
-	lineSeparator      = "\n"
+	// 文件结束标识，写入完整的文件一定以该行结尾，用于识别写入中断的文件
+	fileFormatEnd   = "# end"
+	keyValueSplitor = " --- "
+	lineSeparator   = "\n"
+	// 锁文件后缀，和数据文件放在同一目录下
+	lockFileSuffix = ".lock"
 )
 
 var (
@@ -40,60 +46,114 @@ type localFileConnector struct {
 # object / key-value （数据格式）
 object1 string format
 object2 string format
+# end
 
 or:
+# key-value
 key1 --- key2 --- key3
 value1 --- value2 --- value3 (object1)
 key1 --- key2 --- key3
 value1 --- value2 --- value3 (object2)
+# end
 */
+// 默认覆盖整个文件，设置 InsertAppend 时保留已有数据（数据格式需要和已有文件一致）
+// 写入过程: 先写临时文件并 fsync，再 rename 覆盖正式文件，避免进程崩溃导致文件只写了一半
 func (connector *localFileConnector) Insert(funcArr ...RDBInsertConfigFunc) (ret UpdateRet, err error) {
 	action := MakeRDBInsertAction()
 	for _, currentFunc := range funcArr {
 		currentFunc(action)
 	}
 
+	if err = action.CheckLimit(); nil != err {
+		log.Error("[localFileConnector.Insert] %s", err.Error())
+		return ret, err
+	}
+
 	fileAbsolutePath := connector.getFileAbsolutePath(action.GetSpaceName())
-	file, err := os.OpenFile(fileAbsolutePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	unlock, err := lockFile(fileAbsolutePath+lockFileSuffix, true)
 	if nil != err {
-		log.Error("[localFileConnector.Insert] write file failed, file name: %s, err: %s", fileAbsolutePath, err.Error())
-		return
+		log.Error("[localFileConnector.Insert] lock file failed, file name: %s, err: %s", fileAbsolutePath, err.Error())
+		return ret, errorcode.BuildErrorWithMsg(errorcode.DBExecFailed, err.Error())
 	}
-	defer file.Close()
+	defer unlock()
 
 	fieldArr := action.GetFieldArr()
 	objectArr := action.GetObjectArr()
+
+	// 追加写入: 先读取已有数据，和新数据一起写入临时文件
+	var existLineArr []string
+	if action.IsAppend() {
+		if _, statErr := os.Stat(fileAbsolutePath); nil == statErr {
+			var existFormat string
+			existFormat, existLineArr, err = connector.readFileWithoutLock(fileAbsolutePath)
+			if nil != err {
+				return ret, err
+			}
+			if (existFormat == fileFormatKeyValue && 0 == len(fieldArr)) || (existFormat == fileFormatObject && 0 == len(objectArr)) {
+				return ret, errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid, "append data format not match exist file: "+existFormat)
+			}
+		}
+	}
+	writeExistLineFunc := func(writer *bufio.Writer) {
+		for _, currentLine := range existLineArr {
+			writer.WriteString(currentLine)
+			writer.WriteString(lineSeparator)
+		}
+	}
+
+	var writeFunc func(*bufio.Writer) error
 	switch {
 	case 0 != len(fieldArr):
-		file.WriteString(fileFormatKeyValue + lineSeparator)
-		for _, currentField := range fieldArr {
-			keyStrAppender := new(bytes.Buffer)
-			valueStrAppender := new(bytes.Buffer)
-			for key, value := range currentField.GetMap() {
-				if keyStrAppender.Len() != 0 {
-					keyStrAppender.WriteString(keyValueSplitor)
-					valueStrAppender.WriteString(keyValueSplitor)
+		writeFunc = func(writer *bufio.Writer) error {
+			writer.WriteString(fileFormatKeyValue + lineSeparator)
+			writeExistLineFunc(writer)
+			for _, currentField := range fieldArr {
+				keyStrAppender := new(bytes.Buffer)
+				valueStrAppender := new(bytes.Buffer)
+				for key, value := range currentField.GetMap() {
+					if keyStrAppender.Len() != 0 {
+						keyStrAppender.WriteString(keyValueSplitor)
+						valueStrAppender.WriteString(keyValueSplitor)
+					}
+					// 注意: 内容中如果有换行符要替换掉
+					keyStrAppender.WriteString(connector.cleanInvalidChar(key))
+					valueStrAppender.WriteString(connector.cleanInvalidChar(value))
 				}
-				// 注意: 内容中如果有换行符要替换掉
-				keyStrAppender.WriteString(connector.cleanInvalidChar(key))
-				valueStrAppender.WriteString(connector.cleanInvalidChar(value))
-			}
 
-			file.WriteString(keyStrAppender.String())
-			file.WriteString(lineSeparator)
-			file.WriteString(valueStrAppender.String())
-			file.WriteString(lineSeparator)
+				writer.WriteString(keyStrAppender.String())
+				writer.WriteString(lineSeparator)
+				writer.WriteString(valueStrAppender.String())
+				writer.WriteString(lineSeparator)
+			}
+			_, writeErr := writer.WriteString(fileFormatEnd + lineSeparator)
+			return writeErr
 		}
-
 		ret.AffectedRows = len(fieldArr)
 	case 0 != len(objectArr):
-		file.WriteString(fileFormatObject + lineSeparator)
-		for _, currentObject := range objectArr {
-			objectBytes, _ := json.Marshal(currentObject)
-			file.Write(objectBytes)
-			file.WriteString(lineSeparator)
+		writeFunc = func(writer *bufio.Writer) error {
+			writer.WriteString(fileFormatObject + lineSeparator)
+			writeExistLineFunc(writer)
+			for _, currentObject := range objectArr {
+				objectBytes, marshalErr := json.Marshal(currentObject)
+				if nil != marshalErr {
+					return marshalErr
+				}
+				writer.Write(objectBytes)
+				writer.WriteString(lineSeparator)
+			}
+			_, writeErr := writer.WriteString(fileFormatEnd + lineSeparator)
+			return writeErr
 		}
 		ret.AffectedRows = len(objectArr)
+	default:
+		log.Warn("[localFileConnector.Insert] To insert data is empty")
+		return ret, nil
+	}
+
+	err = file.WriteAtomicFunc(fileAbsolutePath, writeFunc)
+	if nil != err {
+		log.Error("[localFileConnector.Insert] write file failed, file name: %s, err: %s", fileAbsolutePath, err.Error())
+		return UpdateRet{}, errorcode.BuildErrorWithMsg(errorcode.DBExecFailed, err.Error())
 	}
 
 	log.Info("[localFileConnector.Insert] Write file success, rows: %d", ret.AffectedRows)
@@ -103,8 +163,7 @@ func (connector *localFileConnector) Insert(funcArr ...RDBInsertConfigFunc) (ret
 // 更新数据
 // 文件不支持更新，只能覆盖
 func (connector *localFileConnector) Update(funcArr ...RDBUpdateConfigFunc) (ret UpdateRet, err error) {
-	err = fmt.Errorf("File storage not support update")
-	return
+	return ret, errorcode.BuildErrorWithMsg(errorcode.NotImplement, "[localFileConnector.Update] file storage not support update")
 }
 
 // 删除数据
@@ -116,11 +175,27 @@ func (connector *localFileConnector) Delete(funcArr ...RDBDeleteConfigFunc) (ret
 	}
 
 	fileAbsolutePath := connector.getFileAbsolutePath(action.GetSpaceName())
+	unlock, err := lockFile(fileAbsolutePath+lockFileSuffix, true)
+	if nil != err {
+		log.Error("[localFileConnector.Delete] lock file failed, file name: %s, err: %s", fileAbsolutePath, err.Error())
+		return ret, errorcode.BuildErrorWithMsg(errorcode.DBExecFailed, err.Error())
+	}
+	defer unlock()
+
+	// 文件不存在: 没有需要删除的数据
+	if _, err = os.Stat(fileAbsolutePath); errors.Is(err, os.ErrNotExist) {
+		return ret, nil
+	}
+	// 删除之前统计数据条数，文件损坏时仍然删除
+	if format, lineArr, readErr := connector.readFileWithoutLock(fileAbsolutePath); nil == readErr {
+		ret.AffectedRows = connector.getRowCount(format, lineArr)
+	}
+
 	err = os.Remove(fileAbsolutePath)
 	if nil != err {
-		log.Error("[localFileConnector.Deletel] delete file failed, file name: %s, err: %s", fileAbsolutePath, err.Error())
+		log.Error("[localFileConnector.Delete] delete file failed, file name: %s, err: %s", fileAbsolutePath, err.Error())
+		return UpdateRet{}, errorcode.BuildErrorWithMsg(errorcode.DBExecFailed, err.Error())
 	}
-	// 后续: 获取文件内容中对应的数据条数
 	return
 }
 
@@ -137,70 +212,57 @@ func (connector *localFileConnector) Search(funcArr ...RDBSearchConfigFunc) (ret
 	}
 
 	fileAbsolutePath := connector.getFileAbsolutePath(action.GetSpaceName())
-	// 文件不存在，返回失败结果
-	if _, err := os.Stat(fileAbsolutePath); errors.Is(err, os.ErrNotExist) {
-		return ret, fmt.Errorf("File is not exists")
-	}
-
-	file, _ := os.Open(fileAbsolutePath)
-	defer file.Close()
-
-	reader := bufio.NewReader(file)
-	firstLine, _, _ := reader.ReadLine()
-	if string(firstLine) == fileFormatKeyValue {
-		for {
-			keyBytes, _, readErr := reader.ReadLine()
-			valueBytes, _, _ := reader.ReadLine()
-			if nil != readErr {
-				// read finish
-				// 文件读取现在没做 limit，所以 total = len
-				ret.Total = ret.Len
-				return
-			}
+	// 文件不存在: 和空表一致，返回空结果
+	if _, err = os.Stat(fileAbsolutePath); errors.Is(err, os.ErrNotExist) {
+		return ret, nil
+	}
+	format, lineArr, err := connector.readFile(fileAbsolutePath)
+	if nil != err {
+		return ret, err
+	}
 
-			keyArr := strings.Split(string(keyBytes), keyValueSplitor)
-			valueArr := strings.Split(string(valueBytes), keyValueSplitor)
+	if format == fileFormatKeyValue {
+		for index := 0; index+1 < len(lineArr); index += 2 {
+			keyArr := strings.Split(lineArr[index], keyValueSplitor)
+			valueArr := strings.Split(lineArr[index+1], keyValueSplitor)
+			if len(keyArr) != len(valueArr) {
+				log.Error("[localFileConnector.Search] key value not match, file name: %s, line: %d", fileAbsolutePath, index+2)
+				return SearchRet{}, errorcode.BuildErrorWithMsg(errorcode.DBDataBroken, "key value count not match")
+			}
 
 			currentField := BuildNewField()
-			for index := 0; index < len(keyArr); index++ {
-				currentField.AddKeyValue(keyArr[index], valueArr[index])
+			for keyIndex := 0; keyIndex < len(keyArr); keyIndex++ {
+				currentField.AddKeyValue(keyArr[keyIndex], valueArr[keyIndex])
 			}
 			ret.AddField(currentField)
 			ret.Len++
 		}
-	} else if string(firstLine) == fileFormatObject {
-		// 查询条件中 对象为空 或者是 结果数组类型为空，则直接返回错误信息
-		object, objectArrType := action.GetObject(), action.GetObjectArrType()
-		if nil == object || nil == objectArrType {
-			return ret, fmt.Errorf("Search base object is empty, please use 'SearchSetObject' to set object struct")
-		}
-		// reflect
-		objValue := reflect.New(reflect.TypeOf(object))
-		objectReflectArr := reflect.MakeSlice(objectArrType, 0, 0)
-		ret.ObjectArr = make([]interface{}, 0)
-
-		for {
-			objectBytes, _, readErr := reader.ReadLine()
-			if nil != readErr {
-				// read finish
-				ret.ObjectArr = objectReflectArr.Interface()
-				ret.Total = ret.Len
-				return
-			}
-
-			currentObj := objValue.Interface()
-			err = json.Unmarshal(objectBytes, currentObj)
-			if nil != err {
-				log.Error("[localFileConnector.Search] object unmarshal failed, object: %s, err: %s", string(objectBytes), err.Error())
-				return
-			}
+		// 文件读取现在没做 limit，所以 total = len
+		ret.Total = ret.Len
+		return
+	}
 
-			objectReflectArr = reflect.Append(objectReflectArr, reflect.ValueOf(currentObj))
-			ret.Len++
+	// 查询条件中 对象为空 或者是 结果数组类型为空，则直接返回错误信息
+	object, objectArrType := action.GetObject(), action.GetObjectArrType()
+	if nil == object || nil == objectArrType {
+		return ret, fmt.Errorf("Search base object is empty, please use 'SearchSetObject' to set object struct")
+	}
+	// reflect
+	objectReflectArr := reflect.MakeSlice(objectArrType, 0, len(lineArr))
+	for _, currentLine := range lineArr {
+		currentObj := reflect.New(reflect.TypeOf(object)).Interface()
+		err = json.Unmarshal([]byte(currentLine), currentObj)
+		if nil != err {
+			log.Error("[localFileConnector.Search] object unmarshal failed, object: %s, err: %s", currentLine, err.Error())
+			return
 		}
-	} else {
-		return ret, fmt.Errorf("File format is not valid")
+
+		objectReflectArr = reflect.Append(objectReflectArr, reflect.ValueOf(currentObj))
+		ret.Len++
 	}
+	ret.ObjectArr = objectReflectArr.Interface()
+	ret.Total = ret.Len
+	return
 }
 
 func (connector *localFileConnector) Exec(funcArr ...RDBUpdateConfigFunc) (ret UpdateRet, err error) {
@@ -225,15 +287,12 @@ func (connector *localFileConnector) Count(funcArr ...RDBSearchConfigFunc) (ret
 		return ret, nil
 	}
 
-	file, _ := os.Open(fileAbsolutePath)
-	defer file.Close()
-
-	reader := bufio.NewReader(file)
-	for _, _, readErr := reader.ReadLine(); nil != readErr; {
-		ret.Total++
+	format, lineArr, err := connector.readFile(fileAbsolutePath)
+	if nil != err {
+		return ret, err
 	}
-	// 去掉开头 表示文件格式的那一行
-	ret.Total--
+	ret.Total = connector.getRowCount(format, lineArr)
+	ret.Len = ret.Total
 	return
 }
 
@@ -250,7 +309,7 @@ func (connector *localFileConnector) Close() error {
 
 // stat
 func (connector *localFileConnector) Stat() (ret DBStat, err error) {
-	return ret, errorcode.BuildErrorWithMsg(errorcode.DBStatFailed, err.Error())
+	return ret, errorcode.BuildErrorWithMsg(errorcode.NotImplement, "[localFileConnector.Stat] not implement")
 }
 
 // 公共方法: 获取需要操作的文件的绝对路径
@@ -258,6 +317,14 @@ func (connector *localFileConnector) getFileAbsolutePath(spaceName string) strin
 	return fmt.Sprintf("%s%s%s", connector.localFolderPath, string(os.PathSeparator), spaceName)
 }
 
+// 公共方法: 根据文件格式计算数据条数，key-value 格式每条数据占两行
+func (connector *localFileConnector) getRowCount(format string, lineArr []string) int {
+	if format == fileFormatKeyValue {
+		return len(lineArr) / 2
+	}
+	return len(lineArr)
+}
+
 // 公共方法: 清理不合法字符
 func (connector *localFileConnector) cleanInvalidChar(toWriteStr string) (retStr string) {
 	retStr = strings.ReplaceAll(toWriteStr, "\n", "")
@@ -265,6 +332,75 @@ func (connector *localFileConnector) cleanInvalidChar(toWriteStr string) (retStr
 	return
 }
 
+// 公共方法: 读取整个文件（共享锁），校验文件头和结束标识，返回文件格式和中间的数据行
+// 兼容之前没有结束标识的文件，只有文件旁边残留了临时文件时，才认为是写入中断的文件，返回 DBDataBroken
+func (connector *localFileConnector) readFile(fileAbsolutePath string) (format string, lineArr []string, err error) {
+	unlock, err := lockFile(fileAbsolutePath+lockFileSuffix, false)
+	if nil != err {
+		log.Error("[localFileConnector.readFile] lock file failed, file name: %s, err: %s", fileAbsolutePath, err.Error())
+		return "", nil, errorcode.BuildErrorWithMsg(errorcode.DBExecFailed, err.Error())
+	}
+	defer unlock()
+
+	return connector.readFileWithoutLock(fileAbsolutePath)
+}
+
+// 公共方法: 读取整个文件，调用方需要先获取文件锁
+func (connector *localFileConnector) readFileWithoutLock(fileAbsolutePath string) (format string, lineArr []string, err error) {
+	// 文件不存在，返回失败结果
+	contentBytes, err := ioutil.ReadFile(fileAbsolutePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil, fmt.Errorf("File is not exists")
+	} else if nil != err {
+		return "", nil, errorcode.BuildErrorWithMsg(errorcode.DBExecFailed, err.Error())
+	}
+
+	// 文件以换行符结尾，split 之后最后一个元素为空
+	lineArr = strings.Split(string(contentBytes), lineSeparator)
+	if lineArr[len(lineArr)-1] == "" {
+		lineArr = lineArr[:len(lineArr)-1]
+	}
+	if len(lineArr) > 1 && lineArr[len(lineArr)-1] == fileFormatEnd {
+		lineArr = lineArr[:len(lineArr)-1]
+	} else if tempFileArr := file.GetTempFileArr(fileAbsolutePath); len(tempFileArr) != 0 {
+		log.Error("[localFileConnector.readFile] file is not complete, temp file left: %s", strings.Join(tempFileArr, ", "))
+		return "", nil, errorcode.BuildErrorWithMsg(errorcode.DBDataBroken, "file is not complete: "+fileAbsolutePath)
+	}
+
+	if len(lineArr) == 0 {
+		return "", nil, fmt.Errorf("File format is not valid")
+	}
+	format = lineArr[0]
+	if format != fileFormatKeyValue && format != fileFormatObject {
+		return "", nil, fmt.Errorf("File format is not valid")
+	}
+	return format, lineArr[1:], nil
+}
+
+// 公共方法: 恢复目录状态，清理进程崩溃后残留的临时文件
+// 临时文件只会在持有排他锁的时候创建，获取到锁之后仍然存在的临时文件一定是残留文件
+func (connector *localFileConnector) recover() {
+	for _, tempFilePath := range file.ListTempFile(connector.localFolderPath) {
+		// 临时文件名格式: db.table.writing.random，格式不正确的文件不处理
+		fileName, ok := file.ParseTempFileName(tempFilePath)
+		if !ok {
+			log.Warn("[localFileConnector.recover] skip unknown temp file: %s", tempFilePath)
+			continue
+		}
+
+		unlock, err := lockFile(fileName+lockFileSuffix, true)
+		if nil != err {
+			log.Warn("[localFileConnector.recover] lock file failed, file name: %s, err: %s", fileName, err.Error())
+			continue
+		}
+		if _, err = os.Stat(tempFilePath); nil == err {
+			log.Warn("[localFileConnector.recover] remove partially written file: %s", tempFilePath)
+			os.Remove(tempFilePath)
+		}
+		unlock()
+	}
+}
+
 // 获取 根据目录路径匹配的单例
 func GetLocalFileConnector(folderPath string) (RDBConnector, error) {
 	var connector RDBConnector
@@ -290,6 +426,7 @@ func GetLocalFileConnector(folderPath string) (RDBConnector, error) {
 	}
 	fileConnector := new(localFileConnector)
 	fileConnector.localFolderPath = folderPath
+	fileConnector.recover()
 	fileConnectorMap[folderPath] = fileConnector
 	return fileConnector, nil
 }
@@ -1,6 +1,8 @@
 package local
 
 import (
+	"sort"
+	"strings"
 	"sync"
 
 	. "github.com/smiecj/go_common/db"
@@ -13,75 +15,116 @@ var (
 )
 
 // 本地内存存储
+// 每个 space 保存一组 key-value 格式的数据行，支持按条件查询、更新、删除，以及排序和分页
 type localMemoryConnector struct {
-	// db_name -> key: table name; id: uuid
-	storage map[string]map[string]string
+	// db.table -> 数据行
+	storage map[string][]map[string]string
+	lock    sync.RWMutex
 }
 
 func (connector *localMemoryConnector) init() {
-	connector.storage = make(map[string]map[string]string)
+	connector.storage = make(map[string][]map[string]string)
 }
 
-// 本地存储: 插入数据
+// 本地存储: 插入数据，追加到已有数据之后
+// 仅支持 key-value 格式的数据
 func (connector *localMemoryConnector) Insert(funcArr ...RDBInsertConfigFunc) (UpdateRet, error) {
 	action := MakeRDBInsertAction()
 	for _, currentFunc := range funcArr {
 		currentFunc(action)
 	}
 
-	// 本地存储仅会存入 key-value 格式的数据，并且会覆盖
+	if err := action.CheckLimit(); nil != err {
+		return UpdateRet{}, err
+	}
+	if len(action.GetObjectArr()) != 0 || nil != action.GetObject() {
+		return UpdateRet{}, errorcode.BuildErrorWithMsg(errorcode.NotImplement, "[localMemoryConnector.Insert] object not support")
+	}
+
+	connector.lock.Lock()
+	defer connector.lock.Unlock()
+
 	spaceName := action.GetSpaceName()
 	fieldArr := action.GetFieldArr()
-	if nil == connector.storage[spaceName] {
-		connector.storage[spaceName] = make(map[string]string)
-	}
 	for _, currentField := range fieldArr {
+		row := make(map[string]string, len(currentField.GetMap()))
 		for key, value := range currentField.GetMap() {
-			connector.storage[spaceName][key] = value
+			row[key] = value
 		}
+		connector.storage[spaceName] = append(connector.storage[spaceName], row)
 	}
 
 	return UpdateRet{AffectedRows: len(fieldArr)}, nil
 }
 
-// 本地存储: 更新数据
+// 本地存储: 更新满足条件的数据，设置 limit 时最多更新 limit 条，仅支持 key-value 格式
 func (connector *localMemoryConnector) Update(funcArr ...RDBUpdateConfigFunc) (UpdateRet, error) {
 	action := MakeRDBUpdateAction()
 	for _, currentFunc := range funcArr {
 		currentFunc(action)
 	}
 
-	// 更新的逻辑和存储一样
-	spaceName := action.GetSpaceName()
-	fieldArr := action.GetFieldArr()
-	if nil == connector.storage[spaceName] {
-		connector.storage[spaceName] = make(map[string]string)
+	if err := action.CheckLimit(); nil != err {
+		return UpdateRet{}, err
 	}
-	for _, currentField := range fieldArr {
-		for key, value := range currentField.GetMap() {
-			connector.storage[spaceName][key] = value
+
+	fieldArr := action.GetFieldArr()
+	if len(fieldArr) == 0 {
+		if len(action.GetObjectArr()) != 0 {
+			return UpdateRet{}, errorcode.BuildErrorWithMsg(errorcode.NotImplement, "[localMemoryConnector.Update] object not support")
 		}
+		return UpdateRet{}, nil
 	}
 
-	return UpdateRet{AffectedRows: len(fieldArr)}, nil
+	connector.lock.Lock()
+	defer connector.lock.Unlock()
+
+	// 和 mysql 一致，只使用第一组取值
+	affectedRows := 0
+	condition := action.GetCondition()
+	for _, row := range connector.storage[action.GetSpaceName()] {
+		if (condition.Limit > 0 && affectedRows >= condition.Limit) || !condition.WhereArr.Match(row) {
+			continue
+		}
+		for key, value := range fieldArr[0].GetMap() {
+			row[key] = value
+		}
+		affectedRows++
+	}
+	return UpdateRet{AffectedRows: affectedRows}, nil
 }
 
-// 本地存储: 删除数据
+// 本地存储: 删除满足条件的数据，最多删除 limit 条
 func (connector *localMemoryConnector) Delete(funcArr ...RDBDeleteConfigFunc) (UpdateRet, error) {
 	action := MakeRDBDeleteAction()
 	for _, currentFunc := range funcArr {
 		currentFunc(action)
 	}
+	if err := action.CheckLimit(); nil != err {
+		return UpdateRet{}, err
+	}
+
+	connector.lock.Lock()
+	defer connector.lock.Unlock()
 
-	// 删除: 忽略查询条件，直接删除整个space
 	spaceName := action.GetSpaceName()
-	if nil == connector.storage[spaceName] {
-		return UpdateRet{AffectedRows: 0}, nil
-	} else {
-		affectedRows := len(connector.storage[spaceName])
+	condition := action.GetCondition()
+	rowArr := connector.storage[spaceName]
+	leftRowArr := make([]map[string]string, 0, len(rowArr))
+	affectedRows := 0
+	for _, row := range rowArr {
+		if (condition.Limit == 0 || affectedRows < condition.Limit) && condition.WhereArr.Match(row) {
+			affectedRows++
+			continue
+		}
+		leftRowArr = append(leftRowArr, row)
+	}
+	if len(leftRowArr) == 0 {
 		delete(connector.storage, spaceName)
-		return UpdateRet{AffectedRows: affectedRows}, nil
+	} else {
+		connector.storage[spaceName] = leftRowArr
 	}
+	return UpdateRet{AffectedRows: affectedRows}, nil
 }
 
 // 备份数据
@@ -89,62 +132,147 @@ func (connector *localMemoryConnector) Backup(funcArr ...RDBBackupConfigFunc) (r
 	return ret, errorcode.BuildErrorWithMsg(errorcode.NotImplement, "[localMemoryConnector.Backup] not implement")
 }
 
-// 本地存储: 查询数据
+// 本地存储: 查询数据，支持条件、join、排序、分页和指定返回字段
 func (connector *localMemoryConnector) Search(funcArr ...RDBSearchConfigFunc) (ret SearchRet, err error) {
 	action := MakeRDBSearchAction()
 	for _, currentFunc := range funcArr {
 		currentFunc(action)
 	}
+	if nil != action.GetObjectArrType() {
+		return ret, errorcode.BuildErrorWithMsg(errorcode.NotImplement, "[localMemoryConnector.Search] object not support")
+	}
+	if action.GetCondition().WhereArr.HasSubQuery() {
+		return ret, errorcode.BuildErrorWithMsg(errorcode.NotImplement, "[localMemoryConnector.Search] sub query not support")
+	}
 
-	// 查询: 按照 spaceName 查询
-	spaceName := action.GetSpaceName()
-	if nil != connector.storage[spaceName] {
-		currentField := BuildNewField()
-		for key, value := range connector.storage[spaceName] {
-			currentField.AddKeyValue(key, value)
+	connector.lock.RLock()
+	defer connector.lock.RUnlock()
+
+	condition := action.GetCondition()
+	matchRowArr := connector.searchRowArr(action.GetSpaceName(), action.GetName(), condition)
+	if condition.Order.Field != "" {
+		sort.SliceStable(matchRowArr, func(i, j int) bool {
+			return condition.CompareOrderValueArr(condition.GetRowOrderValueArr(matchRowArr[i]),
+				condition.GetRowOrderValueArr(matchRowArr[j])) < 0
+		})
+	}
+	ret.Total = len(matchRowArr)
+
+	start, end := 0, len(matchRowArr)
+	if condition.Page.Limit > 0 {
+		start = condition.Page.No * condition.Page.Limit
+		if start > end {
+			start = end
 		}
-		ret.Len = 1
+		if start+condition.Page.Limit < end {
+			end = start + condition.Page.Limit
+		}
+	}
+
+	keyArr := action.GetKeyArr()
+	for _, row := range matchRowArr[start:end] {
+		currentField := BuildNewField()
+		currentField.AddMap(ProjectRow(row, keyArr))
 		ret.AddField(currentField)
 	}
+	ret.Len = end - start
 	return
 }
 
-// 本地内存: 统计数据量
+// 本地内存: 统计满足条件的数据量
 func (connector *localMemoryConnector) Count(funcArr ...RDBSearchConfigFunc) (SearchRet, error) {
 	action := MakeRDBSearchAction()
 	for _, currentFunc := range funcArr {
 		currentFunc(action)
 	}
 
-	spaceName := action.GetSpaceName()
-	if nil == connector.storage[spaceName] {
-		return SearchRet{Total: 0}, nil
-	} else {
-		return SearchRet{Total: 1}, nil
+	if action.GetCondition().WhereArr.HasSubQuery() {
+		return SearchRet{}, errorcode.BuildErrorWithMsg(errorcode.NotImplement, "[localMemoryConnector.Count] sub query not support")
 	}
+
+	connector.lock.RLock()
+	defer connector.lock.RUnlock()
+
+	total := len(connector.searchRowArr(action.GetSpaceName(), action.GetName(), action.GetCondition()))
+	return SearchRet{Total: total, Len: total}, nil
 }
 
-// 本地内存: 暂不需要实现 Distinct
+// 本地内存: 查询指定字段的不同取值组合，和 mysql 一致只计算 len
 func (connector *localMemoryConnector) Distinct(funcArr ...RDBSearchConfigFunc) (ret SearchRet, err error) {
-	return ret, errorcode.BuildErrorWithMsg(errorcode.NotImplement, "[localMemoryConnector.Distinct] not implement")
+	action := MakeRDBSearchAction()
+	for _, currentFunc := range funcArr {
+		currentFunc(action)
+	}
+	keyArr := action.GetKeyArr()
+	if len(keyArr) == 0 {
+		return ret, errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid, "[localMemoryConnector.Distinct] distinct must set key array")
+	}
+	if action.GetCondition().WhereArr.HasSubQuery() {
+		return ret, errorcode.BuildErrorWithMsg(errorcode.NotImplement, "[localMemoryConnector.Distinct] sub query not support")
+	}
+
+	connector.lock.RLock()
+	defer connector.lock.RUnlock()
+
+	existValueMap := make(map[string]bool)
+	for _, row := range connector.searchRowArr(action.GetSpaceName(), action.GetName(), action.GetCondition()) {
+		valueArr := make([]string, 0, len(keyArr))
+		for _, key := range keyArr {
+			value, _ := GetRowValue(row, key)
+			valueArr = append(valueArr, value)
+		}
+		distinctValue := strings.Join(valueArr, "\x00")
+		if existValueMap[distinctValue] {
+			continue
+		}
+		existValueMap[distinctValue] = true
+
+		currentField := BuildNewField()
+		for index, key := range keyArr {
+			currentField.AddKeyValue(key, valueArr[index])
+		}
+		ret.AddField(currentField)
+	}
+	ret.Len = len(ret.FieldArr)
+	return
+}
+
+// 获取满足查询条件的数据行，有 join 条件时先关联其他表（调用方需要先加锁）
+// name: 主表在字段和条件中的名称（别名或表名）
+func (connector *localMemoryConnector) searchRowArr(spaceName, name string, condition SearchCondition) []map[string]string {
+	rowArr := connector.storage[spaceName]
+	if len(condition.Join) != 0 {
+		rowArr = condition.Join.JoinRows(name, rowArr, func(joinSpaceName string) []map[string]string {
+			return connector.storage[joinSpaceName]
+		})
+	}
+	retArr := make([]map[string]string, 0)
+	for _, row := range rowArr {
+		if condition.WhereArr.Match(row) {
+			retArr = append(retArr, row)
+		}
+	}
+	return retArr
 }
 
-// close
+// close: 清空所有数据
 func (connector *localMemoryConnector) Close() error {
-	connector.storage = nil
+	connector.lock.Lock()
+	defer connector.lock.Unlock()
+	connector.init()
 	return nil
 }
 
 // stat
 func (connector *localMemoryConnector) Stat() (ret DBStat, err error) {
-	return ret, errorcode.BuildErrorWithMsg(errorcode.DBStatFailed, err.Error())
+	return ret, errorcode.BuildErrorWithMsg(errorcode.NotImplement, "[localMemoryConnector.Stat] not implement")
 }
 
 // 实现本地内存连接器
 func GetLocalMemoryConnector() (RDBConnector, error) {
 	localMemoryConnectorOnce.Do(func() {
 		localConnector := new(localMemoryConnector)
-		localConnector.storage = make(map[string]map[string]string)
+		localConnector.init()
 		localMemoryConnectorSingleton = localConnector
 	})
 	return localMemoryConnectorSingleton, nil
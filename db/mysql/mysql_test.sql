@@ -20,4 +20,12 @@ CREATE TABLE `test_student_bak` (
   `class_id` bigint(20) COMMENT 'class id',
    PRIMARY KEY (`id`),
   KEY `name_index` (`name`)
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `test_conformance` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `name` varchar(32) NOT NULL COMMENT 'student name',
+  `class_id` bigint(20) COMMENT 'class id',
+   PRIMARY KEY (`id`),
+  KEY `name_index` (`name`)
 ) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4;
\ No newline at end of file
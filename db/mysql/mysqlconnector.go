@@ -38,15 +38,15 @@ var (
 
 // mysql 连接配置
 type MySQLConnectOption struct {
-	Host        string `yaml:"host" json:"host"`
-	Port        int    `yaml:"port" json:"port"`
-	User        string `yaml:"user" json:"user"`
+	Host        string `yaml:"host" json:"host" validate:"required"`
+	Port        int    `yaml:"port" json:"port" default:"3306" validate:"min=1,max=65535"`
+	User        string `yaml:"user" json:"user" validate:"required"`
 	Password    string `yaml:"password" json:"password"`
 	Database    string `yaml:"database" json:"database"`
 	IsSSL       bool   `yaml:"is_ssl" json:"isSSL"`
-	MaxLifeTime int    `yaml:"max_life_time" json:"maxLifeTime"`
-	MaxIdleTime int    `yaml:"max_idle_time" json:"maxIdleTime"`
-	MaxIdleConn int    `yaml:"max_idle_conn" json:"maxIdleConn"`
+	MaxLifeTime int    `yaml:"max_life_time" json:"maxLifeTime" validate:"min=0"`
+	MaxIdleTime int    `yaml:"max_idle_time" json:"maxIdleTime" validate:"min=0"`
+	MaxIdleConn int    `yaml:"max_idle_conn" json:"maxIdleConn" validate:"min=0"`
 	LogPrefix   string `yaml:"log_prefix" json:"log_prefix"`
 	// 特殊情况: 同一个数据库地址 也需要生成多个连接池，此时可通过随机数生成 id
 	Id string
@@ -66,15 +66,30 @@ func (option *MySQLConnectOption) check() {
 	// }
 }
 
+// 注册到全局 connector 列表中的名称，用于连接池状态监控等场景
+// 默认: mysql-host:port/database，可通过 log_prefix 指定，和其他 connector 重复时追加 #2 等后缀
+func (option *MySQLConnectOption) registerName() string {
+	if option.LogPrefix != "" {
+		return option.LogPrefix
+	}
+	name := fmt.Sprintf("mysql-%s:%d/%s", option.Host, option.Port, option.Database)
+	if option.Id != "" {
+		name = fmt.Sprintf("%s-%s", name, option.Id)
+	}
+	return name
+}
+
 // mysql 存储
 type mysqlConnector struct {
 	db     *gorm.DB
 	log    log.Logger
 	option MySQLConnectOption
+	// 注册到全局 connector 列表中的名称，名称重复时会追加后缀
+	registeredName string
 }
 
 // mysql: 插入数据
-// 后续: 对批量插入场景，单次插入的数据量进行控制
+// 按照 InsertBatch 设置的数据量分批写入，返回每一批的写入结果
 func (connector *mysqlConnector) Insert(funcArr ...RDBInsertConfigFunc) (ret UpdateRet, err error) {
 	// 后续: 考虑是否要适配，只传入表名，支持使用默认库名的场景
 	action := MakeRDBInsertAction()
@@ -82,13 +97,21 @@ func (connector *mysqlConnector) Insert(funcArr ...RDBInsertConfigFunc) (ret Upd
 		currentFunc(action)
 	}
 
+	if err = action.CheckLimit(); nil != err {
+		connector.log.Error("[Insert] %s", err.Error())
+		return ret, err
+	}
+
 	// 插入 (按field，即 key-value map 插入 / 按 objectArr 批量插入)
-	var dbRet *gorm.DB
+	// createChunkFunc: 在指定的 db (事务) 上写入 [start, end) 范围的数据
+	var createChunkFunc func(tx *gorm.DB, start, end int) *gorm.DB
 	fieldArr := action.GetFieldArr()
 	objectArr := action.GetObjectArr()
 	object := action.GetObject()
 	if nil != object {
-		dbRet = connector.db.Table(action.GetSpaceName()).Create(object)
+		createChunkFunc = func(tx *gorm.DB, start, end int) *gorm.DB {
+			return tx.Table(action.GetSpaceName()).Create(object)
+		}
 	} else if len(fieldArr) != 0 {
 		keyValueMapArr := make([]map[string]interface{}, 0)
 		for _, currentField := range fieldArr {
@@ -98,7 +121,9 @@ func (connector *mysqlConnector) Insert(funcArr ...RDBInsertConfigFunc) (ret Upd
 			}
 			keyValueMapArr = append(keyValueMapArr, currentKeyValueMap)
 		}
-		dbRet = connector.db.Table(action.GetSpaceName()).Session(&gorm.Session{CreateBatchSize: action.Batch()}).Create(keyValueMapArr)
+		createChunkFunc = func(tx *gorm.DB, start, end int) *gorm.DB {
+			return tx.Table(action.GetSpaceName()).Create(keyValueMapArr[start:end])
+		}
 	} else if len(objectArr) != 0 {
 		insertKeyArr := []string{}
 		keyArr := action.GetKeyArr()
@@ -106,29 +131,68 @@ func (connector *mysqlConnector) Insert(funcArr ...RDBInsertConfigFunc) (ret Upd
 			insertKeyArr = keyArr
 		}
 		// 注意数组类型需要转换一下，传入的 interface{} 数组无法被 gorm 识别（即数组需要保持原有的type）
-		var toInsertArr interface{}
 		objectArrType := action.GetObjectArrType()
-		if nil != objectArrType {
-			slice := reflect.MakeSlice(objectArrType, 0, 0)
-			for _, currentObj := range objectArr {
-				slice = reflect.Append(slice, reflect.ValueOf(currentObj))
-			}
-			toInsertArr = slice.Interface()
-		} else {
+		if nil == objectArrType {
 			connector.log.Error("[Insert] %s", insertUnknownObjectType)
 			return ret, errorcode.BuildErrorWithMsg(errorcode.DBExecFailed, insertUnknownObjectType)
 		}
+		slice := reflect.MakeSlice(objectArrType, 0, len(objectArr))
+		for _, currentObj := range objectArr {
+			slice = reflect.Append(slice, reflect.ValueOf(currentObj))
+		}
 		// todo: insert 不能选定字段。可能要想其他办法进行插入
-		dbRet = connector.db.Table(action.GetSpaceName()).Select(insertKeyArr).Session(&gorm.Session{CreateBatchSize: action.Batch()}).Create(toInsertArr)
+		createChunkFunc = func(tx *gorm.DB, start, end int) *gorm.DB {
+			return tx.Table(action.GetSpaceName()).Select(insertKeyArr).Create(slice.Slice(start, end).Interface())
+		}
 	} else {
 		connector.log.Warn("[Insert] To insert data is empty")
 		return ret, nil
 	}
 
-	ret.AffectedRows, err = int(dbRet.RowsAffected), dbRet.Error
+	ret.ChunkRetArr = action.SplitChunk()
+	// 执行所有批次: 失败后根据配置决定是否继续，没有执行的批次标记为 skipped
+	execChunkFunc := func(tx *gorm.DB, isStopOnError bool) error {
+		var firstErr error
+		for index := range ret.ChunkRetArr {
+			chunkRet := &ret.ChunkRetArr[index]
+			if nil != firstErr && isStopOnError {
+				chunkRet.Skipped = true
+				continue
+			}
+			dbRet := createChunkFunc(tx, chunkRet.Start, chunkRet.End)
+			chunkRet.AffectedRows, chunkRet.Err = int(dbRet.RowsAffected), dbRet.Error
+			if nil != chunkRet.Err {
+				connector.log.Warn("[Insert] Insert chunk failed: table: %s, chunk: %d [%d, %d), reason: %s",
+					action.GetSpaceName(), chunkRet.Index, chunkRet.Start, chunkRet.End, chunkRet.Err.Error())
+				if nil == firstErr {
+					firstErr = chunkRet.Err
+				}
+			}
+		}
+		return firstErr
+	}
+
+	if action.IsTransaction() {
+		txErr := connector.db.Transaction(func(tx *gorm.DB) error {
+			return execChunkFunc(tx, true)
+		})
+		// 事务回滚: 所有批次都没有写入
+		if nil != txErr {
+			for index := range ret.ChunkRetArr {
+				ret.ChunkRetArr[index].AffectedRows = 0
+			}
+		}
+	} else {
+		execChunkFunc(connector.db, !action.IsContinueOnError())
+	}
+
+	for _, chunkRet := range ret.ChunkRetArr {
+		ret.AffectedRows += chunkRet.AffectedRows
+	}
+	err = BuildChunkError(ret.ChunkRetArr)
 
 	if nil != err {
-		connector.log.Error("[Insert] Insert failed: table: %s, reason: %s", action.GetSpaceName(), err.Error())
+		connector.log.Error("[Insert] Insert failed: table: %s, insert rows: %d, reason: %s", action.GetSpaceName(), ret.AffectedRows, err.Error())
 	} else {
 		connector.log.Info("[Insert] Insert success: %s, insert rows: %d", action.GetSpaceName(), ret.AffectedRows)
 	}
@@ -142,25 +206,34 @@ func (connector *mysqlConnector) Update(funcArr ...RDBUpdateConfigFunc) (ret Upd
 		currentFunc(action)
 	}
 
+	if err = action.CheckLimit(); nil != err {
+		connector.log.Error("[Update] %s", err.Error())
+		return ret, err
+	}
+
 	// 根据查询条件 更新指定数据，只更新一种取值
 	var dbRet *gorm.DB
 	fieldArr := action.GetFieldArr()
 	objectArr := action.GetObjectArr()
 	keyArr := action.GetKeyArr()
 	condition := action.GetCondition()
+	updateDB := connector.db.Table(action.GetSpaceName()).Where(condition.WhereArr.ToSQL())
+	if condition.Limit > 0 {
+		updateDB = updateDB.Limit(condition.Limit)
+	}
 	if len(fieldArr) != 0 {
 		keyValueMap := make(map[string]interface{}, 0)
 		currentField := fieldArr[0]
 		for key, value := range currentField.GetMap() {
 			keyValueMap[key] = value
 		}
-		dbRet = connector.db.Table(action.GetSpaceName()).Where(condition.WhereArr.ToSQL()).Updates(keyValueMap)
+		dbRet = updateDB.Updates(keyValueMap)
 	} else if len(objectArr) != 0 {
 		searchKeyArr := []string{}
 		if len(keyArr) != 0 {
 			searchKeyArr = keyArr
 		}
-		dbRet = connector.db.Table(action.GetSpaceName()).Where(condition.WhereArr.ToSQL()).Select(searchKeyArr).Updates(objectArr[0])
+		dbRet = updateDB.Select(searchKeyArr).Updates(objectArr[0])
 	} else {
 		connector.log.Warn("[Update] To update data is empty")
 		return ret, nil
@@ -183,6 +256,11 @@ func (connector *mysqlConnector) Delete(funcArr ...RDBDeleteConfigFunc) (ret Upd
 		currentFunc(action)
 	}
 
+	if err = action.CheckLimit(); nil != err {
+		connector.log.Error("[Delete] %s", err.Error())
+		return ret, err
+	}
+
 	updateCondition := action.GetCondition()
 	dbRet := connector.db.Exec(fmt.Sprintf("DELETE FROM %s %s %s",
 		action.GetSpaceName(), updateCondition.GetUpdateCondition(), updateCondition.GetLimitCondition()))
@@ -203,6 +281,11 @@ func (connector *mysqlConnector) Backup(funcArr ...RDBBackupConfigFunc) (ret Upd
 		currentFunc(action)
 	}
 
+	if err = action.CheckLimit(); nil != err {
+		connector.log.Error("[Backup] %s", err.Error())
+		return ret, err
+	}
+
 	// 备份
 	// todo: 支持备份选择指定字段
 	// todo: 考虑到需要format 的字段过多，可以考虑 where、order、limit 部分的条件都统一放到一个方法中去封装
@@ -226,12 +309,17 @@ func (connector *mysqlConnector) Search(funcArr ...RDBSearchConfigFunc) (ret Sea
 	}
 
 	condition := action.GetCondition()
+	if err = checkJoin(condition); nil != err {
+		connector.log.Error("[Search] %s", err.Error())
+		return ret, err
+	}
 
 	// 统计 count
 	var count int64
 	keyArr := action.GetKeyArr()
-	dbRet := connector.db.Table(action.GetSpaceName()).Joins(condition.Join.ToSQL()).
-		Select(keyArr).Where(condition.WhereArr.ToSQL()).Count(&count)
+	whereSQL, whereArgArr := condition.WhereArr.ToSQLWithArgs()
+	dbRet := connector.searchTable(action.GetSpaceName(), action.GetAlias(), action.GetCondition()).
+		Select(keyArr).Where(whereSQL, whereArgArr...).Count(&count)
 
 	if nil != dbRet.Error {
 		connector.log.Error("[Search] Count failed, table: %s, reason: %s", action.GetSpaceName(), dbRet.Error.Error())
@@ -241,24 +329,21 @@ func (connector *mysqlConnector) Search(funcArr ...RDBSearchConfigFunc) (ret Sea
 	}
 
 	// order condition
-	var orderStr string
-	if "" != condition.Order.Field {
-		orderStr = fmt.Sprintf("%s %s", condition.Order.Field, condition.Order.Sc)
-	}
+	orderStr := condition.GetOrderSQL()
 
 	objectArrType := action.GetObjectArrType()
 	if nil != objectArrType {
 		objectReflectArr := reflect.MakeSlice(objectArrType, 0, 0).Interface()
-		dbRet = connector.db.Table(action.GetSpaceName()).Joins(condition.Join.ToSQL()).
-			Select(keyArr).Where(condition.WhereArr.ToSQL()).Order(orderStr).
+		dbRet = connector.searchTable(action.GetSpaceName(), action.GetAlias(), action.GetCondition()).
+			Select(keyArr).Where(whereSQL, whereArgArr...).Order(orderStr).
 			Offset(condition.Page.No * condition.Page.Limit).Limit(condition.Page.Limit).
 			Find(&objectReflectArr)
 		ret.ObjectArr = objectReflectArr
 	} else {
 		// 非导入到 object 情况，存在 value 在转换的时候不准确的问题，需要测试
 		keyValueMapArr := make([]map[string]interface{}, 0)
-		dbRet = connector.db.Table(action.GetSpaceName()).
-			Select(keyArr).Where(condition.WhereArr.ToSQL()).Order(orderStr).Joins(condition.Join.ToSQL()).
+		dbRet = connector.searchTable(action.GetSpaceName(), action.GetAlias(), action.GetCondition()).
+			Select(keyArr).Where(whereSQL, whereArgArr...).Order(orderStr).
 			Offset(condition.Page.No * condition.Page.Limit).Limit(condition.Page.Limit).
 			Find(&keyValueMapArr)
 		connector.addFielBySearchRet(keyValueMapArr, &ret)
@@ -341,8 +426,13 @@ func (connector *mysqlConnector) Count(funcArr ...RDBSearchConfigFunc) (ret Sear
 	}
 
 	condition := action.GetCondition()
+	if err = checkJoin(condition); nil != err {
+		connector.log.Error("[Count] %s", err.Error())
+		return ret, err
+	}
 	var count int64
-	dbRet := connector.db.Table(action.GetSpaceName()).Where(condition.WhereArr.ToSQL()).Count(&count)
+	whereSQL, whereArgArr := condition.WhereArr.ToSQLWithArgs()
+	dbRet := connector.searchTable(action.GetSpaceName(), action.GetAlias(), condition).Where(whereSQL, whereArgArr...).Count(&count)
 
 	ret.Total, err = int(count), dbRet.Error
 	ret.Len = ret.Total
@@ -383,8 +473,13 @@ func (connector *mysqlConnector) Distinct(funcArr ...RDBSearchConfigFunc) (ret S
 		distinctColumn += ")"
 	}
 
-	dbRet := connector.db.Table(action.GetSpaceName()).Select(keyArr).Where(action.GetCondition().WhereArr.ToSQL()).
-		Distinct().Pluck(distinctColumn, &fieldValueArr)
+	if err = checkJoin(action.GetCondition()); nil != err {
+		connector.log.Error("[Distinct] %s", err.Error())
+		return ret, err
+	}
+	whereSQL, whereArgArr := action.GetCondition().WhereArr.ToSQLWithArgs()
+	dbRet := connector.searchTable(action.GetSpaceName(), action.GetAlias(), action.GetCondition()).Select(keyArr).
+		Where(whereSQL, whereArgArr...).Distinct().Pluck(distinctColumn, &fieldValueArr)
 	err = dbRet.Error
 	if nil != dbRet.Error {
 		connector.log.Error("[Distinct] Distinct %s get field value failed: %s", action.GetSpaceName(), err.Error())
@@ -412,9 +507,16 @@ func (connector *mysqlConnector) Stat() (ret DBStat, err error) {
 		connector.log.Warn("[Stat] get db stat err: %s", err.Error())
 		return ret, errorcode.BuildErrorWithMsg(errorcode.DBStatFailed, err.Error())
 	}
-	ret.OpenConnections = db.Stats().OpenConnections
-	ret.Idle = db.Stats().Idle
-	ret.InUse = db.Stats().InUse
+	stats := db.Stats()
+	ret.MaxOpenConnections = stats.MaxOpenConnections
+	ret.OpenConnections = stats.OpenConnections
+	ret.Idle = stats.Idle
+	ret.InUse = stats.InUse
+	ret.WaitCount = stats.WaitCount
+	ret.WaitDuration = stats.WaitDuration
+	ret.MaxIdleClosed = stats.MaxIdleClosed
+	ret.MaxIdleTimeClosed = stats.MaxIdleTimeClosed
+	ret.MaxLifetimeClosed = stats.MaxLifetimeClosed
 	return
 }
 
@@ -422,6 +524,7 @@ func (connector *mysqlConnector) Close() error {
 	mysqlConnectorLock.Lock()
 	delete(mysqlConnectorMap, connector.option)
 	mysqlConnectorLock.Unlock()
+	UnregisterConnector(connector.registeredName)
 
 	db, err := connector.db.DB()
 	if nil != err {
@@ -437,6 +540,22 @@ func (connector *mysqlConnector) Close() error {
 	return nil
 }
 
+// 查询的表: 包括主表别名和 join 条件
+func (connector *mysqlConnector) searchTable(spaceName, alias string, condition SearchCondition) *gorm.DB {
+	if alias != "" {
+		spaceName = fmt.Sprintf("%s AS %s", spaceName, alias)
+	}
+	return connector.db.Table(spaceName).Joins(condition.Join.ToSQL())
+}
+
+// mysql 不支持 FULL JOIN
+func checkJoin(condition SearchCondition) error {
+	if condition.Join.Contains(FullJoin) {
+		return errorcode.BuildErrorWithMsg(errorcode.NotImplement, "mysql not support full join")
+	}
+	return nil
+}
+
 // mysql: 转换 key-value field
 func (connector *mysqlConnector) addFielBySearchRet(keyValueMapArr []map[string]interface{}, ret *SearchRet) {
 	for _, currentKeyValueMap := range keyValueMapArr {
@@ -452,7 +571,10 @@ func (connector *mysqlConnector) addFielBySearchRet(keyValueMapArr []map[string]
 // 通过配置中心，获取 mysql 连接器
 func GetMySQLConnector(configManager config.Manager) (RDBConnector, error) {
 	option := MySQLConnectOption{}
-	configManager.Unmarshal(mysqlConfigDefaultSpace, &option)
+	if err := config.UnmarshalAndValidate(configManager, mysqlConfigDefaultSpace, &option); nil != err {
+		log.Error("[GetMySQLConnector] mysql config is invalid: %s", err.Error())
+		return nil, err
+	}
 	return getMySQLConnector(option)
 }
 
@@ -490,8 +612,8 @@ func getMySQLConnector(option MySQLConnectOption) (RDBConnector, error) {
 
 	// mysql 连接能成功创建，并执行 SQL, 才算是创建成功
 	if nil != err {
-		log.Error("[GetMySQLConnector] Get mysql connector failed, please check config: %s, err: %s", connectStr, err.Error())
-		return nil, errorcode.BuildErrorWithMsg(errorcode.DBConnectFailed, err.Error())
+		log.Error("[GetMySQLConnector] Get mysql connector failed, please check config: %s, err: %s", log.MaskDSN(connectStr), err.Error())
+		return nil, errorcode.BuildErrorWithMsg(errorcode.DBConnectFailed, log.MaskString(err.Error()))
 	}
 
 	connDB, _ := db.DB()
@@ -500,8 +622,8 @@ func getMySQLConnector(option MySQLConnectOption) (RDBConnector, error) {
 	connDB.SetConnMaxLifetime(time.Second * time.Duration(option.MaxLifeTime))
 	err = db.Exec("SELECT 1;").Error
 	if nil != err {
-		log.Error("[GetMySQLConnector] Exec mysql check sql failed, please check config: %s, err: %s", connectStr, err.Error())
-		return nil, errorcode.BuildErrorWithMsg(errorcode.DBConnectFailed, err.Error())
+		log.Error("[GetMySQLConnector] Exec mysql check sql failed, please check config: %s, err: %s", log.MaskDSN(connectStr), err.Error())
+		return nil, errorcode.BuildErrorWithMsg(errorcode.DBConnectFailed, log.MaskString(err.Error()))
 	}
 	mysqlConnector := new(mysqlConnector)
 	mysqlConnector.db = db
@@ -514,5 +636,6 @@ func getMySQLConnector(option MySQLConnectOption) (RDBConnector, error) {
 	}
 
 	mysqlConnectorMap[option] = mysqlConnector
+	mysqlConnector.registeredName = RegisterConnector(option.registerName(), mysqlConnector)
 	return mysqlConnector, nil
 }
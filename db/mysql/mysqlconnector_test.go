@@ -9,6 +9,7 @@ import (
 	yamlconfig "github.com/smiecj/go_common/config/yaml"
 	"github.com/smiecj/go_common/db"
 	. "github.com/smiecj/go_common/db"
+	"github.com/smiecj/go_common/db/conformance"
 	"github.com/smiecj/go_common/util/file"
 	"github.com/smiecj/go_common/util/log"
 	"github.com/stretchr/testify/require"
@@ -139,6 +140,28 @@ func TestMySQLConnector(t *testing.T) {
 	require.Empty(t, err)
 	require.LessOrEqual(t, 1, searchRet.Len)
 
+	// join with alias: count and distinct also use join
+	countRet, err := connector.Count(SearchSetSpace(dbTemp, tableStudent), SearchSetAlias("s"),
+		SearchAddJoinTable(dbTemp, tableClass, JoinSetMethod(InnerJoin), JoinSetAlias("c"), JoinSetOn("s.class_id", "c.id")),
+		SearchSetCondition("s.name", "=", testStudentSingle.Name))
+	require.Empty(t, err)
+	require.LessOrEqual(t, 1, countRet.Total)
+	_, err = connector.Count(SearchSetSpace(dbTemp, tableStudent),
+		SearchAddJoinTable(dbTemp, tableClass, JoinSetMethod(FullJoin), JoinSetOn("test_student.class_id", "test_class.id")))
+	require.NotEmpty(t, err)
+
+	// sub query: students in existing classes
+	countRet, err = connector.Count(SearchSetSpace(dbTemp, tableStudent),
+		SearchAddSubQueryCondition("class_id", "in", SearchSetSpace(dbTemp, tableClass), SearchSetKeyArr([]string{"id"})))
+	require.Empty(t, err)
+	require.LessOrEqual(t, 1, countRet.Total)
+	searchRet, err = connector.Search(SearchSetSpace(dbTemp, tableStudent), SearchSetAlias("s"),
+		SearchSetCondition("s.name", "=", testStudentSingle.Name),
+		SearchAddExistsCondition(SearchSetSpace(dbTemp, tableClass), SearchSetAlias("c"), SearchSetKeyArr([]string{"1"}),
+			SearchSetCondition("c.id", "=", "`s`.class_id")))
+	require.Empty(t, err)
+	require.LessOrEqual(t, 1, searchRet.Len)
+
 	// distinct
 	searchRet, err = connector.Distinct(SearchSetSpace(dbTemp, tableStudent),
 		SearchSetKeyArr([]string{"name", "class_id"}))
@@ -215,9 +238,64 @@ func TestMySQLBatchInsert(t *testing.T) {
 		InsertAddKeyArr(studentArr.getFields()))
 	require.Nil(t, err)
 	require.Equal(t, arrSize, insertRet.AffectedRows)
+	require.Equal(t, arrSize/batchSize, len(insertRet.ChunkRetArr))
 
 	deleteRet, err := connector.Delete(DeleteSetSpace(dbTemp, tableStudent),
 		DeleteSetCondition("class_id", "=", strconv.Itoa(specialClassId)))
 	require.Nil(t, err)
 	require.Equal(t, arrSize, deleteRet.AffectedRows)
 }
+
+// 分批写入部分批次失败: 继续写入 / 事务回滚
+func TestMySQLChunkInsert(t *testing.T) {
+	const (
+		batchSize      = 2
+		specialClassId = 2334
+	)
+
+	connector := initConnection(t)
+
+	// 3 个批次，第 2 个批次包含不存在的字段，写入会失败
+	insertFuncArr := []RDBInsertConfigFunc{InsertSetSpace(dbTemp, tableStudent), InsertBatch(batchSize)}
+	for index := 0; index < 3*batchSize; index++ {
+		field := BuildNewField()
+		field.AddKeyValue("name", fmt.Sprintf("chunk-%d", index))
+		field.AddKeyValue("class_id", strconv.Itoa(specialClassId))
+		if index == batchSize {
+			field.AddKeyValue("not_exist_column", "1")
+		}
+		insertFuncArr = append(insertFuncArr, InsertAddField(field))
+	}
+
+	// 默认: 第一个失败的批次停止
+	insertRet, err := connector.Insert(insertFuncArr...)
+	require.NotNil(t, err)
+	require.Equal(t, batchSize, insertRet.AffectedRows)
+	require.NotNil(t, insertRet.ChunkRetArr[1].Err)
+	require.True(t, insertRet.ChunkRetArr[2].Skipped)
+
+	// 继续写入后面的批次
+	insertRet, err = connector.Insert(append(insertFuncArr, InsertContinueOnError())...)
+	require.NotNil(t, err)
+	require.Equal(t, 2*batchSize, insertRet.AffectedRows)
+	require.Nil(t, insertRet.ChunkRetArr[2].Err)
+
+	// 事务: 全部回滚
+	insertRet, err = connector.Insert(append(insertFuncArr, InsertTransaction())...)
+	require.NotNil(t, err)
+	require.Equal(t, 0, insertRet.AffectedRows)
+
+	deleteRet, err := connector.Delete(DeleteSetSpace(dbTemp, tableStudent),
+		DeleteSetCondition("class_id", "=", strconv.Itoa(specialClassId)))
+	require.Nil(t, err)
+	require.Equal(t, 3*batchSize, deleteRet.AffectedRows)
+}
+
+// mysql 连接器一致性测试: 支持全部能力，依赖 mysql_test.sql 中的 test_conformance 表
+func TestMySQLConformance(t *testing.T) {
+	connector := initConnection(t)
+	conformance.Run(t, connector, conformance.Capability{
+		Insert: true, Search: true, Update: true, Delete: true, Count: true, Distinct: true,
+		Object: true, Condition: true, OrderAndPage: true, DeleteCondition: true,
+	}, conformance.SetSpace(dbTemp, "test_conformance"))
+}
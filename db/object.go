@@ -0,0 +1,210 @@
+package db
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/smiecj/go_common/errorcode"
+)
+
+const (
+	objectTimeFormat = "2006-01-02 15:04:05"
+)
+
+// 获取结构体中指定字段的值（转换成字符串）
+// 字段匹配顺序: gorm column 标签、json 标签、字段名、字段名的下划线格式（gorm 默认列名）
+// 时间类型按照 2006-01-02 15:04:05 格式转换
+func GetObjectColumnValue(object interface{}, column string) (string, error) {
+	value := reflect.ValueOf(object)
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return "", errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid, "object is nil")
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return "", errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid,
+			fmt.Sprintf("object type %s is not struct", value.Type()))
+	}
+
+	valueType := value.Type()
+	for index := 0; index < valueType.NumField(); index++ {
+		structField := valueType.Field(index)
+		if structField.PkgPath != "" || !isColumnMatch(structField, column) {
+			continue
+		}
+		fieldValue := value.Field(index).Interface()
+		switch typedValue := fieldValue.(type) {
+		case time.Time:
+			return typedValue.Format(objectTimeFormat), nil
+		case *time.Time:
+			if nil == typedValue {
+				return "", nil
+			}
+			return typedValue.Format(objectTimeFormat), nil
+		}
+		return fmt.Sprint(fieldValue), nil
+	}
+	return "", errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid,
+		fmt.Sprintf("column %s not found in %s", column, valueType))
+}
+
+// 结构体中列名对应的字段为零值时，设置成指定取值（字符串转换成字段类型）
+// 返回设置之后的结构体: 结构体指针直接修改，非指针结构体返回修改之后的副本；没有对应字段、字段已有取值或类型无法转换时不修改
+func fillObjectColumnValue(object interface{}, column, value string) (interface{}, bool) {
+	objectValue := reflect.ValueOf(object)
+	var structValue reflect.Value
+	switch {
+	case objectValue.Kind() == reflect.Ptr && !objectValue.IsNil() && objectValue.Elem().Kind() == reflect.Struct:
+		structValue = objectValue.Elem()
+	case objectValue.Kind() == reflect.Struct:
+		structValue = reflect.New(objectValue.Type()).Elem()
+		structValue.Set(objectValue)
+	default:
+		return object, false
+	}
+
+	structType := structValue.Type()
+	for index := 0; index < structType.NumField(); index++ {
+		structField := structType.Field(index)
+		if structField.PkgPath != "" || !isColumnMatch(structField, column) {
+			continue
+		}
+		fieldValue := structValue.Field(index)
+		if !fieldValue.IsZero() {
+			return object, false
+		}
+		toSetValue, ok := parseColumnValue(fieldValue.Type(), value)
+		if !ok {
+			return object, false
+		}
+		fieldValue.Set(toSetValue)
+		if objectValue.Kind() == reflect.Ptr {
+			return object, true
+		}
+		return structValue.Interface(), true
+	}
+	return object, false
+}
+
+// 字符串转换成指定类型的取值，支持 字符串、数字、布尔、时间（2006-01-02 15:04:05 格式）以及对应的指针类型
+func parseColumnValue(valueType reflect.Type, value string) (reflect.Value, bool) {
+	if valueType.Kind() == reflect.Ptr {
+		elemValue, ok := parseColumnValue(valueType.Elem(), value)
+		if !ok {
+			return reflect.Value{}, false
+		}
+		ptrValue := reflect.New(valueType.Elem())
+		ptrValue.Elem().Set(elemValue)
+		return ptrValue, true
+	}
+
+	retValue := reflect.New(valueType).Elem()
+	if valueType == reflect.TypeOf(time.Time{}) {
+		timeValue, err := time.ParseInLocation(objectTimeFormat, value, time.Local)
+		if nil != err {
+			return reflect.Value{}, false
+		}
+		retValue.Set(reflect.ValueOf(timeValue))
+		return retValue, true
+	}
+
+	var err error
+	switch valueType.Kind() {
+	case reflect.String:
+		retValue.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var intValue int64
+		intValue, err = strconv.ParseInt(value, 10, 64)
+		retValue.SetInt(intValue)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var uintValue uint64
+		uintValue, err = strconv.ParseUint(value, 10, 64)
+		retValue.SetUint(uintValue)
+	case reflect.Float32, reflect.Float64:
+		var floatValue float64
+		floatValue, err = strconv.ParseFloat(value, 64)
+		retValue.SetFloat(floatValue)
+	case reflect.Bool:
+		var boolValue bool
+		boolValue, err = strconv.ParseBool(value)
+		retValue.SetBool(boolValue)
+	default:
+		return reflect.Value{}, false
+	}
+	return retValue, nil == err
+}
+
+// 获取结构体的列名列表，按照字段定义顺序
+// 列名优先级: gorm column 标签、json 标签、字段名的下划线格式
+func GetObjectColumnArr(object interface{}) ([]string, error) {
+	objectType := reflect.TypeOf(object)
+	for nil != objectType && (objectType.Kind() == reflect.Ptr || objectType.Kind() == reflect.Slice) {
+		objectType = objectType.Elem()
+	}
+	if nil == objectType || objectType.Kind() != reflect.Struct {
+		return nil, errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid,
+			fmt.Sprintf("object type %v is not struct", objectType))
+	}
+
+	columnArr := make([]string, 0, objectType.NumField())
+	for index := 0; index < objectType.NumField(); index++ {
+		structField := objectType.Field(index)
+		if structField.PkgPath != "" {
+			continue
+		}
+		columnArr = append(columnArr, getColumnName(structField))
+	}
+	return columnArr, nil
+}
+
+// 获取结构体字段对应的列名
+func getColumnName(structField reflect.StructField) string {
+	for _, setting := range strings.Split(structField.Tag.Get("gorm"), ";") {
+		setting = strings.TrimSpace(setting)
+		if strings.HasPrefix(strings.ToLower(setting), "column:") {
+			return strings.TrimSpace(setting[len("column:"):])
+		}
+	}
+	if jsonName := strings.Split(structField.Tag.Get("json"), ",")[0]; jsonName != "" && jsonName != "-" {
+		return jsonName
+	}
+	return toSnakeCase(structField.Name)
+}
+
+// 判断结构体字段是否对应指定列名
+func isColumnMatch(structField reflect.StructField, column string) bool {
+	for _, setting := range strings.Split(structField.Tag.Get("gorm"), ";") {
+		setting = strings.TrimSpace(setting)
+		if strings.HasPrefix(strings.ToLower(setting), "column:") {
+			return strings.TrimSpace(setting[len("column:"):]) == column
+		}
+	}
+	if jsonName := strings.Split(structField.Tag.Get("json"), ",")[0]; jsonName != "" && jsonName != "-" {
+		return jsonName == column
+	}
+	return strings.EqualFold(structField.Name, column) || toSnakeCase(structField.Name) == column
+}
+
+// 驼峰转下划线: UpdateTime -> update_time
+func toSnakeCase(name string) string {
+	runeArr := []rune(name)
+	var builder strings.Builder
+	for index, currentRune := range runeArr {
+		if unicode.IsUpper(currentRune) {
+			// 连续大写（如 ID）只在单词边界处分隔
+			if index > 0 && (unicode.IsLower(runeArr[index-1]) ||
+				(index+1 < len(runeArr) && unicode.IsLower(runeArr[index+1]) && unicode.IsUpper(runeArr[index-1]))) {
+				builder.WriteRune('_')
+			}
+			builder.WriteRune(unicode.ToLower(currentRune))
+		} else {
+			builder.WriteRune(currentRune)
+		}
+	}
+	return builder.String()
+}
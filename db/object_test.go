@@ -0,0 +1,77 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type columnObject struct {
+	Id         int       `gorm:"column:id;primaryKey"`
+	Name       string    `json:"student_name"`
+	UpdateTime time.Time
+}
+
+// 测试按列名获取结构体字段值
+func TestGetObjectColumnValue(t *testing.T) {
+	updateTime, _ := time.Parse("2006-01-02 15:04:05", "2022-01-01 10:00:00")
+	object := &columnObject{Id: 1, Name: "xiaoming", UpdateTime: updateTime}
+
+	value, err := GetObjectColumnValue(object, "id")
+	require.Empty(t, err)
+	require.Equal(t, "1", value)
+
+	value, err = GetObjectColumnValue(*object, "student_name")
+	require.Empty(t, err)
+	require.Equal(t, "xiaoming", value)
+
+	value, err = GetObjectColumnValue(object, "update_time")
+	require.Empty(t, err)
+	require.Equal(t, "2022-01-01 10:00:00", value)
+
+	_, err = GetObjectColumnValue(object, "not_exist")
+	require.NotEmpty(t, err)
+}
+
+// 测试获取结构体列名列表
+func TestGetObjectColumnArr(t *testing.T) {
+	columnArr, err := GetObjectColumnArr([]*columnObject{})
+	require.Empty(t, err)
+	require.Equal(t, []string{"id", "student_name", "update_time"}, columnArr)
+
+	_, err = GetObjectColumnArr(1)
+	require.NotEmpty(t, err)
+}
+
+type auditObject struct {
+	Id         int
+	Operator   *string
+	CreateTime time.Time
+	UpdateTime int64
+}
+
+// 测试结构体零值字段填充
+func TestFillObjectColumnValue(t *testing.T) {
+	object := &auditObject{Id: 1, UpdateTime: 100}
+	ret, ok := fillObjectColumnValue(object, "create_time", "2022-01-01 10:00:00")
+	require.True(t, ok)
+	require.Equal(t, "2022-01-01 10:00:00", object.CreateTime.Format("2006-01-02 15:04:05"))
+	require.True(t, object == ret.(*auditObject))
+
+	// 已有取值不覆盖
+	_, ok = fillObjectColumnValue(object, "update_time", "200")
+	require.False(t, ok)
+	require.Equal(t, int64(100), object.UpdateTime)
+
+	// 非指针结构体返回副本
+	ret, ok = fillObjectColumnValue(auditObject{Id: 2}, "operator", "admin")
+	require.True(t, ok)
+	require.Equal(t, "admin", *ret.(auditObject).Operator)
+
+	// 类型无法转换、字段不存在
+	_, ok = fillObjectColumnValue(auditObject{}, "update_time", "2022-01-01 10:00:00")
+	require.False(t, ok)
+	_, ok = fillObjectColumnValue(auditObject{}, "not_exist", "1")
+	require.False(t, ok)
+}
@@ -0,0 +1,281 @@
+// package policy 表策略: 包装任意 RDBConnector，按表自动维护审计字段（创建/更新时间、操作人）和软删除
+// 软删除: Delete 转换成更新删除标记字段，Search / Count / Distinct 默认过滤已删除的数据，可以通过 db.SearchIncludeDeleted 查询全部数据
+package policy
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/smiecj/go_common/db"
+)
+
+const (
+	// 默认时间格式，和结构体时间字段的转换格式一致
+	defaultTimeFormat = "2006-01-02 15:04:05"
+	// 时间字段使用秒级时间戳
+	TimeFormatUnix = "unix"
+)
+
+// 表策略
+type rule struct {
+	createTimeColumn string
+	updateTimeColumn string
+	createByColumn   string
+	updateByColumn   string
+	timeFormat       string
+	// 软删除字段，deletedValue 为空时删除写入当前时间，未删除的数据该字段为 NULL
+	softDeleteColumn string
+	deletedValue     string
+	notDeletedValue  string
+}
+
+type RuleConfigFunc func(*rule)
+
+// 设置创建时间字段: 插入时自动填充
+func RuleSetCreateTime(column string) RuleConfigFunc {
+	return func(rule *rule) {
+		rule.createTimeColumn = column
+	}
+}
+
+// 设置更新时间字段: 插入、更新、软删除时自动填充
+func RuleSetUpdateTime(column string) RuleConfigFunc {
+	return func(rule *rule) {
+		rule.updateTimeColumn = column
+	}
+}
+
+// 设置操作人字段，操作人通过 PolicySetOperatorFunc 获取，字段为空时不填充
+func RuleSetOperator(createByColumn, updateByColumn string) RuleConfigFunc {
+	return func(rule *rule) {
+		rule.createByColumn = createByColumn
+		rule.updateByColumn = updateByColumn
+	}
+}
+
+// 设置时间字段格式，默认 2006-01-02 15:04:05，TimeFormatUnix 表示秒级时间戳
+func RuleSetTimeFormat(timeFormat string) RuleConfigFunc {
+	return func(rule *rule) {
+		rule.timeFormat = timeFormat
+	}
+}
+
+// 设置软删除时间字段（如 deleted_at）: 删除时写入当前时间，查询时过滤该字段不为 NULL 的数据
+func RuleSetSoftDelete(column string) RuleConfigFunc {
+	return func(rule *rule) {
+		rule.softDeleteColumn = column
+		rule.deletedValue, rule.notDeletedValue = "", ""
+	}
+}
+
+// 设置软删除标记字段（如 is_deleted）: 删除时写入 deletedValue，插入时默认写入 notDeletedValue，查询时只返回 notDeletedValue 的数据
+func RuleSetSoftDeleteFlag(column, deletedValue, notDeletedValue string) RuleConfigFunc {
+	return func(rule *rule) {
+		rule.softDeleteColumn = column
+		rule.deletedValue, rule.notDeletedValue = deletedValue, notDeletedValue
+	}
+}
+
+// 是否是标记字段方式的软删除
+func (rule *rule) isFlag() bool {
+	return rule.deletedValue != ""
+}
+
+// 格式化时间
+func (rule *rule) formatTime(now time.Time) string {
+	if rule.timeFormat == TimeFormatUnix {
+		return strconv.FormatInt(now.Unix(), 10)
+	}
+	return now.Format(rule.timeFormat)
+}
+
+// 未删除的数据条件
+func (rule *rule) getNotDeletedCondition(name string) []string {
+	column := rule.softDeleteColumn
+	if name != "" {
+		column = name + "." + column
+	}
+	if rule.isFlag() {
+		return []string{column, "=", rule.notDeletedValue}
+	}
+	return []string{column, "is null"}
+}
+
+// 连接器配置
+type policyOption struct {
+	ruleMap      map[string]*rule
+	operatorFunc func() string
+	nowFunc      func() time.Time
+}
+
+type PolicyConfigFunc func(*policyOption)
+
+// 添加表策略
+func PolicyAddRule(dbName, table string, funcArr ...RuleConfigFunc) PolicyConfigFunc {
+	return func(option *policyOption) {
+		currentRule := &rule{timeFormat: defaultTimeFormat}
+		for _, currentFunc := range funcArr {
+			currentFunc(currentRule)
+		}
+		option.ruleMap[dbName+"."+table] = currentRule
+	}
+}
+
+// 设置获取当前操作人的方法
+func PolicySetOperatorFunc(operatorFunc func() string) PolicyConfigFunc {
+	return func(option *policyOption) {
+		option.operatorFunc = operatorFunc
+	}
+}
+
+// 设置获取当前时间的方法，默认 time.Now
+func PolicySetNowFunc(nowFunc func() time.Time) PolicyConfigFunc {
+	return func(option *policyOption) {
+		option.nowFunc = nowFunc
+	}
+}
+
+// 带表策略的连接器，没有配置策略的表和其他方法直接调用被包装的连接器
+type PolicyConnector struct {
+	db.RDBConnector
+	option policyOption
+}
+
+// 创建带表策略的连接器
+func NewPolicyConnector(connector db.RDBConnector, funcArr ...PolicyConfigFunc) *PolicyConnector {
+	option := policyOption{ruleMap: make(map[string]*rule), nowFunc: time.Now}
+	for _, currentFunc := range funcArr {
+		currentFunc(&option)
+	}
+	return &PolicyConnector{RDBConnector: connector, option: option}
+}
+
+// 获取当前操作人
+func (connector *PolicyConnector) getOperator() string {
+	if nil == connector.option.operatorFunc {
+		return ""
+	}
+	return connector.option.operatorFunc()
+}
+
+// 插入: 填充创建时间、更新时间、操作人，标记方式的软删除填充未删除标记
+func (connector *PolicyConnector) Insert(funcArr ...db.RDBInsertConfigFunc) (db.UpdateRet, error) {
+	action := db.MakeRDBInsertAction()
+	for _, currentFunc := range funcArr {
+		currentFunc(action)
+	}
+	currentRule, ok := connector.option.ruleMap[action.GetSpaceName()]
+	if !ok {
+		return connector.RDBConnector.Insert(funcArr...)
+	}
+
+	now, operator := currentRule.formatTime(connector.option.nowFunc()), connector.getOperator()
+	// 字段 -> 填充取值
+	fillArr := [][2]string{
+		{currentRule.createTimeColumn, now},
+		{currentRule.updateTimeColumn, now},
+		{currentRule.createByColumn, operator},
+		{currentRule.updateByColumn, operator},
+	}
+	if currentRule.isFlag() {
+		fillArr = append(fillArr, [2]string{currentRule.softDeleteColumn, currentRule.notDeletedValue})
+	}
+
+	insertFuncArr := append([]db.RDBInsertConfigFunc{}, funcArr...)
+	for _, currentFill := range fillArr {
+		if currentFill[0] != "" && currentFill[1] != "" {
+			insertFuncArr = append(insertFuncArr, db.InsertFillColumn(currentFill[0], currentFill[1]))
+		}
+	}
+	return connector.RDBConnector.Insert(insertFuncArr...)
+}
+
+// 更新: 填充更新时间、更新人
+func (connector *PolicyConnector) Update(funcArr ...db.RDBUpdateConfigFunc) (db.UpdateRet, error) {
+	action := db.MakeRDBUpdateAction()
+	for _, currentFunc := range funcArr {
+		currentFunc(action)
+	}
+	currentRule, ok := connector.option.ruleMap[action.GetSpaceName()]
+	if !ok || action.GetSQL() != "" {
+		return connector.RDBConnector.Update(funcArr...)
+	}
+
+	updateFuncArr := append([]db.RDBUpdateConfigFunc{}, funcArr...)
+	return connector.RDBConnector.Update(append(updateFuncArr, connector.getUpdateFillFuncArr(currentRule)...)...)
+}
+
+// 更新时需要填充的字段
+func (connector *PolicyConnector) getUpdateFillFuncArr(currentRule *rule) []db.RDBUpdateConfigFunc {
+	fillFuncArr := make([]db.RDBUpdateConfigFunc, 0)
+	if currentRule.updateTimeColumn != "" {
+		fillFuncArr = append(fillFuncArr, db.UpdateFillColumn(currentRule.updateTimeColumn,
+			currentRule.formatTime(connector.option.nowFunc())))
+	}
+	if operator := connector.getOperator(); currentRule.updateByColumn != "" && operator != "" {
+		fillFuncArr = append(fillFuncArr, db.UpdateFillColumn(currentRule.updateByColumn, operator))
+	}
+	return fillFuncArr
+}
+
+// 删除: 配置了软删除的表，转换成更新删除标记（只更新未删除的数据，删除条件中的 limit 不生效）
+func (connector *PolicyConnector) Delete(funcArr ...db.RDBDeleteConfigFunc) (db.UpdateRet, error) {
+	action := db.MakeRDBDeleteAction()
+	for _, currentFunc := range funcArr {
+		currentFunc(action)
+	}
+	currentRule, ok := connector.option.ruleMap[action.GetSpaceName()]
+	if !ok || currentRule.softDeleteColumn == "" {
+		return connector.RDBConnector.Delete(funcArr...)
+	}
+
+	deletedValue := currentRule.deletedValue
+	if !currentRule.isFlag() {
+		deletedValue = currentRule.formatTime(connector.option.nowFunc())
+	}
+	field := db.BuildNewField()
+	field.AddKeyValue(currentRule.softDeleteColumn, deletedValue)
+	updateFuncArr := action.BuildUpdateFuncArr(db.UpdateAddField(field),
+		db.UpdateAddCondition(currentRule.getNotDeletedCondition("")...))
+	return connector.RDBConnector.Update(append(updateFuncArr, connector.getUpdateFillFuncArr(currentRule)...)...)
+}
+
+// 物理删除: 不经过软删除策略，直接删除数据
+func (connector *PolicyConnector) ForceDelete(funcArr ...db.RDBDeleteConfigFunc) (db.UpdateRet, error) {
+	return connector.RDBConnector.Delete(funcArr...)
+}
+
+// 查询: 过滤已删除的数据
+func (connector *PolicyConnector) Search(funcArr ...db.RDBSearchConfigFunc) (db.SearchRet, error) {
+	return connector.RDBConnector.Search(connector.filterDeleted(funcArr)...)
+}
+
+// 统计: 过滤已删除的数据
+func (connector *PolicyConnector) Count(funcArr ...db.RDBSearchConfigFunc) (db.SearchRet, error) {
+	return connector.RDBConnector.Count(connector.filterDeleted(funcArr)...)
+}
+
+// 去重查询: 过滤已删除的数据
+func (connector *PolicyConnector) Distinct(funcArr ...db.RDBSearchConfigFunc) (db.SearchRet, error) {
+	return connector.RDBConnector.Distinct(connector.filterDeleted(funcArr)...)
+}
+
+// 查询条件中添加未删除的条件，只过滤主表；指定 SQL 或者 SearchIncludeDeleted 时不过滤
+// 有 join 时条件中的字段带上主表名（或别名），避免和 join 表的字段冲突
+func (connector *PolicyConnector) filterDeleted(funcArr []db.RDBSearchConfigFunc) []db.RDBSearchConfigFunc {
+	action := db.MakeRDBSearchAction()
+	for _, currentFunc := range funcArr {
+		currentFunc(action)
+	}
+	currentRule, ok := connector.option.ruleMap[action.GetSpaceName()]
+	if !ok || currentRule.softDeleteColumn == "" || action.IsIncludeDeleted() || action.GetSQL() != "" {
+		return funcArr
+	}
+
+	name := ""
+	if len(action.GetCondition().Join) != 0 {
+		name = action.GetName()
+	}
+	searchFuncArr := append([]db.RDBSearchConfigFunc{}, funcArr...)
+	return append(searchFuncArr, db.SearchAddCondition(currentRule.getNotDeletedCondition(name)...))
+}
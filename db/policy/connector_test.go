@@ -0,0 +1,127 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/smiecj/go_common/db"
+	"github.com/smiecj/go_common/db/local"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testDB    = "temp_policy"
+	testTable = "test_student"
+)
+
+// 测试审计字段填充和软删除
+func TestPolicyConnector(t *testing.T) {
+	memoryConnector, err := local.GetLocalMemoryConnector()
+	require.Empty(t, err)
+	now, _ := time.ParseInLocation(defaultTimeFormat, "2022-01-01 10:00:00", time.Local)
+	connector := NewPolicyConnector(memoryConnector, PolicySetNowFunc(func() time.Time { return now }),
+		PolicySetOperatorFunc(func() string { return "admin" }),
+		PolicyAddRule(testDB, testTable, RuleSetCreateTime("create_time"), RuleSetUpdateTime("update_time"),
+			RuleSetOperator("create_by", "update_by"), RuleSetSoftDelete("deleted_at")))
+	defer memoryConnector.Delete(db.DeleteSetSpace(testDB, testTable))
+
+	// 插入: 自动填充审计字段，已设置的字段保持不变
+	for _, name := range []string{"xiaoming", "xiaohong", "xiaogang"} {
+		field := db.BuildNewField()
+		field.AddKeyValue("name", name)
+		if name == "xiaogang" {
+			field.AddKeyValue("create_time", "2021-01-01 00:00:00")
+		}
+		_, err = connector.Insert(db.InsertSetSpace(testDB, testTable), db.InsertAddField(field))
+		require.Empty(t, err)
+	}
+	searchRet, err := connector.Search(db.SearchSetSpace(testDB, testTable), db.SearchSetCondition("name", "=", "xiaoming"))
+	require.Empty(t, err)
+	require.Equal(t, map[string]string{"name": "xiaoming", "create_time": "2022-01-01 10:00:00",
+		"update_time": "2022-01-01 10:00:00", "create_by": "admin", "update_by": "admin"}, searchRet.FieldArr[0].GetMap())
+	searchRet, err = connector.Search(db.SearchSetSpace(testDB, testTable), db.SearchSetCondition("name", "=", "xiaogang"))
+	require.Empty(t, err)
+	require.Equal(t, "2021-01-01 00:00:00", searchRet.FieldArr[0].GetMap()["create_time"])
+
+	// 更新: 填充更新时间
+	now = now.Add(time.Hour)
+	field := db.BuildNewField()
+	field.AddKeyValue("class_id", "1")
+	updateRet, err := connector.Update(db.UpdateSetSpace(testDB, testTable), db.UpdateAddField(field),
+		db.UpdateSetCondition("name", "=", "xiaohong"))
+	require.Empty(t, err)
+	require.Equal(t, 1, updateRet.AffectedRows)
+	searchRet, err = connector.Search(db.SearchSetSpace(testDB, testTable), db.SearchSetCondition("class_id", "=", "1"))
+	require.Empty(t, err)
+	require.Equal(t, "2022-01-01 11:00:00", searchRet.FieldArr[0].GetMap()["update_time"])
+	require.Equal(t, "2022-01-01 10:00:00", searchRet.FieldArr[0].GetMap()["create_time"])
+
+	// 软删除: 查询、统计过滤已删除数据，重复删除不会再次更新
+	updateRet, err = connector.Delete(db.DeleteSetSpace(testDB, testTable), db.DeleteSetCondition("name", "=", "xiaohong"))
+	require.Empty(t, err)
+	require.Equal(t, 1, updateRet.AffectedRows)
+	updateRet, err = connector.Delete(db.DeleteSetSpace(testDB, testTable), db.DeleteSetCondition("name", "=", "xiaohong"))
+	require.Empty(t, err)
+	require.Equal(t, 0, updateRet.AffectedRows)
+
+	countRet, err := connector.Count(db.SearchSetSpace(testDB, testTable))
+	require.Empty(t, err)
+	require.Equal(t, 2, countRet.Total)
+	countRet, err = connector.Count(db.SearchSetSpace(testDB, testTable), db.SearchSetCondition("name", "=", "xiaohong", "or", "name", "=", "xiaoming"))
+	require.Empty(t, err)
+	require.Equal(t, 1, countRet.Total)
+	searchRet, err = connector.Search(db.SearchSetSpace(testDB, testTable), db.SearchIncludeDeleted(),
+		db.SearchSetCondition("deleted_at", "is not null"))
+	require.Empty(t, err)
+	require.Equal(t, 1, searchRet.Len)
+	require.Equal(t, "2022-01-01 11:00:00", searchRet.FieldArr[0].GetMap()["deleted_at"])
+	countRet, err = memoryConnector.Count(db.SearchSetSpace(testDB, testTable))
+	require.Empty(t, err)
+	require.Equal(t, 3, countRet.Total)
+
+	// 物理删除
+	updateRet, err = connector.ForceDelete(db.DeleteSetSpace(testDB, testTable), db.DeleteSetCondition("name", "=", "xiaohong"))
+	require.Empty(t, err)
+	require.Equal(t, 1, updateRet.AffectedRows)
+	countRet, err = connector.Count(db.SearchSetSpace(testDB, testTable), db.SearchIncludeDeleted())
+	require.Empty(t, err)
+	require.Equal(t, 2, countRet.Total)
+}
+
+// 测试标记字段方式的软删除，以及 join 查询中的主表过滤
+func TestPolicyConnectorFlag(t *testing.T) {
+	memoryConnector, err := local.GetLocalMemoryConnector()
+	require.Empty(t, err)
+	connector := NewPolicyConnector(memoryConnector,
+		PolicyAddRule(testDB, "test_class", RuleSetSoftDeleteFlag("is_deleted", "1", "0"), RuleSetUpdateTime("update_time"),
+			RuleSetTimeFormat(TimeFormatUnix)))
+	defer memoryConnector.Delete(db.DeleteSetSpace(testDB, "test_class"))
+	defer memoryConnector.Delete(db.DeleteSetSpace(testDB, "test_teacher"))
+
+	for _, classId := range []string{"1", "2"} {
+		field := db.BuildNewField()
+		field.AddKeyValue("id", classId)
+		_, err = connector.Insert(db.InsertSetSpace(testDB, "test_class"), db.InsertAddField(field))
+		require.Empty(t, err)
+		field = db.BuildNewField()
+		field.AddKeyValue("class_id", classId)
+		field.AddKeyValue("is_deleted", "0")
+		_, err = connector.Insert(db.InsertSetSpace(testDB, "test_teacher"), db.InsertAddField(field))
+		require.Empty(t, err)
+	}
+	searchRet, err := connector.Search(db.SearchSetSpace(testDB, "test_class"), db.SearchSetCondition("id", "=", "1"))
+	require.Empty(t, err)
+	require.Equal(t, "0", searchRet.FieldArr[0].GetMap()["is_deleted"])
+	require.NotEmpty(t, searchRet.FieldArr[0].GetMap()["update_time"])
+
+	_, err = connector.Delete(db.DeleteSetSpace(testDB, "test_class"), db.DeleteSetCondition("id", "=", "2"))
+	require.Empty(t, err)
+
+	// join 的表也有 is_deleted 字段，过滤条件只作用于主表
+	searchRet, err = connector.Search(db.SearchSetSpace(testDB, "test_class"), db.SearchSetAlias("c"),
+		db.SearchAddJoinTable(testDB, "test_teacher", db.JoinSetMethod(db.InnerJoin), db.JoinSetAlias("t"), db.JoinSetOn("c.id", "t.class_id")),
+		db.SearchSetKeyArr([]string{"c.id", "t.class_id"}))
+	require.Empty(t, err)
+	require.Equal(t, 1, searchRet.Len)
+	require.Equal(t, "1", searchRet.FieldArr[0].GetMap()["id"])
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"fmt"
+	"sync"
+)
+
+var (
+	// 全局 connector 注册列表: 名称 -> connector
+	registeredConnectorMap  = make(map[string]RDBConnector)
+	registeredConnectorLock sync.RWMutex
+)
+
+// 注册 connector，返回实际注册的名称
+// 名称已经被其他 connector 使用时追加 #2、#3 等后缀，不会覆盖；同一个 connector 重复注册时返回已注册的名称
+// 一般由 connector 的实现在创建成功后自动注册，用于连接池状态监控等需要遍历所有 connector 的场景
+func RegisterConnector(name string, connector RDBConnector) string {
+	registeredConnectorLock.Lock()
+	defer registeredConnectorLock.Unlock()
+
+	registerName := name
+	for index := 2; ; index++ {
+		existConnector, ok := registeredConnectorMap[registerName]
+		if !ok {
+			registeredConnectorMap[registerName] = connector
+			return registerName
+		}
+		if existConnector == connector {
+			return registerName
+		}
+		registerName = fmt.Sprintf("%s#%d", name, index)
+	}
+}
+
+// 取消注册 connector，需要使用注册时返回的名称，一般在 connector 关闭的时候调用
+func UnregisterConnector(name string) {
+	registeredConnectorLock.Lock()
+	defer registeredConnectorLock.Unlock()
+	delete(registeredConnectorMap, name)
+}
+
+// 获取所有已注册的 connector（副本）
+func GetRegisteredConnectorMap() map[string]RDBConnector {
+	registeredConnectorLock.RLock()
+	defer registeredConnectorLock.RUnlock()
+
+	retMap := make(map[string]RDBConnector, len(registeredConnectorMap))
+	for name, connector := range registeredConnectorMap {
+		retMap[name] = connector
+	}
+	return retMap
+}
@@ -0,0 +1,27 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// 仅用于注册的 connector
+type registryConnector struct {
+	RDBConnector
+}
+
+// 测试注册名称重复时追加后缀，取消注册不影响其他 connector
+func TestRegisterConnector(t *testing.T) {
+	first, second := &registryConnector{}, &registryConnector{}
+	require.Equal(t, "test_registry", RegisterConnector("test_registry", first))
+	require.Equal(t, "test_registry", RegisterConnector("test_registry", first))
+	require.Equal(t, "test_registry#2", RegisterConnector("test_registry", second))
+
+	UnregisterConnector("test_registry")
+	connectorMap := GetRegisteredConnectorMap()
+	_, ok := connectorMap["test_registry"]
+	require.False(t, ok)
+	require.True(t, connectorMap["test_registry#2"] == second)
+	UnregisterConnector("test_registry#2")
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"reflect"
+
+	"github.com/smiecj/go_common/errorcode"
+)
+
+// 查询结果按行处理的公共方法，屏蔽 key-value（FieldArr）和结构体数组（ObjectArr）两种格式的差异
+
+// 是否为结构体数组格式
+func (ret SearchRet) isObject() bool {
+	return nil != ret.ObjectArr
+}
+
+// 查询结果的行数
+func (ret SearchRet) RowLen() int {
+	if ret.isObject() {
+		return reflect.ValueOf(ret.ObjectArr).Len()
+	}
+	return len(ret.FieldArr)
+}
+
+// 截取 [start, end) 的行
+func (ret SearchRet) SliceRow(start, end int) SearchRet {
+	sliceRet := SearchRet{Len: end - start, Total: end - start}
+	if ret.isObject() {
+		sliceRet.ObjectArr = reflect.ValueOf(ret.ObjectArr).Slice(start, end).Interface()
+	} else {
+		sliceRet.FieldArr = ret.FieldArr[start:end]
+	}
+	return sliceRet
+}
+
+// 按照下标选出部分行
+func (ret SearchRet) PickRow(indexArr []int) SearchRet {
+	pickRet := SearchRet{Len: len(indexArr), Total: len(indexArr)}
+	if ret.isObject() {
+		objectArr := reflect.ValueOf(ret.ObjectArr)
+		pickArr := reflect.MakeSlice(objectArr.Type(), 0, len(indexArr))
+		for _, index := range indexArr {
+			pickArr = reflect.Append(pickArr, objectArr.Index(index))
+		}
+		pickRet.ObjectArr = pickArr.Interface()
+	} else {
+		pickRet.FieldArr = ret.FieldArr[:0:0]
+		for _, index := range indexArr {
+			pickRet.FieldArr = append(pickRet.FieldArr, ret.FieldArr[index])
+		}
+	}
+	return pickRet
+}
+
+// 获取指定行的字段取值（转换成字符串）
+func (ret SearchRet) RowColumnValue(index int, column string) (string, error) {
+	if ret.isObject() {
+		return GetObjectColumnValue(reflect.ValueOf(ret.ObjectArr).Index(index).Interface(), column)
+	}
+	value, ok := ret.FieldArr[index].GetMap()[column]
+	if !ok {
+		return "", errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid, "column not found in search result: "+column)
+	}
+	return value, nil
+}
+
+// 两个查询结果的数据是否完全相同
+func (ret SearchRet) IsRowEqual(compareRet SearchRet) bool {
+	return reflect.DeepEqual(ret.ObjectArr, compareRet.ObjectArr) && reflect.DeepEqual(ret.FieldArr, compareRet.FieldArr)
+}
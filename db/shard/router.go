@@ -0,0 +1,680 @@
+// package shard 分库分表路由: 将逻辑表映射到多个连接器上的物理表
+// 根据分片字段的取值路由到单个分片，无法确定分片的查询会发送到所有分片，再按照排序和分页条件合并结果
+package shard
+
+import (
+	"fmt"
+	"hash/crc32"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/smiecj/go_common/db"
+	"github.com/smiecj/go_common/errorcode"
+)
+
+// 分片方法: 根据分片字段的取值，返回分片下标
+type ShardFunc func(value string) (int, error)
+
+// 分片数量不合法时的分片方法: 总是返回错误
+func invalidCountShardFunc(funcName string, count int) ShardFunc {
+	return func(value string) (int, error) {
+		return 0, errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid,
+			fmt.Sprintf("[%s] shard count must be positive, current: %d", funcName, count))
+	}
+}
+
+// 按数值取模分片，分片字段需要是整数；分片数量需要大于 0，否则路由时返回 DBParamInvalid
+func ModShardFunc(count int) ShardFunc {
+	if count <= 0 {
+		return invalidCountShardFunc("ModShardFunc", count)
+	}
+	return func(value string) (int, error) {
+		num, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if nil != err {
+			return 0, errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid,
+				fmt.Sprintf("[ModShardFunc] shard value %s is not integer", value))
+		}
+		if num < 0 {
+			num = -num
+		}
+		return int(num % int64(count)), nil
+	}
+}
+
+// 按 crc32 哈希取模分片，适用于字符串类型的分片字段；分片数量需要大于 0，否则路由时返回 DBParamInvalid
+func HashShardFunc(count int) ShardFunc {
+	if count <= 0 {
+		return invalidCountShardFunc("HashShardFunc", count)
+	}
+	return func(value string) (int, error) {
+		return int(crc32.ChecksumIEEE([]byte(value)) % uint32(count)), nil
+	}
+}
+
+// 物理分片
+type Shard struct {
+	Connector db.RDBConnector
+	DB        string
+	Table     string
+}
+
+// 构建分片列表: 表名通过 tableFormat 和分片下标生成（如 orders_%02d），分片按顺序平均分配到各个连接器上
+// 如 16 个分片、2 个连接器: 0-7 在第一个连接器，8-15 在第二个连接器
+// 分片数量不大于 0 或者没有连接器时返回空列表，NewRouter 会返回错误
+func BuildShardArr(dbName, tableFormat string, count int, connectorArr ...db.RDBConnector) []Shard {
+	if count <= 0 || len(connectorArr) == 0 {
+		return []Shard{}
+	}
+	shardArr := make([]Shard, 0, count)
+	for index := 0; index < count; index++ {
+		shardArr = append(shardArr, Shard{
+			Connector: connectorArr[index*len(connectorArr)/count],
+			DB:        dbName,
+			Table:     fmt.Sprintf(tableFormat, index),
+		})
+	}
+	return shardArr
+}
+
+// 逻辑表的分片规则
+type rule struct {
+	keyColumn string
+	shardFunc ShardFunc
+	shardArr  []Shard
+}
+
+// 根据分片字段取值获取分片下标
+func (rule *rule) getShardIndex(value string) (int, error) {
+	index, err := rule.shardFunc(value)
+	if nil != err {
+		return 0, err
+	}
+	if index < 0 || index >= len(rule.shardArr) {
+		return 0, errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid,
+			fmt.Sprintf("[shard] shard index %d of value %s out of range, shard count: %d", index, value, len(rule.shardArr)))
+	}
+	return index, nil
+}
+
+// 根据条件获取需要访问的分片: 条件中分片字段使用 = 或 in 时只访问对应分片，否则返回所有分片
+func (rule *rule) route(valueArr []string, isKeyFound bool) ([]int, error) {
+	if !isKeyFound {
+		indexArr := make([]int, 0, len(rule.shardArr))
+		for index := range rule.shardArr {
+			indexArr = append(indexArr, index)
+		}
+		return indexArr, nil
+	}
+	indexSet := make(map[int]bool)
+	for _, value := range valueArr {
+		index, err := rule.getShardIndex(value)
+		if nil != err {
+			return nil, err
+		}
+		indexSet[index] = true
+	}
+	indexArr := make([]int, 0, len(indexSet))
+	for index := range indexSet {
+		indexArr = append(indexArr, index)
+	}
+	sort.Ints(indexArr)
+	return indexArr, nil
+}
+
+// 写入类操作的分片: 必须能通过分片字段确定分片，防止误更新所有分片
+func (rule *rule) routeWrite(valueArr []string, isKeyFound bool) ([]int, error) {
+	if !isKeyFound {
+		return nil, errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid,
+			fmt.Sprintf("[Router] condition must contain shard key %s with = or in", rule.keyColumn))
+	}
+	return rule.route(valueArr, isKeyFound)
+}
+
+// 更新时不能修改分片字段（修改之后数据需要迁移到其他分片），只允许设置成和条件中唯一取值相同的值
+func (rule *rule) checkUpdateKey(keyValueArr, setValueArr []string) error {
+	for _, setValue := range setValueArr {
+		if len(keyValueArr) != 1 || keyValueArr[0] != setValue {
+			return errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid,
+				fmt.Sprintf("[Router.Update] shard key %s can not be changed", rule.keyColumn))
+		}
+	}
+	return nil
+}
+
+// 字段列表中是否包含指定字段
+func containsColumn(columnArr []string, column string) bool {
+	for _, currentColumn := range columnArr {
+		if currentColumn == column {
+			return true
+		}
+	}
+	return false
+}
+
+// 路由配置方法
+type RouterConfigFunc func(*Router)
+
+// 添加逻辑表的分片规则: 逻辑表 dbName.table 按照 keyColumn 的取值，通过 shardFunc 路由到 shardArr 中的分片
+func RouterAddRule(dbName, table, keyColumn string, shardFunc ShardFunc, shardArr []Shard) RouterConfigFunc {
+	return func(router *Router) {
+		router.ruleMap[fmt.Sprintf("%s.%s", dbName, table)] = &rule{keyColumn: keyColumn, shardFunc: shardFunc, shardArr: shardArr}
+	}
+}
+
+// 设置默认连接器: 没有分片规则的表，以及 Exec / ExecSearch 直接使用默认连接器
+func RouterSetDefaultConnector(connector db.RDBConnector) RouterConfigFunc {
+	return func(router *Router) {
+		router.defaultConnector = connector
+	}
+}
+
+// 分库分表路由连接器
+type Router struct {
+	ruleMap          map[string]*rule
+	defaultConnector db.RDBConnector
+}
+
+// 创建分库分表路由连接器
+func NewRouter(funcArr ...RouterConfigFunc) (*Router, error) {
+	router := &Router{ruleMap: make(map[string]*rule)}
+	for _, currentFunc := range funcArr {
+		currentFunc(router)
+	}
+	for spaceName, currentRule := range router.ruleMap {
+		if currentRule.keyColumn == "" || nil == currentRule.shardFunc || len(currentRule.shardArr) == 0 {
+			return nil, errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid,
+				fmt.Sprintf("[NewRouter] rule of %s must set key column, shard func and shard array", spaceName))
+		}
+		for _, currentShard := range currentRule.shardArr {
+			if nil == currentShard.Connector {
+				return nil, errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid,
+					fmt.Sprintf("[NewRouter] rule of %s has shard %s.%s without connector", spaceName, currentShard.DB, currentShard.Table))
+			}
+		}
+	}
+	return router, nil
+}
+
+// 插入: 按照每行数据的分片字段分组，分别写入对应分片
+// 注意: 数据分布在多个分片时，InsertTransaction 只保证单个分片内的事务，分片之间不是原子的，部分分片可能已经写入成功
+func (router *Router) Insert(funcArr ...db.RDBInsertConfigFunc) (ret db.UpdateRet, err error) {
+	action := db.MakeRDBInsertAction()
+	for _, currentFunc := range funcArr {
+		currentFunc(action)
+	}
+	currentRule, ok := router.ruleMap[action.GetSpaceName()]
+	if !ok {
+		if nil == router.defaultConnector {
+			return ret, router.buildNoRuleError(action.GetSpaceName())
+		}
+		return router.defaultConnector.Insert(funcArr...)
+	}
+
+	// 每行数据对应的分片
+	rowShardArr := make([]int, 0, action.RowCount())
+	addRowShard := func(value string, err error) error {
+		if nil != err {
+			return err
+		}
+		index, err := currentRule.getShardIndex(value)
+		if nil != err {
+			return err
+		}
+		rowShardArr = append(rowShardArr, index)
+		return nil
+	}
+	if object := action.GetObject(); nil != object {
+		err = addRowShard(db.GetObjectColumnValue(object, currentRule.keyColumn))
+	} else if fieldArr := action.GetFieldArr(); len(fieldArr) != 0 {
+		for _, currentField := range fieldArr {
+			value, ok := currentField.GetMap()[currentRule.keyColumn]
+			if !ok {
+				err = errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid,
+					fmt.Sprintf("[Router.Insert] field %s not contain shard key %s", currentField.String(), currentRule.keyColumn))
+				break
+			}
+			if err = addRowShard(value, nil); nil != err {
+				break
+			}
+		}
+	} else {
+		for _, currentObject := range action.GetObjectArr() {
+			if err = addRowShard(db.GetObjectColumnValue(currentObject, currentRule.keyColumn)); nil != err {
+				break
+			}
+		}
+	}
+	if nil != err {
+		return ret, err
+	}
+	if len(rowShardArr) == 0 {
+		return ret, nil
+	}
+
+	// 所有数据都在同一个分片: 直接修改表名
+	isSingleShard := true
+	for _, index := range rowShardArr {
+		isSingleShard = isSingleShard && index == rowShardArr[0]
+	}
+	if isSingleShard {
+		currentShard := currentRule.shardArr[rowShardArr[0]]
+		return currentShard.Connector.Insert(appendInsertFunc(funcArr, db.InsertSetSpace(currentShard.DB, currentShard.Table))...)
+	}
+
+	// 多个分片: 按分片拆分数据，保留原有的写入配置
+	shardFuncArrMap := make(map[int][]db.RDBInsertConfigFunc)
+	optionFuncArr := []db.RDBInsertConfigFunc{db.InsertAddKeyArr(action.GetKeyArr()), db.InsertBatch(action.Batch())}
+	if action.IsAppend() {
+		optionFuncArr = append(optionFuncArr, db.InsertAppend())
+	}
+	if action.IsContinueOnError() {
+		optionFuncArr = append(optionFuncArr, db.InsertContinueOnError())
+	}
+	if action.IsTransaction() {
+		optionFuncArr = append(optionFuncArr, db.InsertTransaction())
+	}
+	if fieldArr := action.GetFieldArr(); len(fieldArr) != 0 {
+		for rowIndex, shardIndex := range rowShardArr {
+			shardFuncArrMap[shardIndex] = append(shardFuncArrMap[shardIndex], db.InsertAddField(fieldArr[rowIndex]))
+		}
+	} else {
+		objectArr := action.GetObjectArr()
+		shardObjectArrMap := make(map[int]reflect.Value)
+		for rowIndex, shardIndex := range rowShardArr {
+			if _, ok := shardObjectArrMap[shardIndex]; !ok {
+				shardObjectArrMap[shardIndex] = reflect.MakeSlice(action.GetObjectArrType(), 0, 0)
+			}
+			shardObjectArrMap[shardIndex] = reflect.Append(shardObjectArrMap[shardIndex], reflect.ValueOf(objectArr[rowIndex]))
+		}
+		for shardIndex, objectArrValue := range shardObjectArrMap {
+			shardFuncArrMap[shardIndex] = []db.RDBInsertConfigFunc{db.InsertAddObjectArr(objectArrValue.Interface())}
+		}
+	}
+
+	shardIndexArr := make([]int, 0, len(shardFuncArrMap))
+	for shardIndex := range shardFuncArrMap {
+		shardIndexArr = append(shardIndexArr, shardIndex)
+	}
+	sort.Ints(shardIndexArr)
+	retArr := make([]db.UpdateRet, len(currentRule.shardArr))
+	err = router.scatter(currentRule, shardIndexArr, func(shardIndex int, currentShard Shard) (err error) {
+		shardFuncArr := append(append([]db.RDBInsertConfigFunc{db.InsertSetSpace(currentShard.DB, currentShard.Table)},
+			optionFuncArr...), shardFuncArrMap[shardIndex]...)
+		retArr[shardIndex], err = currentShard.Connector.Insert(shardFuncArr...)
+		return
+	})
+	return mergeUpdateRet(retArr), err
+}
+
+// 更新: 条件中需要包含分片字段，只更新对应分片；不能把分片字段修改成其他值
+func (router *Router) Update(funcArr ...db.RDBUpdateConfigFunc) (ret db.UpdateRet, err error) {
+	action := db.MakeRDBUpdateAction()
+	for _, currentFunc := range funcArr {
+		currentFunc(action)
+	}
+	currentRule, ok := router.ruleMap[action.GetSpaceName()]
+	if !ok {
+		if nil == router.defaultConnector {
+			return ret, router.buildNoRuleError(action.GetSpaceName())
+		}
+		return router.defaultConnector.Update(funcArr...)
+	}
+	keyValueArr, isKeyFound := action.GetCondition().WhereArr.GetKeyValueArr(currentRule.keyColumn)
+	shardIndexArr, err := currentRule.routeWrite(keyValueArr, isKeyFound)
+	if nil != err {
+		return ret, err
+	}
+
+	// 更新的分片字段取值: 结构体没有指定更新字段时，零值字段不会更新
+	setValueArr := make([]string, 0)
+	for _, currentField := range action.GetFieldArr() {
+		if value, ok := currentField.GetMap()[currentRule.keyColumn]; ok {
+			setValueArr = append(setValueArr, value)
+		}
+	}
+	for _, currentObject := range action.GetObjectArr() {
+		value, err := db.GetObjectColumnValue(currentObject, currentRule.keyColumn)
+		if nil != err {
+			continue
+		}
+		if len(action.GetKeyArr()) != 0 && !containsColumn(action.GetKeyArr(), currentRule.keyColumn) ||
+			len(action.GetKeyArr()) == 0 && (value == "" || value == "0") {
+			continue
+		}
+		setValueArr = append(setValueArr, value)
+	}
+	if err = currentRule.checkUpdateKey(keyValueArr, setValueArr); nil != err {
+		return ret, err
+	}
+
+	retArr := make([]db.UpdateRet, len(currentRule.shardArr))
+	err = router.scatter(currentRule, shardIndexArr, func(shardIndex int, currentShard Shard) (err error) {
+		retArr[shardIndex], err = currentShard.Connector.Update(
+			append(append([]db.RDBUpdateConfigFunc{}, funcArr...), db.UpdateSetSpace(currentShard.DB, currentShard.Table))...)
+		return
+	})
+	return mergeUpdateRet(retArr), err
+}
+
+// 删除: 条件中需要包含分片字段，只删除对应分片的数据
+func (router *Router) Delete(funcArr ...db.RDBDeleteConfigFunc) (ret db.UpdateRet, err error) {
+	action := db.MakeRDBDeleteAction()
+	for _, currentFunc := range funcArr {
+		currentFunc(action)
+	}
+	currentRule, ok := router.ruleMap[action.GetSpaceName()]
+	if !ok {
+		if nil == router.defaultConnector {
+			return ret, router.buildNoRuleError(action.GetSpaceName())
+		}
+		return router.defaultConnector.Delete(funcArr...)
+	}
+	shardIndexArr, err := currentRule.routeWrite(action.GetCondition().WhereArr.GetKeyValueArr(currentRule.keyColumn))
+	if nil != err {
+		return ret, err
+	}
+
+	retArr := make([]db.UpdateRet, len(currentRule.shardArr))
+	err = router.scatter(currentRule, shardIndexArr, func(shardIndex int, currentShard Shard) (err error) {
+		retArr[shardIndex], err = currentShard.Connector.Delete(
+			append(append([]db.RDBDeleteConfigFunc{}, funcArr...), db.DeleteSetSpace(currentShard.DB, currentShard.Table))...)
+		return
+	})
+	return mergeUpdateRet(retArr), err
+}
+
+// 备份: 分片表暂不支持
+func (router *Router) Backup(funcArr ...db.RDBBackupConfigFunc) (ret db.UpdateRet, err error) {
+	action := db.MakeRDBBackupAction()
+	for _, currentFunc := range funcArr {
+		currentFunc(action)
+	}
+	_, isSourceShard := router.ruleMap[action.GetSourceSpaceName()]
+	_, isTargetShard := router.ruleMap[action.GetTargetSpaceName()]
+	if isSourceShard || isTargetShard || nil == router.defaultConnector {
+		return ret, errorcode.BuildErrorWithMsg(errorcode.NotImplement, "[Router.Backup] backup sharded table not support")
+	}
+	return router.defaultConnector.Backup(funcArr...)
+}
+
+// 查询: 条件中包含分片字段时只查询对应分片，否则查询所有分片，并按照排序字段合并后分页
+func (router *Router) Search(funcArr ...db.RDBSearchConfigFunc) (ret db.SearchRet, err error) {
+	action := db.MakeRDBSearchAction()
+	for _, currentFunc := range funcArr {
+		currentFunc(action)
+	}
+	currentRule, ok := router.ruleMap[action.GetSpaceName()]
+	if !ok {
+		if nil == router.defaultConnector {
+			return ret, router.buildNoRuleError(action.GetSpaceName())
+		}
+		return router.defaultConnector.Search(funcArr...)
+	}
+	condition := action.GetCondition()
+	shardIndexArr, err := currentRule.route(condition.WhereArr.GetKeyValueArr(currentRule.keyColumn))
+	if nil != err {
+		return ret, err
+	}
+	if len(shardIndexArr) == 1 {
+		currentShard := currentRule.shardArr[shardIndexArr[0]]
+		return currentShard.Connector.Search(appendSearchFunc(funcArr, db.SearchSetSpace(currentShard.DB, currentShard.Table))...)
+	}
+
+	// 每个分片都需要查询到当前页的最后一条数据，合并之后再分页
+	pageNo, pageLimit := condition.Page.No, condition.Page.Limit
+	shardPageFuncArr := []db.RDBSearchConfigFunc{}
+	if pageLimit > 0 {
+		shardPageFuncArr = append(shardPageFuncArr, db.SearchSetPageCondition(0, (pageNo+1)*pageLimit))
+	}
+	retArr := make([]db.SearchRet, len(currentRule.shardArr))
+	err = router.scatter(currentRule, shardIndexArr, func(shardIndex int, currentShard Shard) (err error) {
+		shardFuncArr := appendSearchFunc(funcArr, append(shardPageFuncArr, db.SearchSetSpace(currentShard.DB, currentShard.Table))...)
+		retArr[shardIndex], err = currentShard.Connector.Search(shardFuncArr...)
+		return
+	})
+	if nil != err {
+		return ret, err
+	}
+	return mergeSearchRet(retArr, condition)
+}
+
+// 统计: 汇总所有需要访问的分片的数据量
+func (router *Router) Count(funcArr ...db.RDBSearchConfigFunc) (ret db.SearchRet, err error) {
+	action := db.MakeRDBSearchAction()
+	for _, currentFunc := range funcArr {
+		currentFunc(action)
+	}
+	currentRule, ok := router.ruleMap[action.GetSpaceName()]
+	if !ok {
+		if nil == router.defaultConnector {
+			return ret, router.buildNoRuleError(action.GetSpaceName())
+		}
+		return router.defaultConnector.Count(funcArr...)
+	}
+	shardIndexArr, err := currentRule.route(action.GetCondition().WhereArr.GetKeyValueArr(currentRule.keyColumn))
+	if nil != err {
+		return ret, err
+	}
+
+	retArr := make([]db.SearchRet, len(currentRule.shardArr))
+	err = router.scatter(currentRule, shardIndexArr, func(shardIndex int, currentShard Shard) (err error) {
+		retArr[shardIndex], err = currentShard.Connector.Count(appendSearchFunc(funcArr, db.SearchSetSpace(currentShard.DB, currentShard.Table))...)
+		return
+	})
+	for _, currentRet := range retArr {
+		ret.Total += currentRet.Total
+		ret.Len += currentRet.Len
+	}
+	return ret, err
+}
+
+// 去重查询: 合并所有需要访问的分片的结果，并再次去重
+func (router *Router) Distinct(funcArr ...db.RDBSearchConfigFunc) (ret db.SearchRet, err error) {
+	action := db.MakeRDBSearchAction()
+	for _, currentFunc := range funcArr {
+		currentFunc(action)
+	}
+	currentRule, ok := router.ruleMap[action.GetSpaceName()]
+	if !ok {
+		if nil == router.defaultConnector {
+			return ret, router.buildNoRuleError(action.GetSpaceName())
+		}
+		return router.defaultConnector.Distinct(funcArr...)
+	}
+	shardIndexArr, err := currentRule.route(action.GetCondition().WhereArr.GetKeyValueArr(currentRule.keyColumn))
+	if nil != err {
+		return ret, err
+	}
+
+	retArr := make([]db.SearchRet, len(currentRule.shardArr))
+	err = router.scatter(currentRule, shardIndexArr, func(shardIndex int, currentShard Shard) (err error) {
+		retArr[shardIndex], err = currentShard.Connector.Distinct(appendSearchFunc(funcArr, db.SearchSetSpace(currentShard.DB, currentShard.Table))...)
+		return
+	})
+	if nil != err {
+		return ret, err
+	}
+
+	keyArr := action.GetKeyArr()
+	existValueMap := make(map[string]bool)
+	for _, currentRet := range retArr {
+		for _, currentField := range currentRet.FieldArr {
+			valueArr := make([]string, 0, len(keyArr))
+			for _, key := range keyArr {
+				valueArr = append(valueArr, currentField.GetMap()[key])
+			}
+			distinctValue := strings.Join(valueArr, "\x00")
+			if existValueMap[distinctValue] {
+				continue
+			}
+			existValueMap[distinctValue] = true
+			ret.AddField(currentField)
+		}
+	}
+	ret.Len = len(ret.FieldArr)
+	return ret, nil
+}
+
+// 执行语句: 无法解析语句中的表，使用默认连接器
+func (router *Router) Exec(funcArr ...db.RDBUpdateConfigFunc) (ret db.UpdateRet, err error) {
+	if nil == router.defaultConnector {
+		return ret, errorcode.BuildErrorWithMsg(errorcode.NotImplement, "[Router.Exec] exec need default connector")
+	}
+	return router.defaultConnector.Exec(funcArr...)
+}
+
+// 执行查询语句: 无法解析语句中的表，使用默认连接器
+func (router *Router) ExecSearch(funcArr ...db.RDBSearchConfigFunc) (ret db.SearchRet, err error) {
+	if nil == router.defaultConnector {
+		return ret, errorcode.BuildErrorWithMsg(errorcode.NotImplement, "[Router.ExecSearch] exec search need default connector")
+	}
+	return router.defaultConnector.ExecSearch(funcArr...)
+}
+
+// stat: 多个连接器的状态无法合并，需要分别获取
+func (router *Router) Stat() (ret db.DBStat, err error) {
+	return ret, errorcode.BuildErrorWithMsg(errorcode.NotImplement, "[Router.Stat] not implement")
+}
+
+// 关闭所有分片和默认连接器，相同连接器只关闭一次
+func (router *Router) Close() error {
+	connectorArr := make([]db.RDBConnector, 0)
+	if nil != router.defaultConnector {
+		connectorArr = append(connectorArr, router.defaultConnector)
+	}
+	for _, currentRule := range router.ruleMap {
+		for _, currentShard := range currentRule.shardArr {
+			connectorArr = append(connectorArr, currentShard.Connector)
+		}
+	}
+
+	var lastErr error
+	closedMap := make(map[db.RDBConnector]bool)
+	for _, connector := range connectorArr {
+		if closedMap[connector] {
+			continue
+		}
+		closedMap[connector] = true
+		if err := connector.Close(); nil != err {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// 并发访问多个分片，返回第一个失败分片的错误
+func (router *Router) scatter(currentRule *rule, shardIndexArr []int, shardFunc func(int, Shard) error) error {
+	errArr := make([]error, len(shardIndexArr))
+	var wg sync.WaitGroup
+	for index, shardIndex := range shardIndexArr {
+		wg.Add(1)
+		go func(index, shardIndex int) {
+			defer wg.Done()
+			errArr[index] = shardFunc(shardIndex, currentRule.shardArr[shardIndex])
+		}(index, shardIndex)
+	}
+	wg.Wait()
+	for _, err := range errArr {
+		if nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+func (router *Router) buildNoRuleError(spaceName string) error {
+	return errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid, fmt.Sprintf("[Router] space %s has no shard rule", spaceName))
+}
+
+// 在原有配置之后追加配置（复制一份，防止修改调用方的数组）
+func appendSearchFunc(funcArr []db.RDBSearchConfigFunc, toAppendFuncArr ...db.RDBSearchConfigFunc) []db.RDBSearchConfigFunc {
+	return append(append(make([]db.RDBSearchConfigFunc, 0, len(funcArr)+len(toAppendFuncArr)), funcArr...), toAppendFuncArr...)
+}
+
+func appendInsertFunc(funcArr []db.RDBInsertConfigFunc, toAppendFuncArr ...db.RDBInsertConfigFunc) []db.RDBInsertConfigFunc {
+	return append(append(make([]db.RDBInsertConfigFunc, 0, len(funcArr)+len(toAppendFuncArr)), funcArr...), toAppendFuncArr...)
+}
+
+// 合并多个分片的更新结果
+func mergeUpdateRet(retArr []db.UpdateRet) (ret db.UpdateRet) {
+	for _, currentRet := range retArr {
+		ret.AffectedRows += currentRet.AffectedRows
+		ret.ChunkRetArr = append(ret.ChunkRetArr, currentRet.ChunkRetArr...)
+	}
+	return
+}
+
+// 合并多个分片的查询结果: 按排序字段归并，再取出当前页的数据
+func mergeSearchRet(retArr []db.SearchRet, condition db.SearchCondition) (ret db.SearchRet, err error) {
+	type row struct {
+		field         *db.SearchRet
+		fieldIndex    int
+		object        reflect.Value
+		orderValueArr []string
+	}
+	rowArr := make([]row, 0)
+	var objectArrType reflect.Type
+	for retIndex := range retArr {
+		currentRet := &retArr[retIndex]
+		ret.Total += currentRet.Total
+		for fieldIndex, currentField := range currentRet.FieldArr {
+			rowArr = append(rowArr, row{field: currentRet, fieldIndex: fieldIndex, orderValueArr: condition.GetRowOrderValueArr(currentField.GetMap())})
+		}
+		if nil == currentRet.ObjectArr {
+			continue
+		}
+		objectArrValue := reflect.ValueOf(currentRet.ObjectArr)
+		if objectArrValue.Kind() != reflect.Slice {
+			continue
+		}
+		objectArrType = objectArrValue.Type()
+		for index := 0; index < objectArrValue.Len(); index++ {
+			currentRow := row{object: objectArrValue.Index(index)}
+			for _, field := range condition.GetOrderFieldArr() {
+				orderValue, err := db.GetObjectColumnValue(currentRow.object.Interface(), field)
+				if nil != err {
+					return ret, err
+				}
+				currentRow.orderValueArr = append(currentRow.orderValueArr, orderValue)
+			}
+			rowArr = append(rowArr, currentRow)
+		}
+	}
+
+	if condition.Order.Field != "" {
+		sort.SliceStable(rowArr, func(i, j int) bool {
+			return condition.CompareOrderValueArr(rowArr[i].orderValueArr, rowArr[j].orderValueArr) < 0
+		})
+	}
+
+	start, end := 0, len(rowArr)
+	if condition.Page.Limit > 0 {
+		start = condition.Page.No * condition.Page.Limit
+		if start > end {
+			start = end
+		}
+		if start+condition.Page.Limit < end {
+			end = start + condition.Page.Limit
+		}
+	}
+
+	var objectArrValue reflect.Value
+	if nil != objectArrType {
+		objectArrValue = reflect.MakeSlice(objectArrType, 0, end-start)
+	}
+	for _, currentRow := range rowArr[start:end] {
+		if nil != currentRow.field {
+			ret.AddField(currentRow.field.FieldArr[currentRow.fieldIndex])
+		} else {
+			objectArrValue = reflect.Append(objectArrValue, currentRow.object)
+		}
+	}
+	if nil != objectArrType {
+		ret.ObjectArr = objectArrValue.Interface()
+	}
+	ret.Len = end - start
+	return ret, nil
+}
@@ -0,0 +1,161 @@
+package shard
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/smiecj/go_common/db"
+	"github.com/smiecj/go_common/db/local"
+	"github.com/smiecj/go_common/errorcode"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testDB    = "temp_shard"
+	testTable = "orders"
+)
+
+// 测试分片写入、单分片路由、跨分片合并查询
+func TestRouter(t *testing.T) {
+	connector, err := local.GetLocalMemoryConnector()
+	require.Empty(t, err)
+	shardArr := BuildShardArr(testDB, "orders_%02d", 4, connector, connector)
+	require.Equal(t, "orders_03", shardArr[3].Table)
+	router, err := NewRouter(RouterAddRule(testDB, testTable, "user_id", ModShardFunc(4), shardArr))
+	require.Empty(t, err)
+
+	// 写入 8 条数据，user_id 1-8 分别落在 4 个分片上
+	fieldArr := make([]db.RDBInsertConfigFunc, 0)
+	for userId := 1; userId <= 8; userId++ {
+		field := db.BuildNewField()
+		field.AddKeyValue("user_id", strconv.Itoa(userId))
+		field.AddKeyValue("amount", strconv.Itoa(userId*10))
+		fieldArr = append(fieldArr, db.InsertAddField(field))
+	}
+	updateRet, err := router.Insert(append(fieldArr, db.InsertSetSpace(testDB, testTable))...)
+	require.Empty(t, err)
+	require.Equal(t, 8, updateRet.AffectedRows)
+	countRet, err := connector.Count(db.SearchSetSpace(testDB, "orders_01"))
+	require.Empty(t, err)
+	require.Equal(t, 2, countRet.Total)
+
+	// 单分片查询
+	searchRet, err := router.Search(db.SearchSetSpace(testDB, testTable), db.SearchSetCondition("user_id", "=", "5"))
+	require.Empty(t, err)
+	require.Equal(t, 1, searchRet.Len)
+	require.Equal(t, "50", searchRet.FieldArr[0].GetMap()["amount"])
+
+	// 跨分片查询: 合并排序和分页
+	searchRet, err = router.Search(db.SearchSetSpace(testDB, testTable), db.SearchSetCondition("amount", ">", "10"),
+		db.SearchSetOrderFieldAndAsc("amount", "desc"), db.SearchSetPageCondition(3, 3))
+	require.Empty(t, err)
+	require.Equal(t, 7, searchRet.Total)
+	require.Equal(t, 3, searchRet.Len)
+	amountArr := make([]string, 0)
+	for _, currentField := range searchRet.FieldArr {
+		amountArr = append(amountArr, currentField.GetMap()["amount"])
+	}
+	require.Equal(t, []string{"50", "40", "30"}, amountArr)
+
+	// in 条件只访问对应分片
+	countRet, err = router.Count(db.SearchSetSpace(testDB, testTable), db.SearchSetCondition("user_id", "in", "(1, 2, 5)"))
+	require.Empty(t, err)
+	require.Equal(t, 3, countRet.Total)
+	countRet, err = router.Count(db.SearchSetSpace(testDB, testTable))
+	require.Empty(t, err)
+	require.Equal(t, 8, countRet.Total)
+
+	distinctRet, err := router.Distinct(db.SearchSetSpace(testDB, testTable), db.SearchSetKeyArr([]string{"user_id"}),
+		db.SearchSetCondition("amount", "<=", "30"))
+	require.Empty(t, err)
+	require.Equal(t, 3, distinctRet.Len)
+
+	// 更新和删除必须带分片字段
+	updateField := db.BuildNewField()
+	updateField.AddKeyValue("amount", "0")
+	_, err = router.Update(db.UpdateSetSpace(testDB, testTable), db.UpdateAddField(updateField),
+		db.UpdateSetCondition("amount", ">", "10"))
+	require.ErrorIs(t, err, errorcode.BuildError(errorcode.DBParamInvalid))
+	updateRet, err = router.Update(db.UpdateSetSpace(testDB, testTable), db.UpdateAddField(updateField),
+		db.UpdateSetCondition("user_id", "in", "(3, 4)"))
+	require.Empty(t, err)
+	require.Equal(t, 2, updateRet.AffectedRows)
+
+	// 不能修改分片字段，设置成条件中的唯一取值时可以更新
+	keyField := db.BuildNewField()
+	keyField.AddKeyValue("user_id", "5")
+	for _, condition := range [][]string{{"user_id", "=", "4"}, {"user_id", "in", "(4, 5)"}} {
+		_, err = router.Update(db.UpdateSetSpace(testDB, testTable), db.UpdateAddField(keyField), db.UpdateSetCondition(condition...))
+		require.ErrorIs(t, err, errorcode.BuildError(errorcode.DBParamInvalid))
+	}
+	// 结构体中没有分片字段时不拦截，内存连接器不支持结构体更新
+	_, err = router.Update(db.UpdateSetSpace(testDB, testTable), db.UpdateAddObject(testOrder{OrderId: "a", Amount: 1}),
+		db.UpdateSetCondition("user_id", "=", "4"))
+	require.ErrorIs(t, err, errorcode.BuildError(errorcode.NotImplement))
+	_, err = router.Update(db.UpdateSetSpace(testDB, testTable), db.UpdateAddObject(struct {
+		UserId int `json:"user_id"`
+	}{UserId: 5}), db.UpdateSetCondition("user_id", "=", "4"))
+	require.ErrorIs(t, err, errorcode.BuildError(errorcode.DBParamInvalid))
+	updateRet, err = router.Update(db.UpdateSetSpace(testDB, testTable), db.UpdateAddField(keyField), db.UpdateSetCondition("user_id", "=", "5"))
+	require.Empty(t, err)
+	require.Equal(t, 1, updateRet.AffectedRows)
+	countRet, err = router.Count(db.SearchSetSpace(testDB, testTable), db.SearchSetCondition("user_id", "=", "4"))
+	require.Empty(t, err)
+	require.Equal(t, 1, countRet.Total)
+
+	updateRet, err = router.Delete(db.DeleteSetSpace(testDB, testTable), db.DeleteSetCondition("user_id", "=", "3"))
+	require.Empty(t, err)
+	require.Equal(t, 1, updateRet.AffectedRows)
+	countRet, err = router.Count(db.SearchSetSpace(testDB, testTable), db.SearchSetCondition("amount", "=", "0"))
+	require.Empty(t, err)
+	require.Equal(t, 1, countRet.Total)
+
+	// 没有分片规则的表
+	_, err = router.Search(db.SearchSetSpace(testDB, "not_exist"))
+	require.ErrorIs(t, err, errorcode.BuildError(errorcode.DBParamInvalid))
+
+	for _, currentShard := range shardArr {
+		_, err = connector.Delete(db.DeleteSetSpace(currentShard.DB, currentShard.Table))
+		require.Empty(t, err)
+	}
+}
+
+type testOrder struct {
+	OrderId string `json:"order_id"`
+	Amount  int    `json:"amount"`
+}
+
+// 测试结构体数据按照哈希分片
+func TestRouterObject(t *testing.T) {
+	connector, err := local.GetLocalMemoryConnector()
+	require.Empty(t, err)
+	shardArr := BuildShardArr(testDB, "object_orders_%d", 2, connector)
+	router, err := NewRouter(RouterAddRule(testDB, testTable, "order_id", HashShardFunc(2), shardArr))
+	require.Empty(t, err)
+
+	// 内存连接器不支持结构体写入，这里只校验分组之后的错误返回
+	_, err = router.Insert(db.InsertSetSpace(testDB, testTable), db.InsertAddObjectArr([]testOrder{
+		{OrderId: "a", Amount: 1}, {OrderId: "b", Amount: 2}, {OrderId: "c", Amount: 3},
+	}))
+	require.ErrorIs(t, err, errorcode.BuildError(errorcode.NotImplement))
+
+	// 结构体中没有分片字段
+	_, err = router.Insert(db.InsertSetSpace(testDB, testTable), db.InsertAddObjectArr([]struct{ Id int }{{Id: 1}}))
+	require.ErrorIs(t, err, errorcode.BuildError(errorcode.DBParamInvalid))
+}
+
+// 测试分片数量不合法: 不会 panic，返回参数错误
+func TestRouterInvalidShardCount(t *testing.T) {
+	for _, shardFunc := range []ShardFunc{ModShardFunc(0), ModShardFunc(-1), HashShardFunc(0)} {
+		_, err := shardFunc("1")
+		require.True(t, errors.Is(err, errorcode.BuildError(errorcode.DBParamInvalid)))
+	}
+
+	connector, err := local.GetLocalMemoryConnector()
+	require.Empty(t, err)
+	require.Empty(t, BuildShardArr(testDB, "orders_%02d", 0, connector))
+	require.Empty(t, BuildShardArr(testDB, "orders_%02d", -1, connector))
+	_, err = NewRouter(RouterAddRule(testDB, testTable, "user_id", ModShardFunc(0), BuildShardArr(testDB, "orders_%02d", 0, connector)))
+	require.True(t, errors.Is(err, errorcode.BuildError(errorcode.DBParamInvalid)))
+}
@@ -0,0 +1,231 @@
+// package stat 连接池状态采集
+// 定时遍历所有已注册的 connector，将连接池状态通过 util/monitor 上报为 gauge / counter 指标
+package stat
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/smiecj/go_common/db"
+	"github.com/smiecj/go_common/errorcode"
+	"github.com/smiecj/go_common/util/log"
+	"github.com/smiecj/go_common/util/monitor"
+)
+
+const (
+	defaultInterval      = 15 * time.Second
+	defaultMetricsPrefix = "db_pool"
+
+	// 指标标签: connector 注册名称
+	labelConnector = "connector"
+)
+
+// gauge 指标: 当前连接池状态
+var gaugeMetricsMap = map[string]func(db.DBStat) float64{
+	"max_open_connections": func(stat db.DBStat) float64 { return float64(stat.MaxOpenConnections) },
+	"open_connections":     func(stat db.DBStat) float64 { return float64(stat.OpenConnections) },
+	"in_use":               func(stat db.DBStat) float64 { return float64(stat.InUse) },
+	"idle":                 func(stat db.DBStat) float64 { return float64(stat.Idle) },
+}
+
+// counter 指标: 累计值，按照两次采集的差值递增
+var counterMetricsMap = map[string]func(db.DBStat) float64{
+	"wait_count_total":            func(stat db.DBStat) float64 { return float64(stat.WaitCount) },
+	"wait_duration_seconds_total": func(stat db.DBStat) float64 { return stat.WaitDuration.Seconds() },
+	"max_idle_closed_total":       func(stat db.DBStat) float64 { return float64(stat.MaxIdleClosed) },
+	"max_idle_time_closed_total":  func(stat db.DBStat) float64 { return float64(stat.MaxIdleTimeClosed) },
+	"max_lifetime_closed_total":   func(stat db.DBStat) float64 { return float64(stat.MaxLifetimeClosed) },
+}
+
+// 采集器配置
+type collectorConf struct {
+	interval      time.Duration
+	metricsPrefix string
+}
+
+type CollectorConfigFunc func(*collectorConf)
+
+// 设置采集间隔
+func CollectorSetInterval(interval time.Duration) CollectorConfigFunc {
+	return func(conf *collectorConf) {
+		conf.interval = interval
+	}
+}
+
+// 设置指标名称前缀，默认: db_pool
+func CollectorSetMetricsPrefix(prefix string) CollectorConfigFunc {
+	return func(conf *collectorConf) {
+		conf.metricsPrefix = prefix
+	}
+}
+
+// 连接池状态采集器
+type Collector struct {
+	manager monitor.Manager
+	conf    *collectorConf
+
+	// 上次采集的 counter 值: connector 名称 -> 指标名称 -> 值
+	lastCounterMap map[string]map[string]float64
+	lock           sync.Mutex
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	startOnce sync.Once
+	stopOnce  sync.Once
+}
+
+// 创建采集器，并在 monitor manager 中注册指标
+func NewCollector(manager monitor.Manager, funcArr ...CollectorConfigFunc) (*Collector, error) {
+	conf := &collectorConf{
+		interval:      defaultInterval,
+		metricsPrefix: defaultMetricsPrefix,
+	}
+	for _, currentFunc := range funcArr {
+		currentFunc(conf)
+	}
+	if conf.interval <= 0 {
+		return nil, errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid, "collector interval must be positive")
+	}
+
+	collector := &Collector{
+		manager:        manager,
+		conf:           conf,
+		lastCounterMap: make(map[string]map[string]float64),
+	}
+	collector.ctx, collector.cancel = context.WithCancel(context.Background())
+
+	for name := range gaugeMetricsMap {
+		if err := collector.addMetrics(monitor.Gauge, name); nil != err {
+			return nil, err
+		}
+	}
+	for name := range counterMetricsMap {
+		if err := collector.addMetrics(monitor.Counter, name); nil != err {
+			return nil, err
+		}
+	}
+	return collector, nil
+}
+
+// 注册指标，已经存在的指标（如多个采集器共用一个 manager）直接复用
+func (collector *Collector) addMetrics(t monitor.MetricsType, name string) error {
+	metricsName := collector.metricsName(name)
+	err := collector.manager.AddMetrics(monitor.NewMonitorMetrics(t, metricsName,
+		"db connection pool stat: "+name, monitor.LabelKey{labelConnector}))
+	if nil != err && !errors.Is(err, errorcode.BuildError(errorcode.MonitorMetricsExists)) {
+		log.Error("[Collector.addMetrics] add metrics %s failed: %s", metricsName, err.Error())
+		return err
+	}
+	return nil
+}
+
+func (collector *Collector) metricsName(name string) string {
+	return collector.conf.metricsPrefix + "_" + name
+}
+
+// 启动定时采集
+func (collector *Collector) Start() error {
+	collector.startOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(collector.conf.interval)
+			defer ticker.Stop()
+			for {
+				collector.Collect()
+				select {
+				case <-collector.ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	})
+	return nil
+}
+
+// 停止定时采集
+func (collector *Collector) Stop() error {
+	collector.stopOnce.Do(func() {
+		collector.cancel()
+	})
+	return nil
+}
+
+// 采集一次所有已注册 connector 的连接池状态
+// 不支持 Stat 的 connector 会被跳过
+func (collector *Collector) Collect() {
+	collector.lock.Lock()
+	defer collector.lock.Unlock()
+
+	connectorMap := db.GetRegisteredConnectorMap()
+	for connectorName, connector := range connectorMap {
+		stat, err := connector.Stat()
+		if nil != err {
+			continue
+		}
+		label := monitor.MetricsLabel{labelConnector: connectorName}
+
+		for name, valueFunc := range gaugeMetricsMap {
+			metrics, err := collector.manager.GetMetrics(collector.metricsName(name))
+			if nil != err {
+				continue
+			}
+			if gauge, ok := metrics.(*monitor.PrometheusGauge); ok {
+				gauge.With(label).Set(valueFunc(stat))
+			}
+		}
+
+		lastCounterMap := collector.lastCounterMap[connectorName]
+		if nil == lastCounterMap {
+			lastCounterMap = make(map[string]float64)
+			collector.lastCounterMap[connectorName] = lastCounterMap
+		}
+		for name, valueFunc := range counterMetricsMap {
+			current := valueFunc(stat)
+			delta := current - lastCounterMap[name]
+			// 累计值变小: connector 重新创建过，从 0 开始计算
+			if delta < 0 {
+				delta = current
+			}
+			lastCounterMap[name] = current
+			if delta == 0 {
+				continue
+			}
+			metrics, err := collector.manager.GetMetrics(collector.metricsName(name))
+			if nil != err {
+				continue
+			}
+			if counter, ok := metrics.(*monitor.PrometheusCounter); ok {
+				counter.With(label).Add(delta)
+			}
+		}
+	}
+
+	// 清理已经取消注册的 connector，包括已经上报的指标
+	for connectorName := range collector.lastCounterMap {
+		if _, ok := connectorMap[connectorName]; !ok {
+			delete(collector.lastCounterMap, connectorName)
+			collector.deleteMetrics(connectorName)
+		}
+	}
+}
+
+// 删除 connector 已经上报的 gauge / counter 指标
+func (collector *Collector) deleteMetrics(connectorName string) {
+	label := monitor.MetricsLabel{labelConnector: connectorName}
+	for name := range gaugeMetricsMap {
+		if metrics, err := collector.manager.GetMetrics(collector.metricsName(name)); nil == err {
+			if gauge, ok := metrics.(*monitor.PrometheusGauge); ok {
+				gauge.With(label).Delete()
+			}
+		}
+	}
+	for name := range counterMetricsMap {
+		if metrics, err := collector.manager.GetMetrics(collector.metricsName(name)); nil == err {
+			if counter, ok := metrics.(*monitor.PrometheusCounter); ok {
+				counter.With(label).Delete()
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package stat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	yamlconfig "github.com/smiecj/go_common/config/yaml"
+	"github.com/smiecj/go_common/db"
+	"github.com/smiecj/go_common/util/file"
+	"github.com/smiecj/go_common/util/monitor"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	exampleConfigFile = "conf_example.yaml"
+	testConnectorName = "test_stat_connector"
+)
+
+// 仅实现 Stat 方法的 connector
+type statConnector struct {
+	db.RDBConnector
+	stat db.DBStat
+}
+
+func (connector *statConnector) Stat() (db.DBStat, error) {
+	return connector.stat, nil
+}
+
+// 从 prometheus 默认注册表中获取 connector 的指标值，没有上报时返回 false
+func getMetricsValue(t *testing.T, name, connectorName string) (float64, bool) {
+	familyArr, err := prometheus.DefaultGatherer.Gather()
+	require.Empty(t, err)
+	for _, family := range familyArr {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() != labelConnector || label.GetValue() != connectorName {
+					continue
+				}
+				if nil != metric.GetGauge() {
+					return metric.GetGauge().GetValue(), true
+				}
+				return metric.GetCounter().GetValue(), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// 测试连接池状态采集: gauge 为当前值，counter 按差值递增，connector 取消注册后删除指标
+func TestCollector(t *testing.T) {
+	configManager, err := yamlconfig.GetYamlConfigManager(file.FindFilePath(exampleConfigFile))
+	require.Empty(t, err)
+	collector, err := NewCollector(monitor.GetPrometheusMonitorManager(configManager), CollectorSetInterval(time.Second))
+	require.Empty(t, err)
+
+	connector := &statConnector{stat: db.DBStat{MaxOpenConnections: 10, OpenConnections: 2, InUse: 1, Idle: 1,
+		WaitCount: 3, WaitDuration: time.Second}}
+	connectorName := db.RegisterConnector(testConnectorName, connector)
+	collector.Collect()
+	for name, expectValue := range map[string]float64{
+		"db_pool_max_open_connections":        10,
+		"db_pool_in_use":                      1,
+		"db_pool_wait_count_total":            3,
+		"db_pool_wait_duration_seconds_total": 1,
+	} {
+		value, ok := getMetricsValue(t, name, connectorName)
+		require.True(t, ok, name)
+		require.Equal(t, expectValue, value, name)
+	}
+
+	// counter 只增加两次采集的差值，gauge 直接设置
+	connector.stat.WaitCount = 5
+	connector.stat.InUse = 2
+	collector.Collect()
+	waitCount, _ := getMetricsValue(t, "db_pool_wait_count_total", connectorName)
+	require.Equal(t, float64(5), waitCount)
+	inUse, _ := getMetricsValue(t, "db_pool_in_use", connectorName)
+	require.Equal(t, float64(2), inUse)
+
+	db.UnregisterConnector(connectorName)
+	collector.Collect()
+	_, ok := getMetricsValue(t, "db_pool_in_use", connectorName)
+	require.False(t, ok)
+	_, ok = getMetricsValue(t, "db_pool_wait_count_total", connectorName)
+	require.False(t, ok)
+}
@@ -0,0 +1,286 @@
+// package transfer 跨连接器的数据复制工具
+// 通过源连接器的 Search 分页读取数据，再通过目标连接器的 Insert 批量写入，支持任意 RDBConnector 之间复制
+package transfer
+
+import (
+	"strconv"
+
+	"github.com/smiecj/go_common/db"
+	"github.com/smiecj/go_common/errorcode"
+	"github.com/smiecj/go_common/util/log"
+)
+
+const (
+	// 默认每批复制的数据量
+	defaultBatch = 1000
+)
+
+// 复制进度
+type CopyProgress struct {
+	// 源表已经读取到的位置，也是下次继续复制的起始位置
+	Offset int
+	// 本次复制已经写入目标表的数据量
+	Copied int
+	// 源表满足条件的数据总量，源连接器不支持 Count 时为 -1
+	Total int
+}
+
+// 复制配置
+type copyConf struct {
+	sourceDB      string
+	sourceTable   string
+	targetDB      string
+	targetTable   string
+	conditionArr  []string
+	keyArr        []string
+	orderField    string
+	columnMap     map[string]string
+	object        interface{}
+	objectArrType interface{}
+	batch         int
+	progressFunc  func(CopyProgress)
+	checkpoint    db.Checkpoint
+}
+
+// 复制配置方法定义
+type CopyConfigFunc func(*copyConf)
+
+// 设置源表
+func CopySetSourceSpace(dbName, table string) CopyConfigFunc {
+	return func(conf *copyConf) {
+		conf.sourceDB, conf.sourceTable = dbName, table
+	}
+}
+
+// 设置目标表
+func CopySetTargetSpace(dbName, table string) CopyConfigFunc {
+	return func(conf *copyConf) {
+		conf.targetDB, conf.targetTable = dbName, table
+	}
+}
+
+// 设置源表过滤条件，格式和 db.SearchSetCondition 一致
+func CopySetCondition(args ...string) CopyConfigFunc {
+	return func(conf *copyConf) {
+		conf.conditionArr = args
+	}
+}
+
+// 设置需要复制的字段
+func CopySetKeyArr(keyArr []string) CopyConfigFunc {
+	return func(conf *copyConf) {
+		conf.keyArr = append(conf.keyArr, keyArr...)
+	}
+}
+
+// 设置排序字段，分页读取的时候保证顺序稳定，断点续传时建议设置（如自增 id）
+func CopySetOrderField(field string) CopyConfigFunc {
+	return func(conf *copyConf) {
+		conf.orderField = field
+	}
+}
+
+// 设置字段映射: 源表字段名 -> 目标表字段名，没有设置映射的字段保持原名
+// 注意: 仅支持 key-value 格式的数据，和 CopySetObject 一起设置时复制失败
+func CopySetColumnMap(columnMap map[string]string) CopyConfigFunc {
+	return func(conf *copyConf) {
+		if nil == conf.columnMap {
+			conf.columnMap = make(map[string]string)
+		}
+		for sourceColumn, targetColumn := range columnMap {
+			conf.columnMap[sourceColumn] = targetColumn
+		}
+	}
+}
+
+// 设置按结构体复制: 源表查询结果转换成结构体数组，再整体写入目标表
+// object: 结构体类型, objectArr: 结构体数组类型
+func CopySetObject(object, objectArr interface{}) CopyConfigFunc {
+	return func(conf *copyConf) {
+		conf.object, conf.objectArrType = object, objectArr
+	}
+}
+
+// 设置每批读取和写入的数据量
+// 注意: 本地文件连接器追加写入时会重写整个文件，目标为本地文件时每批的数据量需要设置得足够大
+func CopySetBatch(batch int) CopyConfigFunc {
+	return func(conf *copyConf) {
+		conf.batch = batch
+	}
+}
+
+// 设置进度回调，每批数据写入成功后调用
+func CopySetProgressFunc(progressFunc func(CopyProgress)) CopyConfigFunc {
+	return func(conf *copyConf) {
+		conf.progressFunc = progressFunc
+	}
+}
+
+// 设置断点存储: 开始复制时从断点位置继续，每批数据写入成功后更新断点
+func CopySetCheckpoint(checkpoint db.Checkpoint) CopyConfigFunc {
+	return func(conf *copyConf) {
+		conf.checkpoint = checkpoint
+	}
+}
+
+// 检查复制配置
+func (conf *copyConf) check() error {
+	if conf.sourceTable == "" || conf.targetTable == "" {
+		return errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid, "source and target space must be set")
+	}
+	if nil != conf.objectArrType && len(conf.columnMap) != 0 {
+		return errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid, "column map not support copy by object")
+	}
+	if conf.batch <= 0 {
+		conf.batch = defaultBatch
+	}
+	return nil
+}
+
+// 源表查询条件
+func (conf *copyConf) searchFuncArr() []db.RDBSearchConfigFunc {
+	funcArr := []db.RDBSearchConfigFunc{db.SearchSetSpace(conf.sourceDB, conf.sourceTable)}
+	if len(conf.conditionArr) != 0 {
+		funcArr = append(funcArr, db.SearchSetCondition(conf.conditionArr...))
+	}
+	if len(conf.keyArr) != 0 {
+		funcArr = append(funcArr, db.SearchSetKeyArr(conf.keyArr))
+	}
+	if conf.orderField != "" {
+		funcArr = append(funcArr, db.SearchSetOrderField(conf.orderField))
+	}
+	if nil != conf.objectArrType {
+		funcArr = append(funcArr, db.SearchSetObject(conf.object), db.SearchSetObjectArrType(conf.objectArrType))
+	}
+	return funcArr
+}
+
+// 数据复制入口: 从 source 分页读取数据写入 target
+// 返回的进度中 Offset 可用于下次继续复制，写入失败时断点停留在最后一个成功的批次
+func Copy(source, target db.RDBConnector, funcArr ...CopyConfigFunc) (progress CopyProgress, err error) {
+	conf := new(copyConf)
+	for _, currentFunc := range funcArr {
+		currentFunc(conf)
+	}
+	if err = conf.check(); nil != err {
+		return
+	}
+
+	if nil != conf.checkpoint {
+		offsetStr, loadErr := conf.checkpoint.Load()
+		if nil != loadErr {
+			return progress, loadErr
+		}
+		if offsetStr != "" {
+			progress.Offset, err = strconv.Atoi(offsetStr)
+			if nil != err {
+				return progress, errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid, "checkpoint invalid: "+offsetStr)
+			}
+		}
+	}
+	progress.Total = -1
+	if countRet, countErr := source.Count(conf.searchFuncArr()...); nil == countErr {
+		progress.Total = countRet.Total
+	}
+
+	searchFuncArr := conf.searchFuncArr()
+	var previousRet db.SearchRet
+	for page := 0; ; page++ {
+		// 已知数据总量时，复制到总量即结束
+		if progress.Total >= 0 && progress.Offset >= progress.Total {
+			break
+		}
+
+		// 分页接口只能按页号查询: 断点位置没有和每批数据量对齐时，查询断点所在的页并跳过已经复制的部分
+		pageStart := progress.Offset - progress.Offset%conf.batch
+		searchRet, searchErr := source.Search(append(searchFuncArr, db.SearchSetPageCondition(pageStart, conf.batch))...)
+		if nil != searchErr {
+			log.Error("[transfer.Copy] search source failed, offset: %d, err: %s", progress.Offset, searchErr.Error())
+			return progress, searchErr
+		}
+
+		// 数据总量未知，并且和上一页的数据完全相同: 源连接器忽略了分页条件，数据已经复制完成
+		if progress.Total < 0 && page > 0 && searchRet.IsRowEqual(previousRet) {
+			break
+		}
+		previousRet = searchRet
+
+		pageLen := searchRet.RowLen()
+		// 源连接器不支持分页时（返回数据量超过一批）: 跳过已经复制的部分，复制剩余的全部数据后结束
+		ignorePage := pageLen > conf.batch
+		rows := searchRet
+		if ignorePage {
+			rows = rows.SliceRow(minInt(progress.Offset, pageLen), pageLen)
+		} else {
+			rows = rows.SliceRow(minInt(progress.Offset-pageStart, pageLen), pageLen)
+		}
+
+		for start := 0; start < rows.RowLen(); start += conf.batch {
+			end := minInt(start+conf.batch, rows.RowLen())
+			insertRet, insertErr := target.Insert(append(conf.insertFuncArr(), conf.rowInsertFuncArr(rows.SliceRow(start, end))...)...)
+			if nil != insertErr {
+				log.Error("[transfer.Copy] insert target failed, offset: %d, err: %s", progress.Offset, insertErr.Error())
+				return progress, insertErr
+			}
+
+			progress.Offset += end - start
+			progress.Copied += insertRet.AffectedRows
+			if nil != conf.checkpoint {
+				if err = conf.checkpoint.Save(strconv.Itoa(progress.Offset)); nil != err {
+					return progress, err
+				}
+			}
+			if nil != conf.progressFunc {
+				conf.progressFunc(progress)
+			}
+		}
+
+		if ignorePage || pageLen < conf.batch {
+			break
+		}
+	}
+
+	log.Info("[transfer.Copy] copy %s.%s -> %s.%s finish, copied rows: %d", conf.sourceDB, conf.sourceTable,
+		conf.targetDB, conf.targetTable, progress.Copied)
+	return progress, nil
+}
+
+// 目标表公共写入配置
+func (conf *copyConf) insertFuncArr() []db.RDBInsertConfigFunc {
+	funcArr := []db.RDBInsertConfigFunc{db.InsertSetSpace(conf.targetDB, conf.targetTable),
+		db.InsertBatch(conf.batch), db.InsertAppend()}
+	if nil != conf.objectArrType && len(conf.keyArr) != 0 {
+		funcArr = append(funcArr, db.InsertAddKeyArr(conf.keyArr))
+	}
+	return funcArr
+}
+
+// 查询结果转换成目标表写入配置，key-value 格式的数据会按照字段映射重命名
+func (conf *copyConf) rowInsertFuncArr(rows db.SearchRet) []db.RDBInsertConfigFunc {
+	if nil != rows.ObjectArr {
+		return []db.RDBInsertConfigFunc{db.InsertAddObjectArr(rows.ObjectArr)}
+	}
+	if len(conf.columnMap) == 0 {
+		return []db.RDBInsertConfigFunc{db.InsertAddFieldArr(rows.FieldArr)}
+	}
+
+	mappedFieldArr := rows.FieldArr[:0:0]
+	for _, currentField := range rows.FieldArr {
+		mappedField := db.BuildNewField()
+		for key, value := range currentField.GetMap() {
+			if targetKey, ok := conf.columnMap[key]; ok {
+				key = targetKey
+			}
+			mappedField.AddKeyValue(key, value)
+		}
+		mappedFieldArr = append(mappedFieldArr, mappedField)
+	}
+	return []db.RDBInsertConfigFunc{db.InsertAddFieldArr(mappedFieldArr)}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,142 @@
+package transfer
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/smiecj/go_common/db"
+	"github.com/smiecj/go_common/db/dbtest"
+	"github.com/smiecj/go_common/db/local"
+	"github.com/smiecj/go_common/errorcode"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testDBName      = "test_db"
+	testSourceTable = "test_source"
+	testTargetTable = "test_target"
+	testRowCount    = 5
+)
+
+// 测试本地文件之间的数据复制: 字段映射、进度回调、断点续传
+func TestCopy(t *testing.T) {
+	folderPath, err := ioutil.TempDir("", "transfer")
+	require.Nil(t, err)
+	defer os.RemoveAll(folderPath)
+
+	connector, err := local.GetLocalFileConnector(folderPath)
+	require.Nil(t, err)
+	insertFuncArr := []db.RDBInsertConfigFunc{db.InsertSetSpace(testDBName, testSourceTable)}
+	for index := 0; index < testRowCount; index++ {
+		field := db.BuildNewField()
+		field.AddKeyValue("name", "student-"+strconv.Itoa(index))
+		insertFuncArr = append(insertFuncArr, db.InsertAddField(field))
+	}
+	_, err = connector.Insert(insertFuncArr...)
+	require.Nil(t, err)
+
+	// 模拟之前已经复制过两条数据
+	checkpoint, err := db.NewFileCheckpoint(filepath.Join(folderPath, "checkpoint"))
+	require.Nil(t, err)
+	require.Nil(t, checkpoint.Save("2"))
+
+	progressArr := make([]CopyProgress, 0)
+	progress, err := Copy(connector, connector,
+		CopySetSourceSpace(testDBName, testSourceTable),
+		CopySetTargetSpace(testDBName, testTargetTable),
+		CopySetColumnMap(map[string]string{"name": "student_name"}),
+		CopySetBatch(2),
+		CopySetCheckpoint(checkpoint),
+		CopySetProgressFunc(func(progress CopyProgress) {
+			progressArr = append(progressArr, progress)
+		}))
+	require.Nil(t, err)
+	require.Equal(t, testRowCount, progress.Offset)
+	require.Equal(t, testRowCount-2, progress.Copied)
+	require.Equal(t, testRowCount, progress.Total)
+	require.Equal(t, 2, len(progressArr))
+
+	offset, err := checkpoint.Load()
+	require.Nil(t, err)
+	require.Equal(t, strconv.Itoa(testRowCount), offset)
+
+	searchRet, err := connector.Search(db.SearchSetSpace(testDBName, testTargetTable))
+	require.Nil(t, err)
+	require.Equal(t, testRowCount-2, searchRet.Len)
+	require.Equal(t, "student-2", searchRet.FieldArr[0].GetMap()["student_name"])
+
+	// 已经复制完成，再次复制不会写入数据
+	progress, err = Copy(connector, connector,
+		CopySetSourceSpace(testDBName, testSourceTable),
+		CopySetTargetSpace(testDBName, testTargetTable),
+		CopySetBatch(2),
+		CopySetCheckpoint(checkpoint))
+	require.Nil(t, err)
+	require.Equal(t, 0, progress.Copied)
+
+	// 按结构体复制时不支持字段映射
+	_, err = Copy(connector, connector,
+		CopySetSourceSpace(testDBName, testSourceTable),
+		CopySetTargetSpace(testDBName, testTargetTable),
+		CopySetObject(copyObject{}, []*copyObject{}),
+		CopySetColumnMap(map[string]string{"name": "student_name"}))
+	require.True(t, errors.Is(err, errorcode.BuildError(errorcode.DBParamInvalid)))
+}
+
+type copyObject struct {
+	Name string `json:"name"`
+}
+
+// 测试源连接器忽略分页条件，并且数据量正好为一批时，复制能够结束
+func TestCopyIgnorePage(t *testing.T) {
+	// 数据总量未知: 通过和上一页数据对比判断分页被忽略
+	source := dbtest.NewMockConnector()
+	source.Expect(dbtest.MethodCount).ReturnError(errorcode.BuildError(errorcode.DBExecFailed))
+	source.Expect(dbtest.MethodSearch).AnyTimes().ReturnRows(
+		map[string]string{"name": "student-0"}, map[string]string{"name": "student-1"})
+	target := dbtest.NewMockConnector()
+	target.Expect(dbtest.MethodInsert).Times(1).ReturnAffectedRows(2)
+
+	progress, err := Copy(source, target,
+		CopySetSourceSpace(testDBName, testSourceTable),
+		CopySetTargetSpace(testDBName, testTargetTable),
+		CopySetBatch(2))
+	require.Nil(t, err)
+	require.Equal(t, 2, progress.Offset)
+	require.Equal(t, 2, progress.Copied)
+	require.Equal(t, -1, progress.Total)
+	require.Nil(t, target.ExpectationsWereMet())
+
+	// 数据总量已知: 本地文件连接器不支持分页，复制到总量即结束
+	folderPath, err := ioutil.TempDir("", "transfer")
+	require.Nil(t, err)
+	defer os.RemoveAll(folderPath)
+
+	connector, err := local.GetLocalFileConnector(folderPath)
+	require.Nil(t, err)
+	insertFuncArr := []db.RDBInsertConfigFunc{db.InsertSetSpace(testDBName, testSourceTable)}
+	for index := 0; index < 2; index++ {
+		field := db.BuildNewField()
+		field.AddKeyValue("name", "student-"+strconv.Itoa(index))
+		insertFuncArr = append(insertFuncArr, db.InsertAddField(field))
+	}
+	_, err = connector.Insert(insertFuncArr...)
+	require.Nil(t, err)
+
+	progress, err = Copy(connector, connector,
+		CopySetSourceSpace(testDBName, testSourceTable),
+		CopySetTargetSpace(testDBName, testTargetTable),
+		CopySetBatch(2))
+	require.Nil(t, err)
+	require.Equal(t, 2, progress.Offset)
+	require.Equal(t, 2, progress.Copied)
+	require.Equal(t, 2, progress.Total)
+
+	searchRet, err := connector.Search(db.SearchSetSpace(testDBName, testTargetTable))
+	require.Nil(t, err)
+	require.Equal(t, 2, searchRet.Len)
+}
@@ -0,0 +1,364 @@
+// package watcher 表数据变更监听（轻量级 CDC）
+// 按照单调递增的字段（更新时间 或 自增 id）定时查询新增 / 变更的数据，并持久化水位线
+// 投递语义: at-least-once，数据投递成功之后才会更新水位线，程序重启后可能会重复投递最后一批数据
+// 分页方式: 按照 (水位线字段, 主键字段) 排序，每批从上一批最后一条数据之后开始查询（keyset），不使用 OFFSET
+package watcher
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smiecj/go_common/db"
+	"github.com/smiecj/go_common/errorcode"
+	"github.com/smiecj/go_common/util/log"
+)
+
+// 水位线字段类型
+type WatermarkType int
+
+const (
+	// 时间戳字段（如 update_time）: 需要设置主键字段，时间戳相同的数据按照主键继续查询，不会遗漏
+	WatermarkTimestamp WatermarkType = iota
+	// 自增 id 字段: 查询 > 水位线的数据
+	WatermarkId
+)
+
+const (
+	defaultBatch    = 1000
+	defaultInterval = time.Minute
+	// 水位线存储格式: 水位线字段取值 + 分隔符 + 主键取值
+	watermarkSeparator = "\n"
+)
+
+// 监听配置
+type watcherConf struct {
+	dbName           string
+	table            string
+	column           string
+	watermarkType    WatermarkType
+	keyColumn        string
+	conditionArr     []string
+	keyArr           []string
+	object           interface{}
+	objectArrType    interface{}
+	batch            int
+	interval         time.Duration
+	checkpoint       db.Checkpoint
+	initialWatermark string
+	handler          func(db.SearchRet) error
+}
+
+type WatcherConfigFunc func(*watcherConf)
+
+// 设置监听的表
+func WatcherSetSpace(dbName, table string) WatcherConfigFunc {
+	return func(conf *watcherConf) {
+		conf.dbName, conf.table = dbName, table
+	}
+}
+
+// 设置水位线字段及类型
+func WatcherSetColumn(column string, watermarkType WatermarkType) WatcherConfigFunc {
+	return func(conf *watcherConf) {
+		conf.column, conf.watermarkType = column, watermarkType
+	}
+}
+
+// 设置主键字段: 和水位线字段一起排序和分页，时间戳水位线必须设置
+func WatcherSetKeyColumn(keyColumn string) WatcherConfigFunc {
+	return func(conf *watcherConf) {
+		conf.keyColumn = keyColumn
+	}
+}
+
+// 设置额外的过滤条件，格式和 db.SearchSetCondition 一致
+func WatcherSetCondition(args ...string) WatcherConfigFunc {
+	return func(conf *watcherConf) {
+		conf.conditionArr = args
+	}
+}
+
+// 设置需要查询的字段，需要包含水位线字段和主键字段
+func WatcherSetKeyArr(keyArr []string) WatcherConfigFunc {
+	return func(conf *watcherConf) {
+		conf.keyArr = append(conf.keyArr, keyArr...)
+	}
+}
+
+// 设置查询结果转换成结构体数组
+// object: 结构体类型, objectArr: 结构体数组类型
+func WatcherSetObject(object, objectArr interface{}) WatcherConfigFunc {
+	return func(conf *watcherConf) {
+		conf.object, conf.objectArrType = object, objectArr
+	}
+}
+
+// 设置每批查询的数据量
+func WatcherSetBatch(batch int) WatcherConfigFunc {
+	return func(conf *watcherConf) {
+		conf.batch = batch
+	}
+}
+
+// 设置查询间隔
+func WatcherSetInterval(interval time.Duration) WatcherConfigFunc {
+	return func(conf *watcherConf) {
+		conf.interval = interval
+	}
+}
+
+// 设置水位线存储，可以是本地文件（db.NewFileCheckpoint）或数据库（db.NewConnectorCheckpoint）
+func WatcherSetCheckpoint(checkpoint db.Checkpoint) WatcherConfigFunc {
+	return func(conf *watcherConf) {
+		conf.checkpoint = checkpoint
+	}
+}
+
+// 设置初始水位线，水位线存储中没有记录时使用，不设置则从头开始查询
+func WatcherSetInitialWatermark(watermark string) WatcherConfigFunc {
+	return func(conf *watcherConf) {
+		conf.initialWatermark = watermark
+	}
+}
+
+// 设置数据处理回调: 返回错误时不更新水位线，下次查询会重新投递
+// 不设置回调时，数据通过 Changes 返回的 channel 投递
+func WatcherSetHandler(handler func(db.SearchRet) error) WatcherConfigFunc {
+	return func(conf *watcherConf) {
+		conf.handler = handler
+	}
+}
+
+// 检查监听配置
+func (conf *watcherConf) check() error {
+	if conf.table == "" || conf.column == "" {
+		return errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid, "space and watermark column must be set")
+	}
+	if conf.watermarkType == WatermarkTimestamp && conf.keyColumn == "" {
+		return errorcode.BuildErrorWithMsg(errorcode.DBParamInvalid, "key column must be set for timestamp watermark")
+	}
+	if conf.batch <= 0 {
+		conf.batch = defaultBatch
+	}
+	if conf.interval <= 0 {
+		conf.interval = defaultInterval
+	}
+	return nil
+}
+
+// 变更监听器
+type Watcher struct {
+	connector db.RDBConnector
+	conf      *watcherConf
+	logger    log.Logger
+
+	// 当前水位线，以及水位线位置最后一条已投递数据的主键
+	watermark    string
+	watermarkKey string
+	lock         sync.Mutex
+
+	changeChan chan db.SearchRet
+	errorChan  chan error
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	startOnce sync.Once
+	stopOnce  sync.Once
+}
+
+// 创建变更监听器，会从水位线存储中读取上次的水位线
+func NewWatcher(connector db.RDBConnector, funcArr ...WatcherConfigFunc) (*Watcher, error) {
+	conf := new(watcherConf)
+	for _, currentFunc := range funcArr {
+		currentFunc(conf)
+	}
+	if err := conf.check(); nil != err {
+		return nil, err
+	}
+
+	watcher := &Watcher{
+		connector:  connector,
+		conf:       conf,
+		logger:     log.PrefixLogger("watcher"),
+		watermark:  conf.initialWatermark,
+		changeChan: make(chan db.SearchRet),
+		errorChan:  make(chan error, 1),
+	}
+	watcher.ctx, watcher.cancel = context.WithCancel(context.Background())
+
+	if nil != conf.checkpoint {
+		watermark, err := conf.checkpoint.Load()
+		if nil != err {
+			return nil, err
+		}
+		if watermark != "" {
+			watcher.watermark, watcher.watermarkKey = parseWatermark(watermark)
+		}
+	}
+	return watcher, nil
+}
+
+// 变更数据 channel，没有设置回调时使用，停止监听后关闭
+func (watcher *Watcher) Changes() <-chan db.SearchRet {
+	return watcher.changeChan
+}
+
+// 查询或投递失败的错误信息，未及时消费的错误会被丢弃（仍会打印日志）
+func (watcher *Watcher) Error() <-chan error {
+	return watcher.errorChan
+}
+
+// 当前水位线
+func (watcher *Watcher) Watermark() string {
+	watcher.lock.Lock()
+	defer watcher.lock.Unlock()
+	return watcher.watermark
+}
+
+// 启动定时查询
+func (watcher *Watcher) Start() error {
+	watcher.startOnce.Do(func() {
+		go func() {
+			defer close(watcher.changeChan)
+			ticker := time.NewTicker(watcher.conf.interval)
+			defer ticker.Stop()
+			for {
+				if err := watcher.Poll(); nil != err {
+					select {
+					case watcher.errorChan <- err:
+					default:
+					}
+				}
+				select {
+				case <-watcher.ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	})
+	return nil
+}
+
+// 停止定时查询
+func (watcher *Watcher) Stop() error {
+	watcher.stopOnce.Do(func() {
+		watcher.cancel()
+	})
+	return nil
+}
+
+// 查询一次水位线之后的所有数据并投递，每批数据投递成功后更新水位线
+func (watcher *Watcher) Poll() error {
+	watcher.lock.Lock()
+	defer watcher.lock.Unlock()
+
+	conf := watcher.conf
+	for {
+		searchRet, err := watcher.connector.Search(watcher.searchFuncArr(watcher.watermark, watcher.watermarkKey)...)
+		if nil != err {
+			watcher.logger.Error("[Watcher.Poll] search %s failed: %s", conf.table, err.Error())
+			return err
+		}
+
+		rowLen := searchRet.RowLen()
+		if rowLen == 0 {
+			return nil
+		}
+		// 查询结果按照 (水位线字段, 主键字段) 升序，最后一条数据即新的水位线
+		nextWatermark, err := searchRet.RowColumnValue(rowLen-1, conf.column)
+		if nil != err {
+			watcher.logger.Error("[Watcher.Poll] get watermark failed: %s", err.Error())
+			return err
+		}
+		nextWatermarkKey := ""
+		if conf.keyColumn != "" {
+			if nextWatermarkKey, err = searchRet.RowColumnValue(rowLen-1, conf.keyColumn); nil != err {
+				watcher.logger.Error("[Watcher.Poll] get watermark key failed: %s", err.Error())
+				return err
+			}
+		}
+
+		if err = watcher.deliver(searchRet.SliceRow(0, rowLen)); nil != err {
+			return err
+		}
+		if nil != conf.checkpoint {
+			if err = conf.checkpoint.Save(formatWatermark(nextWatermark, nextWatermarkKey)); nil != err {
+				watcher.logger.Error("[Watcher.Poll] save watermark failed: %s", err.Error())
+				return err
+			}
+		}
+		watcher.watermark, watcher.watermarkKey = nextWatermark, nextWatermarkKey
+
+		if rowLen < conf.batch {
+			return nil
+		}
+	}
+}
+
+// 查询条件: 额外条件 + 水位线之后的数据，按照 (水位线字段, 主键字段) 升序，每次查询一批
+// 有主键时: column > watermark or (column = watermark and key > watermarkKey)
+func (watcher *Watcher) searchFuncArr(watermark, watermarkKey string) []db.RDBSearchConfigFunc {
+	conf := watcher.conf
+	funcArr := []db.RDBSearchConfigFunc{db.SearchSetSpace(conf.dbName, conf.table),
+		db.SearchSetOrderField(conf.column), db.SearchSetLimit(conf.batch)}
+	if conf.keyColumn != "" {
+		funcArr = append(funcArr, db.SearchAddOrderField(conf.keyColumn))
+	}
+	if len(conf.conditionArr) != 0 {
+		funcArr = append(funcArr, db.SearchSetCondition(conf.conditionArr...))
+	}
+	if watermark != "" {
+		switch {
+		case conf.keyColumn != "" && watermarkKey != "":
+			funcArr = append(funcArr, db.SearchAddCondition(conf.column, ">", watermark,
+				"or", conf.column, "=", watermark, "and", conf.keyColumn, ">", watermarkKey))
+		case conf.watermarkType == WatermarkId:
+			funcArr = append(funcArr, db.SearchAddCondition(conf.column, ">", watermark))
+		default:
+			// 水位线存储中没有主键（如初始水位线）: 包含水位线位置的数据，可能重复投递
+			funcArr = append(funcArr, db.SearchAddCondition(conf.column, ">=", watermark))
+		}
+	}
+	if len(conf.keyArr) != 0 {
+		funcArr = append(funcArr, db.SearchSetKeyArr(conf.keyArr))
+	}
+	if nil != conf.objectArrType {
+		funcArr = append(funcArr, db.SearchSetObject(conf.object), db.SearchSetObjectArrType(conf.objectArrType))
+	}
+	return funcArr
+}
+
+// 水位线存储格式: 没有主键时只存储水位线字段取值，兼容之前的格式
+func formatWatermark(watermark, watermarkKey string) string {
+	if watermarkKey == "" {
+		return watermark
+	}
+	return watermark + watermarkSeparator + watermarkKey
+}
+
+func parseWatermark(value string) (watermark, watermarkKey string) {
+	if index := strings.Index(value, watermarkSeparator); index >= 0 {
+		return value[:index], value[index+len(watermarkSeparator):]
+	}
+	return value, ""
+}
+
+// 投递数据: 优先使用回调，否则写入 channel（等待消费方接收）
+func (watcher *Watcher) deliver(ret db.SearchRet) error {
+	if nil != watcher.conf.handler {
+		if err := watcher.conf.handler(ret); nil != err {
+			watcher.logger.Warn("[Watcher.deliver] handler failed, watermark will not move: %s", err.Error())
+			return err
+		}
+		return nil
+	}
+	select {
+	case watcher.changeChan <- ret:
+		return nil
+	case <-watcher.ctx.Done():
+		return errorcode.BuildErrorWithMsg(errorcode.ServiceError, "watcher stopped before changes delivered")
+	}
+}
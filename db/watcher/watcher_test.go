@@ -0,0 +1,159 @@
+package watcher
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/smiecj/go_common/db"
+	"github.com/stretchr/testify/require"
+)
+
+// 内存表: 仅实现按照条件、排序、分页查询
+type tableConnector struct {
+	db.RDBConnector
+	rowArr []map[string]string
+}
+
+func (connector *tableConnector) Search(funcArr ...db.RDBSearchConfigFunc) (ret db.SearchRet, err error) {
+	action := db.MakeRDBSearchAction()
+	for _, currentFunc := range funcArr {
+		currentFunc(action)
+	}
+	condition := action.GetCondition()
+
+	matchArr := make([]map[string]string, 0)
+	for _, row := range connector.rowArr {
+		if condition.WhereArr.Match(row) {
+			matchArr = append(matchArr, row)
+		}
+	}
+	sort.SliceStable(matchArr, func(i, j int) bool {
+		return condition.CompareOrderValueArr(condition.GetRowOrderValueArr(matchArr[i]), condition.GetRowOrderValueArr(matchArr[j])) < 0
+	})
+
+	start := condition.Page.No * condition.Page.Limit
+	for index := start; index < len(matchArr) && index < start+condition.Page.Limit; index++ {
+		currentField := db.BuildNewField()
+		currentField.AddMap(matchArr[index])
+		ret.AddField(currentField)
+		ret.Len++
+	}
+	ret.Total = len(matchArr)
+	return ret, nil
+}
+
+func (connector *tableConnector) add(id, updateTime string) {
+	connector.rowArr = append(connector.rowArr, map[string]string{"id": id, "update_time": updateTime})
+}
+
+// 收集投递的数据 id
+func collectIdArr(idArr *[]string) func(db.SearchRet) error {
+	return func(ret db.SearchRet) error {
+		for _, currentField := range ret.FieldArr {
+			*idArr = append(*idArr, currentField.GetMap()["id"])
+		}
+		return nil
+	}
+}
+
+// 测试时间戳水位线: 分批投递、相同时间戳数据按照主键分页、持久化水位线
+func TestWatcherTimestamp(t *testing.T) {
+	connector := new(tableConnector)
+	connector.add("1", "2022-01-01 00:00:01")
+	connector.add("2", "2022-01-01 00:00:02")
+	connector.add("3", "2022-01-01 00:00:02")
+
+	checkpoint, err := db.NewFileCheckpoint(filepath.Join(t.TempDir(), "watermark"))
+	require.Empty(t, err)
+	idArr := make([]string, 0)
+	watcher, err := NewWatcher(connector, WatcherSetSpace("db", "table"),
+		WatcherSetColumn("update_time", WatermarkTimestamp), WatcherSetKeyColumn("id"),
+		WatcherSetBatch(2), WatcherSetCheckpoint(checkpoint), WatcherSetHandler(collectIdArr(&idArr)))
+	require.Empty(t, err)
+
+	require.Empty(t, watcher.Poll())
+	require.Equal(t, []string{"1", "2", "3"}, idArr)
+	require.Equal(t, "2022-01-01 00:00:02", watcher.Watermark())
+
+	// 没有变更: 不重复投递
+	require.Empty(t, watcher.Poll())
+	require.Equal(t, 3, len(idArr))
+
+	// 新增数据和已有数据时间戳相同
+	connector.add("4", "2022-01-01 00:00:02")
+	connector.add("5", "2022-01-01 00:00:03")
+	require.Empty(t, watcher.Poll())
+	require.Equal(t, []string{"1", "2", "3", "4", "5"}, idArr)
+
+	// 超过一批的数据时间戳相同: 按照主键继续查询
+	connector.add("8", "2022-01-01 00:00:04")
+	connector.add("6", "2022-01-01 00:00:04")
+	connector.add("7", "2022-01-01 00:00:04")
+	require.Empty(t, watcher.Poll())
+	require.Equal(t, []string{"1", "2", "3", "4", "5", "6", "7", "8"}, idArr)
+
+	watermark, err := checkpoint.Load()
+	require.Empty(t, err)
+	require.Equal(t, "2022-01-01 00:00:04\n8", watermark)
+
+	// 重启之后从水位线继续，不重复投递
+	restartIdArr := make([]string, 0)
+	watcher, err = NewWatcher(connector, WatcherSetSpace("db", "table"),
+		WatcherSetColumn("update_time", WatermarkTimestamp), WatcherSetKeyColumn("id"),
+		WatcherSetBatch(2), WatcherSetCheckpoint(checkpoint), WatcherSetHandler(collectIdArr(&restartIdArr)))
+	require.Empty(t, err)
+	require.Equal(t, "2022-01-01 00:00:04", watcher.Watermark())
+	connector.add("9", "2022-01-01 00:00:04")
+	require.Empty(t, watcher.Poll())
+	require.Equal(t, []string{"9"}, restartIdArr)
+
+	// 时间戳水位线必须设置主键字段
+	_, err = NewWatcher(connector, WatcherSetSpace("db", "table"), WatcherSetColumn("update_time", WatermarkTimestamp))
+	require.NotEmpty(t, err)
+}
+
+// 测试自增 id 水位线: 回调失败不更新水位线，重新投递
+func TestWatcherIdRetry(t *testing.T) {
+	connector := new(tableConnector)
+	connector.add("1", "")
+	connector.add("2", "")
+
+	handleErr := errors.New("handle failed")
+	idArr := make([]string, 0)
+	watcher, err := NewWatcher(connector, WatcherSetSpace("db", "table"), WatcherSetColumn("id", WatermarkId),
+		WatcherSetHandler(func(ret db.SearchRet) error {
+			if nil != handleErr {
+				return handleErr
+			}
+			return collectIdArr(&idArr)(ret)
+		}))
+	require.Empty(t, err)
+
+	require.Equal(t, handleErr, watcher.Poll())
+	require.Equal(t, "", watcher.Watermark())
+
+	handleErr = nil
+	require.Empty(t, watcher.Poll())
+	require.Equal(t, []string{"1", "2"}, idArr)
+	require.Equal(t, "2", watcher.Watermark())
+}
+
+// 测试 channel 投递
+func TestWatcherChannel(t *testing.T) {
+	connector := new(tableConnector)
+	connector.add("1", "")
+
+	watcher, err := NewWatcher(connector, WatcherSetSpace("db", "table"), WatcherSetColumn("id", WatermarkId))
+	require.Empty(t, err)
+	require.Empty(t, watcher.Start())
+
+	ret := <-watcher.Changes()
+	require.Equal(t, 1, ret.Len)
+	require.Equal(t, "1", ret.FieldArr[0].GetMap()["id"])
+
+	require.Empty(t, watcher.Stop())
+	for range watcher.Changes() {
+	}
+}
@@ -1,6 +1,10 @@
 package errorcode
 
 const (
+	ConfigParamInvalid     = "config_201"
+	ConfigTypeInvalid      = "config_202"
+	ConfigValidateFailed   = "config_203"
+	ConfigDecryptFailed    = "config_204"
 	SpaceNotExist          = "config_401"
 	ConfigNotExist         = "config_402"
 	ConfigMethodNotSupport = "config_501"
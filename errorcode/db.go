@@ -7,4 +7,5 @@ const (
 	DBExecTimeout   = "db_302"
 	DBCloseFailed   = "db_303"
 	DBStatFailed    = "db_304"
+	DBDataBroken    = "db_305"
 )
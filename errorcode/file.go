@@ -1,5 +1,6 @@
 package errorcode
 
 const (
-	FileNotFound = "file_101"
+	FileNotFound    = "file_101"
+	FileWriteFailed = "file_201"
 )
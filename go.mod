@@ -3,6 +3,7 @@ module github.com/smiecj/go_common
 go 1.16
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/Lofanmi/chinese-calendar-golang v0.0.0-20211214151323-ef5cb443e55e
 	github.com/aliyun/alibaba-cloud-sdk-go v1.61.1704 // indirect
 	github.com/apache/thrift v0.12.0 // indirect
@@ -1,9 +1,11 @@
 package http
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -42,13 +44,21 @@ type Client interface {
 
 // 请求体
 type Request struct {
-	ctx    context.Context
-	cancel context.CancelFunc
-	url    string
-	header map[string]string
-	param  map[string]string
-	body   string
-	method string
+	ctx     context.Context
+	cancel  context.CancelFunc
+	url     string
+	header  map[string]string
+	param   map[string]string
+	body    string
+	method  string
+	fileArr []uploadFile
+}
+
+// 上传文件
+type uploadFile struct {
+	fieldName string
+	fileName  string
+	content   []byte
 }
 
 // 获取一个新request
@@ -64,7 +74,7 @@ func buildRequest() *Request {
 
 // 构造请求结构体
 // 需要借助 method + header 共同构建
-func (req *Request) buildRequestBody() {
+func (req *Request) buildRequestBody() error {
 	switch req.method {
 	case string(methodGet):
 		// GET 方法统一不需要设置body
@@ -75,7 +85,9 @@ func (req *Request) buildRequestBody() {
 		if req.body != "" {
 			break
 		}
-		if req.header[headerContentType] == contentTypeJson {
+		if len(req.fileArr) != 0 {
+			return req.buildMultipartBody()
+		} else if req.header[headerContentType] == contentTypeJson {
 			jsonBytes, _ := json.Marshal(req.param)
 			req.body = string(jsonBytes)
 		} else if req.header[headerContentType] == contentTypeUrlEncoded {
@@ -86,6 +98,33 @@ func (req *Request) buildRequestBody() {
 			req.body = data.Encode()
 		}
 	}
+	return nil
+}
+
+// 构造 multipart/form-data 请求体: param 作为表单字段，文件作为文件字段
+func (req *Request) buildMultipartBody() error {
+	buf := new(bytes.Buffer)
+	writer := multipart.NewWriter(buf)
+	for key, value := range req.param {
+		if err := writer.WriteField(key, value); nil != err {
+			return errorcode.BuildErrorWithMsg(errorcode.NetHandleFailed, "write form field failed: "+err.Error())
+		}
+	}
+	for _, file := range req.fileArr {
+		fileWriter, err := writer.CreateFormFile(file.fieldName, file.fileName)
+		if nil != err {
+			return errorcode.BuildErrorWithMsg(errorcode.NetHandleFailed, "create form file failed: "+err.Error())
+		}
+		if _, err = fileWriter.Write(file.content); nil != err {
+			return errorcode.BuildErrorWithMsg(errorcode.NetHandleFailed, "write form file failed: "+err.Error())
+		}
+	}
+	if err := writer.Close(); nil != err {
+		return errorcode.BuildErrorWithMsg(errorcode.NetHandleFailed, "close multipart writer failed: "+err.Error())
+	}
+	req.body = buf.String()
+	req.header[headerContentType] = writer.FormDataContentType()
+	return nil
 }
 
 // 检查 request 参数是否合法
@@ -154,6 +193,14 @@ func SetBody(body interface{}) func(*Request) {
 	}
 }
 
+// 添加上传文件，请求体会以 multipart/form-data 格式发送
+// 需要和 Post 一起使用，AddParam 添加的参数作为表单字段
+func AddFile(fieldName, fileName string, content []byte) func(*Request) {
+	return func(request *Request) {
+		request.fileArr = append(request.fileArr, uploadFile{fieldName: fieldName, fileName: fileName, content: content})
+	}
+}
+
 // 配置 method get
 func Get() func(*Request) {
 	return func(request *Request) {
@@ -206,7 +253,10 @@ func (client *httpClient) Do(configFuncArr ...ConfigRequestFunc) (rsp *Response,
 	for _, currentConfigFunc := range configFuncArr {
 		currentConfigFunc(request)
 	}
-	request.buildRequestBody()
+	if err = request.buildRequestBody(); nil != err {
+		log.Error("[http.client.Do] request url: %s, build request body failed: %s", request.url, err.Error())
+		return nil, err
+	}
 	err = request.checkIsValid()
 	if nil != err {
 		return nil, err
@@ -1,9 +1,13 @@
 package http
 
 import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/smiecj/go_common/util/log"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMakeGetRequest(t *testing.T) {
@@ -17,6 +21,25 @@ func TestMakeGetRequest(t *testing.T) {
 	log.Info("[TestMakeGetRequest] request success: %s", rsp.Body)
 }
 
+// 测试上传文件
+func TestUploadFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, header, err := r.FormFile("file")
+		if nil != err {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		content, _ := ioutil.ReadAll(file)
+		w.Write([]byte(r.FormValue("type") + "|" + header.Filename + "|" + string(content)))
+	}))
+	defer server.Close()
+
+	rsp, err := DefaultHTTPClient().Do(Post(), Url(server.URL), AddParam("type", "report"),
+		AddFile("file", "report.csv", []byte("id,name\n1,xiaoming\n")))
+	require.Empty(t, err)
+	require.Equal(t, "report|report.csv|id,name\n1,xiaoming\n", rsp.Body)
+}
+
 /* func TestMakePostUrlEncodeRequest(t *testing.T) {
 	client := DefaultHTTPClient()
 	rsp, err := client.Do(PostWithUrlEncode(), Url("http://azkaban_address/userReq/doLogin"),
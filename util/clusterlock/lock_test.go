@@ -3,10 +3,15 @@ package clusterlock
 import (
 	"context"
 	"testing"
+	"time"
 
 	yamlconfig "github.com/smiecj/go_common/config/yaml"
+	"github.com/smiecj/go_common/db"
+	"github.com/smiecj/go_common/db/dbtest"
 	"github.com/smiecj/go_common/db/mysql"
 	"github.com/smiecj/go_common/util/file"
+	"github.com/smiecj/go_common/util/log"
+	timeutil "github.com/smiecj/go_common/util/time"
 	"github.com/stretchr/testify/require"
 )
 
@@ -35,3 +40,43 @@ func TestLock(t *testing.T) {
 	lockManager.Lock(testLockName, IntervalShort, putDataToChan)
 	<-dataChan
 }
+
+// 不依赖 mysql 的占锁测试: 没有锁记录时插入，锁过期时更新版本
+func TestTryLockWithMock(t *testing.T) {
+	connector := dbtest.NewMockConnector()
+	lockManager := &lockManager{
+		connector:               connector,
+		envName:                 testEnvName,
+		logger:                  log.PrefixLogger("lock"),
+		lockNameIntervalTypeMap: map[string]IntervalType{testLockName: IntervalShort},
+	}
+
+	// 没有锁记录: 插入
+	connector.Expect(dbtest.MethodCount).Space(dbMeta, tableLock).Condition("name", "=", testLockName)
+	connector.Expect(dbtest.MethodInsert).Space(dbMeta, tableLock).Match(func(call dbtest.Call) bool {
+		return len(call.ObjectArr) == 1 && call.ObjectArr[0].(lock).Env == testEnvName
+	}).ReturnAffectedRows(1)
+	require.Empty(t, lockManager.tryLock(testLockName))
+	require.Empty(t, connector.ExpectationsWereMet())
+
+	// 其他节点占用的锁已经过期: 更新版本
+	connector = dbtest.NewMockConnector()
+	lockManager.connector = connector
+	connector.Expect(dbtest.MethodCount).Space(dbMeta, tableLock).Condition("name", "=", testLockName).
+		ReturnSearchRet(db.SearchRet{Len: 1, Total: 1})
+	connector.Expect(dbtest.MethodSearch).Space(dbMeta, tableLock).Condition("name", "=", testLockName).
+		ReturnObjectArr([]lock{{Name: testLockName, Version: 1, UpdateTime: "2022-01-01 00:00:00", Env: "other_env"}})
+	connector.Expect(dbtest.MethodUpdate).Space(dbMeta, tableLock).
+		Condition("name", "=", testLockName, "and", "version", "=", "1").ReturnAffectedRows(1)
+	require.Empty(t, lockManager.tryLock(testLockName))
+	require.Empty(t, connector.ExpectationsWereMet())
+
+	// 其他节点占用的锁没有过期: 占锁失败
+	connector = dbtest.NewMockConnector()
+	lockManager.connector = connector
+	connector.Expect(dbtest.MethodCount).ReturnSearchRet(db.SearchRet{Len: 1, Total: 1})
+	connector.Expect(dbtest.MethodSearch).ReturnObjectArr([]lock{{Name: testLockName, Version: 1,
+		UpdateTime: timeutil.CurrentTimeAfterDuration(24 * time.Hour), Env: "other_env"}})
+	require.NotEmpty(t, lockManager.tryLock(testLockName))
+	require.Empty(t, connector.ExpectationsWereMet())
+}
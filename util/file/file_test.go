@@ -1,6 +1,11 @@
 package file
 
 import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"regexp"
 	"testing"
 
@@ -13,3 +18,41 @@ func TestFindFilePath(t *testing.T) {
 	isMatch, _ := regexp.MatchString("go_common", filePath)
 	require.Equal(t, true, isMatch)
 }
+
+// 原子写入文件，保持原文件权限
+func TestWriteAtomic(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.yaml")
+	require.Empty(t, ioutil.WriteFile(filePath, []byte("old"), 0600))
+	require.Empty(t, WriteAtomic(filePath, []byte("new")))
+	content, err := ioutil.ReadFile(filePath)
+	require.Empty(t, err)
+	require.Equal(t, "new", string(content))
+	fileInfo, err := os.Stat(filePath)
+	require.Empty(t, err)
+	require.Equal(t, os.FileMode(0600), fileInfo.Mode().Perm())
+	fileArr, err := ioutil.ReadDir(filepath.Dir(filePath))
+	require.Empty(t, err)
+	require.Len(t, fileArr, 1)
+
+	// 写入失败时删除临时文件，目标文件不变
+	writeErr := errors.New("write failed")
+	require.Equal(t, writeErr, WriteAtomicFunc(filePath, func(writer *bufio.Writer) error {
+		writer.WriteString("broken")
+		return writeErr
+	}))
+	content, err = ioutil.ReadFile(filePath)
+	require.Empty(t, err)
+	require.Equal(t, "new", string(content))
+	require.Empty(t, GetTempFileArr(filePath))
+}
+
+// 解析临时文件名
+func TestParseTempFileName(t *testing.T) {
+	fileName, ok := ParseTempFileName(filepath.Join("data", "db.table.v2"+TempFileMark+"123"))
+	require.True(t, ok)
+	require.Equal(t, filepath.Join("data", "db.table.v2"), fileName)
+	for _, tempFileName := range []string{"db.table" + TempFileMark, "db.table" + TempFileMark + "bak", TempFileMark + "123", "db.table.writing"} {
+		_, ok = ParseTempFileName(tempFileName)
+		require.False(t, ok, tempFileName)
+	}
+}
@@ -1,7 +1,11 @@
 package file
 
 import (
+	"bufio"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/smiecj/go_common/util/log"
 )
@@ -13,6 +17,9 @@ const (
 
 const (
 	defaultFileMode = 0644
+
+	// 原子写入时的临时文件标识，临时文件名: 目标文件名 + .writing. + 随机数
+	TempFileMark = ".writing."
 )
 
 // 覆盖/追加指定文件和内容
@@ -40,3 +47,100 @@ func Write(fileAbsolutePath string, content []byte, fileMode int) int {
 	}
 	return writeSize
 }
+
+// 原子写入文件: 先写到同目录下的临时文件，再重命名覆盖目标文件，目标文件存在时保持原文件权限
+func WriteAtomic(fileAbsolutePath string, content []byte) error {
+	return WriteAtomicFunc(fileAbsolutePath, func(writer *bufio.Writer) error {
+		_, err := writer.Write(content)
+		return err
+	})
+}
+
+// 原子写入文件，通过 writeFunc 写入文件内容
+// 先写入同目录下的临时文件并 fsync，再通过 rename 替换目标文件，最后 fsync 目录保证 rename 落盘
+// 临时文件名: 目标文件名 + .writing. + 随机数，写入失败时删除
+func WriteAtomicFunc(fileAbsolutePath string, writeFunc func(*bufio.Writer) error) (err error) {
+	fileMode := os.FileMode(defaultFileMode)
+	if fileInfo, statErr := os.Stat(fileAbsolutePath); nil == statErr {
+		fileMode = fileInfo.Mode().Perm()
+	}
+
+	folderPath := filepath.Dir(fileAbsolutePath)
+	tempFile, err := ioutil.TempFile(folderPath, filepath.Base(fileAbsolutePath)+TempFileMark+"*")
+	if nil != err {
+		return err
+	}
+	tempFilePath := tempFile.Name()
+	defer func() {
+		if nil != err {
+			tempFile.Close()
+			os.Remove(tempFilePath)
+		}
+	}()
+
+	writer := bufio.NewWriter(tempFile)
+	if err = writeFunc(writer); nil != err {
+		return err
+	}
+	if err = writer.Flush(); nil != err {
+		return err
+	}
+	if err = tempFile.Sync(); nil != err {
+		return err
+	}
+	if err = tempFile.Chmod(fileMode); nil != err {
+		return err
+	}
+	if err = tempFile.Close(); nil != err {
+		return err
+	}
+	if err = os.Rename(tempFilePath, fileAbsolutePath); nil != err {
+		return err
+	}
+
+	// 目录 fsync 失败不影响数据正确性（部分系统不支持），只打印日志
+	if folder, openErr := os.Open(folderPath); nil == openErr {
+		if syncErr := folder.Sync(); nil != syncErr {
+			log.Warn("[file.WriteAtomicFunc] sync folder failed: %s", syncErr.Error())
+		}
+		folder.Close()
+	}
+	return nil
+}
+
+// 列出目录下所有原子写入时的临时文件
+func ListTempFile(folderPath string) []string {
+	fileInfoArr, _ := ioutil.ReadDir(folderPath)
+	tempFileArr := make([]string, 0)
+	for _, fileInfo := range fileInfoArr {
+		if !fileInfo.IsDir() && strings.Contains(fileInfo.Name(), TempFileMark) {
+			tempFileArr = append(tempFileArr, filepath.Join(folderPath, fileInfo.Name()))
+		}
+	}
+	return tempFileArr
+}
+
+// 获取目标文件对应的临时文件，存在时说明有写入中或者写入中断的文件
+func GetTempFileArr(fileAbsolutePath string) []string {
+	tempFileArr := make([]string, 0)
+	for _, tempFilePath := range ListTempFile(filepath.Dir(fileAbsolutePath)) {
+		if fileName, ok := ParseTempFileName(tempFilePath); ok && fileName == filepath.Clean(fileAbsolutePath) {
+			tempFileArr = append(tempFileArr, tempFilePath)
+		}
+	}
+	return tempFileArr
+}
+
+// 从临时文件路径解析目标文件路径，临时文件名: 目标文件名 + .writing. + 随机数，格式不正确时返回 false
+func ParseTempFileName(tempFilePath string) (string, bool) {
+	folderPath, tempFileName := filepath.Split(tempFilePath)
+	index := strings.LastIndex(tempFileName, TempFileMark)
+	if index <= 0 {
+		return "", false
+	}
+	randomStr := tempFileName[index+len(TempFileMark):]
+	if randomStr == "" || strings.Trim(randomStr, "0123456789") != "" {
+		return "", false
+	}
+	return filepath.Join(folderPath, tempFileName[:index]), true
+}
@@ -138,17 +138,35 @@ func (cl *customLogger) SetLevel(level LogLevel) {
 }
 
 func (cl *customLogger) Debug(format string, args ...interface{}) {
-	cl.logger.Debugf(format, args...)
+	cl.log(log.DebugLevel, format, args...)
 }
 
 func (cl *customLogger) Info(format string, args ...interface{}) {
-	cl.logger.Infof(format, args...)
+	cl.log(log.InfoLevel, format, args...)
 }
 
 func (cl *customLogger) Warn(format string, args ...interface{}) {
-	cl.logger.Warnf(format, args...)
+	cl.log(log.WarnLevel, format, args...)
 }
 
 func (cl *customLogger) Error(format string, args ...interface{}) {
-	cl.logger.Errorf(format, args...)
+	cl.log(log.ErrorLevel, format, args...)
+}
+
+// 输出日志之前对敏感信息脱敏，日志级别未开启时不做处理
+func (cl *customLogger) log(level log.Level, format string, args ...interface{}) {
+	if !cl.logger.IsLevelEnabled(level) {
+		return
+	}
+	msg := MaskString(fmt.Sprintf(format, args...))
+	switch level {
+	case log.DebugLevel:
+		cl.logger.Debug(msg)
+	case log.InfoLevel:
+		cl.logger.Info(msg)
+	case log.WarnLevel:
+		cl.logger.Warn(msg)
+	default:
+		cl.logger.Error(msg)
+	}
 }
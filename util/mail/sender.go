@@ -2,10 +2,16 @@
 package mail
 
 import (
+	"bytes"
 	"crypto/tls"
+	"encoding/base64"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/smtp"
+	"net/textproto"
+	"path/filepath"
 	"strings"
 	"sync"
 
@@ -20,6 +26,12 @@ const (
 	// 配置中心读取邮件发送配置，默认使用的space
 	// 可能会造成多个项目误共用配置的问题，在项目使用中，尽量通过配置文件路径区分不同的项目配置
 	mailDefaultConfigSpace = "mail"
+	// 正文格式
+	textContentType = "text/plain; charset=UTF-8"
+	// 附件默认格式
+	defaultAttachmentContentType = "application/octet-stream"
+	// base64 编码每行长度
+	base64LineLength = 76
 )
 
 var (
@@ -31,10 +43,10 @@ var (
 
 // 邮件发送器配置定义
 type mailSenderInitConf struct {
-	Host          string `yaml:"host"`
-	Port          int    `yaml:"port"`
-	Token         string `yaml:"token"`
-	Sender        string `yaml:"sender"`
+	Host          string `yaml:"host" validate:"required"`
+	Port          int    `yaml:"port" default:"587" validate:"min=1,max=65535"`
+	Token         string `yaml:"token" validate:"required"`
+	Sender        string `yaml:"sender" validate:"required"`
 	Receiver      string `yaml:"receiver"`
 	SSL           bool   `yaml:"ssl"`
 	SendSeparatly bool   `yaml:"send_separatly"`
@@ -42,11 +54,19 @@ type mailSenderInitConf struct {
 
 // 具体邮件发送配置定义
 type mailSendConf struct {
-	title       string
-	receiverArr []string
-	ccArr       []string
-	content     string
-	nickName    string
+	title         string
+	receiverArr   []string
+	ccArr         []string
+	content       string
+	nickName      string
+	attachmentArr []attachment
+}
+
+// 邮件附件
+type attachment struct {
+	name        string
+	contentType string
+	content     []byte
 }
 
 // 邮件发送结构体定义
@@ -111,6 +131,81 @@ func SetNickName(nickName string) func(*mailSendConf) {
 	}
 }
 
+// 添加附件，文件类型根据文件名后缀判断
+func AddAttachment(name string, content []byte) func(*mailSendConf) {
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType == "" {
+		contentType = defaultAttachmentContentType
+	}
+	return AddAttachmentWithContentType(name, contentType, content)
+}
+
+// 添加附件，指定文件类型
+func AddAttachmentWithContentType(name, contentType string, content []byte) func(*mailSendConf) {
+	return func(conf *mailSendConf) {
+		conf.attachmentArr = append(conf.attachmentArr, attachment{name: name, contentType: contentType, content: content})
+	}
+}
+
+// 构建邮件正文: 没有附件时为纯文本，有附件时为 multipart/mixed
+func (conf *mailSendConf) buildBody() (contentType, body string, err error) {
+	if len(conf.attachmentArr) == 0 {
+		return textContentType, conf.content, nil
+	}
+
+	buf := new(bytes.Buffer)
+	writer := multipart.NewWriter(buf)
+	textHeader := make(textproto.MIMEHeader)
+	textHeader.Set("Content-Type", textContentType)
+	textHeader.Set("Content-Transfer-Encoding", "base64")
+	textWriter, err := writer.CreatePart(textHeader)
+	if nil != err {
+		return "", "", errorcode.BuildErrorWithMsg(errorcode.SendMailFailed, "create mail content part failed: "+err.Error())
+	}
+	if err = writeBase64(textWriter, []byte(conf.content)); nil != err {
+		return "", "", errorcode.BuildErrorWithMsg(errorcode.SendMailFailed, "write mail content failed: "+err.Error())
+	}
+
+	for _, currentAttachment := range conf.attachmentArr {
+		attachmentHeader := make(textproto.MIMEHeader)
+		attachmentHeader.Set("Content-Type", currentAttachment.contentType)
+		attachmentHeader.Set("Content-Transfer-Encoding", "base64")
+		attachmentHeader.Set("Content-Disposition", mime.FormatMediaType("attachment",
+			map[string]string{"filename": currentAttachment.name}))
+		attachmentWriter, err := writer.CreatePart(attachmentHeader)
+		if nil != err {
+			return "", "", errorcode.BuildErrorWithMsg(errorcode.SendMailFailed,
+				fmt.Sprintf("create attachment %s part failed: %s", currentAttachment.name, err.Error()))
+		}
+		if err = writeBase64(attachmentWriter, currentAttachment.content); nil != err {
+			return "", "", errorcode.BuildErrorWithMsg(errorcode.SendMailFailed,
+				fmt.Sprintf("write attachment %s failed: %s", currentAttachment.name, err.Error()))
+		}
+	}
+	if err = writer.Close(); nil != err {
+		return "", "", errorcode.BuildErrorWithMsg(errorcode.SendMailFailed, "close multipart writer failed: "+err.Error())
+	}
+	return "multipart/mixed; boundary=" + writer.Boundary(), buf.String(), nil
+}
+
+// base64 编码写入，每行最多 76 个字符
+func writeBase64(writer io.Writer, content []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(content)
+	for len(encoded) > base64LineLength {
+		if _, err := io.WriteString(writer, encoded[:base64LineLength]+"\r\n"); nil != err {
+			return err
+		}
+		encoded = encoded[base64LineLength:]
+	}
+	_, err := io.WriteString(writer, encoded+"\r\n")
+	return err
+}
+
+// 邮件标题: 包含非 ASCII 字符（如中文）时使用 RFC 2047 编码
+func (conf *mailSendConf) encodeTitle() string {
+	return mime.BEncoding.Encode("UTF-8", conf.title)
+}
+
 // 发送邮件接口实现
 func (impl *mailSenderSMTPImpl) Send(setterArr ...mailSendConfSetter) error {
 	conf := new(mailSendConf)
@@ -146,15 +241,21 @@ func (impl *mailSenderSMTPImpl) send(conf *mailSendConf, receiverArr []string) e
 		return impl.sendBySSL(conf, conf.receiverArr)
 	}
 	auth := smtp.PlainAuth("", impl.conf.Sender, impl.conf.Token, impl.conf.Host)
-	contentType := "Content-Type: text/plain; charset=UTF-8"
+	bodyContentType, body, err := conf.buildBody()
+	if nil != err {
+		log.Error("[mailSenderSMTPImpl.SendMail] build mail body failed: %s", err.Error())
+		return err
+	}
+	contentType := "MIME-Version: 1.0\r\nContent-Type: " + bodyContentType
 	msg := []byte("To: " + strings.Join(receiverArr, ",") + "\r\nFrom: " + conf.nickName +
-		"<" + impl.conf.Sender + ">\r\nSubject: " + conf.title + "\r\n" + contentType + "\r\n\r\n" + conf.content)
-	err := smtp.SendMail(fmt.Sprintf("%s:%d", impl.conf.Host, impl.conf.Port), auth, impl.conf.Sender, receiverArr, msg)
+		"<" + impl.conf.Sender + ">\r\nSubject: " + conf.encodeTitle() + "\r\n" + contentType + "\r\n\r\n" + body)
+	err = smtp.SendMail(fmt.Sprintf("%s:%d", impl.conf.Host, impl.conf.Port), auth, impl.conf.Sender, receiverArr, msg)
 	if err != nil {
 		log.Error("[mailSenderSMTPImpl.SendMail] send mail failed: %s", err.Error())
-		return errorcode.BuildErrorWithMsg(errorcode.SendMailFailed, err.Error())
+		return errorcode.BuildErrorWithMsg(errorcode.SendMailFailed, log.MaskString(err.Error()))
 	}
-	log.Info("[mailSenderSMTPImpl.SendMail] send mail success, sender: %s, receiver: %v", impl.conf.Sender, receiverArr)
+	log.Info("[mailSenderSMTPImpl.SendMail] send mail success, sender: %s, receiver: %v",
+		log.MaskEmail(impl.conf.Sender), log.MaskValue("", receiverArr))
 	return nil
 }
 
@@ -202,14 +303,21 @@ func (impl *mailSenderSMTPImpl) sendBySSL(conf *mailSendConf, receiverArr []stri
 		tempHeader := make(map[string]string)
 		tempHeader["From"] = impl.conf.Sender
 		tempHeader["To"] = strings.Join(receiverArr, ";")
-		tempHeader["Subject"] = conf.title
+		tempHeader["Subject"] = conf.encodeTitle()
+		bodyContentType, body, buildErr := conf.buildBody()
+		if nil != buildErr {
+			err = buildErr
+			return
+		}
+		tempHeader["MIME-Version"] = "1.0"
+		tempHeader["Content-Type"] = bodyContentType
 
 		// Setup message
 		message := ""
 		for k, v := range tempHeader {
 			message += fmt.Sprintf("%s: %s\r\n", k, v)
 		}
-		message += "\r\n" + conf.content
+		message += "\r\n" + body
 
 		_, err = writer.Write([]byte(message))
 	}
@@ -234,14 +342,19 @@ func (impl *mailSenderSMTPImpl) sendBySSL(conf *mailSendConf, receiverArr []stri
 // 构建一个 SMTP 邮件发送器
 func NewSMTPMailSender(configManager config.Manager) (Sender, error) {
 	var sender Sender
-	mailSenderLock.RLock()
 	senderInitConf := mailSenderInitConf{}
-	configManager.Unmarshal(mailDefaultConfigSpace, &senderInitConf)
+	err := config.UnmarshalAndValidate(configManager, mailDefaultConfigSpace, &senderInitConf)
 	if senderInitConf.Host == "mock" {
-		sender = &mockMailSender{}
-	} else {
-		sender = mailSenderMap[senderInitConf]
+		return &mockMailSender{}, nil
+	}
+	// host、token 和 发送者 不能为空
+	if nil != err {
+		log.Error("[NewSMTPMailSender] mail config is invalid: %s", err.Error())
+		return nil, errorcode.BuildErrorWithMsg(errorcode.SenderInitFailed, fmt.Sprintf("mail config is invalid: %s", err.Error()))
 	}
+
+	mailSenderLock.RLock()
+	sender = mailSenderMap[senderInitConf]
 	mailSenderLock.RUnlock()
 
 	if nil != sender {
@@ -252,21 +365,6 @@ func NewSMTPMailSender(configManager config.Manager) (Sender, error) {
 	defer mailSenderLock.Unlock()
 
 	impl := new(mailSenderSMTPImpl)
-	if senderInitConf.Host == "" {
-		return nil, errorcode.BuildErrorWithMsg(errorcode.SenderInitFailed, "smtp host is empty")
-	}
-	if senderInitConf.Port == 0 {
-		senderInitConf.Port = 587
-	}
-
-	// token 和 发送者 不能为空
-	if senderInitConf.Token == "" {
-		return nil, errorcode.BuildErrorWithMsg(errorcode.SenderInitFailed, "token is empty")
-	}
-	if senderInitConf.Sender == "" {
-		return nil, errorcode.BuildErrorWithMsg(errorcode.SenderInitFailed, "sender is empty")
-	}
-
 	impl.conf = senderInitConf
 	mailSenderMap[senderInitConf] = impl
 	return impl, nil
@@ -13,7 +13,7 @@ func (impl *mockMailSender) Send(setterArr ...mailSendConfSetter) error {
 		setter(conf)
 	}
 
-	log.Info("[mockMailSender] send mail: title: %s, msg: %s", conf.title, conf.content)
+	log.Info("[mockMailSender] send mail: title: %s, msg: %s, attachment count: %d", conf.title, conf.content, len(conf.attachmentArr))
 
 	return nil
 }
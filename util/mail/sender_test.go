@@ -1,9 +1,17 @@
 package mail
 
 import (
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	yamlconfig "github.com/smiecj/go_common/config/yaml"
+	"github.com/smiecj/go_common/errorcode"
 	"github.com/smiecj/go_common/util/file"
 	"github.com/stretchr/testify/require"
 )
@@ -23,3 +31,57 @@ func TestSendMail(t *testing.T) {
 	err = sender.Send(SetTitle("test_title"), SetContent("test_content"), SetNickName("smiecj"))
 	require.Equal(t, nil, err)
 }
+
+// 测试配置不合法时返回 SenderInitFailed
+func TestNewSMTPMailSenderInvalid(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "conf.yaml")
+	require.Empty(t, ioutil.WriteFile(filePath, []byte("mail:\n  host: smtp.qq.com\n"), 0644))
+	configManager, err := yamlconfig.GetYamlConfigManager(filePath)
+	require.Empty(t, err)
+
+	_, err = NewSMTPMailSender(configManager)
+	require.True(t, errors.Is(err, errorcode.BuildError(errorcode.SenderInitFailed)))
+}
+
+// 测试带附件的邮件正文构建
+func TestBuildMailBody(t *testing.T) {
+	conf := new(mailSendConf)
+	SetContent("test_content")(conf)
+	contentType, body, err := conf.buildBody()
+	require.Empty(t, err)
+	require.Equal(t, textContentType, contentType)
+	require.Equal(t, "test_content", body)
+
+	AddAttachmentWithContentType("report.csv", "text/csv", []byte("id,name\n1,xiaoming\n"))(conf)
+	contentType, body, err = conf.buildBody()
+	require.Empty(t, err)
+	mediaType, paramMap, err := mime.ParseMediaType(contentType)
+	require.Empty(t, err)
+	require.Equal(t, "multipart/mixed", mediaType)
+
+	reader := multipart.NewReader(strings.NewReader(body), paramMap["boundary"])
+	_, err = reader.NextPart()
+	require.Empty(t, err)
+	part, err := reader.NextPart()
+	require.Empty(t, err)
+	require.Equal(t, "report.csv", part.FileName())
+	require.Equal(t, "text/csv", part.Header.Get("Content-Type"))
+	encoded, _ := ioutil.ReadAll(part)
+	content, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(encoded), "\r\n", ""))
+	require.Empty(t, err)
+	require.Equal(t, "id,name\n1,xiaoming\n", string(content))
+}
+
+// 测试邮件标题编码: 中文标题使用 RFC 2047 编码，ASCII 标题保持不变
+func TestEncodeMailTitle(t *testing.T) {
+	conf := new(mailSendConf)
+	SetTitle("test_title")(conf)
+	require.Equal(t, "test_title", conf.encodeTitle())
+
+	SetTitle("测试报告")(conf)
+	encodedTitle := conf.encodeTitle()
+	require.NotEqual(t, "测试报告", encodedTitle)
+	decodedTitle, err := new(mime.WordDecoder).DecodeHeader(encodedTitle)
+	require.Empty(t, err)
+	require.Equal(t, "测试报告", decodedTitle)
+}
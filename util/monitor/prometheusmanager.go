@@ -22,7 +22,7 @@ var (
 
 // prometheus monitor manager 初始化配置
 type prometheusMonitorManagerConf struct {
-	Port int `yaml:"port"`
+	Port int `yaml:"port" validate:"required,min=1,max=65535"`
 }
 
 func (conf prometheusMonitorManagerConf) portUsed() bool {
@@ -60,7 +60,10 @@ func (manager *prometheusMonitorManager) RemoveMetrics(name string) error {
 
 func GetPrometheusMonitorManager(configManager config.Manager) Manager {
 	conf := prometheusMonitorManagerConf{}
-	configManager.Unmarshal(configSpaceMonitor, &conf)
+	if err := config.UnmarshalAndValidate(configManager, configSpaceMonitor, &conf); nil != err {
+		log.Error("[GetPrometheusMonitorManager] monitor config is invalid: %s", err.Error())
+		return nil
+	}
 	return getPrometheusMonitorManager(conf)
 }
 
@@ -35,6 +35,11 @@ func (gauge *PrometheusGauge) Set(val float64) {
 	gauge.gaugeVec.With(prometheus.Labels(gauge.label)).Set(val)
 }
 
+// 删除当前标签的指标值，返回是否存在
+func (gauge *PrometheusGauge) Delete() bool {
+	return gauge.gaugeVec.Delete(prometheus.Labels(gauge.label))
+}
+
 // 新建一个 prometheus gauge
 func newPrometheusGauge(metrics monitorMetricsDesc) *PrometheusGauge {
 	return &PrometheusGauge{
@@ -76,6 +81,11 @@ func (counter *PrometheusCounter) Inc() {
 	counter.counterVec.With(prometheus.Labels(counter.label)).Inc()
 }
 
+// counter: 删除当前标签的指标值，返回是否存在
+func (counter *PrometheusCounter) Delete() bool {
+	return counter.counterVec.Delete(prometheus.Labels(counter.label))
+}
+
 // 新建一个 prometheus counter
 func newPrometheusCounter(metrics monitorMetricsDesc) *PrometheusCounter {
 	return &PrometheusCounter{
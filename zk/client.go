@@ -127,11 +127,13 @@ func (client *zkClient) Status() string {
 // 获取zk连接
 func GetZKonnector(configManager config.Manager) (Client, error) {
 	var client Client
-	zkClientLock.RLock()
-
 	option := zkConnectOption{}
-	configManager.Unmarshal(zkConfigDefaultSpace, &option)
+	if err := config.UnmarshalAndValidate(configManager, zkConfigDefaultSpace, &option); nil != err {
+		log.Error("[GetZKonnector] zk config is invalid: %s", err.Error())
+		return nil, err
+	}
 
+	zkClientLock.RLock()
 	if option.Address == "mock" {
 		client, _ = getZKonnectorMock()
 	} else {
@@ -14,7 +14,7 @@ const (
 
 // mysql 连接配置
 type zkConnectOption struct {
-	Address string `yaml:"address"`
+	Address string `yaml:"address" validate:"required"`
 	Home    string `yaml:"home"`
 }
 